@@ -76,6 +76,7 @@ type CheckRunEvent struct {
 	Rerequested     *CheckRunRerequestedEvent     `json:"rerequested,omitempty"`
 	RequestedAction *CheckRunRequestedActionEvent `json:"requestedAction,omitempty"`
 	Completed       *CheckRunCompletedEvent       `json:"completed,omitempty"`
+	Comment         *CheckRunCommentEvent         `json:"comment,omitempty"`
 }
 
 // Github sends a created event after a github check run is created. It includes
@@ -98,6 +99,20 @@ type CheckRunRequestedActionEvent struct {
 
 type CheckRunCompletedEvent struct{}
 
+// User commented on the pull with a command for the check run's workspace.
+type CheckRunCommentEvent struct {
+	// +kubebuilder:validation:Enum={"plan","apply"}
+
+	// The command that the user requested.
+	Command string `json:"command"`
+
+	// Additional arguments to pass to terraform.
+	Args []string `json:"args,omitempty"`
+
+	// Login of the user who commented.
+	Author string `json:"author,omitempty"`
+}
+
 type CheckRunIteration struct {
 	// Etok run triggered in this iteration
 	Run string `json:"runName"`
@@ -38,6 +38,17 @@ type CheckSuiteSpec struct {
 
 	Branch string `json:"branch"`
 
+	// Branch into which the pull request is to be merged. Empty if unknown, in
+	// which case workspaces are not filtered by branch.
+	BaseBranch string `json:"baseBranch,omitempty"`
+
+	// Default branch of the repository
+	DefaultBranch string `json:"defaultBranch,omitempty"`
+
+	// Number of the pull request. Zero if unknown, in which case workspaces are
+	// not filtered by the files the pull request changes.
+	PullNumber int `json:"pullNumber,omitempty"`
+
 	SHA string `json:"sha"`
 
 	Owner string `json:"owner"`
@@ -59,4 +70,19 @@ type CheckSuiteStatus struct {
 	// Mergeable means all related PRs are mergeable. Check Runs use this to
 	// determine whether to permit an apply.
 	Mergeable bool `json:"mergeable,omitempty"`
+
+	// Number of reviewers whose latest review approves the pull request
+	Approvals int `json:"approvals,omitempty"`
+
+	// CodeOwnersApproved means each path changed by the pull request that has
+	// code owners has been approved by one of its code owners
+	CodeOwnersApproved bool `json:"codeOwnersApproved,omitempty"`
+
+	// Names of other checks on the pull request's latest commit that have
+	// failed
+	FailingChecks []string `json:"failingChecks,omitempty"`
+
+	// UpToDate means the pull request's branch contains the latest commit of
+	// its base branch
+	UpToDate bool `json:"upToDate,omitempty"`
 }
@@ -5,18 +5,47 @@ const (
 	RunCompleteCondition    = "Complete"
 	WorkspaceReadyCondition = "Ready"
 
-	PodCreatedReason        = "PodCreated"
-	PodPendingReason        = "PodPending"
-	PodUnknownReason        = "PodUnknown"
-	PodSucceededReason      = "PodSucceeded"
-	PodFailedReason         = "PodFailed"
-	PodRunningReason        = "PodRunning"
-	RunQueuedReason         = "Queued"
-	RunUnqueuedReason       = "Unqueued"
-	RunEnqueueTimeoutReason = "EnqueueTimeout"
-	QueueTimeoutReason      = "QueueTimeout"
-	RunPendingTimeoutReason = "PodPendingTimeout"
-	WorkspaceNotFoundReason = "WorkspaceNotFound"
+	// PolicyFailed is true if the run's saved plan violates the workspace's
+	// policy rule sets
+	RunPolicyFailedCondition = "PolicyFailed"
+
+	// Drifted is true if the last drift check found resources had been
+	// changed outside of terraform
+	WorkspaceDriftedCondition = "Drifted"
+
+	// DependenciesReady is true if the outputs of other workspaces that the
+	// workspace's variables reference can be resolved
+	WorkspaceDependenciesReadyCondition = "DependenciesReady"
+
+	PodCreatedReason          = "PodCreated"
+	PodPendingReason          = "PodPending"
+	PodUnknownReason          = "PodUnknown"
+	PodSucceededReason        = "PodSucceeded"
+	PodFailedReason           = "PodFailed"
+	PodRunningReason          = "PodRunning"
+	RunQueuedReason           = "Queued"
+	RunUnqueuedReason         = "Unqueued"
+	RunEnqueueTimeoutReason   = "EnqueueTimeout"
+	QueueTimeoutReason        = "QueueTimeout"
+	RunPendingTimeoutReason   = "PodPendingTimeout"
+	WorkspaceNotFoundReason   = "WorkspaceNotFound"
+	PolicyPassedReason        = "PolicyPassed"
+	PolicyViolationReason     = "PolicyViolation"
+	RuleSetInvalidReason      = "RuleSetInvalid"
+	PolicyPendingReason       = "PolicyPending"
+	PlanNotFoundReason        = "PlanNotFound"
+	DriftDetectedReason       = "DriftDetected"
+	NoDriftReason             = "NoDrift"
+	DriftCheckFailedReason    = "DriftCheckFailed"
+	RunCancelledReason        = "Cancelled"
+	MaxDurationExceededReason = "MaxDurationExceeded"
+	SpecModifiedReason        = "SpecModified"
+
+	// Reasons for the DependenciesReady condition
+	DependenciesResolvedReason   = "DependenciesResolved"
+	DependencyCycleReason        = "DependencyCycle"
+	UpstreamNotFoundReason       = "UpstreamNotFound"
+	UpstreamOutputNotFoundReason = "UpstreamOutputNotFound"
 
 	// Pending means whatever is being observed is reported to be progressing
 	// towards a non-failure state.
@@ -60,6 +60,10 @@ type RunSpec struct {
 	// The config map key identifying the tarball to extract
 	ConfigMapKey string `json:"configMapKey"`
 
+	// Git repository to clone on the pod, in place of extracting the tarball
+	// in ConfigMap
+	Repository *RunRepository `json:"repository,omitempty"`
+
 	// The workspace of the run.
 	Workspace string `json:"workspace"`
 
@@ -68,10 +72,61 @@ type RunSpec struct {
 	// Logging verbosity.
 	Verbosity int `json:"verbosity,omitempty"`
 
+	// Save the plan file produced by a plan run, permitting it to be applied
+	// by a subsequent apply run
+	SavePlan bool `json:"savePlan,omitempty"`
+
+	// Name of the plan run whose saved plan file an apply run is to apply
+	Plan string `json:"plan,omitempty"`
+
+	// Cancel the run. A run that is yet to run its command is cancelled
+	// straight away, whereas a running command is interrupted, permitting it
+	// to exit gracefully.
+	Cancel bool `json:"cancel,omitempty"`
+
+	// Maximum duration of the run's command, after which the command is
+	// interrupted and the run cancelled
+	MaxDuration *metav1.Duration `json:"maxDuration,omitempty"`
+
+	// Priority of the run in the workspace queue. Runs with a higher priority
+	// are queued ahead of those with a lower priority, whereas runs with the
+	// same priority are queued in the order in which they were created. A
+	// priority other than zero is only honoured once it has been granted on
+	// the workspace.
+	Priority int `json:"priority,omitempty"`
+
+	// Timeouts for the run, overriding those of its workspace
+	Timeouts RunTimeouts `json:"timeouts,omitempty"`
+
 	// AttachSpec defines behaviour for clients attaching to the pod's TTY
 	AttachSpec `json:",inline"`
 }
 
+// RunRepository is a git repository to be cloned on a run's pod. The clone is
+// authenticated with the credentials, if any, set in the etok secret.
+type RunRepository struct {
+	// URL of the repository
+	URL string `json:"url"`
+
+	// Branch to clone. The repository's default branch is cloned if empty.
+	Branch string `json:"branch,omitempty"`
+}
+
+// RunTimeouts are the maximum times a run can spend waiting before its command
+// is run, after which the run fails. A timeout that is not set defaults to the
+// operator's default.
+type RunTimeouts struct {
+	// Maximum time a run can remain waiting to be added to the workspace
+	// queue
+	Enqueue *metav1.Duration `json:"enqueue,omitempty"`
+
+	// Maximum time a run can remain waiting in the workspace queue
+	Queue *metav1.Duration `json:"queue,omitempty"`
+
+	// Maximum time a run's pod can remain in the pending phase
+	PodPending *metav1.Duration `json:"podPending,omitempty"`
+}
+
 // AttachSpec defines behaviour for clients attaching to the pod's TTY
 type AttachSpec struct {
 	// Enable TTY on pod and await handshake string from client
@@ -100,6 +155,35 @@ func GetRunFromApprovalAnnotationKey(key string) string {
 	return strings.Split(key, "/")[1]
 }
 
+// PriorityAnnotationKey is the key to be set on a workspace's annotations to
+// grant this run its priority. The value of the annotation is the priority
+// granted.
+func (r *Run) PriorityAnnotationKey() string {
+	return PriorityAnnotationKey(r.Name)
+}
+
+const PriorityAnnotationKeyPrefix = "priorities.etok.dev"
+
+func PriorityAnnotationKey(runName string) string {
+	return fmt.Sprintf("%s/%s", PriorityAnnotationKeyPrefix, runName)
+}
+
+// LaunchedByAnnotationKey is the key of the annotation recording who launched
+// the run. The annotation is set by the client creating the run and is not
+// verified; it is informational only and must not be relied upon for auditing
+// or access control.
+const LaunchedByAnnotationKey = "etok.dev/launched-by"
+
+// LaunchedBy returns who launched the run as reported by the client that
+// created it, or an empty string if unknown
+func (r *Run) LaunchedBy() string {
+	if launcher, ok := r.Annotations[LaunchedByAnnotationKey]; ok {
+		return launcher
+	}
+	// Runs created by a controller record it with a label
+	return r.Labels["app.kubernetes.io/created-by"]
+}
+
 // Run's pod shares its name
 func (r *Run) PodName() string { return r.Name }
 
@@ -111,6 +195,35 @@ func RunLockFileConfigMapName(name string) string {
 	return name + "-lockfile"
 }
 
+// PlanConfigMapName is the name of the config map recording the details of the
+// plan saved by this run
+func (r *Run) PlanConfigMapName() string {
+	return RunPlanConfigMapName(r.Name)
+}
+
+func RunPlanConfigMapName(name string) string {
+	return name + "-plan"
+}
+
+// UpstreamOutputsSecretName is the name of the secret holding the values of
+// sensitive outputs of upstream workspaces that the run's variables reference
+func (r *Run) UpstreamOutputsSecretName() string {
+	return r.Name + "-upstream-outputs"
+}
+
+func GetRunFromPlanConfigMapName(name string) string {
+	return strings.TrimSuffix(name, "-plan")
+}
+
+const (
+	// Keys of a saved plan's config map: the serial number of the state from
+	// which the plan was created, a summary of the plan's changes, and the
+	// changes to be made to each resource
+	PlanSerialKey  = "serial"
+	PlanSummaryKey = "summary"
+	PlanChangesKey = "changes"
+)
+
 // RunStatus defines the observed state of Run
 type RunStatus struct {
 	// Current phase of the run's lifecycle.
@@ -120,6 +233,35 @@ type RunStatus struct {
 
 	// Exit code of run pod's runner container
 	ExitCode *int `json:"exitCode,omitempty"`
+
+	// Blast radius of the run's saved plan
+	BlastRadius *BlastRadius `json:"blastRadius,omitempty"`
+
+	// True if the logs of the run have been archived to the backup provider
+	LogsArchived bool `json:"logsArchived,omitempty"`
+
+	// Digest of the run's spec, recorded when the run is first reconciled.
+	// The run fails if its spec is subsequently modified, other than to
+	// cancel it or to change its priority.
+	SpecDigest string `json:"specDigest,omitempty"`
+}
+
+// BlastRadius summarises the resources a plan changes
+type BlastRadius struct {
+	// Number of resources changed, keyed by provider
+	Providers map[string]int `json:"providers,omitempty"`
+
+	// Number of resources changed, keyed by module. Resources in the root
+	// module are keyed by "root".
+	Modules map[string]int `json:"modules,omitempty"`
+
+	// Addresses of critical resources that are to be replaced or deleted
+	Critical []string `json:"critical,omitempty"`
+
+	// Number of resources changed whose changes were omitted from the
+	// recorded plan, and which are therefore neither counted above nor
+	// checked for being critical
+	Omitted int `json:"omitted,omitempty"`
 }
 
 func (r *Run) IsReconciled() bool {
@@ -140,12 +282,25 @@ func (r *Run) IsDone() bool {
 	return false
 }
 
+// CompliesWithPolicy determines whether the run's saved plan may be applied
+// as far as the workspace's policy is concerned. A plan violating policy can
+// never be applied, and if the workspace has policy rule sets then the plan
+// must have been evaluated against them and passed.
+func (r *Run) CompliesWithPolicy(ws *Workspace) bool {
+	for _, cond := range r.Conditions {
+		if cond.Type == RunPolicyFailedCondition {
+			return cond.Status == metav1.ConditionFalse
+		}
+	}
+	return len(ws.Spec.PolicyRuleSets) == 0
+}
+
 // IsStreamable determines if a run's pod is ready to have its logs streamed
 func (r *Run) IsStreamable() bool {
 	for _, cond := range r.Conditions {
 		if cond.Type == RunCompleteCondition {
 			switch cond.Reason {
-			case PodRunningReason, PodSucceededReason, PodFailedReason:
+			case PodRunningReason, PodSucceededReason, PodFailedReason, PolicyPendingReason:
 				return true
 			}
 		}
@@ -173,6 +328,9 @@ const (
 	RunPhaseCompleted RunPhase = "completed"
 	// Failed: a fatal error occurred and the run will not be completed
 	RunPhaseFailed RunPhase = "failed"
+	// Cancelled: run was cancelled, either upon request or because it
+	// exceeded its maximum duration
+	RunPhaseCancelled RunPhase = "cancelled"
 
 	RunDefaultConfigMapKey = "config.tar.gz"
 )
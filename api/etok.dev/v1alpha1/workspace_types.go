@@ -2,6 +2,8 @@ package v1alpha1
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 
 	"github.com/leg100/etok/pkg/util/slice"
 	corev1 "k8s.io/api/core/v1"
@@ -23,6 +25,7 @@ func init() {
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 // +kubebuilder:printcolumn:name="Active",type="string",JSONPath=".status.active"
 // +kubebuilder:printcolumn:name="Queue",type="string",JSONPath=".status.queue"
+// +kubebuilder:printcolumn:name="Drifted",type="string",JSONPath=`.status.conditions[?(@.type=="Drifted")].status`
 // +genclient
 type Workspace struct {
 	metav1.TypeMeta   `json:",inline"`
@@ -75,6 +78,118 @@ type WorkspaceSpec struct {
 
 	// Details of the VCS repository we want to connect to the workspace
 	VCS VCS `json:"vcs,omitempty"`
+
+	// Names of config maps containing policy rule sets. Every plan saved for
+	// the workspace is evaluated against the rule sets, and a plan that
+	// violates a rule cannot be applied.
+	PolicyRuleSets []string `json:"policyRuleSets,omitempty"`
+
+	// Glob patterns matching the addresses of critical resources. A saved plan
+	// that replaces or deletes a critical resource has it flagged in its blast
+	// radius.
+	CriticalResources []string `json:"criticalResources,omitempty"`
+
+	// Cron schedule, in UTC, on which to check for drift, i.e. changes made to
+	// resources outside of terraform. A plan is run against the VCS repository
+	// on the schedule. Leave blank to disable drift detection.
+	DriftDetection string `json:"driftDetection,omitempty"`
+
+	// Overrides for the pods created for the workspace, i.e. the workspace pod
+	// and the pods of its runs
+	PodTemplate *PodTemplate `json:"podTemplate,omitempty"`
+
+	// Timeouts for the workspace's runs. A run can override these timeouts.
+	RunTimeouts RunTimeouts `json:"runTimeouts,omitempty"`
+
+	// +kubebuilder:validation:Enum={"","plan","apply"}
+
+	// Command to run when an output that the workspace's variables reference
+	// on another workspace changes. Leave blank to run nothing. Requires a VCS
+	// repository.
+	OnUpstreamChange string `json:"onUpstreamChange,omitempty"`
+
+	// Project outputs into secrets or config maps, for consumption by other
+	// applications
+	OutputProjections []OutputProjection `json:"outputProjections,omitempty"`
+
+	// Policy determining which of the workspace's completed runs are
+	// retained. A setting that is not set defaults to the operator's default.
+	RunRetention RunRetentionPolicy `json:"runRetention,omitempty"`
+}
+
+// RunRetentionPolicy determines which completed runs are retained. Deleting a
+// run deletes its pod and config maps too.
+type RunRetentionPolicy struct {
+	// +kubebuilder:validation:Minimum=0
+
+	// Number of the most recently completed runs to retain. Zero retains all
+	// runs.
+	KeepLast *int `json:"keepLast,omitempty"`
+
+	// Completed runs are deleted once this duration has elapsed since their
+	// completion. Zero retains runs indefinitely.
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+
+	// Retain the last successful apply regardless of the other settings.
+	KeepLastSuccessfulApply *bool `json:"keepLastSuccessfulApply,omitempty"`
+}
+
+// PodTemplate overrides the pods that etok creates. Maps and lists are merged
+// with the values etok sets, whereas other values replace those etok sets.
+type PodTemplate struct {
+	// Labels to add to pods. Labels that etok sets take precedence.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations to add to pods
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Service account for pods. Defaults to the etok service account for run
+	// pods, if it exists.
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// Node selector for pods
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Affinity for pods. A run pod retains its affinity for the workspace
+	// pod.
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// Tolerations for pods
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Secrets for pulling the container image
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// Priority class for pods
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// Security context for pods
+	SecurityContext *corev1.PodSecurityContext `json:"securityContext,omitempty"`
+
+	// Overrides for containers. An override with a name applies to the
+	// container of the same name: runner for run pods, and installer and idler
+	// for the workspace pod. An override without a name applies to all
+	// containers, before any named override.
+	Containers []ContainerTemplate `json:"containers,omitempty"`
+}
+
+// ContainerTemplate overrides a container that etok creates
+type ContainerTemplate struct {
+	// Name of the container to override. Leave blank to override all
+	// containers.
+	Name string `json:"name,omitempty"`
+
+	// Container image. Defaults to the image of the operator.
+	Image string `json:"image,omitempty"`
+
+	// Image pull policy. Defaults to IfNotPresent.
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// Compute resources required by the container
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Security context for the container
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
 }
 
 // Details of the VCS repository we want to connect to the workspace
@@ -90,6 +205,33 @@ type VCS struct {
 
 	// Sub-directory within VCS repository to connect to the workspace
 	WorkingDir string `json:"workingDir,omitempty"`
+
+	// Requirements a pull request must meet before its plans for the
+	// workspace can be applied. Takes precedence over any requirements set
+	// for the repository.
+	ApplyRequirements *ApplyRequirements `json:"applyRequirements,omitempty"`
+}
+
+// ApplyRequirements are the requirements a pull request must meet, in addition
+// to being mergeable, before a plan can be applied from the pull request.
+type ApplyRequirements struct {
+	// +kubebuilder:validation:Minimum=0
+
+	// Minimum number of reviewers whose latest review approves the pull
+	// request.
+	Approvals int `json:"approvals,omitempty"`
+
+	// Require each changed path that has code owners to be approved by one of
+	// its code owners.
+	CodeOwnerApproval bool `json:"codeOwnerApproval,omitempty"`
+
+	// Require that no other check on the pull request's latest commit has
+	// failed.
+	PassingChecks bool `json:"passingChecks,omitempty"`
+
+	// Require the pull request's branch to be up to date with its base
+	// branch.
+	UpToDate bool `json:"upToDate,omitempty"`
 }
 
 // WorkspaceSpec defines the desired state of Workspace's cache storage
@@ -126,6 +268,31 @@ type WorkspaceStatus struct {
 	// has not been backed up.
 	BackupSerial *int `json:"backupSerial,omitempty"`
 
+	// Backups of the state file available to restore, ordered by serial
+	// number, oldest first.
+	Backups []*Backup `json:"backups,omitempty"`
+
+	// Outcome of the last backup requested with the backup-requested
+	// annotation. Nil means no such request has been handled.
+	LastBackupRequest *BackupRequest `json:"lastBackupRequest,omitempty"`
+
+	// Outcome of the last restore requested with the restore-serial
+	// annotation. Nil means no such request has been handled.
+	LastRestoreRequest *RestoreRequest `json:"lastRestoreRequest,omitempty"`
+
+	// Time at which the last drift check was started
+	LastDriftCheck *metav1.Time `json:"lastDriftCheck,omitempty"`
+
+	// Name of the run checking for drift. Empty if no check is in progress.
+	DriftRun string `json:"driftRun,omitempty"`
+
+	// Workspaces upon whose outputs the workspace's variables depend
+	Dependencies []string `json:"dependencies,omitempty"`
+
+	// Digest of the values of the outputs upon which the workspace's
+	// variables depend, as last observed
+	UpstreamOutputsDigest string `json:"upstreamOutputsDigest,omitempty"`
+
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
@@ -136,18 +303,87 @@ type Variable struct {
 	// Variable value
 	Value string `json:"value"`
 	// Source for the variable's value. Cannot be used if value is not empty.
-	ValueFrom *corev1.EnvVarSource `json:"valueFrom,omitempty"`
+	ValueFrom *VariableSource `json:"valueFrom,omitempty"`
 	// EnvironmentVariable denotes if this variable should be created as
 	// environment variable
 	EnvironmentVariable bool `json:"environmentVariable,omitempty"`
 }
 
+// VariableSource is a source for a variable's value
+type VariableSource struct {
+	corev1.EnvVarSource `json:",inline"`
+
+	// Selects an output of another workspace in the same namespace
+	WorkspaceOutputRef *WorkspaceOutputSelector `json:"workspaceOutputRef,omitempty"`
+}
+
+// WorkspaceOutputSelector selects an output of a workspace
+type WorkspaceOutputSelector struct {
+	// Name of the workspace
+	Workspace string `json:"workspace"`
+
+	// Name of the output
+	Output string `json:"output"`
+}
+
+// Backup is a backed up copy of the state file
+type Backup struct {
+	// Serial number of the state file
+	Serial int `json:"serial"`
+	// Time at which the backup was made
+	Created metav1.Time `json:"created"`
+}
+
+// BackupRequest is the outcome of a request to backup the state file
+type BackupRequest struct {
+	// Value of the backup-requested annotation with which the backup was
+	// requested
+	ID string `json:"id"`
+	// Error backing up the state file. Empty if the backup succeeded.
+	Error string `json:"error,omitempty"`
+}
+
+// RestoreRequest is the outcome of a request to restore a backup of the state
+// file
+type RestoreRequest struct {
+	// Value of the restore-request-id annotation with which the restore was
+	// requested
+	ID string `json:"id"`
+	// Serial number of the backup requested to be restored
+	Serial int `json:"serial"`
+	// Error restoring the backup. Empty if the restore succeeded.
+	Error string `json:"error,omitempty"`
+}
+
 // Output outputs the values of Terraform output
 type Output struct {
 	// Attribute name in module
 	Key string `json:"key"`
-	// Value
+	// Value. Values other than strings are encoded as JSON. Empty if the
+	// output is sensitive.
 	Value string `json:"value"`
+	// Terraform type of the output, e.g. string, number, or ["list","string"]
+	Type string `json:"type,omitempty"`
+	// Sensitive denotes if the output is marked sensitive, in which case its
+	// value is redacted
+	Sensitive bool `json:"sensitive,omitempty"`
+}
+
+// OutputProjection projects outputs into a secret or a config map in the
+// workspace's namespace, which is kept in sync with the outputs in the state
+// file
+type OutputProjection struct {
+	// +kubebuilder:validation:Enum={"Secret","ConfigMap"}
+
+	// Kind of resource: Secret or ConfigMap
+	Kind string `json:"kind"`
+
+	// Name of the resource. The resource is created if it does not exist.
+	Name string `json:"name"`
+
+	// Names of the outputs to project. All outputs are projected if empty.
+	// Sensitive outputs are only projected into a secret.
+	Outputs []string `json:"outputs,omitempty"`
 }
 
 // IsReconciled indicates whether resource has reconciled. It does this by
@@ -204,6 +440,54 @@ func (ws *Workspace) IsRunApproved(run *Run) bool {
 	return false
 }
 
+// RunPriority returns the priority of the run in the workspace queue. The
+// run's priority is only honoured if the workspace grants it the same
+// priority, otherwise it is given the default priority of zero.
+func (ws *Workspace) RunPriority(run *Run) int {
+	if granted, ok := ws.Annotations[run.PriorityAnnotationKey()]; ok {
+		if granted == strconv.Itoa(run.Priority) {
+			return run.Priority
+		}
+	}
+	return 0
+}
+
+// Upstreams returns the names of the workspaces upon whose outputs the
+// workspace's variables depend, sorted and without duplicates
+func (ws *Workspace) Upstreams() (upstreams []string) {
+	for _, v := range ws.Spec.Variables {
+		if v.ValueFrom == nil || v.ValueFrom.WorkspaceOutputRef == nil {
+			continue
+		}
+		if !slice.ContainsString(upstreams, v.ValueFrom.WorkspaceOutputRef.Workspace) {
+			upstreams = append(upstreams, v.ValueFrom.WorkspaceOutputRef.Workspace)
+		}
+	}
+	sort.Strings(upstreams)
+	return
+}
+
+// BackupRequestedAnnotationKey is the key to be set on a workspace's
+// annotations to request that the operator immediately backup the workspace's
+// state, regardless of whether the current serial number has already been
+// backed up. The value should be unique to the request: the operator records it
+// along with the outcome of the request in the workspace status, and removes
+// the annotation once the request has been handled.
+const BackupRequestedAnnotationKey = "backups.etok.dev/backup-requested"
+
+// RestoreSerialAnnotationKey is the key to be set on a workspace's annotations
+// to request that the operator restore the backup of the workspace's state with
+// the serial number given in the value. The operator removes the annotation
+// once the request has been handled.
+const RestoreSerialAnnotationKey = "backups.etok.dev/restore-serial"
+
+// RestoreRequestIDAnnotationKey is the key to be set on a workspace's
+// annotations along with RestoreSerialAnnotationKey, to identify the restore
+// request. The value should be unique to the request: the operator records it
+// along with the outcome of the request in the workspace status, and removes
+// the annotation once the request has been handled.
+const RestoreRequestIDAnnotationKey = "backups.etok.dev/restore-request-id"
+
 func WorkspacePodName(name string) string {
 	return "workspace-" + name
 }
@@ -24,6 +24,21 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApplyRequirements) DeepCopyInto(out *ApplyRequirements) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplyRequirements.
+func (in *ApplyRequirements) DeepCopy() *ApplyRequirements {
+	if in == nil {
+		return nil
+	}
+	out := new(ApplyRequirements)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AttachSpec) DeepCopyInto(out *AttachSpec) {
 	*out = *in
@@ -39,6 +54,71 @@ func (in *AttachSpec) DeepCopy() *AttachSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Backup) DeepCopyInto(out *Backup) {
+	*out = *in
+	in.Created.DeepCopyInto(&out.Created)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Backup.
+func (in *Backup) DeepCopy() *Backup {
+	if in == nil {
+		return nil
+	}
+	out := new(Backup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupRequest) DeepCopyInto(out *BackupRequest) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupRequest.
+func (in *BackupRequest) DeepCopy() *BackupRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BlastRadius) DeepCopyInto(out *BlastRadius) {
+	*out = *in
+	if in.Providers != nil {
+		in, out := &in.Providers, &out.Providers
+		*out = make(map[string]int, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Modules != nil {
+		in, out := &in.Modules, &out.Modules
+		*out = make(map[string]int, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Critical != nil {
+		in, out := &in.Critical, &out.Critical
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlastRadius.
+func (in *BlastRadius) DeepCopy() *BlastRadius {
+	if in == nil {
+		return nil
+	}
+	out := new(BlastRadius)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CheckRun) DeepCopyInto(out *CheckRun) {
 	*out = *in
@@ -66,6 +146,26 @@ func (in *CheckRun) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CheckRunCommentEvent) DeepCopyInto(out *CheckRunCommentEvent) {
+	*out = *in
+	if in.Args != nil {
+		in, out := &in.Args, &out.Args
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CheckRunCommentEvent.
+func (in *CheckRunCommentEvent) DeepCopy() *CheckRunCommentEvent {
+	if in == nil {
+		return nil
+	}
+	out := new(CheckRunCommentEvent)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CheckRunCompletedEvent) DeepCopyInto(out *CheckRunCompletedEvent) {
 	*out = *in
@@ -120,6 +220,11 @@ func (in *CheckRunEvent) DeepCopyInto(out *CheckRunEvent) {
 		*out = new(CheckRunCompletedEvent)
 		**out = **in
 	}
+	if in.Comment != nil {
+		in, out := &in.Comment, &out.Comment
+		*out = new(CheckRunCommentEvent)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CheckRunEvent.
@@ -280,7 +385,7 @@ func (in *CheckSuite) DeepCopyInto(out *CheckSuite) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CheckSuite.
@@ -366,6 +471,11 @@ func (in *CheckSuiteSpec) DeepCopy() *CheckSuiteSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CheckSuiteStatus) DeepCopyInto(out *CheckSuiteStatus) {
 	*out = *in
+	if in.FailingChecks != nil {
+		in, out := &in.FailingChecks, &out.FailingChecks
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CheckSuiteStatus.
@@ -378,6 +488,31 @@ func (in *CheckSuiteStatus) DeepCopy() *CheckSuiteStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContainerTemplate) DeepCopyInto(out *ContainerTemplate) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(corev1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerTemplate.
+func (in *ContainerTemplate) DeepCopy() *ContainerTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(ContainerTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Output) DeepCopyInto(out *Output) {
 	*out = *in
@@ -393,6 +528,106 @@ func (in *Output) DeepCopy() *Output {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OutputProjection) DeepCopyInto(out *OutputProjection) {
+	*out = *in
+	if in.Outputs != nil {
+		in, out := &in.Outputs, &out.Outputs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OutputProjection.
+func (in *OutputProjection) DeepCopy() *OutputProjection {
+	if in == nil {
+		return nil
+	}
+	out := new(OutputProjection)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodTemplate) DeepCopyInto(out *PodTemplate) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(corev1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]corev1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(corev1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Containers != nil {
+		in, out := &in.Containers, &out.Containers
+		*out = make([]ContainerTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodTemplate.
+func (in *PodTemplate) DeepCopy() *PodTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(PodTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RestoreRequest) DeepCopyInto(out *RestoreRequest) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RestoreRequest.
+func (in *RestoreRequest) DeepCopy() *RestoreRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(RestoreRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Run) DeepCopyInto(out *Run) {
 	*out = *in
@@ -452,6 +687,51 @@ func (in *RunList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunRepository) DeepCopyInto(out *RunRepository) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunRepository.
+func (in *RunRepository) DeepCopy() *RunRepository {
+	if in == nil {
+		return nil
+	}
+	out := new(RunRepository)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunRetentionPolicy) DeepCopyInto(out *RunRetentionPolicy) {
+	*out = *in
+	if in.KeepLast != nil {
+		in, out := &in.KeepLast, &out.KeepLast
+		*out = new(int)
+		**out = **in
+	}
+	if in.TTL != nil {
+		in, out := &in.TTL, &out.TTL
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.KeepLastSuccessfulApply != nil {
+		in, out := &in.KeepLastSuccessfulApply, &out.KeepLastSuccessfulApply
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunRetentionPolicy.
+func (in *RunRetentionPolicy) DeepCopy() *RunRetentionPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(RunRetentionPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RunSpec) DeepCopyInto(out *RunSpec) {
 	*out = *in
@@ -460,6 +740,17 @@ func (in *RunSpec) DeepCopyInto(out *RunSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Repository != nil {
+		in, out := &in.Repository, &out.Repository
+		*out = new(RunRepository)
+		**out = **in
+	}
+	if in.MaxDuration != nil {
+		in, out := &in.MaxDuration, &out.MaxDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	in.Timeouts.DeepCopyInto(&out.Timeouts)
 	out.AttachSpec = in.AttachSpec
 }
 
@@ -488,6 +779,11 @@ func (in *RunStatus) DeepCopyInto(out *RunStatus) {
 		*out = new(int)
 		**out = **in
 	}
+	if in.BlastRadius != nil {
+		in, out := &in.BlastRadius, &out.BlastRadius
+		*out = new(BlastRadius)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunStatus.
@@ -500,9 +796,44 @@ func (in *RunStatus) DeepCopy() *RunStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunTimeouts) DeepCopyInto(out *RunTimeouts) {
+	*out = *in
+	if in.Enqueue != nil {
+		in, out := &in.Enqueue, &out.Enqueue
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Queue != nil {
+		in, out := &in.Queue, &out.Queue
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.PodPending != nil {
+		in, out := &in.PodPending, &out.PodPending
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunTimeouts.
+func (in *RunTimeouts) DeepCopy() *RunTimeouts {
+	if in == nil {
+		return nil
+	}
+	out := new(RunTimeouts)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VCS) DeepCopyInto(out *VCS) {
 	*out = *in
+	if in.ApplyRequirements != nil {
+		in, out := &in.ApplyRequirements, &out.ApplyRequirements
+		*out = new(ApplyRequirements)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VCS.
@@ -520,7 +851,7 @@ func (in *Variable) DeepCopyInto(out *Variable) {
 	*out = *in
 	if in.ValueFrom != nil {
 		in, out := &in.ValueFrom, &out.ValueFrom
-		*out = new(corev1.EnvVarSource)
+		*out = new(VariableSource)
 		(*in).DeepCopyInto(*out)
 	}
 }
@@ -535,6 +866,27 @@ func (in *Variable) DeepCopy() *Variable {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VariableSource) DeepCopyInto(out *VariableSource) {
+	*out = *in
+	in.EnvVarSource.DeepCopyInto(&out.EnvVarSource)
+	if in.WorkspaceOutputRef != nil {
+		in, out := &in.WorkspaceOutputRef, &out.WorkspaceOutputRef
+		*out = new(WorkspaceOutputSelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VariableSource.
+func (in *VariableSource) DeepCopy() *VariableSource {
+	if in == nil {
+		return nil
+	}
+	out := new(VariableSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Workspace) DeepCopyInto(out *Workspace) {
 	*out = *in
@@ -614,6 +966,21 @@ func (in *WorkspaceList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkspaceOutputSelector) DeepCopyInto(out *WorkspaceOutputSelector) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkspaceOutputSelector.
+func (in *WorkspaceOutputSelector) DeepCopy() *WorkspaceOutputSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkspaceOutputSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WorkspaceSpec) DeepCopyInto(out *WorkspaceSpec) {
 	*out = *in
@@ -634,7 +1001,31 @@ func (in *WorkspaceSpec) DeepCopyInto(out *WorkspaceSpec) {
 			}
 		}
 	}
-	out.VCS = in.VCS
+	in.VCS.DeepCopyInto(&out.VCS)
+	if in.PolicyRuleSets != nil {
+		in, out := &in.PolicyRuleSets, &out.PolicyRuleSets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CriticalResources != nil {
+		in, out := &in.CriticalResources, &out.CriticalResources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PodTemplate != nil {
+		in, out := &in.PodTemplate, &out.PodTemplate
+		*out = new(PodTemplate)
+		(*in).DeepCopyInto(*out)
+	}
+	in.RunTimeouts.DeepCopyInto(&out.RunTimeouts)
+	if in.OutputProjections != nil {
+		in, out := &in.OutputProjections, &out.OutputProjections
+		*out = make([]OutputProjection, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.RunRetention.DeepCopyInto(&out.RunRetention)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkspaceSpec.
@@ -676,6 +1067,36 @@ func (in *WorkspaceStatus) DeepCopyInto(out *WorkspaceStatus) {
 		*out = new(int)
 		**out = **in
 	}
+	if in.Backups != nil {
+		in, out := &in.Backups, &out.Backups
+		*out = make([]*Backup, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(Backup)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+	if in.LastBackupRequest != nil {
+		in, out := &in.LastBackupRequest, &out.LastBackupRequest
+		*out = new(BackupRequest)
+		**out = **in
+	}
+	if in.LastRestoreRequest != nil {
+		in, out := &in.LastRestoreRequest, &out.LastRestoreRequest
+		*out = new(RestoreRequest)
+		**out = **in
+	}
+	if in.LastDriftCheck != nil {
+		in, out := &in.LastDriftCheck, &out.LastDriftCheck
+		*out = (*in).DeepCopy()
+	}
+	if in.Dependencies != nil {
+		in, out := &in.Dependencies, &out.Dependencies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]v1.Condition, len(*in))
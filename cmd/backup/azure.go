@@ -0,0 +1,58 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/leg100/etok/pkg/backup"
+	"github.com/spf13/pflag"
+)
+
+func init() {
+	addProvider("azure", newAzureFlags)
+}
+
+// azureStorageKeyEnvVar is the environment variable from which the storage
+// account access key is read
+const azureStorageKeyEnvVar = "AZURE_STORAGE_KEY"
+
+type azureFlags struct {
+	account   string
+	container string
+}
+
+func newAzureFlags() flags {
+	return &azureFlags{}
+}
+
+func (f *azureFlags) addToFlagSet(fs *pflag.FlagSet) {
+	fs.StringVar(&f.account, "azure-storage-account", "", "Specify azure storage account for terraform state backups")
+	fs.StringVar(&f.container, "azure-container", "", "Specify azure blob storage container for terraform state backups")
+}
+
+func (f *azureFlags) createProvider(ctx context.Context) (backup.Provider, error) {
+	cred, err := azblob.NewSharedKeyCredential(f.account, os.Getenv(azureStorageKeyEnvVar))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read azure storage key from %s: %w", azureStorageKeyEnvVar, err)
+	}
+
+	serviceURL, err := url.Parse(fmt.Sprintf("https://%s.blob.core.windows.net/", f.account))
+	if err != nil {
+		return nil, err
+	}
+
+	return backup.NewAzureProvider(ctx, f.container, serviceURL, cred)
+}
+
+func (f *azureFlags) validate() error {
+	if f.account == "" {
+		return fmt.Errorf("%w: missing azure storage account name", ErrInvalidConfig)
+	}
+	if f.container == "" {
+		return fmt.Errorf("%w: missing azure container name", ErrInvalidConfig)
+	}
+	return nil
+}
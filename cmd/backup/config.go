@@ -2,8 +2,10 @@ package backup
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/leg100/etok/pkg/backup"
@@ -18,6 +20,12 @@ type flags interface {
 	validate() error
 }
 
+// volumeFlags is implemented by the flags of providers that write backups to
+// a volume mounted into the operator pod
+type volumeFlags interface {
+	volume() (*corev1.Volume, *corev1.VolumeMount)
+}
+
 // flagMaker is a constructor for a flags obj
 type flagMaker func() flags
 
@@ -41,6 +49,10 @@ var (
 	ErrInvalidProvider = fmt.Errorf("%w: invalid provider", ErrInvalidConfig)
 )
 
+// encryptionKeyEnvVar is the environment variable from which the backup
+// encryption key is read
+const encryptionKeyEnvVar = "BACKUP_ENCRYPTION_KEY"
+
 func addProvider(name string, f flagMaker) {
 	mappings = append(mappings, providerMap{name: name, maker: f})
 }
@@ -54,6 +66,15 @@ type Config struct {
 
 	// Name of Selected provider
 	Selected string
+
+	// Retention policy for backups
+	retention backup.RetentionPolicy
+
+	// Toggle encryption of backups
+	encrypt bool
+
+	// Toggle archiving of run logs
+	runLogs bool
 }
 
 func NewConfig(optionalMappings ...providerMap) *Config {
@@ -74,6 +95,10 @@ func NewConfig(optionalMappings ...providerMap) *Config {
 	}
 
 	cfg.flagSet.StringVar(&cfg.Selected, "backup-provider", "", fmt.Sprintf("Enable backups specifying a provider (%v)", strings.Join(cfg.providers, ",")))
+	cfg.flagSet.IntVar(&cfg.retention.KeepLast, "backup-keep-last", 0, "Keep only the last N backups of each workspace's state (0 keeps all)")
+	cfg.flagSet.DurationVar(&cfg.retention.KeepFor, "backup-keep-for", 0, "Keep only backups of each workspace's state made within this duration (0 keeps all)")
+	cfg.flagSet.BoolVar(&cfg.runLogs, "backup-run-logs", false, "Archive the logs of completed runs alongside backups")
+	cfg.flagSet.BoolVar(&cfg.encrypt, "backup-encrypt", false, fmt.Sprintf("Encrypt backups with the base64-encoded 32 byte key in the %s environment variable", encryptionKeyEnvVar))
 
 	return cfg
 }
@@ -106,7 +131,56 @@ func (c *Config) CreateSelectedProvider(ctx context.Context) (backup.Provider, e
 	if !ok {
 		return nil, nil
 	}
-	return flags.createProvider(ctx)
+	provider, err := flags.createProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Always wrap provider, even if encryption is disabled, to prevent
+	// restoring encrypted backups without decrypting them
+	var wrapper backup.KeyWrapper
+	if c.encrypt {
+		wrapper, err = newKeyWrapperFromEnv()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return backup.NewEncryptingProvider(provider, wrapper), nil
+}
+
+// newKeyWrapperFromEnv constructs a key wrapper using the key found in the
+// environment
+func newKeyWrapperFromEnv() (backup.KeyWrapper, error) {
+	encoded, ok := os.LookupEnv(encryptionKeyEnvVar)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s not set", backup.ErrInvalidEncryptionKey, encryptionKeyEnvVar)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", backup.ErrInvalidEncryptionKey, err.Error())
+	}
+	return backup.NewLocalKeyWrapper(key)
+}
+
+// Volume returns the volume, and its mount, that the selected provider
+// requires be mounted into the operator pod. Nil is returned if no volume is
+// required.
+func (c *Config) Volume() (*corev1.Volume, *corev1.VolumeMount) {
+	flags, ok := c.providerToFlags[c.Selected].(volumeFlags)
+	if !ok {
+		return nil, nil
+	}
+	return flags.volume()
+}
+
+// RetentionPolicy returns the user-specified policy for retaining backups
+func (c *Config) RetentionPolicy() backup.RetentionPolicy {
+	return c.retention
+}
+
+// RunLogs determines whether the logs of completed runs are to be archived
+func (c *Config) RunLogs() bool {
+	return c.runLogs
 }
 
 // Validate all user-specified flags
@@ -114,6 +188,12 @@ func (c *Config) Validate(fs *pflag.FlagSet) error {
 	if c.Selected == "" {
 		return nil
 	}
+	if c.retention.KeepLast < 0 {
+		return fmt.Errorf("%w: backup-keep-last cannot be negative", ErrInvalidConfig)
+	}
+	if c.retention.KeepFor < 0 {
+		return fmt.Errorf("%w: backup-keep-for cannot be negative", ErrInvalidConfig)
+	}
 	flags, ok := c.providerToFlags[c.Selected]
 	if !ok {
 		return fmt.Errorf("%w: %s (valid providers: %s)", ErrInvalidProvider, c.Selected, strings.Join(c.providers, ","))
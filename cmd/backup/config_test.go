@@ -1,10 +1,14 @@
 package backup
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/leg100/etok/pkg/backup"
 	"github.com/leg100/etok/pkg/testutil"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
@@ -52,6 +56,56 @@ func TestConfig(t *testing.T) {
 				require.NotNil(t, provider)
 			},
 		},
+		{
+			name: "retention policy",
+			args: []string{"--backup-provider=fake", "--fake-bucket=backups-bucket", "--fake-region=eu-west2", "--backup-keep-last=10", "--backup-keep-for=720h"},
+			assertions: func(t *testutil.T, cmd *cobra.Command, cfg *Config) {
+				assert.Contains(t, cfg.GetEnvVars(cmd.Flags()), corev1.EnvVar{
+					Name:  "ETOK_BACKUP_KEEP_LAST",
+					Value: "10",
+				})
+				assert.Contains(t, cfg.GetEnvVars(cmd.Flags()), corev1.EnvVar{
+					Name:  "ETOK_BACKUP_KEEP_FOR",
+					Value: "720h0m0s",
+				})
+				assert.Equal(t, backup.RetentionPolicy{KeepLast: 10, KeepFor: 720 * time.Hour}, cfg.RetentionPolicy())
+			},
+		},
+		{
+			name: "run logs",
+			args: []string{"--backup-provider=fake", "--fake-bucket=backups-bucket", "--fake-region=eu-west2", "--backup-run-logs"},
+			assertions: func(t *testutil.T, cmd *cobra.Command, cfg *Config) {
+				assert.Contains(t, cfg.GetEnvVars(cmd.Flags()), corev1.EnvVar{
+					Name:  "ETOK_BACKUP_RUN_LOGS",
+					Value: "true",
+				})
+				assert.True(t, cfg.RunLogs())
+			},
+		},
+		{
+			name: "encryption",
+			args: []string{"--backup-provider=fake", "--fake-bucket=backups-bucket", "--fake-region=eu-west2", "--backup-encrypt"},
+			assertions: func(t *testutil.T, cmd *cobra.Command, cfg *Config) {
+				t.SetEnvs(map[string]string{"BACKUP_ENCRYPTION_KEY": base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0x01}, 32))})
+
+				provider, err := cfg.CreateSelectedProvider(context.Background())
+				require.NoError(t, err)
+				require.NotNil(t, provider)
+			},
+		},
+		{
+			name: "encryption without key",
+			args: []string{"--backup-provider=fake", "--fake-bucket=backups-bucket", "--fake-region=eu-west2", "--backup-encrypt"},
+			assertions: func(t *testutil.T, cmd *cobra.Command, cfg *Config) {
+				_, err := cfg.CreateSelectedProvider(context.Background())
+				assert.True(t, errors.Is(err, backup.ErrInvalidEncryptionKey))
+			},
+		},
+		{
+			name: "negative retention policy",
+			args: []string{"--backup-provider=fake", "--fake-bucket=backups-bucket", "--fake-region=eu-west2", "--backup-keep-last=-1"},
+			err:  ErrInvalidConfig,
+		},
 		{
 			name: "invalid config",
 			args: []string{"--backup-provider=fake", "--fake-bucket=backups-bucket"},
@@ -0,0 +1,74 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/leg100/etok/pkg/backup"
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func init() {
+	addProvider("fs", newFSFlags)
+}
+
+// fsVolumeName is the name of the volume mounted into the operator pod to
+// which backups are written
+const fsVolumeName = "backups"
+
+type fsFlags struct {
+	path     string
+	pvc      string
+	hostPath string
+}
+
+func newFSFlags() flags {
+	return &fsFlags{}
+}
+
+func (f *fsFlags) addToFlagSet(fs *pflag.FlagSet) {
+	fs.StringVar(&f.path, "fs-path", "/backups", "Specify directory to which terraform state backups are written")
+	fs.StringVar(&f.pvc, "fs-pvc", "", "Specify persistent volume claim to mount at the backup directory")
+	fs.StringVar(&f.hostPath, "fs-host-path", "", "Specify host path to mount at the backup directory")
+}
+
+func (f *fsFlags) createProvider(ctx context.Context) (backup.Provider, error) {
+	return backup.NewFSProvider(f.path)
+}
+
+func (f *fsFlags) validate() error {
+	if f.path == "" {
+		return fmt.Errorf("%w: missing fs path", ErrInvalidConfig)
+	}
+	if !filepath.IsAbs(f.path) {
+		return fmt.Errorf("%w: fs path must be absolute", ErrInvalidConfig)
+	}
+	if f.pvc != "" && f.hostPath != "" {
+		return fmt.Errorf("%w: cannot specify both fs pvc and fs host path", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// volume returns the volume to mount at the backup directory. Nil is returned
+// if neither a PVC nor a host path has been specified, in which case the
+// directory is expected to already exist.
+func (f *fsFlags) volume() (*corev1.Volume, *corev1.VolumeMount) {
+	vol := corev1.Volume{Name: fsVolumeName}
+	switch {
+	case f.pvc != "":
+		vol.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{
+			ClaimName: f.pvc,
+		}
+	case f.hostPath != "":
+		hostPathType := corev1.HostPathDirectoryOrCreate
+		vol.HostPath = &corev1.HostPathVolumeSource{
+			Path: f.hostPath,
+			Type: &hostPathType,
+		}
+	default:
+		return nil, nil
+	}
+	return &vol, &corev1.VolumeMount{Name: fsVolumeName, MountPath: f.path}
+}
@@ -0,0 +1,107 @@
+package cancel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/leg100/etok/cmd/flags"
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/leg100/etok/pkg/client"
+	"github.com/leg100/etok/pkg/env"
+	"github.com/spf13/cobra"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// default namespace if .terraform/environment is not found
+	defaultNamespace = "default"
+)
+
+var (
+	errRunNotFound = errors.New("run not found")
+	errRunDone     = errors.New("run has already finished")
+)
+
+type cancelOptions struct {
+	*cmdutil.Factory
+
+	*client.Client
+
+	path        string
+	namespace   string
+	kubeContext string
+
+	run string
+}
+
+func CancelCmd(f *cmdutil.Factory) (*cobra.Command, *cancelOptions) {
+	o := &cancelOptions{
+		Factory:   f,
+		namespace: defaultNamespace,
+	}
+	cmd := &cobra.Command{
+		Use:   "cancel <run>",
+		Short: "Cancel a run",
+		Long:  "Cancel a run. A run that is waiting in the workspace queue is removed from the queue, whereas a run that is running has its command interrupted, permitting terraform to exit gracefully and release its state lock.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			o.run = args[0]
+
+			// Override default namespace with value from env file, unless
+			// flag is set
+			etokenv, err := env.Read(o.path)
+			if err != nil {
+				if !os.IsNotExist(err) {
+					return err
+				}
+			} else if !flags.IsFlagPassed(cmd.Flags(), "namespace") {
+				o.namespace = etokenv.Namespace
+			}
+
+			o.Client, err = f.Create(o.kubeContext)
+			if err != nil {
+				return err
+			}
+
+			return o.cancel(cmd.Context())
+		},
+	}
+
+	flags.AddPathFlag(cmd, &o.path)
+	flags.AddNamespaceFlag(cmd, &o.namespace)
+	flags.AddKubeContextFlag(cmd, &o.kubeContext)
+
+	return cmd, o
+}
+
+func (o *cancelOptions) cancel(ctx context.Context) error {
+	run, err := o.RunsClient(o.namespace).Get(ctx, o.run, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return fmt.Errorf("%w: %s/%s", errRunNotFound, o.namespace, o.run)
+	} else if err != nil {
+		return err
+	}
+
+	if run.IsDone() {
+		return fmt.Errorf("%w: %s/%s", errRunDone, o.namespace, o.run)
+	}
+
+	if run.Cancel {
+		fmt.Fprintf(o.Out, "Run %s is already being cancelled\n", o.run)
+		return nil
+	}
+
+	// The run controller cancels a run that is yet to run its command,
+	// whereas the runner interrupts a running command
+	run.Cancel = true
+	if _, err := o.RunsClient(o.namespace).Update(ctx, run, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("unable to cancel run: %w", err)
+	}
+
+	fmt.Fprintf(o.Out, "Cancelling run %s\n", o.run)
+
+	return nil
+}
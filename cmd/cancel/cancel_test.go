@@ -0,0 +1,91 @@
+package cancel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/leg100/etok/pkg/env"
+	"github.com/leg100/etok/pkg/testobj"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestCancel(t *testing.T) {
+	tests := []struct {
+		name       string
+		objs       []runtime.Object
+		args       []string
+		env        *env.Env
+		out        string
+		err        error
+		assertions func(*testutil.T, *cancelOptions)
+	}{
+		{
+			name: "cancel run",
+			objs: []runtime.Object{testobj.Run("default", "run-12345", "apply")},
+			args: []string{"run-12345"},
+			out:  "Cancelling run run-12345\n",
+			assertions: func(t *testutil.T, o *cancelOptions) {
+				run, err := o.RunsClient("default").Get(context.Background(), "run-12345", metav1.GetOptions{})
+				require.NoError(t, err)
+				assert.True(t, run.Cancel)
+			},
+		},
+		{
+			name: "namespace from environment file",
+			objs: []runtime.Object{testobj.Run("dev", "run-12345", "apply")},
+			args: []string{"run-12345"},
+			env:  &env.Env{Namespace: "dev", Workspace: "networking"},
+			out:  "Cancelling run run-12345\n",
+		},
+		{
+			name: "run already being cancelled",
+			objs: []runtime.Object{testobj.Run("default", "run-12345", "apply", testobj.WithCancel())},
+			args: []string{"run-12345"},
+			out:  "Run run-12345 is already being cancelled\n",
+		},
+		{
+			name: "run not found",
+			args: []string{"run-12345"},
+			err:  errRunNotFound,
+		},
+		{
+			name: "run already finished",
+			objs: []runtime.Object{testobj.Run("default", "run-12345", "apply", testobj.WithCondition(v1alpha1.RunCompleteCondition))},
+			args: []string{"run-12345"},
+			err:  errRunDone,
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			path := t.NewTempDir().Chdir().Root()
+
+			// Write .terraform/environment
+			if tt.env != nil {
+				require.NoError(t, tt.env.Write(path))
+			}
+
+			out := new(bytes.Buffer)
+			cmd, o := CancelCmd(cmdutil.NewFakeFactory(out, tt.objs...))
+			cmd.SetArgs(tt.args)
+
+			err := cmd.ExecuteContext(context.Background())
+			if !assert.True(t, errors.Is(err, tt.err)) {
+				t.Errorf("unexpected error: %s", err)
+			}
+
+			assert.Equal(t, tt.out, out.String())
+
+			if tt.assertions != nil {
+				tt.assertions(t, o)
+			}
+		})
+	}
+}
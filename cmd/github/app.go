@@ -6,7 +6,9 @@ import (
 	"strings"
 	"time"
 
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -15,6 +17,7 @@ import (
 	"github.com/google/go-github/v31/github"
 	"github.com/leg100/etok/api/etok.dev/v1alpha1"
 	"github.com/leg100/etok/pkg/builders"
+	"github.com/leg100/etok/pkg/vcs"
 	"k8s.io/klog/v2"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -26,11 +29,20 @@ type app struct {
 	// getter permits the webhook server to retrieve github clients for
 	// different installations
 	getter clientGetter
+
+	// ID of the github app. Check runs created by other apps are only of
+	// interest for the checks they report on pulls.
+	appID int64
+
+	// Namespace of the app, in which apply requirements for repos are set
+	namespace string
 }
 
-func newApp(client runtimeclient.Client) *app {
+func newApp(client runtimeclient.Client, appID int64, namespace string) *app {
 	return &app{
-		Client: client,
+		Client:    client,
+		appID:     appID,
+		namespace: namespace,
 	}
 }
 
@@ -43,13 +55,16 @@ func (a *app) handleEvent(ev event, clients githubClients) (result string, id in
 		result, err = a.handleCheckSuiteEvent(ev, ev.GetAction())
 	case *github.CheckRunEvent:
 		id = ev.GetCheckRun().GetID()
-		result, err = a.handleCheckRunEvent(ev, ev.GetAction())
+		result, err = a.handleCheckRunEvent(ev, ev.GetAction(), clients)
 	case *github.PullRequestEvent:
 		id = ev.GetPullRequest().GetID()
 		result, err = a.handlePullRequestEvent(ev, ev.GetAction(), clients)
 	case *github.PullRequestReviewEvent:
 		id = ev.GetPullRequest().GetID()
 		result, err = a.handlePullRequestReviewEvent(ev, ev.GetAction(), clients)
+	case *github.IssueCommentEvent:
+		id = ev.GetComment().GetID()
+		result, err = a.handleIssueCommentEvent(ev, ev.GetAction(), clients)
 	default:
 		result = "ignored"
 	}
@@ -102,7 +117,11 @@ func (a *app) handleCheckSuiteEvent(ev *github.CheckSuiteEvent, action string) (
 // +kubebuilder:rbac:groups=etok.dev,resources=checkruns,verbs=get;update
 
 // Handle incoming check run events, updating k8s resources accordingly
-func (a *app) handleCheckRunEvent(ev *github.CheckRunEvent, action string) (string, error) {
+func (a *app) handleCheckRunEvent(ev *github.CheckRunEvent, action string, gclients githubClients) (string, error) {
+	if ev.GetCheckRun().GetApp().GetID() != a.appID {
+		return a.handleOtherCheckRunEvent(ev, action, gclients)
+	}
+
 	// Extract namespace/name of Check from the external ID field
 	parts := strings.Split(ev.CheckRun.GetExternalID(), "/")
 	if len(parts) != 2 {
@@ -111,9 +130,11 @@ func (a *app) handleCheckRunEvent(ev *github.CheckRunEvent, action string) (stri
 
 	// Update CheckRun resource with new event
 
+	ctx := context.Background()
+
 	check := &v1alpha1.CheckRun{}
 	checkKey := types.NamespacedName{Namespace: parts[0], Name: parts[1]}
-	if err := a.Client.Get(context.Background(), checkKey, check); err != nil {
+	if err := a.Client.Get(ctx, checkKey, check); err != nil {
 		return "", fmt.Errorf("unable to retrieve check run kubernetes resource: %w", err)
 	}
 
@@ -124,7 +145,20 @@ func (a *app) handleCheckRunEvent(ev *github.CheckRunEvent, action string) (stri
 	case "rerequested":
 		checkEvent.Rerequested = &v1alpha1.CheckRunRerequestedEvent{}
 	case "requested_action":
-		checkEvent.RequestedAction = &v1alpha1.CheckRunRequestedActionEvent{Action: ev.GetRequestedAction().Identifier}
+		identifier := ev.GetRequestedAction().Identifier
+		if identifier == string(applyCmd) {
+			// The apply button is only offered when the plan can be applied,
+			// but the button may be stale, or the request forged, so subject
+			// it to the same checks as an apply comment.
+			reason, err := a.refuseRequestedApply(ctx, gclients, check)
+			if err != nil {
+				return "", err
+			}
+			if reason != "" {
+				return fmt.Sprintf("refused apply for check run resource: %s: %s", klog.KObj(check), reason), nil
+			}
+		}
+		checkEvent.RequestedAction = &v1alpha1.CheckRunRequestedActionEvent{Action: identifier}
 	case "completed":
 		checkEvent.Completed = &v1alpha1.CheckRunCompletedEvent{}
 	default:
@@ -134,7 +168,7 @@ func (a *app) handleCheckRunEvent(ev *github.CheckRunEvent, action string) (stri
 	check.Status.Events = append(check.Status.Events, checkEvent)
 
 	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
-		return a.Client.Status().Update(context.Background(), check)
+		return a.Client.Status().Update(ctx, check)
 	})
 	if err != nil {
 		return "", fmt.Errorf("unable to add event to check run kubernetes resource: %w", err)
@@ -143,9 +177,68 @@ func (a *app) handleCheckRunEvent(ev *github.CheckRunEvent, action string) (stri
 	return fmt.Sprintf("added %s event to check run resource: %s", action, klog.KObj(check)), nil
 }
 
-// Handle incoming pull request events. On every event action ensure there is a
-// CheckSuite k8s resource, and update its mergeable status.
+// +kubebuilder:rbac:groups=etok.dev,resources=checksuites,verbs=get
+//
+// Determine whether a check run cannot apply its plan upon the user requesting
+// it via an action, returning the reason why not
+func (a *app) refuseRequestedApply(ctx context.Context, gclients githubClients, check *v1alpha1.CheckRun) (string, error) {
+	suite := &v1alpha1.CheckSuite{}
+	if err := a.Client.Get(ctx, runtimeclient.ObjectKey{Name: check.Spec.CheckSuiteRef.Name}, suite); err != nil {
+		return "", fmt.Errorf("unable to retrieve check suite kubernetes resource: %w", err)
+	}
+	// Without a pull there is no status to refresh
+	if suite.Spec.PullNumber != 0 {
+		if err := a.refreshRequirementsStatus(ctx, gclients, suite, suite.Spec.Owner, suite.Spec.Repo, suite.Spec.PullNumber); err != nil {
+			return "", err
+		}
+	}
+	return a.refuseCommand(ctx, check, suite, &vcs.Command{Name: string(applyCmd)})
+}
+
+// Handle incoming events for check runs created by other apps. Upon one
+// completing, update the status of its pulls, which records their failing
+// checks.
+func (a *app) handleOtherCheckRunEvent(ev *github.CheckRunEvent, action string, gclients githubClients) (string, error) {
+	if action != "completed" {
+		return "ignored", nil
+	}
+
+	if len(ev.GetCheckRun().PullRequests) == 0 {
+		return "no pulls found", nil
+	}
+
+	var results []string
+	for _, pull := range ev.GetCheckRun().PullRequests {
+		result, err := a.updateCheckSuiteStatus(
+			gclients,
+			ev.GetRepo().GetOwner().GetLogin(),
+			ev.GetRepo().GetName(),
+			pull.GetHead().GetRef(),
+			ev.GetRepo().GetCloneURL(),
+			ev.GetInstallation().GetID(),
+			pull.GetNumber(),
+		)
+		if err != nil {
+			return "", err
+		}
+		results = append(results, result)
+	}
+	return strings.Join(results, "; "), nil
+}
+
+// Handle incoming pull request events. On every event action, other than a
+// closed action, ensure there is a CheckSuite k8s resource, and update its
+// pull status. Upon the pull being closed, delete its CheckSuite k8s
+// resources.
 func (a *app) handlePullRequestEvent(ev *github.PullRequestEvent, action string, gclients githubClients) (string, error) {
+	if action == "closed" {
+		return a.deleteCheckSuites(
+			ev.GetRepo().GetOwner().GetLogin(),
+			ev.GetRepo().GetName(),
+			ev.GetPullRequest().GetHead().GetRef(),
+		)
+	}
+
 	return a.updateCheckSuiteStatus(
 		gclients,
 		ev.GetRepo().GetOwner().GetLogin(),
@@ -158,7 +251,7 @@ func (a *app) handlePullRequestEvent(ev *github.PullRequestEvent, action string,
 }
 
 // Handle incoming pull request review events. On every event action ensure
-// there is a CheckSuite k8s resource, and update its mergeable status.
+// there is a CheckSuite k8s resource, and update its pull status.
 func (a *app) handlePullRequestReviewEvent(ev *github.PullRequestReviewEvent, action string, gclients githubClients) (string, error) {
 	return a.updateCheckSuiteStatus(
 		gclients,
@@ -171,6 +264,258 @@ func (a *app) handlePullRequestReviewEvent(ev *github.PullRequestReviewEvent, ac
 	)
 }
 
+// Handle incoming issue comment events. A comment on a pull containing an etok
+// command is authorised against the commenter's permission on the repo, and
+// then added as an event to the check runs of the workspaces it targets,
+// starting a new iteration of each. The comment is acknowledged with a
+// reaction and a reply summarising the outcome.
+func (a *app) handleIssueCommentEvent(ev *github.IssueCommentEvent, action string, gclients githubClients) (string, error) {
+	if action != "created" || !ev.GetIssue().IsPullRequest() {
+		return "ignored", nil
+	}
+
+	cmd, err := vcs.ParseComment(ev.GetComment().GetBody())
+	if err == nil && cmd == nil {
+		return "ignored", nil
+	}
+
+	ctx := context.Background()
+	owner := ev.GetRepo().GetOwner().GetLogin()
+	repo := ev.GetRepo().GetName()
+	reply := &commentReply{
+		issues:    gclients.issues,
+		reactions: gclients.reactions,
+		owner:     owner,
+		repo:      repo,
+		number:    ev.GetIssue().GetNumber(),
+		commentID: ev.GetComment().GetID(),
+	}
+
+	if err != nil {
+		return reply.send(ctx, reactionInvalid, fmt.Sprintf("Unable to parse command: %s.", err))
+	}
+
+	author := ev.GetComment().GetUser().GetLogin()
+	authorised, err := isAuthorised(ctx, gclients.repos, owner, repo, author)
+	if err != nil {
+		return "", fmt.Errorf("unable to retrieve permission of commenter: %w", err)
+	}
+	if !authorised {
+		return reply.send(ctx, reactionDenied, fmt.Sprintf("@%s does not have permission to run `%s`: write access to the repository is required.", author, cmd))
+	}
+
+	suite, checkRuns, created, err := a.targetedCheckRuns(ctx, gclients, owner, repo, ev.GetIssue().GetNumber(), cmd)
+	if err != nil {
+		return "", err
+	}
+	if len(checkRuns) == 0 && len(created) == 0 {
+		return reply.send(ctx, reactionInvalid, fmt.Sprintf("`%s` does not match any workspaces connected to this pull.", cmd))
+	}
+
+	if checkRunCommand(cmd.Name) == applyCmd {
+		if err := a.refreshRequirementsStatus(ctx, gclients, suite, owner, repo, ev.GetIssue().GetNumber()); err != nil {
+			return "", err
+		}
+	}
+
+	// Refuse the command if any of its check runs cannot run it. A check run
+	// created on demand is planning the workspace, which is all it can do for
+	// now.
+	var refusals []string
+	for _, cr := range append(checkRuns, created...) {
+		if createdOnDemand(cr, created) && cmd.Name == vcs.PlanCommand && len(cmd.Args) == 0 {
+			continue
+		}
+		reason, err := a.refuseCommand(ctx, cr, suite, cmd)
+		if err != nil {
+			return "", err
+		}
+		if reason != "" {
+			refusals = append(refusals, fmt.Sprintf("* `%s/%s`: %s", cr.Namespace, cr.Spec.Workspace, reason))
+		}
+	}
+	if len(refusals) > 0 {
+		return reply.send(ctx, reactionInvalid, fmt.Sprintf("Unable to run `%s`:\n\n%s", cmd, strings.Join(refusals, "\n")))
+	}
+
+	var workspaces []string
+	for _, cr := range checkRuns {
+		checkEvent := &v1alpha1.CheckRunEvent{
+			Received: metav1.Now(),
+			Comment: &v1alpha1.CheckRunCommentEvent{
+				Command: cmd.Name,
+				Args:    cmd.Args,
+				Author:  author,
+			},
+		}
+		err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+			if err := a.Client.Get(ctx, runtimeclient.ObjectKeyFromObject(cr), cr); err != nil {
+				return err
+			}
+			cr.Status.Events = append(cr.Status.Events, checkEvent)
+			return a.Client.Status().Update(ctx, cr)
+		})
+		if err != nil {
+			return "", fmt.Errorf("unable to add event to check run kubernetes resource: %w", err)
+		}
+		workspaces = append(workspaces, fmt.Sprintf("`%s/%s`", cr.Namespace, cr.Spec.Workspace))
+	}
+	for _, cr := range created {
+		workspaces = append(workspaces, fmt.Sprintf("`%s/%s`", cr.Namespace, cr.Spec.Workspace))
+	}
+
+	return reply.send(ctx, reactionAccepted, fmt.Sprintf("Running `%s` for workspaces: %s.", cmd, strings.Join(workspaces, ", ")))
+}
+
+// +kubebuilder:rbac:groups=etok.dev,resources=checksuites,verbs=get
+// +kubebuilder:rbac:groups=etok.dev,resources=checkruns,verbs=list;create
+// +kubebuilder:rbac:groups=etok.dev,resources=workspaces,verbs=list
+//
+// Retrieve the check suite resource for a pull, along with the check runs of
+// its current re-request that are targeted by a comment command. A connected
+// workspace explicitly targeted by the command, i.e. with -w, but not
+// triggered by the pull's changes has no check run: one is created for it,
+// which starts by planning the workspace, and is returned separately.
+func (a *app) targetedCheckRuns(ctx context.Context, gclients githubClients, owner, repo string, number int, cmd *vcs.Command) (suite *v1alpha1.CheckSuite, targeted, created []*v1alpha1.CheckRun, err error) {
+	pull, _, err := gclients.pulls.Get(ctx, owner, repo, number)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("unable to retrieve pull: %w", err)
+	}
+
+	obj, err := getSuiteFromRef(ctx, gclients.checks, owner, repo, pull.GetHead().GetRef())
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("unable to find check suite for pull: %w", err)
+	}
+
+	suite = builders.CheckSuite(obj.GetID()).Build()
+	if err := a.Client.Get(ctx, runtimeclient.ObjectKeyFromObject(suite), suite); err != nil {
+		if errors.IsNotFound(err) {
+			return suite, nil, nil, nil
+		}
+		return nil, nil, nil, fmt.Errorf("unable to retrieve check suite kubernetes resource: %w", err)
+	}
+
+	checkRuns := &v1alpha1.CheckRunList{}
+	if err := a.Client.List(ctx, checkRuns); err != nil {
+		return nil, nil, nil, fmt.Errorf("unable to list check run kubernetes resources: %w", err)
+	}
+
+	// Workspaces with a check run, keyed by namespace/name
+	checked := make(map[string]bool)
+	for i, cr := range checkRuns.Items {
+		if cr.Spec.CheckSuiteRef.Name != suite.Name || cr.Spec.CheckSuiteRef.RerequestNumber != suite.Spec.Rerequests {
+			continue
+		}
+		checked[cr.Namespace+"/"+cr.Spec.Workspace] = true
+		if cmd.Targets(cr.Namespace, cr.Spec.Workspace) {
+			targeted = append(targeted, &checkRuns.Items[i])
+		}
+	}
+
+	// Only an explicitly targeted workspace is planned on demand, and only
+	// once the suite's repo has been cloned, from which its run is created
+	if cmd.Workspace == "" || suite.Status.RepoPath == "" {
+		return suite, targeted, nil, nil
+	}
+
+	workspaces := &v1alpha1.WorkspaceList{}
+	if err := a.Client.List(ctx, workspaces); err != nil {
+		return nil, nil, nil, fmt.Errorf("unable to list workspace kubernetes resources: %w", err)
+	}
+	for i, ws := range workspaces.Items {
+		if checked[ws.Namespace+"/"+ws.Name] || !cmd.Targets(ws.Namespace, ws.Name) || !isConnected(&ws, suite) {
+			continue
+		}
+		cr, err := newCheckRun(suite, &workspaces.Items[i])
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if err := a.Client.Create(ctx, cr); err != nil {
+			return nil, nil, nil, fmt.Errorf("unable to create check run kubernetes resource: %w", err)
+		}
+		created = append(created, cr)
+	}
+
+	return suite, targeted, created, nil
+}
+
+// createdOnDemand determines whether a check run is one of those created
+func createdOnDemand(cr *v1alpha1.CheckRun, created []*v1alpha1.CheckRun) bool {
+	for _, c := range created {
+		if c == cr {
+			return true
+		}
+	}
+	return false
+}
+
+// +kubebuilder:rbac:groups=etok.dev,resources=runs,verbs=get
+// +kubebuilder:rbac:groups=etok.dev,resources=workspaces,verbs=get
+//
+// Determine whether a check run cannot run a command, requested either via a
+// comment or an action, returning the reason why not. A check run must first finish its current command, and can
+// only apply a plan that succeeded without violating policy, and only if the
+// pull is mergeable and meets the workspace's apply requirements.
+func (a *app) refuseCommand(ctx context.Context, obj *v1alpha1.CheckRun, suite *v1alpha1.CheckSuite, cmd *vcs.Command) (string, error) {
+	cr := &checkRun{obj}
+	if !cr.isCompleted() {
+		return fmt.Sprintf("%s is in progress", cr.command()), nil
+	}
+
+	if checkRunCommand(cmd.Name) != applyCmd {
+		return "", nil
+	}
+
+	if cr.command() != planCmd {
+		return "there is no plan to apply", nil
+	}
+	if !suite.Status.Mergeable {
+		return "pull is not mergeable", nil
+	}
+
+	run := &v1alpha1.Run{}
+	if err := a.Client.Get(ctx, runtimeclient.ObjectKey{Namespace: cr.Namespace, Name: cr.etokRunName()}, run); err != nil {
+		if errors.IsNotFound(err) {
+			return "there is no plan to apply", nil
+		}
+		return "", fmt.Errorf("unable to retrieve run kubernetes resource: %w", err)
+	}
+	if complete := meta.FindStatusCondition(run.Conditions, v1alpha1.RunCompleteCondition); complete == nil || complete.Reason != v1alpha1.PodSucceededReason {
+		return "plan failed", nil
+	}
+
+	ws := &v1alpha1.Workspace{}
+	if err := a.Client.Get(ctx, runtimeclient.ObjectKey{Namespace: cr.Namespace, Name: cr.Spec.Workspace}, ws); err != nil {
+		return "", fmt.Errorf("unable to retrieve workspace kubernetes resource: %w", err)
+	}
+	if !run.CompliesWithPolicy(ws) {
+		return "plan has not passed policy", nil
+	}
+	reqs, err := getApplyRequirements(ctx, a.Client, a.namespace, ws, suite)
+	if err != nil {
+		return "", err
+	}
+	if unmet := unmetApplyRequirements(reqs, suite.Status); len(unmet) > 0 {
+		return fmt.Sprintf("apply requirements not met: %s", strings.Join(unmet, "; ")), nil
+	}
+
+	return "", nil
+}
+
+// Commenters must have write access to a repo to run comment commands
+func isAuthorised(ctx context.Context, client reposClient, owner, repo, user string) (bool, error) {
+	level, _, err := client.GetPermissionLevel(ctx, owner, repo, user)
+	if err != nil {
+		return false, err
+	}
+	switch level.GetPermission() {
+	case "admin", "write":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
 func (a *app) updateCheckSuiteStatus(gclients githubClients, owner, repo, ref, cloneURL string, installID int64, pullNumber int) (string, error) {
 	ctx := context.Background()
 
@@ -190,19 +535,119 @@ func (a *app) updateCheckSuiteStatus(gclients githubClients, owner, repo, ref, c
 		results = append(results, fmt.Sprintf("created check suite kubernetes resource: %s", klog.KObj(resource)))
 	}
 
-	updated, err := a.updateMergeableStatus(ctx, gclients.pulls, resource, owner, repo, pullNumber)
+	updated, err := a.updatePullStatus(ctx, gclients, resource, owner, repo, pullNumber)
 	if err != nil {
 		return "", err
 	}
 	if updated {
-		results = append(results, fmt.Sprintf("mergeable status updated: %v", resource.Status.Mergeable))
+		results = append(results, fmt.Sprintf("pull status updated: mergeable: %v", resource.Status.Mergeable))
 	} else {
-		results = append(results, fmt.Sprintf("mergeable status unchanged: %v", resource.Status.Mergeable))
+		results = append(results, fmt.Sprintf("pull status unchanged: mergeable: %v", resource.Status.Mergeable))
 	}
 
 	return strings.Join(results, ", "), nil
 }
 
+// Delete the CheckSuite k8s resources for a pull's branch. Their CheckRun k8s
+// resources are garbage collected along with them, but only the completed plan
+// runs of the check runs are deleted: see releaseRuns.
+//
+// +kubebuilder:rbac:groups=etok.dev,resources=checksuites,verbs=list;delete
+// +kubebuilder:rbac:groups=etok.dev,resources=checkruns,verbs=list
+func (a *app) deleteCheckSuites(owner, repo, branch string) (string, error) {
+	ctx := context.Background()
+
+	suites := &v1alpha1.CheckSuiteList{}
+	if err := a.Client.List(ctx, suites); err != nil {
+		return "", fmt.Errorf("unable to list check suite kubernetes resources: %w", err)
+	}
+
+	checkRuns := &v1alpha1.CheckRunList{}
+	if err := a.Client.List(ctx, checkRuns); err != nil {
+		return "", fmt.Errorf("unable to list check run kubernetes resources: %w", err)
+	}
+
+	var deleted []string
+	for i, suite := range suites.Items {
+		if suite.Spec.Owner != owner || suite.Spec.Repo != repo || suite.Spec.Branch != branch {
+			continue
+		}
+		for j := range checkRuns.Items {
+			if checkRuns.Items[j].Spec.CheckSuiteRef.Name != suite.Name {
+				continue
+			}
+			if err := a.releaseRuns(ctx, &checkRuns.Items[j]); err != nil {
+				return "", err
+			}
+		}
+		if err := a.Client.Delete(ctx, &suites.Items[i], runtimeclient.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+			return "", fmt.Errorf("unable to delete check suite kubernetes resource: %w", err)
+		}
+		deleted = append(deleted, klog.KObj(&suite).String())
+	}
+
+	if len(deleted) == 0 {
+		return "no check suite kubernetes resources to delete", nil
+	}
+	return fmt.Sprintf("deleted check suite kubernetes resources: %s", strings.Join(deleted, ", ")), nil
+}
+
+// +kubebuilder:rbac:groups=etok.dev,resources=runs,verbs=list;update;delete
+//
+// Prepare the etok runs of a check run for the check run's deletion. Completed
+// plan runs are deleted. Other runs are released from the check run's
+// ownership so that they are not garbage collected along with it, both to
+// permit an apply in progress to complete and to keep a record of what was
+// applied; they are deleted by the run retention policy instead.
+func (a *app) releaseRuns(ctx context.Context, cr *v1alpha1.CheckRun) error {
+	runs := &v1alpha1.RunList{}
+	if err := a.Client.List(ctx, runs, runtimeclient.InNamespace(cr.Namespace), runtimeclient.MatchingLabels(checkrunControllerLabels)); err != nil {
+		return fmt.Errorf("unable to list run kubernetes resources: %w", err)
+	}
+
+	for i := range runs.Items {
+		run := &runs.Items[i]
+		if !isOwnedBy(run, cr) {
+			continue
+		}
+
+		if run.SavePlan && run.IsDone() {
+			if err := a.Client.Delete(ctx, run, runtimeclient.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+				return fmt.Errorf("unable to delete run kubernetes resource: %w", err)
+			}
+			continue
+		}
+
+		err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+			if err := a.Client.Get(ctx, runtimeclient.ObjectKeyFromObject(run), run); err != nil {
+				return err
+			}
+			var refs []metav1.OwnerReference
+			for _, ref := range run.OwnerReferences {
+				if ref.UID != cr.UID {
+					refs = append(refs, ref)
+				}
+			}
+			run.OwnerReferences = refs
+			return a.Client.Update(ctx, run)
+		})
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("unable to release run kubernetes resource from check run: %w", err)
+		}
+	}
+	return nil
+}
+
+// isOwnedBy determines whether obj has owner amongst its owners
+func isOwnedBy(obj, owner metav1.Object) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == owner.GetUID() {
+			return true
+		}
+	}
+	return false
+}
+
 func getSuiteFromRef(ctx context.Context, client checksClient, owner, repo, ref string) (*github.CheckSuite, error) {
 	suites, _, err := client.ListCheckSuitesForRef(ctx, owner, repo, ref, nil)
 	if err != nil {
@@ -238,26 +683,55 @@ func (a *app) ensureCheckSuiteResourceExists(ctx context.Context, obj *github.Ch
 // +kubebuilder:rbac:groups=etok.dev,resources=checksuites,verbs=get
 // +kubebuilder:rbac:groups=etok.dev,resources=checksuites/status,verbs=update
 //
-// Update mergeable status if it has changed
-func (a *app) updateMergeableStatus(ctx context.Context, pullsClient pullsClient, resource *v1alpha1.CheckSuite, owner, repo string, pullNumber int) (bool, error) {
-	mergeable, err := isMergeable(pullsClient, owner, repo, pullNumber)
+// Update the status of the pull if it has changed: whether it is mergeable,
+// and its status against the requirements for applying plans
+func (a *app) updatePullStatus(ctx context.Context, gclients githubClients, resource *v1alpha1.CheckSuite, owner, repo string, pullNumber int) (bool, error) {
+	mergeable, err := isMergeable(gclients.pulls, owner, repo, pullNumber)
 	if err != nil {
 		return false, fmt.Errorf("unable to check mergeable status of pull: %w", err)
 	}
-	if resource.Status.Mergeable == mergeable {
+
+	status := resource.Status.DeepCopy()
+	status.Mergeable = mergeable
+	if err := getRequirementsStatus(ctx, gclients, owner, repo, pullNumber, resource.Spec.ID, status); err != nil {
+		return false, fmt.Errorf("unable to check apply requirements status of pull: %w", err)
+	}
+	return a.setPullStatus(ctx, resource, status)
+}
+
+// Refresh the status of the pull against the requirements for applying plans.
+// Not every change to that status is received as an event, e.g. a push to the
+// base branch leaves the pull out of date, so it is refreshed before
+// evaluating apply requirements.
+func (a *app) refreshRequirementsStatus(ctx context.Context, gclients githubClients, resource *v1alpha1.CheckSuite, owner, repo string, pullNumber int) error {
+	status := resource.Status.DeepCopy()
+	if err := getRequirementsStatus(ctx, gclients, owner, repo, pullNumber, resource.Spec.ID, status); err != nil {
+		return fmt.Errorf("unable to check apply requirements status of pull: %w", err)
+	}
+	_, err := a.setPullStatus(ctx, resource, status)
+	return err
+}
+
+// Propagate the pull status to the check suite resource if it has changed,
+// returning whether it has
+func (a *app) setPullStatus(ctx context.Context, resource *v1alpha1.CheckSuite, status *v1alpha1.CheckSuiteStatus) (bool, error) {
+	if equality.Semantic.DeepEqual(&resource.Status, status) {
 		return false, nil
 	}
 
-	// Propagate new mergeable status to kubernetes resource
-	err = retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
 		if err := a.Client.Get(context.Background(), runtimeclient.ObjectKeyFromObject(resource), resource); err != nil {
 			return err
 		}
-		resource.Status.Mergeable = mergeable
+		resource.Status.Mergeable = status.Mergeable
+		resource.Status.Approvals = status.Approvals
+		resource.Status.CodeOwnersApproved = status.CodeOwnersApproved
+		resource.Status.FailingChecks = status.FailingChecks
+		resource.Status.UpToDate = status.UpToDate
 		return a.Client.Status().Update(context.Background(), resource)
 	})
 	if err != nil {
-		return false, fmt.Errorf("unable to update mergeable status of check suite kubernetes resource: %w", err)
+		return false, fmt.Errorf("unable to update pull status of check suite kubernetes resource: %w", err)
 	}
 	return true, nil
 }
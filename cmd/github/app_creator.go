@@ -251,6 +251,7 @@ func manifestJson(appName, webhookUrl, redirectUrl, setupUrl string) (string, er
 		Events: []string{
 			"check_run",
 			"check_suite",
+			"issue_comment",
 			"pull_request_review_comment",
 			"pull_request_review",
 			"pull_request",
@@ -258,7 +259,12 @@ func manifestJson(appName, webhookUrl, redirectUrl, setupUrl string) (string, er
 		Permissions: map[string]string{
 			"checks":        "write",
 			"contents":      "read",
-			"pull_requests": "read",
+			"pull_requests": "write",
+			// Permits determining whether reviewers belong to teams that are
+			// code owners
+			"members": "read",
+			// Permits counting failing commit statuses as failing checks
+			"statuses": "read",
 		},
 	}
 
@@ -2,27 +2,85 @@ package github
 
 import (
 	"context"
+	"errors"
+	"net/http"
 	"testing"
 
 	"github.com/google/go-github/v31/github"
 	"github.com/leg100/etok/api/etok.dev/v1alpha1"
 	"github.com/leg100/etok/cmd/github/client"
 	"github.com/leg100/etok/pkg/scheme"
+	"github.com/leg100/etok/pkg/testobj"
 	"github.com/leg100/etok/pkg/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 func TestHandleEvent(t *testing.T) {
+	// Check run for which a plan has completed, along with its check suite,
+	// workspace, and plan run
+	planned := &v1alpha1.CheckRun{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "abc", Name: "def"},
+		Spec: v1alpha1.CheckRunSpec{
+			CheckSuiteRef: v1alpha1.CheckSuiteRef{Name: "123456"},
+			Workspace:     "networks",
+		},
+		Status: v1alpha1.CheckRunStatus{
+			Iterations: []*v1alpha1.CheckRunIteration{{Run: "def-0", Completed: true}},
+		},
+	}
+	plannedSuite := &v1alpha1.CheckSuite{
+		ObjectMeta: metav1.ObjectMeta{Name: "123456"},
+		Spec:       v1alpha1.CheckSuiteSpec{Owner: "bob", Repo: "myrepo", PullNumber: 7},
+		Status:     v1alpha1.CheckSuiteStatus{Mergeable: true},
+	}
+	planRun := func(conditions ...metav1.Condition) *v1alpha1.Run {
+		return &v1alpha1.Run{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "abc", Name: "def-0"},
+			RunStatus: v1alpha1.RunStatus{
+				Conditions: append(conditions, metav1.Condition{
+					Type:   v1alpha1.RunCompleteCondition,
+					Status: metav1.ConditionTrue,
+					Reason: v1alpha1.PodSucceededReason,
+				}),
+			},
+		}
+	}
+	applyAction := &github.CheckRunEvent{
+		Action: github.String("requested_action"),
+		CheckRun: &github.CheckRun{
+			CheckSuite: &github.CheckSuite{
+				ID:         github.Int64(123456),
+				HeadBranch: github.String("changes"),
+			},
+			ExternalID: github.String("abc/def"),
+		},
+		Repo: &github.Repository{
+			Name: github.String("myrepo"),
+			Owner: &github.User{
+				Login: github.String("bob"),
+			},
+		},
+		RequestedAction: &github.RequestedAction{
+			Identifier: "apply",
+		},
+	}
+
 	tests := []struct {
-		name       string
-		err        error
-		objs       []runtime.Object
-		event      event
+		name  string
+		err   error
+		objs  []runtime.Object
+		event event
+		// Reviews of the pull
+		reviews []*github.PullRequestReview
+		// Number of commits the pull is behind its base branch
+		behindBy   int
 		assertions func(*testutil.T, runtimeclient.Client)
 	}{
 		{
@@ -138,10 +196,122 @@ func TestHandleEvent(t *testing.T) {
 			},
 		},
 		{
-			name: "checkrun requested_action apply event",
+			name:  "checkrun requested_action apply event",
+			event: applyAction,
+			objs: []runtime.Object{
+				planned, plannedSuite, planRun(), testobj.Workspace("abc", "networks"),
+			},
+			assertions: func(t *testutil.T, client runtimeclient.Client) {
+				cr := &v1alpha1.CheckRun{}
+				require.NoError(t, client.Get(context.Background(), runtimeclient.ObjectKey{Namespace: "abc", Name: "def"}, cr))
+				assert.Equal(t, &v1alpha1.CheckRunRequestedActionEvent{Action: "apply"}, cr.Status.Events[0].RequestedAction)
+			},
+		},
+		{
+			name:  "checkrun requested_action apply event for plan violating policy",
+			event: applyAction,
+			objs: []runtime.Object{
+				planned, plannedSuite, testobj.Workspace("abc", "networks"),
+				planRun(metav1.Condition{
+					Type:   v1alpha1.RunPolicyFailedCondition,
+					Status: metav1.ConditionTrue,
+					Reason: v1alpha1.PolicyViolationReason,
+				}),
+			},
+			assertions: func(t *testutil.T, client runtimeclient.Client) {
+				cr := &v1alpha1.CheckRun{}
+				require.NoError(t, client.Get(context.Background(), runtimeclient.ObjectKey{Namespace: "abc", Name: "def"}, cr))
+				assert.Empty(t, cr.Status.Events)
+			},
+		},
+		{
+			name:  "checkrun requested_action apply event for plan not evaluated against policy",
+			event: applyAction,
+			objs: []runtime.Object{
+				planned, plannedSuite, planRun(), testobj.Workspace("abc", "networks", testobj.WithPolicyRuleSets("policy")),
+			},
+			assertions: func(t *testutil.T, client runtimeclient.Client) {
+				cr := &v1alpha1.CheckRun{}
+				require.NoError(t, client.Get(context.Background(), runtimeclient.ObjectKey{Namespace: "abc", Name: "def"}, cr))
+				assert.Empty(t, cr.Status.Events)
+			},
+		},
+		{
+			name:  "checkrun requested_action apply event meeting workspace's apply requirements",
+			event: applyAction,
+			objs: []runtime.Object{
+				planned, plannedSuite, planRun(),
+				testobj.Workspace("abc", "networks", testobj.WithApplyRequirements(v1alpha1.ApplyRequirements{Approvals: 1})),
+			},
+			reviews: []*github.PullRequestReview{
+				{User: &github.User{Login: github.String("alice")}, State: github.String("APPROVED"), CommitID: github.String(headSHA)},
+			},
+			assertions: func(t *testutil.T, client runtimeclient.Client) {
+				cr := &v1alpha1.CheckRun{}
+				require.NoError(t, client.Get(context.Background(), runtimeclient.ObjectKey{Namespace: "abc", Name: "def"}, cr))
+				assert.Equal(t, &v1alpha1.CheckRunRequestedActionEvent{Action: "apply"}, cr.Status.Events[0].RequestedAction)
+			},
+		},
+		{
+			name:  "checkrun requested_action apply event not meeting workspace's apply requirements",
+			event: applyAction,
+			objs: []runtime.Object{
+				planned, plannedSuite, planRun(),
+				testobj.Workspace("abc", "networks", testobj.WithApplyRequirements(v1alpha1.ApplyRequirements{Approvals: 1})),
+			},
+			assertions: func(t *testutil.T, client runtimeclient.Client) {
+				cr := &v1alpha1.CheckRun{}
+				require.NoError(t, client.Get(context.Background(), runtimeclient.ObjectKey{Namespace: "abc", Name: "def"}, cr))
+				assert.Empty(t, cr.Status.Events)
+			},
+		},
+		{
+			name:  "checkrun requested_action apply event after base branch has moved",
+			event: applyAction,
+			objs: []runtime.Object{
+				planned, planRun(),
+				testobj.Workspace("abc", "networks", testobj.WithApplyRequirements(v1alpha1.ApplyRequirements{UpToDate: true})),
+				// Recorded status is stale
+				func() *v1alpha1.CheckSuite {
+					suite := plannedSuite.DeepCopy()
+					suite.Status.UpToDate = true
+					return suite
+				}(),
+			},
+			behindBy: 1,
+			assertions: func(t *testutil.T, client runtimeclient.Client) {
+				cr := &v1alpha1.CheckRun{}
+				require.NoError(t, client.Get(context.Background(), runtimeclient.ObjectKey{Namespace: "abc", Name: "def"}, cr))
+				assert.Empty(t, cr.Status.Events)
+
+				suite := &v1alpha1.CheckSuite{}
+				require.NoError(t, client.Get(context.Background(), runtimeclient.ObjectKey{Name: "123456"}, suite))
+				assert.False(t, suite.Status.UpToDate)
+			},
+		},
+		{
+			name:  "checkrun requested_action apply event while plan in progress",
+			event: applyAction,
+			objs: []runtime.Object{
+				func() *v1alpha1.CheckRun {
+					planning := planned.DeepCopy()
+					planning.Status.Iterations[0].Completed = false
+					return planning
+				}(),
+				plannedSuite, planRun(), testobj.Workspace("abc", "networks"),
+			},
+			assertions: func(t *testutil.T, client runtimeclient.Client) {
+				cr := &v1alpha1.CheckRun{}
+				require.NoError(t, client.Get(context.Background(), runtimeclient.ObjectKey{Namespace: "abc", Name: "def"}, cr))
+				assert.Empty(t, cr.Status.Events)
+			},
+		},
+		{
+			name: "checkrun created event",
 			event: &github.CheckRunEvent{
-				Action: github.String("requested_action"),
+				Action: github.String("created"),
 				CheckRun: &github.CheckRun{
+					ID: github.Int64(123456),
 					CheckSuite: &github.CheckSuite{
 						ID:         github.Int64(123456),
 						HeadBranch: github.String("changes"),
@@ -154,9 +324,6 @@ func TestHandleEvent(t *testing.T) {
 						Login: github.String("bob"),
 					},
 				},
-				RequestedAction: &github.RequestedAction{
-					Identifier: "apply",
-				},
 			},
 			objs: []runtime.Object{
 				&v1alpha1.CheckRun{ObjectMeta: metav1.ObjectMeta{Namespace: "abc", Name: "def"}},
@@ -164,35 +331,40 @@ func TestHandleEvent(t *testing.T) {
 			assertions: func(t *testutil.T, client runtimeclient.Client) {
 				cr := &v1alpha1.CheckRun{}
 				require.NoError(t, client.Get(context.Background(), runtimeclient.ObjectKey{Namespace: "abc", Name: "def"}, cr))
-				assert.Equal(t, &v1alpha1.CheckRunRequestedActionEvent{Action: "apply"}, cr.Status.Events[0].RequestedAction)
+				assert.Equal(t, &v1alpha1.CheckRunCreatedEvent{ID: 123456}, cr.Status.Events[0].Created)
 			},
 		},
 		{
-			name: "checkrun created event",
+			name: "other app's checkrun completed event",
 			event: &github.CheckRunEvent{
-				Action: github.String("created"),
+				Action: github.String("completed"),
 				CheckRun: &github.CheckRun{
-					ID: github.Int64(123456),
-					CheckSuite: &github.CheckSuite{
-						ID:         github.Int64(123456),
-						HeadBranch: github.String("changes"),
+					ID:         github.Int64(654321),
+					App:        &github.App{ID: github.Int64(999)},
+					Conclusion: github.String("failure"),
+					PullRequests: []*github.PullRequest{
+						{
+							Number: github.Int(7),
+							Head: &github.PullRequestBranch{
+								Ref: github.String("changes"),
+							},
+						},
 					},
-					ExternalID: github.String("abc/def"),
 				},
 				Repo: &github.Repository{
 					Name: github.String("myrepo"),
 					Owner: &github.User{
 						Login: github.String("bob"),
 					},
+					CloneURL: github.String("https://fakerepo.git"),
 				},
 			},
-			objs: []runtime.Object{
-				&v1alpha1.CheckRun{ObjectMeta: metav1.ObjectMeta{Namespace: "abc", Name: "def"}},
-			},
 			assertions: func(t *testutil.T, client runtimeclient.Client) {
-				cr := &v1alpha1.CheckRun{}
-				require.NoError(t, client.Get(context.Background(), runtimeclient.ObjectKey{Namespace: "abc", Name: "def"}, cr))
-				assert.Equal(t, &v1alpha1.CheckRunCreatedEvent{ID: 123456}, cr.Status.Events[0].Created)
+				suites := &v1alpha1.CheckSuiteList{}
+				require.NoError(t, client.List(context.Background(), suites))
+				require.Equal(t, 1, len(suites.Items))
+
+				assert.True(t, suites.Items[0].Status.Mergeable)
 			},
 		},
 		{
@@ -253,6 +425,56 @@ func TestHandleEvent(t *testing.T) {
 				assert.True(t, suites.Items[0].Status.Mergeable)
 			},
 		},
+		{
+			name: "pull request closed event",
+			event: &github.PullRequestEvent{
+				Action: github.String("closed"),
+				Repo: &github.Repository{
+					Name: github.String("myrepo"),
+					Owner: &github.User{
+						Login: github.String("bob"),
+					},
+					CloneURL: github.String("https://fakerepo.git"),
+				},
+				PullRequest: &github.PullRequest{
+					Head: &github.PullRequestBranch{
+						Ref: github.String("changes"),
+					},
+				},
+			},
+			objs: []runtime.Object{
+				&v1alpha1.CheckSuite{
+					ObjectMeta: metav1.ObjectMeta{Name: "123"},
+					Spec:       v1alpha1.CheckSuiteSpec{Owner: "bob", Repo: "myrepo", Branch: "changes"},
+				},
+				&v1alpha1.CheckSuite{
+					ObjectMeta: metav1.ObjectMeta{Name: "456"},
+					Spec:       v1alpha1.CheckSuiteSpec{Owner: "bob", Repo: "myrepo", Branch: "other-changes"},
+				},
+				&v1alpha1.CheckRun{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "123-networks", UID: "cr-uid"},
+					Spec:       v1alpha1.CheckRunSpec{CheckSuiteRef: v1alpha1.CheckSuiteRef{Name: "123"}, Workspace: "networks"},
+				},
+				testobj.Run("default", "run-plan", "sh", testobj.WithSavePlan(), testobj.WithCondition(v1alpha1.RunCompleteCondition), checkRunOwned("cr-uid")),
+				testobj.Run("default", "run-apply", "sh", checkRunOwned("cr-uid")),
+			},
+			assertions: func(t *testutil.T, client runtimeclient.Client) {
+				suites := &v1alpha1.CheckSuiteList{}
+				require.NoError(t, client.List(context.Background(), suites))
+				require.Equal(t, 1, len(suites.Items))
+
+				assert.Equal(t, "456", suites.Items[0].Name)
+
+				// Completed plan is deleted
+				runs := &v1alpha1.RunList{}
+				require.NoError(t, client.List(context.Background(), runs))
+				require.Equal(t, 1, len(runs.Items))
+
+				// Apply is kept but released from the check run
+				assert.Equal(t, "run-apply", runs.Items[0].Name)
+				assert.Equal(t, 0, len(runs.Items[0].OwnerReferences))
+			},
+		},
 	}
 	for _, tt := range tests {
 		testutil.Run(t, tt.name, func(t *testutil.T) {
@@ -263,10 +485,12 @@ func TestHandleEvent(t *testing.T) {
 
 			gclients := githubClients{
 				checks: &fakeChecksClient{},
-				pulls:  &fakePullsClient{},
+				pulls:  &fakePullsClient{reviews: tt.reviews},
+				repos:  &fakeReposClient{behindBy: tt.behindBy},
+				teams:  &fakeTeamsClient{},
 			}
 
-			_, _, err := newApp(client).handleEvent(tt.event, gclients)
+			_, _, err := newApp(client, 0, "").handleEvent(tt.event, gclients)
 			require.NoError(t, err)
 
 			tt.assertions(t, client)
@@ -274,13 +498,29 @@ func TestHandleEvent(t *testing.T) {
 	}
 }
 
+// checkRunOwned makes a run owned by the check run with the given UID, as the
+// check run controller does.
+func checkRunOwned(uid types.UID) func(*v1alpha1.Run) {
+	return func(run *v1alpha1.Run) {
+		run.SetLabels(checkrunControllerLabels)
+		run.OwnerReferences = append(run.OwnerReferences, metav1.OwnerReference{
+			APIVersion: "etok.dev/v1alpha1",
+			Kind:       "CheckRun",
+			Name:       "123-networks",
+			UID:        uid,
+		})
+	}
+}
+
 type fakeClientGetter struct{}
 
 func (a *fakeClientGetter) Get(_ int64, _ string) (*github.Client, error) {
 	return client.NewAnonymous("fake-github.com")
 }
 
-type fakeChecksClient struct{}
+type fakeChecksClient struct {
+	checkRuns []*github.CheckRun
+}
 
 func (c *fakeChecksClient) ListCheckSuitesForRef(ctx context.Context, owner, repo, ref string, opts *github.ListCheckSuiteOptions) (*github.ListCheckSuiteResults, *github.Response, error) {
 	results := &github.ListCheckSuiteResults{
@@ -302,10 +542,397 @@ func (c *fakeChecksClient) ListCheckSuitesForRef(ctx context.Context, owner, rep
 	return results, nil, nil
 }
 
-type fakePullsClient struct{}
+func (c *fakeChecksClient) ListCheckRunsForRef(ctx context.Context, owner, repo, ref string, opts *github.ListCheckRunsOptions) (*github.ListCheckRunsResults, *github.Response, error) {
+	return &github.ListCheckRunsResults{Total: github.Int(len(c.checkRuns)), CheckRuns: c.checkRuns}, &github.Response{}, nil
+}
+
+type fakePullsClient struct {
+	reviews []*github.PullRequestReview
+	files   []*github.CommitFile
+}
+
+// SHA of the head commit of the pull returned by fakePullsClient
+const headSHA = "c0ffee"
 
 func (c *fakePullsClient) Get(ctx context.Context, owner, repo string, number int) (*github.PullRequest, *github.Response, error) {
 	return &github.PullRequest{
 		MergeableState: github.String("clean"),
+		Head:           &github.PullRequestBranch{SHA: github.String(headSHA)},
 	}, nil, nil
 }
+
+func (c *fakePullsClient) ListReviews(ctx context.Context, owner, repo string, number int, opts *github.ListOptions) ([]*github.PullRequestReview, *github.Response, error) {
+	return c.reviews, &github.Response{}, nil
+}
+
+func (c *fakePullsClient) ListFiles(ctx context.Context, owner, repo string, number int, opts *github.ListOptions) ([]*github.CommitFile, *github.Response, error) {
+	return c.files, &github.Response{}, nil
+}
+
+func TestHandleIssueCommentEvent(t *testing.T) {
+	// Check run for which a plan has completed
+	planned := func(workspace string) *v1alpha1.CheckRun {
+		return &v1alpha1.CheckRun{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "123-0-" + workspace},
+			Spec: v1alpha1.CheckRunSpec{
+				CheckSuiteRef: v1alpha1.CheckSuiteRef{Name: "123"},
+				Workspace:     workspace,
+			},
+			Status: v1alpha1.CheckRunStatus{
+				Events: []*v1alpha1.CheckRunEvent{
+					{Created: &v1alpha1.CheckRunCreatedEvent{ID: 1}},
+				},
+				Iterations: []*v1alpha1.CheckRunIteration{
+					{Run: "123-0-" + workspace + "-0", Completed: true},
+				},
+			},
+		}
+	}
+
+	// Check run for which a plan is in progress
+	planning := planned("networks")
+	planning.Status.Iterations[0].Completed = false
+
+	suite := func(mergeable bool, opts ...func(*v1alpha1.CheckSuite)) *v1alpha1.CheckSuite {
+		suite := &v1alpha1.CheckSuite{
+			ObjectMeta: metav1.ObjectMeta{Name: "123"},
+			Spec:       v1alpha1.CheckSuiteSpec{Owner: "bob", Repo: "myrepo"},
+			Status:     v1alpha1.CheckSuiteStatus{Mergeable: mergeable},
+		}
+		for _, o := range opts {
+			o(suite)
+		}
+		return suite
+	}
+
+	// Run of a plan that has succeeded
+	planRun := &v1alpha1.Run{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: "123-0-networks-0"},
+		RunStatus: v1alpha1.RunStatus{
+			Conditions: []metav1.Condition{
+				{
+					Type:   v1alpha1.RunCompleteCondition,
+					Status: metav1.ConditionTrue,
+					Reason: v1alpha1.PodSucceededReason,
+				},
+			},
+		},
+	}
+
+	// Suite whose repo has been cloned
+	cloned := func(s *v1alpha1.CheckSuite) {
+		s.Spec.ID = 123
+		s.Spec.CloneURL = "https://github.com/bob/myrepo.git"
+		s.Status.RepoPath = "/repos/bob/myrepo/c0ffee"
+	}
+
+	// Workspace connected to the suite's repo
+	connected := func(namespace, name string) *v1alpha1.Workspace {
+		return testobj.Workspace(namespace, name, testobj.WithRepository("https://github.com/bob/myrepo.git"))
+	}
+
+	approval := func(user string) *github.PullRequestReview {
+		return &github.PullRequestReview{User: &github.User{Login: github.String(user)}, State: github.String("APPROVED"), CommitID: github.String(headSHA)}
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		permission string
+		objs       []runtime.Object
+		// Reviews of the pull
+		reviews []*github.PullRequestReview
+		// Number of commits the pull is behind its base branch
+		behindBy int
+		// Expected reaction; empty if the comment is expected to be ignored
+		reaction string
+		// Expected comment event, keyed by workspace of the check run
+		events map[string]*v1alpha1.CheckRunCommentEvent
+		// Expected check runs created on demand
+		created []string
+	}{
+		{
+			name:       "plan all workspaces",
+			body:       "etok plan -- -target=module.vpc",
+			permission: "write",
+			objs:       []runtime.Object{suite(false), planned("networks"), planned("db")},
+			reaction:   reactionAccepted,
+			events: map[string]*v1alpha1.CheckRunCommentEvent{
+				"networks": {Command: "plan", Args: []string{"-target=module.vpc"}, Author: "alice"},
+				"db":       {Command: "plan", Args: []string{"-target=module.vpc"}, Author: "alice"},
+			},
+		},
+		{
+			name:       "plan specific workspace",
+			body:       "Let's try again\netok plan -w dev/networks",
+			permission: "admin",
+			objs:       []runtime.Object{suite(false), planned("networks"), planned("db")},
+			reaction:   reactionAccepted,
+			events: map[string]*v1alpha1.CheckRunCommentEvent{
+				"networks": {Command: "plan", Author: "alice"},
+			},
+		},
+		{
+			name:       "apply",
+			body:       "etok apply -w networks",
+			permission: "write",
+			objs:       []runtime.Object{suite(true), planned("networks"), planRun, testobj.Workspace("dev", "networks")},
+			reaction:   reactionAccepted,
+			events: map[string]*v1alpha1.CheckRunCommentEvent{
+				"networks": {Command: "apply", Author: "alice"},
+			},
+		},
+		{
+			name:       "apply meeting workspace's apply requirements",
+			body:       "etok apply -w networks",
+			permission: "write",
+			objs: []runtime.Object{
+				suite(true),
+				planned("networks"),
+				planRun,
+				testobj.Workspace("dev", "networks", testobj.WithApplyRequirements(v1alpha1.ApplyRequirements{Approvals: 2})),
+			},
+			reviews:  []*github.PullRequestReview{approval("carol"), approval("dave")},
+			reaction: reactionAccepted,
+			events: map[string]*v1alpha1.CheckRunCommentEvent{
+				"networks": {Command: "apply", Author: "alice"},
+			},
+		},
+		{
+			name:       "apply not meeting workspace's apply requirements",
+			body:       "etok apply -w networks",
+			permission: "write",
+			objs: []runtime.Object{
+				// Recorded status is stale
+				suite(true, func(s *v1alpha1.CheckSuite) { s.Status.Approvals = 2 }),
+				planned("networks"),
+				planRun,
+				testobj.Workspace("dev", "networks", testobj.WithApplyRequirements(v1alpha1.ApplyRequirements{Approvals: 2})),
+			},
+			reviews:  []*github.PullRequestReview{approval("carol")},
+			reaction: reactionInvalid,
+		},
+		{
+			name:       "apply not meeting repo's apply requirements",
+			body:       "etok apply -w networks",
+			permission: "write",
+			objs: []runtime.Object{
+				// Recorded status is stale
+				suite(true, func(s *v1alpha1.CheckSuite) { s.Status.UpToDate = true }),
+				planned("networks"),
+				planRun,
+				testobj.Workspace("dev", "networks"),
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "github", Name: applyRequirementsConfigMap},
+					Data:       map[string]string{applyRequirementsKey: "bob/myrepo:\n  upToDate: true\n"},
+				},
+			},
+			behindBy: 1,
+			reaction: reactionInvalid,
+		},
+		{
+			name:       "apply unmergeable pull",
+			body:       "etok apply -w networks",
+			permission: "write",
+			objs:       []runtime.Object{suite(false), planned("networks"), planRun},
+			reaction:   reactionInvalid,
+		},
+		{
+			name:       "apply without successful plan",
+			body:       "etok apply -w networks",
+			permission: "write",
+			objs:       []runtime.Object{suite(true), planned("networks")},
+			reaction:   reactionInvalid,
+		},
+		{
+			name:       "plan in progress",
+			body:       "etok plan",
+			permission: "write",
+			objs:       []runtime.Object{suite(false), planning},
+			reaction:   reactionInvalid,
+		},
+		{
+			name:       "plan workspace not triggered by pull",
+			body:       "etok plan -w dev/db",
+			permission: "write",
+			objs:       []runtime.Object{suite(false, cloned), planned("networks"), connected("dev", "networks"), connected("dev", "db")},
+			reaction:   reactionAccepted,
+			created:    []string{"123-0-db"},
+		},
+		{
+			name:       "apply workspace not triggered by pull",
+			body:       "etok apply -w db",
+			permission: "write",
+			objs:       []runtime.Object{suite(true, cloned), connected("dev", "db")},
+			reaction:   reactionInvalid,
+			created:    []string{"123-0-db"},
+		},
+		{
+			name:       "plan workspace not connected to pull",
+			body:       "etok plan -w dev/db",
+			permission: "write",
+			objs:       []runtime.Object{suite(false, cloned), testobj.Workspace("dev", "db", testobj.WithRepository("https://github.com/bob/other.git"))},
+			reaction:   reactionInvalid,
+		},
+		{
+			name:       "no matching workspace",
+			body:       "etok plan -w staging/networks",
+			permission: "write",
+			objs:       []runtime.Object{suite(false), planned("networks")},
+			reaction:   reactionInvalid,
+		},
+		{
+			name:       "unauthorised",
+			body:       "etok plan",
+			permission: "read",
+			objs:       []runtime.Object{suite(false), planned("networks")},
+			reaction:   reactionDenied,
+		},
+		{
+			name:       "unknown command",
+			body:       "etok destroy",
+			permission: "write",
+			objs:       []runtime.Object{suite(false), planned("networks")},
+			reaction:   reactionInvalid,
+		},
+		{
+			name:       "not a command",
+			body:       "looks good to me",
+			permission: "write",
+			objs:       []runtime.Object{suite(false), planned("networks")},
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			client := fake.NewClientBuilder().
+				WithScheme(scheme.Scheme).
+				WithRuntimeObjects(tt.objs...).
+				Build()
+
+			issues := &fakeIssuesClient{}
+			reactions := &fakeReactionsClient{}
+			gclients := githubClients{
+				checks:    &fakeChecksClient{},
+				pulls:     &fakePullsClient{reviews: tt.reviews},
+				issues:    issues,
+				reactions: reactions,
+				repos:     &fakeReposClient{permission: tt.permission, behindBy: tt.behindBy},
+			}
+
+			ev := &github.IssueCommentEvent{
+				Action: github.String("created"),
+				Issue: &github.Issue{
+					Number: github.Int(7),
+					PullRequestLinks: &github.PullRequestLinks{
+						URL: github.String("https://api.github.com/repos/bob/myrepo/pulls/7"),
+					},
+				},
+				Comment: &github.IssueComment{
+					ID:   github.Int64(99),
+					Body: github.String(tt.body),
+					User: &github.User{Login: github.String("alice")},
+				},
+				Repo: &github.Repository{
+					Name:  github.String("myrepo"),
+					Owner: &github.User{Login: github.String("bob")},
+				},
+			}
+
+			_, _, err := newApp(client, 0, "github").handleEvent(ev, gclients)
+			require.NoError(t, err)
+
+			if tt.reaction != "" {
+				assert.Equal(t, []string{tt.reaction}, reactions.reactions)
+				assert.Equal(t, 1, len(issues.comments))
+			} else {
+				assert.Empty(t, reactions.reactions)
+				assert.Empty(t, issues.comments)
+			}
+
+			checkRuns := &v1alpha1.CheckRunList{}
+			require.NoError(t, client.List(context.Background(), checkRuns))
+			var created []string
+			for _, cr := range checkRuns.Items {
+				if len(cr.OwnerReferences) > 0 {
+					created = append(created, cr.Name)
+				}
+				var got *v1alpha1.CheckRunCommentEvent
+				for _, ev := range cr.Status.Events {
+					if ev.Comment != nil {
+						got = ev.Comment
+					}
+				}
+				assert.Equal(t, tt.events[cr.Spec.Workspace], got, cr.Spec.Workspace)
+			}
+			assert.Equal(t, tt.created, created)
+		})
+	}
+}
+
+type fakeIssuesClient struct {
+	comments []string
+}
+
+func (c *fakeIssuesClient) CreateComment(ctx context.Context, owner, repo string, number int, comment *github.IssueComment) (*github.IssueComment, *github.Response, error) {
+	c.comments = append(c.comments, comment.GetBody())
+	return comment, nil, nil
+}
+
+type fakeReactionsClient struct {
+	reactions []string
+}
+
+func (c *fakeReactionsClient) CreateIssueCommentReaction(ctx context.Context, owner, repo string, id int64, content string) (*github.Reaction, *github.Response, error) {
+	c.reactions = append(c.reactions, content)
+	return &github.Reaction{Content: &content}, nil, nil
+}
+
+type fakeReposClient struct {
+	permission string
+
+	// Number of commits the pull is behind its base branch
+	behindBy int
+
+	// Contents of the CODEOWNERS file; empty if there is no such file
+	codeOwners string
+
+	// Commit statuses on the pull's head commit
+	statuses []*github.RepoStatus
+}
+
+func (c *fakeReposClient) GetPermissionLevel(ctx context.Context, owner, repo, user string) (*github.RepositoryPermissionLevel, *github.Response, error) {
+	return &github.RepositoryPermissionLevel{Permission: &c.permission}, nil, nil
+}
+
+func (c *fakeReposClient) CompareCommits(ctx context.Context, owner, repo string, base, head string) (*github.CommitsComparison, *github.Response, error) {
+	return &github.CommitsComparison{BehindBy: &c.behindBy}, nil, nil
+}
+
+func (c *fakeReposClient) GetContents(ctx context.Context, owner, repo, path string, opts *github.RepositoryContentGetOptions) (*github.RepositoryContent, []*github.RepositoryContent, *github.Response, error) {
+	if c.codeOwners == "" || path != ".github/CODEOWNERS" {
+		return nil, nil, notFoundResponse(), errors.New("not found")
+	}
+	return &github.RepositoryContent{Content: &c.codeOwners}, nil, nil, nil
+}
+
+func (c *fakeReposClient) GetCombinedStatus(ctx context.Context, owner, repo, ref string, opts *github.ListOptions) (*github.CombinedStatus, *github.Response, error) {
+	return &github.CombinedStatus{Statuses: c.statuses}, &github.Response{}, nil
+}
+
+// fakeTeamsClient has members keyed by org/team
+type fakeTeamsClient struct {
+	members map[string][]string
+}
+
+func (c *fakeTeamsClient) GetTeamMembershipBySlug(ctx context.Context, org, slug, user string) (*github.Membership, *github.Response, error) {
+	for _, member := range c.members[org+"/"+slug] {
+		if member == user {
+			return &github.Membership{State: github.String("active")}, nil, nil
+		}
+	}
+	return nil, notFoundResponse(), errors.New("not found")
+}
+
+func notFoundResponse() *github.Response {
+	return &github.Response{Response: &http.Response{StatusCode: http.StatusNotFound}}
+}
@@ -0,0 +1,322 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v31/github"
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// Name of the config map, in the app's namespace, that sets apply
+	// requirements for repos
+	applyRequirementsConfigMap = "apply-requirements"
+
+	// Key of the config map's entry mapping each repo, in the form owner/repo,
+	// to its apply requirements
+	applyRequirementsKey = "requirements.yaml"
+)
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get
+
+// getApplyRequirements retrieves the requirements a pull must meet before a
+// plan for the workspace can be applied. Requirements set on the workspace
+// take precedence over those set for the pull's repo. Nil is returned if
+// neither sets any requirements.
+func getApplyRequirements(ctx context.Context, client runtimeclient.Client, namespace string, ws *v1alpha1.Workspace, suite *v1alpha1.CheckSuite) (*v1alpha1.ApplyRequirements, error) {
+	if ws.Spec.VCS.ApplyRequirements != nil {
+		return ws.Spec.VCS.ApplyRequirements, nil
+	}
+
+	if namespace == "" {
+		return nil, nil
+	}
+
+	cm := &corev1.ConfigMap{}
+	if err := client.Get(ctx, runtimeclient.ObjectKey{Namespace: namespace, Name: applyRequirementsConfigMap}, cm); err != nil {
+		return nil, runtimeclient.IgnoreNotFound(err)
+	}
+
+	repos := make(map[string]*v1alpha1.ApplyRequirements)
+	if err := yaml.Unmarshal([]byte(cm.Data[applyRequirementsKey]), &repos); err != nil {
+		return nil, fmt.Errorf("unable to decode apply requirements: %w", err)
+	}
+
+	// Repo names are case insensitive
+	for repo, reqs := range repos {
+		if strings.EqualFold(repo, suite.Spec.Owner+"/"+suite.Spec.Repo) {
+			return reqs, nil
+		}
+	}
+	return nil, nil
+}
+
+// unmetApplyRequirements describes each requirement the pull does not meet,
+// according to the status recorded on its check suite
+func unmetApplyRequirements(reqs *v1alpha1.ApplyRequirements, status v1alpha1.CheckSuiteStatus) (unmet []string) {
+	if reqs == nil {
+		return nil
+	}
+	if status.Approvals < reqs.Approvals {
+		unmet = append(unmet, fmt.Sprintf("%d of %d required approving reviews", status.Approvals, reqs.Approvals))
+	}
+	if reqs.CodeOwnerApproval && !status.CodeOwnersApproved {
+		unmet = append(unmet, "changed files have not been approved by their code owners")
+	}
+	if reqs.PassingChecks && len(status.FailingChecks) > 0 {
+		unmet = append(unmet, fmt.Sprintf("failing checks: %s", strings.Join(status.FailingChecks, ", ")))
+	}
+	if reqs.UpToDate && !status.UpToDate {
+		unmet = append(unmet, "branch is not up to date with its base branch")
+	}
+	return unmet
+}
+
+// getRequirementsStatus populates the status of the pull against which apply
+// requirements are evaluated: its approvals, whether its changes have been
+// approved by their code owners, any failing checks other than those of the
+// check suite, and whether it is up to date with its base branch.
+func getRequirementsStatus(ctx context.Context, gclients githubClients, owner, repo string, number int, suiteID int64, status *v1alpha1.CheckSuiteStatus) error {
+	pull, _, err := gclients.pulls.Get(ctx, owner, repo, number)
+	if err != nil {
+		return fmt.Errorf("unable to retrieve pull: %w", err)
+	}
+
+	approvers, err := listApprovers(ctx, gclients.pulls, owner, repo, number, pull.GetHead().GetSHA())
+	if err != nil {
+		return fmt.Errorf("unable to list reviews: %w", err)
+	}
+	status.Approvals = len(approvers)
+
+	status.CodeOwnersApproved, err = codeOwnersApproved(ctx, gclients, owner, repo, pull.GetBase().GetRef(), number, approvers)
+	if err != nil {
+		return fmt.Errorf("unable to check code owner approval: %w", err)
+	}
+
+	status.FailingChecks, err = listFailingChecks(ctx, gclients, owner, repo, pull.GetHead().GetSHA(), suiteID)
+	if err != nil {
+		return fmt.Errorf("unable to list failing checks: %w", err)
+	}
+
+	comparison, _, err := gclients.repos.CompareCommits(ctx, owner, repo, pull.GetBase().GetRef(), pull.GetHead().GetSHA())
+	if err != nil {
+		return fmt.Errorf("unable to compare pull with its base branch: %w", err)
+	}
+	status.UpToDate = comparison.GetBehindBy() == 0
+
+	return nil
+}
+
+// listApprovers lists the reviewers whose latest review approves the pull at
+// its head commit. Reviews that only comment leave a reviewer's approval in
+// place, but an approval of an earlier commit is stale and is not counted,
+// regardless of whether the repo dismisses stale reviews.
+func listApprovers(ctx context.Context, client pullsClient, owner, repo string, number int, head string) ([]string, error) {
+	latest := make(map[string]*github.PullRequestReview)
+
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		reviews, resp, err := client.ListReviews(ctx, owner, repo, number, opts)
+		if err != nil {
+			return nil, err
+		}
+		// Reviews are listed in chronological order
+		for _, r := range reviews {
+			switch r.GetState() {
+			case "APPROVED", "CHANGES_REQUESTED", "DISMISSED":
+				latest[r.GetUser().GetLogin()] = r
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	var approvers []string
+	for user, r := range latest {
+		if r.GetState() == "APPROVED" && r.GetCommitID() == head {
+			approvers = append(approvers, user)
+		}
+	}
+	sort.Strings(approvers)
+	return approvers, nil
+}
+
+// codeOwnersApproved determines whether each file changed by the pull that has
+// code owners has been approved by one of its code owners. Code owners are
+// read from the CODEOWNERS file on the pull's base branch; if there is no such
+// file then there is nothing requiring approval.
+func codeOwnersApproved(ctx context.Context, gclients githubClients, owner, repo, base string, number int, approvers []string) (bool, error) {
+	rules, err := getCodeOwners(ctx, gclients.repos, owner, repo, base)
+	if err != nil {
+		return false, err
+	}
+	if rules == nil {
+		return true, nil
+	}
+
+	changed, err := listChangedFiles(ctx, gclients.pulls, owner, repo, number)
+	if err != nil {
+		return false, err
+	}
+
+	members := &teamMembers{client: gclients.teams, cache: make(map[string]bool)}
+	for _, path := range changed {
+		owners := rules.owners(path)
+		if len(owners) == 0 {
+			continue
+		}
+		approved, err := members.anyOwner(ctx, owners, approvers)
+		if err != nil {
+			return false, err
+		}
+		if !approved {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// getCodeOwners retrieves the code owners of a repo from its CODEOWNERS file
+// on the given ref. Nil is returned if there is no such file.
+func getCodeOwners(ctx context.Context, client reposClient, owner, repo, ref string) (codeOwners, error) {
+	for _, path := range codeOwnersPaths {
+		file, _, resp, err := client.GetContents(ctx, owner, repo, path, &github.RepositoryContentGetOptions{Ref: ref})
+		if isNotFound(resp) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		content, err := file.GetContent()
+		if err != nil {
+			return nil, err
+		}
+		return parseCodeOwners(content), nil
+	}
+	return nil, nil
+}
+
+// listFailingChecks lists the names of the checks on a commit that have
+// failed, other than those belonging to the given check suite. Checks include
+// both check runs and commit statuses, the latter being reported by
+// integrations that predate the checks API.
+func listFailingChecks(ctx context.Context, gclients githubClients, owner, repo, sha string, suiteID int64) ([]string, error) {
+	failing := make(map[string]bool)
+
+	opts := &github.ListCheckRunsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		results, resp, err := gclients.checks.ListCheckRunsForRef(ctx, owner, repo, sha, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, run := range results.CheckRuns {
+			if run.GetCheckSuite().GetID() == suiteID {
+				continue
+			}
+			switch run.GetConclusion() {
+			case "failure", "timed_out", "cancelled", "action_required":
+				failing[run.GetName()] = true
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	statusOpts := &github.ListOptions{PerPage: 100}
+	for {
+		combined, resp, err := gclients.repos.GetCombinedStatus(ctx, owner, repo, sha, statusOpts)
+		if err != nil {
+			return nil, err
+		}
+		// Only the latest status of each context is included
+		for _, status := range combined.Statuses {
+			switch status.GetState() {
+			case "failure", "error":
+				failing[status.GetContext()] = true
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		statusOpts.Page = resp.NextPage
+	}
+
+	var names []string
+	for name := range failing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// teamMembers determines whether users are code owners, caching the team
+// memberships it looks up
+type teamMembers struct {
+	client teamsClient
+	cache  map[string]bool
+}
+
+// anyOwner determines whether any of the users is one of the owners, either
+// directly or by being a member of an owning team. Owners identified by email
+// address are not supported.
+func (m *teamMembers) anyOwner(ctx context.Context, owners, users []string) (bool, error) {
+	for _, owner := range owners {
+		if !strings.HasPrefix(owner, "@") {
+			continue
+		}
+		owner = strings.TrimPrefix(owner, "@")
+
+		for _, user := range users {
+			parts := strings.SplitN(owner, "/", 2)
+			if len(parts) == 1 {
+				if strings.EqualFold(owner, user) {
+					return true, nil
+				}
+				continue
+			}
+
+			member, err := m.isMember(ctx, parts[0], parts[1], user)
+			if err != nil {
+				return false, err
+			}
+			if member {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (m *teamMembers) isMember(ctx context.Context, org, team, user string) (bool, error) {
+	key := fmt.Sprintf("%s/%s/%s", org, team, user)
+	if member, ok := m.cache[key]; ok {
+		return member, nil
+	}
+
+	membership, resp, err := m.client.GetTeamMembershipBySlug(ctx, org, team, user)
+	if isNotFound(resp) {
+		m.cache[key] = false
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to retrieve membership of team %s/%s: %w", org, team, err)
+	}
+
+	m.cache[key] = membership.GetState() == "active"
+	return m.cache[key], nil
+}
+
+func isNotFound(resp *github.Response) bool {
+	return resp != nil && resp.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,252 @@
+package github
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-github/v31/github"
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/pkg/builders"
+	"github.com/leg100/etok/pkg/scheme"
+	"github.com/leg100/etok/pkg/testobj"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGetApplyRequirements(t *testing.T) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "github", Name: applyRequirementsConfigMap},
+		Data: map[string]string{
+			applyRequirementsKey: `
+Bob/MyRepo:
+  approvals: 1
+  passingChecks: true
+bob/other-repo:
+  upToDate: true
+`,
+		},
+	}
+
+	tests := []struct {
+		name      string
+		namespace string
+		ws        *v1alpha1.Workspace
+		objs      []runtime.Object
+		want      *v1alpha1.ApplyRequirements
+	}{
+		{
+			name:      "no requirements",
+			namespace: "github",
+			ws:        testobj.Workspace("dev", "networks"),
+		},
+		{
+			name:      "workspace requirements",
+			namespace: "github",
+			ws:        testobj.Workspace("dev", "networks", testobj.WithApplyRequirements(v1alpha1.ApplyRequirements{CodeOwnerApproval: true})),
+			objs:      []runtime.Object{configMap},
+			want:      &v1alpha1.ApplyRequirements{CodeOwnerApproval: true},
+		},
+		{
+			name:      "repo requirements",
+			namespace: "github",
+			ws:        testobj.Workspace("dev", "networks"),
+			objs:      []runtime.Object{configMap},
+			want:      &v1alpha1.ApplyRequirements{Approvals: 1, PassingChecks: true},
+		},
+		{
+			name: "repo requirements without namespace",
+			ws:   testobj.Workspace("dev", "networks"),
+			objs: []runtime.Object{configMap},
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			client := fake.NewClientBuilder().
+				WithScheme(scheme.Scheme).
+				WithRuntimeObjects(tt.objs...).
+				Build()
+
+			suite := builders.CheckSuite(123).Build()
+			suite.Spec.Owner = "bob"
+			suite.Spec.Repo = "myrepo"
+
+			reqs, err := getApplyRequirements(context.Background(), client, tt.namespace, tt.ws, suite)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, reqs)
+		})
+	}
+}
+
+func TestUnmetApplyRequirements(t *testing.T) {
+	reqs := &v1alpha1.ApplyRequirements{
+		Approvals:         2,
+		CodeOwnerApproval: true,
+		PassingChecks:     true,
+		UpToDate:          true,
+	}
+
+	assert.Nil(t, unmetApplyRequirements(nil, v1alpha1.CheckSuiteStatus{}))
+
+	assert.Equal(t, []string{
+		"1 of 2 required approving reviews",
+		"changed files have not been approved by their code owners",
+		"failing checks: lint, test",
+		"branch is not up to date with its base branch",
+	}, unmetApplyRequirements(reqs, v1alpha1.CheckSuiteStatus{
+		Approvals:     1,
+		FailingChecks: []string{"lint", "test"},
+	}))
+
+	assert.Nil(t, unmetApplyRequirements(reqs, v1alpha1.CheckSuiteStatus{
+		Approvals:          2,
+		CodeOwnersApproved: true,
+		UpToDate:           true,
+	}))
+}
+
+func TestGetRequirementsStatus(t *testing.T) {
+	review := func(user, state string, commit ...string) *github.PullRequestReview {
+		sha := headSHA
+		if len(commit) > 0 {
+			sha = commit[0]
+		}
+		return &github.PullRequestReview{User: &github.User{Login: github.String(user)}, State: github.String(state), CommitID: github.String(sha)}
+	}
+	checkRun := func(name, conclusion string, suite int64) *github.CheckRun {
+		return &github.CheckRun{Name: github.String(name), Conclusion: github.String(conclusion), CheckSuite: &github.CheckSuite{ID: github.Int64(suite)}}
+	}
+	commitStatus := func(context, state string) *github.RepoStatus {
+		return &github.RepoStatus{Context: github.String(context), State: github.String(state)}
+	}
+
+	tests := []struct {
+		name     string
+		gclients githubClients
+		want     v1alpha1.CheckSuiteStatus
+	}{
+		{
+			name: "no reviews or checks",
+			gclients: githubClients{
+				checks: &fakeChecksClient{},
+				pulls:  &fakePullsClient{},
+				repos:  &fakeReposClient{behindBy: 3},
+				teams:  &fakeTeamsClient{},
+			},
+			// Without a CODEOWNERS file there is nothing requiring approval
+			want: v1alpha1.CheckSuiteStatus{CodeOwnersApproved: true},
+		},
+		{
+			name: "latest reviews count",
+			gclients: githubClients{
+				checks: &fakeChecksClient{},
+				pulls: &fakePullsClient{
+					reviews: []*github.PullRequestReview{
+						review("alice", "APPROVED"),
+						review("alice", "COMMENTED"),
+						review("bob", "APPROVED"),
+						review("bob", "CHANGES_REQUESTED"),
+						review("carol", "CHANGES_REQUESTED"),
+						review("carol", "APPROVED"),
+					},
+				},
+				repos: &fakeReposClient{},
+				teams: &fakeTeamsClient{},
+			},
+			want: v1alpha1.CheckSuiteStatus{Approvals: 2, CodeOwnersApproved: true, UpToDate: true},
+		},
+		{
+			name: "stale approvals",
+			gclients: githubClients{
+				checks: &fakeChecksClient{},
+				pulls: &fakePullsClient{
+					reviews: []*github.PullRequestReview{
+						review("alice", "APPROVED", "deadbeef"),
+						review("bob", "APPROVED", "deadbeef"),
+						review("bob", "COMMENTED"),
+						review("carol", "APPROVED"),
+					},
+				},
+				repos: &fakeReposClient{},
+				teams: &fakeTeamsClient{},
+			},
+			want: v1alpha1.CheckSuiteStatus{Approvals: 1, CodeOwnersApproved: true, UpToDate: true},
+		},
+		{
+			name: "other failing checks",
+			gclients: githubClients{
+				checks: &fakeChecksClient{
+					checkRuns: []*github.CheckRun{
+						checkRun("test", "failure", 456),
+						checkRun("lint", "success", 456),
+						checkRun("build", "timed_out", 456),
+						checkRun("dev/networks", "failure", 123),
+					},
+				},
+				pulls: &fakePullsClient{},
+				repos: &fakeReposClient{},
+				teams: &fakeTeamsClient{},
+			},
+			want: v1alpha1.CheckSuiteStatus{CodeOwnersApproved: true, FailingChecks: []string{"build", "test"}, UpToDate: true},
+		},
+		{
+			name: "failing commit statuses",
+			gclients: githubClients{
+				checks: &fakeChecksClient{
+					checkRuns: []*github.CheckRun{
+						checkRun("test", "failure", 456),
+					},
+				},
+				pulls: &fakePullsClient{},
+				repos: &fakeReposClient{
+					statuses: []*github.RepoStatus{
+						commitStatus("ci/jenkins", "failure"),
+						commitStatus("ci/circleci", "error"),
+						commitStatus("ci/travis", "success"),
+						commitStatus("security/scan", "pending"),
+						commitStatus("test", "failure"),
+					},
+				},
+				teams: &fakeTeamsClient{},
+			},
+			want: v1alpha1.CheckSuiteStatus{CodeOwnersApproved: true, FailingChecks: []string{"ci/circleci", "ci/jenkins", "test"}, UpToDate: true},
+		},
+		{
+			name: "approved by code owners",
+			gclients: githubClients{
+				checks: &fakeChecksClient{},
+				pulls: &fakePullsClient{
+					reviews: []*github.PullRequestReview{review("alice", "APPROVED"), review("bob", "APPROVED")},
+					files:   []*github.CommitFile{{Filename: github.String("networks/main.tf")}, {Filename: github.String("README.md")}},
+				},
+				repos: &fakeReposClient{codeOwners: "/networks/ @acme/networking\n*.md @alice\n"},
+				teams: &fakeTeamsClient{members: map[string][]string{"acme/networking": {"bob"}}},
+			},
+			want: v1alpha1.CheckSuiteStatus{Approvals: 2, CodeOwnersApproved: true, UpToDate: true},
+		},
+		{
+			name: "not approved by code owners",
+			gclients: githubClients{
+				checks: &fakeChecksClient{},
+				pulls: &fakePullsClient{
+					reviews: []*github.PullRequestReview{review("alice", "APPROVED")},
+					files:   []*github.CommitFile{{Filename: github.String("networks/main.tf")}, {Filename: github.String("README.md")}},
+				},
+				repos: &fakeReposClient{codeOwners: "/networks/ @acme/networking\n*.md @alice\n"},
+				teams: &fakeTeamsClient{members: map[string][]string{"acme/networking": {"bob"}}},
+			},
+			want: v1alpha1.CheckSuiteStatus{Approvals: 1, UpToDate: true},
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			status := v1alpha1.CheckSuiteStatus{}
+			require.NoError(t, getRequirementsStatus(context.Background(), tt.gclients, "bob", "myrepo", 7, 123, &status))
+			assert.Equal(t, tt.want, status)
+		})
+	}
+}
@@ -1,35 +1,259 @@
 package github
 
 import (
-	"io"
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
 	"text/template"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/pkg/plan"
 )
 
 var (
 	markdownFuncs       = make(map[string]interface{})
 	checkOutputTemplate *template.Template
+
+	// Order in which resource changes are grouped
+	actionOrder = []plan.Action{plan.Create, plan.Update, plan.Replace, plan.Delete, plan.Unknown}
 )
 
 func init() {
 	markdownFuncs["quoted"] = func(s string) string { return "`" + s + "`" }
 	markdownFuncs["textBlock"] = func(s string) string { return "```text\n" + s + "```" }
+	markdownFuncs["diffBlock"] = func(s string) string { return "```diff\n" + s + "```" }
+
+	checkOutputTemplate = template.Must(template.New("table").Funcs(markdownFuncs).Parse(`| Resource | Action | Sensitive |
+| --- | --- | --- |
+{{ range . }}| {{ quoted .Address }} | {{ .Action }} | {{ if .Sensitive }}yes{{ end }} |
+{{ end }}`))
+
+	template.Must(checkOutputTemplate.New("resource").Parse(`<details>
+<summary><code>{{ .Address }}</code> ({{ .Action }})</summary>
+
+{{ diffBlock .Diff }}
+</details>
+`))
 
-	checkOutputTemplate = template.Must(template.New("summary").Funcs(markdownFuncs).Parse(`
-{{ if ne .RunOutput "" }}
-{{ .RunOutput | textBlock }}
+	template.Must(checkOutputTemplate.New("blastRadius").Parse(`**Blast radius**
+{{ range .Critical }}
+:warning: Critical resource {{ quoted . }} is to be replaced or deleted
 {{ end }}
-{{ if ne .ErrOutput "" }}
-{{ .ErrOutput | textBlock }}
+| Provider | Resources |
+| --- | --- |
+{{ range .Providers }}| {{ quoted .Name }} | {{ .Count }} |
 {{ end }}
-`))
+| Module | Resources |
+| --- | --- |
+{{ range .Modules }}| {{ quoted .Name }} | {{ .Count }} |
+{{ end }}{{ if .Omitted }}
+:warning: {{ .Omitted }} more resources are changed, whose changes are too large to record
+{{ end }}`))
+}
+
+// resourceCount is the number of resources changed for a provider or module
+type resourceCount struct {
+	Name  string
+	Count int
+}
+
+// sortedCounts converts a map of counts into a slice sorted by name
+func sortedCounts(counts map[string]int) (sorted []resourceCount) {
+	for name, count := range counts {
+		sorted = append(sorted, resourceCount{Name: name, Count: count})
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return
 }
 
-func generateCheckOutput(out io.Writer, runOutput, errOutput string) error {
-	return checkOutputTemplate.Execute(out, struct {
-		RunOutput string
-		ErrOutput string
+// generateBlastRadius renders a plan's blast radius: tables of the number of
+// resources changed per provider and per module, preceded by warnings of any
+// critical resources that are to be replaced or deleted.
+func generateBlastRadius(br *v1alpha1.BlastRadius) (string, error) {
+	buf := new(bytes.Buffer)
+	err := checkOutputTemplate.ExecuteTemplate(buf, "blastRadius", struct {
+		Providers, Modules []resourceCount
+		Critical           []string
+		Omitted            int
 	}{
-		RunOutput: runOutput,
-		ErrOutput: errOutput,
+		Providers: sortedCounts(br.Providers),
+		Modules:   sortedCounts(br.Modules),
+		Critical:  br.Critical,
+		Omitted:   br.Omitted,
 	})
+	return buf.String(), err
+}
+
+// groupChanges returns a plan's resource changes grouped by action
+func groupChanges(p *plan.Plan) (grouped []plan.ResourceChange) {
+	for _, action := range actionOrder {
+		for _, rc := range p.ResourceChanges {
+			if rc.Action == action {
+				grouped = append(grouped, rc)
+			}
+		}
+	}
+	return
+}
+
+// generatePlanTable renders a markdown table of a plan's resource changes,
+// grouped by action. Rows that would take the table beyond max bytes are
+// omitted, and a final row notes how many have been omitted.
+func generatePlanTable(p *plan.Plan, max int) (string, error) {
+	changes := groupChanges(p)
+
+	buf := new(bytes.Buffer)
+	if err := checkOutputTemplate.ExecuteTemplate(buf, "table", changes); err != nil {
+		return "", err
+	}
+	if buf.Len() <= max && p.Omitted == 0 {
+		return buf.String(), nil
+	}
+
+	// Reserve space for a row noting the number of omitted resources,
+	// including those omitted from the recorded plan itself
+	max -= len(fmt.Sprintf("| %d more resources not shown | | |\n", len(changes)+p.Omitted))
+
+	lines := strings.SplitAfter(buf.String(), "\n")
+	var table string
+	var shown int
+	// First two lines are the header
+	for i, line := range lines {
+		if len(table)+len(line) > max {
+			break
+		}
+		table += line
+		if i > 1 && line != "" {
+			shown++
+		}
+	}
+
+	return table + fmt.Sprintf("| %d more resources not shown | | |\n", len(changes)-shown+p.Omitted), nil
+}
+
+// generatePlanDiffs renders each resource change's attribute diff in a
+// collapsible section. If the sections exceed max bytes in total then each diff
+// is truncated to fit, with each resource given an equal share of the space,
+// and any space unused by smaller diffs shared among the larger diffs.
+func generatePlanDiffs(p *plan.Plan, max int) (string, error) {
+	changes := groupChanges(p)
+
+	// Bytes required by each section without its diff, and by each diff
+	overheads := make([]int, len(changes))
+	diffs := make([]string, len(changes))
+	for i, rc := range changes {
+		buf := new(bytes.Buffer)
+		if err := renderResource(buf, rc, ""); err != nil {
+			return "", err
+		}
+		overheads[i] = buf.Len()
+		diffs[i] = resourceDiff(rc)
+	}
+
+	// Determine how many sections can be shown at all, leaving room to note
+	// those that are not
+	var shown, used int
+	for shown < len(changes) && used+overheads[shown] <= max-len(omittedNote(len(changes)+p.Omitted)) {
+		used += overheads[shown]
+		shown++
+	}
+
+	// Allocate remaining space among the diffs of the sections shown, smallest
+	// diffs first
+	allocations := make([]int, shown)
+	order := make([]int, shown)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return len(diffs[order[i]]) < len(diffs[order[j]]) })
+	remaining := max - used
+	omitted := len(changes) - shown + p.Omitted
+	if omitted > 0 {
+		remaining -= len(omittedNote(omitted))
+	}
+	for n, i := range order {
+		share := remaining / (shown - n)
+		if len(diffs[i]) < share {
+			share = len(diffs[i])
+		}
+		allocations[i] = share
+		remaining -= share
+	}
+
+	buf := new(bytes.Buffer)
+	for i := 0; i < shown; i++ {
+		if err := renderResource(buf, changes[i], truncateDiff(diffs[i], allocations[i])); err != nil {
+			return "", err
+		}
+	}
+	if omitted > 0 {
+		buf.WriteString(omittedNote(omitted))
+	}
+
+	return buf.String(), nil
+}
+
+func renderResource(buf *bytes.Buffer, rc plan.ResourceChange, diff string) error {
+	return checkOutputTemplate.ExecuteTemplate(buf, "resource", struct {
+		plan.ResourceChange
+		Diff string
+	}{
+		ResourceChange: rc,
+		Diff:           diff,
+	})
+}
+
+func omittedNote(n int) string {
+	return fmt.Sprintf("\n%d more resources not shown\n", n)
+}
+
+// resourceDiff renders a resource change's attributes in the diff format: a
+// '+' prefixes values being added, and a '-' prefixes values being removed.
+func resourceDiff(rc plan.ResourceChange) string {
+	if rc.AttributesOmitted {
+		return "# attributes omitted to limit the size of the plan\n"
+	}
+
+	var width int
+	for _, attr := range rc.Attributes {
+		if len(attr.Name) > width {
+			width = len(attr.Name)
+		}
+	}
+
+	var b strings.Builder
+	for _, attr := range rc.Attributes {
+		if attr.Before != "" {
+			fmt.Fprintf(&b, "- %-*s = %s\n", width, attr.Name, attr.Before)
+		}
+		if attr.After != "" {
+			fmt.Fprintf(&b, "+ %-*s = %s\n", width, attr.Name, attr.After)
+		}
+	}
+	return b.String()
+}
+
+// truncateDiff truncates a diff to no more than max bytes, cutting it at the
+// end of a line, and appending a line noting the truncation.
+func truncateDiff(diff string, max int) string {
+	if len(diff) <= max {
+		return diff
+	}
+
+	note := fmt.Sprintf("# truncated %d bytes\n", len(diff))
+	max -= len(note)
+	if max <= 0 {
+		return ""
+	}
+
+	// Ensure diff does not end half way through a line
+	truncated := diff[:max]
+	if i := strings.LastIndexByte(truncated, '\n'); i > -1 {
+		truncated = truncated[:i+1]
+	} else {
+		truncated = ""
+	}
+
+	return truncated + fmt.Sprintf("# truncated %d bytes\n", len(diff)-len(truncated))
 }
@@ -0,0 +1,91 @@
+package github
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/leg100/etok/pkg/plan"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratePlanTable(t *testing.T) {
+	p := &plan.Plan{}
+	for i := 0; i < 100; i++ {
+		p.ResourceChanges = append(p.ResourceChanges, plan.ResourceChange{Address: fmt.Sprintf("random_id.id%d", i), Action: plan.Create})
+	}
+
+	testutil.Run(t, "within max size", func(t *testutil.T) {
+		table, err := generatePlanTable(p, defaultMaxFieldSize)
+		require.NoError(t, err)
+		assert.Equal(t, 102, strings.Count(table, "\n"))
+	})
+
+	testutil.Run(t, "exceed max size", func(t *testutil.T) {
+		table, err := generatePlanTable(p, 1000)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(table), 1000)
+		assert.True(t, strings.HasPrefix(table, "| Resource | Action | Sensitive |\n"))
+		assert.Contains(t, table, "more resources not shown")
+	})
+
+	testutil.Run(t, "resources omitted from recorded plan", func(t *testutil.T) {
+		truncated := &plan.Plan{ResourceChanges: p.ResourceChanges[:1], Omitted: 99}
+		table, err := generatePlanTable(truncated, defaultMaxFieldSize)
+		require.NoError(t, err)
+		assert.Contains(t, table, "| 99 more resources not shown | | |\n")
+	})
+}
+
+func TestGeneratePlanDiffs(t *testing.T) {
+	// A resource with many changed attributes
+	big := plan.ResourceChange{Address: "local_file.big", Action: plan.Update}
+	for i := 0; i < 100; i++ {
+		big.Attributes = append(big.Attributes, plan.AttributeChange{Name: fmt.Sprintf("attr%d", i), Before: `"old"`, After: `"new"`})
+	}
+	small := plan.ResourceChange{
+		Address:    "local_file.small",
+		Action:     plan.Create,
+		Attributes: []plan.AttributeChange{{Name: "content", After: `"hello"`}},
+	}
+	p := &plan.Plan{ResourceChanges: []plan.ResourceChange{big, small}}
+
+	testutil.Run(t, "within max size", func(t *testutil.T) {
+		diffs, err := generatePlanDiffs(p, defaultMaxFieldSize)
+		require.NoError(t, err)
+		assert.Contains(t, diffs, "- attr99 = \"old\"\n+ attr99 = \"new\"\n")
+		assert.NotContains(t, diffs, "truncated")
+	})
+
+	testutil.Run(t, "exceed max size", func(t *testutil.T) {
+		diffs, err := generatePlanDiffs(p, 1000)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(diffs), 1000)
+
+		// Small diff is shown in full, while the big diff is truncated from
+		// the end
+		assert.Contains(t, diffs, "+ content = \"hello\"\n")
+		assert.Contains(t, diffs, "- attr0  = \"old\"\n")
+		assert.NotContains(t, diffs, "attr99")
+		assert.Contains(t, diffs, "# truncated")
+	})
+
+	testutil.Run(t, "too many resources", func(t *testutil.T) {
+		diffs, err := generatePlanDiffs(p, 150)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(diffs), 150)
+		assert.Contains(t, diffs, "local_file.small")
+		assert.Contains(t, diffs, "1 more resources not shown")
+	})
+
+	testutil.Run(t, "attributes omitted from recorded plan", func(t *testutil.T) {
+		omitted := plan.ResourceChange{Address: "local_file.big", Action: plan.Update, AttributesOmitted: true}
+		diffs, err := generatePlanDiffs(&plan.Plan{ResourceChanges: []plan.ResourceChange{omitted, small}, Omitted: 3}, defaultMaxFieldSize)
+		require.NoError(t, err)
+		assert.Contains(t, diffs, "# attributes omitted to limit the size of the plan\n")
+		assert.Contains(t, diffs, "+ content = \"hello\"\n")
+		assert.Contains(t, diffs, "3 more resources not shown")
+	})
+}
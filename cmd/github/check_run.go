@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/pkg/vcs"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -58,13 +59,6 @@ func (cr *checkRun) setConclusion(conclusion *string) {
 	cr.CheckRun.Status.Conclusion = conclusion
 }
 
-func (cr *checkRun) currentEvent() *v1alpha1.CheckRunEvent {
-	if len(cr.Status.Events) > 0 {
-		return cr.Status.Events[len(cr.Status.Events)-1]
-	}
-	return nil
-}
-
 func (cr *checkRun) etokRunName() string {
 	return cr.etokRunNameByIteration(cr.currentIteration())
 }
@@ -75,13 +69,50 @@ func (cr *checkRun) etokRunNameByIteration(i int) string {
 
 func (cr *checkRun) currentIteration() (i int) {
 	for _, ev := range cr.Status.Events {
-		if ev.Rerequested != nil || ev.RequestedAction != nil {
+		if startsIteration(ev) {
 			i++
 		}
 	}
 	return
 }
 
+// iterationEvent returns the event that started the current iteration, or nil
+// if it is the first iteration
+func (cr *checkRun) iterationEvent() *v1alpha1.CheckRunEvent {
+	for i := len(cr.Status.Events) - 1; i >= 0; i-- {
+		if startsIteration(cr.Status.Events[i]) {
+			return cr.Status.Events[i]
+		}
+	}
+	return nil
+}
+
+// cancelRequested determines whether the user has requested the cancellation
+// of the current iteration's run
+func (cr *checkRun) cancelRequested() bool {
+	for i := len(cr.Status.Events) - 1; i >= 0; i-- {
+		ev := cr.Status.Events[i]
+		if isCancelAction(ev) {
+			return true
+		}
+		if startsIteration(ev) {
+			return false
+		}
+	}
+	return false
+}
+
+// startsIteration determines whether the event starts a new iteration, i.e. a
+// new run. Every re-request, comment, and requested action does so, other than
+// the cancel action, which instead cancels the current iteration's run.
+func startsIteration(ev *v1alpha1.CheckRunEvent) bool {
+	return ev.Rerequested != nil || ev.Comment != nil || (ev.RequestedAction != nil && !isCancelAction(ev))
+}
+
+func isCancelAction(ev *v1alpha1.CheckRunEvent) bool {
+	return ev.RequestedAction != nil && ev.RequestedAction.Action == cancelAction
+}
+
 // Set status of current iteration
 func (cr *checkRun) setIterationStatus(completed bool) {
 	// Ensure iterations status is populated first
@@ -93,15 +124,30 @@ func (cr *checkRun) setIterationStatus(completed bool) {
 	cr.Status.Iterations[cr.currentIteration()].Completed = completed
 }
 
-// Determine the current command to run according to the most recently received
-// event: plan is the default unless user has requested an apply
+// Determine the current command to run according to the event that started
+// the current iteration: plan is the default unless user has requested an
+// apply, either via an action or a comment
 func (cr *checkRun) command() checkRunCommand {
-	if cr.currentEvent() != nil && cr.currentEvent().RequestedAction != nil && cr.currentEvent().RequestedAction.Action == "apply" {
-		return applyCmd
+	if ev := cr.iterationEvent(); ev != nil {
+		if ev.RequestedAction != nil && ev.RequestedAction.Action == "apply" {
+			return applyCmd
+		}
+		if ev.Comment != nil && ev.Comment.Command == "apply" {
+			return applyCmd
+		}
 	}
 	return planCmd
 }
 
+// Additional terraform arguments for the current command, which can only be
+// provided via a comment
+func (cr *checkRun) args() []string {
+	if ev := cr.iterationEvent(); ev != nil && ev.Comment != nil {
+		return ev.Comment.Args
+	}
+	return nil
+}
+
 // Get path to plan file for use with the check run comand
 func (cr *checkRun) targetPlan() planPath {
 	switch c := cr.command(); c {
@@ -119,10 +165,18 @@ func (cr *checkRun) targetPlan() planPath {
 func (cr *checkRun) script() string {
 	initCmd := "terraform init -no-color -input=false"
 
+	// Quote args to prevent their interpretation by the shell
+	var args string
+	for _, arg := range cr.args() {
+		args += " " + vcs.ShellQuote(arg)
+	}
+
 	switch c := cr.command(); c {
 	case planCmd:
-		return fmt.Sprintf("%s && terraform plan -no-color -input=false -out=%s", initCmd, cr.targetPlan())
+		return fmt.Sprintf("%s && terraform plan -no-color -input=false%s -out=%s", initCmd, args, cr.targetPlan())
 	case applyCmd:
+		// Arguments are never passed to apply, which applies the saved plan
+		// as is
 		return fmt.Sprintf("%s && terraform apply -no-color -input=false %s", initCmd, cr.targetPlan())
 	default:
 		panic(fmt.Sprintf("unsupported check run command: %s", c))
@@ -8,3 +8,7 @@ var (
 
 // Command to be run on behalf of check run
 type checkRunCommand string
+
+// cancelAction is the identifier of the check run action that cancels the
+// current command
+const cancelAction = "cancel"
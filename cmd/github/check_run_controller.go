@@ -2,6 +2,8 @@ package github
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"path/filepath"
 
@@ -9,7 +11,11 @@ import (
 	"github.com/leg100/etok/cmd/github/client"
 	"github.com/leg100/etok/pkg/archive"
 	"github.com/leg100/etok/pkg/builders"
+	"github.com/leg100/etok/pkg/labels"
+	"github.com/leg100/etok/pkg/plan"
+	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -37,15 +43,23 @@ type checkRunReconciler struct {
 	sender
 	streamer
 	stripRefreshing bool
+
+	// Github hostname
+	hostname string
+
+	// Namespace in which apply requirements for repos are set
+	namespace string
 }
 
 // Constructor for run reconciler
-func newCheckRunReconciler(rclient runtimeclient.Client, kclient kubernetes.Interface, sdr sender, stripRefreshing bool) *checkRunReconciler {
+func newCheckRunReconciler(rclient runtimeclient.Client, kclient kubernetes.Interface, sdr sender, hostname, namespace string, stripRefreshing bool) *checkRunReconciler {
 	return &checkRunReconciler{
 		Client:          rclient,
 		sender:          sdr,
 		streamer:        &podStreamer{client: kclient},
 		stripRefreshing: stripRefreshing,
+		hostname:        hostname,
+		namespace:       namespace,
 	}
 }
 
@@ -54,7 +68,7 @@ func newCheckRunReconciler(rclient runtimeclient.Client, kclient kubernetes.Inte
 // +kubebuilder:rbac:groups=etok.dev,resources=checksuites,verbs=get;list;watch
 // +kubebuilder:rbac:groups=etok.dev,resources=workspaces,verbs=get;list;watch
 // +kubebuilder:rbac:groups=etok.dev,resources=runs,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups="",resources=configmaps,verbs=create
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;create
 // +kubebuilder:rbac:groups="",resources=pods/log,verbs=get
 
 func (r *checkRunReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -101,6 +115,14 @@ func (r *checkRunReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		}
 	}
 
+	// Cancel the run if the user has requested its cancellation
+	if !runNotFound && cr.cancelRequested() && !run.Cancel && !run.IsDone() {
+		run.Cancel = true
+		if err := r.Update(ctx, run); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	// Create Run resources / copy its logs. Any error is relayed to github.
 	var logs = make([]byte, 0)
 	var reconcileErr error
@@ -121,6 +143,22 @@ func (r *checkRunReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		}
 	}
 
+	// Retrieve plan produced by a completed plan run
+	var p *plan.Plan
+	if cr.command() == planCmd && !runNotFound && meta.IsStatusConditionTrue(run.Conditions, v1alpha1.RunCompleteCondition) {
+		var err error
+		if p, err = r.getPlan(ctx, run); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Determine which of the workspace's apply requirements the pull does not
+	// meet
+	reqs, err := getApplyRequirements(ctx, r.Client, r.namespace, ws, suite)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
 	// Construct update
 	update := &checkRunUpdate{
 		checkRun:     cr,
@@ -128,6 +166,8 @@ func (r *checkRunReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		ws:           ws,
 		run:          run,
 		logs:         logs,
+		plan:         p,
+		unmet:        unmetApplyRequirements(reqs, suite.Status),
 		reconcileErr: reconcileErr,
 		maxFieldSize: defaultMaxFieldSize,
 	}
@@ -152,7 +192,7 @@ func (r *checkRunReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 
 	// Send update to Github API
 	if send {
-		if err := r.Send(suite.Spec.InstallID, "github.com", update); err != nil {
+		if err := r.Send(suite.Spec.InstallID, r.hostname, update); err != nil {
 			return ctrl.Result{}, err
 		}
 	}
@@ -197,6 +237,21 @@ func (r *checkRunReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return blder.Complete(r)
 }
 
+// getPlan retrieves the changes of the plan saved by a run. Nil is returned if
+// the run did not save a plan, which is the case when the plan failed.
+func (r *checkRunReconciler) getPlan(ctx context.Context, run *v1alpha1.Run) (*plan.Plan, error) {
+	cm := &corev1.ConfigMap{}
+	if err := r.Get(ctx, runtimeclient.ObjectKey{Namespace: run.Namespace, Name: run.PlanConfigMapName()}, cm); err != nil {
+		return nil, runtimeclient.IgnoreNotFound(err)
+	}
+
+	p := &plan.Plan{}
+	if err := json.Unmarshal([]byte(cm.Data[v1alpha1.PlanChangesKey]), p); err != nil {
+		return nil, fmt.Errorf("unable to decode plan changes: %w", err)
+	}
+	return p, nil
+}
+
 // Create Run and ConfigMap resources in k8s
 func (r *checkRunReconciler) createRunResources(ctx context.Context, suite *v1alpha1.CheckSuite, cr *checkRun, ws *v1alpha1.Workspace) error {
 	configMap, err := archive.ConfigMap(cr.Namespace, cr.etokRunName(), filepath.Join(suite.Status.RepoPath, ws.Spec.VCS.WorkingDir), suite.Status.RepoPath)
@@ -209,6 +264,15 @@ func (r *checkRunReconciler) createRunResources(ctx context.Context, suite *v1al
 	for k, v := range checkrunControllerLabels {
 		runBldr = runBldr.SetLabel(k, v)
 	}
+	switch cr.command() {
+	case planCmd:
+		// Persist the plan's changes so they can be reported
+		runBldr = runBldr.SavePlan()
+	case applyCmd:
+		// Identify the run as an apply, for the benefit of the run retention
+		// policy
+		runBldr = runBldr.SetLabel(labels.ApplyScript.Name, labels.ApplyScript.Value)
+	}
 	run := runBldr.Build()
 
 	if err := controllerutil.SetOwnerReference(cr.CheckRun, run, r.Scheme()); err != nil {
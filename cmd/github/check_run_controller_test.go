@@ -10,6 +10,7 @@ import (
 	"github.com/leg100/etok/api/etok.dev/v1alpha1"
 	githubclient "github.com/leg100/etok/cmd/github/client"
 	"github.com/leg100/etok/pkg/builders"
+	"github.com/leg100/etok/pkg/plan"
 	"github.com/leg100/etok/pkg/scheme"
 	"github.com/leg100/etok/pkg/testobj"
 	"github.com/leg100/etok/pkg/testutil"
@@ -48,6 +49,7 @@ func TestCheckRunController(t *testing.T) {
 
 				run := testobj.Run("dev", "12345-0-networks-0", "sh")
 				require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(run), run))
+				assert.True(t, run.SavePlan)
 
 				configMap := testobj.ConfigMap("dev", "12345-0-networks-0")
 				require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(configMap), configMap))
@@ -94,6 +96,19 @@ func TestCheckRunController(t *testing.T) {
 				assert.Equal(t, []byte("fake logs"), u.logs)
 			},
 		},
+		{
+			name: "Cancel requested",
+			cr:   builders.CheckRun().Namespace("dev").Suite(12345, 0).Workspace("networks").ID(123).RequestedAction("cancel").Build(),
+			objs: []runtime.Object{
+				testobj.Workspace("dev", "networks", testobj.WithWorkingDir("networks")),
+				testobj.Run("dev", "12345-0-networks-0", "sh"),
+			},
+			clientAssertions: func(t *testutil.T, c client.Client) {
+				run := testobj.Run("dev", "12345-0-networks-0", "sh")
+				require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(run), run))
+				assert.True(t, run.Cancel)
+			},
+		},
 		{
 			name: "Completed iteration",
 			cr:   builders.CheckRun().Namespace("dev").Suite(12345, 0).Workspace("networks").Build(),
@@ -108,6 +123,35 @@ func TestCheckRunController(t *testing.T) {
 				assert.True(t, cr.Status.Iterations[0].Completed)
 			},
 		},
+		{
+			name: "Completed plan",
+			cr:   builders.CheckRun().Namespace("dev").Suite(12345, 0).Workspace("networks").Build(),
+			objs: []runtime.Object{
+				testobj.Workspace("dev", "networks", testobj.WithWorkingDir("networks")),
+				testobj.Run("dev", "12345-0-networks-0", "sh", testobj.WithCondition(v1alpha1.RunCompleteCondition, v1alpha1.PodSucceededReason)),
+				testobj.ConfigMap("dev", "12345-0-networks-0-plan",
+					testobj.WithSavedPlan("networks", 3, "Plan: 1 to add, 0 to change, 0 to destroy."),
+					testobj.WithPlanChanges(`{"resourceChanges":[{"address":"random_id.test","action":"create"}]}`)),
+			},
+			assertions: func(t *testutil.T, u *checkRunUpdate) {
+				assert.Equal(t, &plan.Plan{
+					ResourceChanges: []plan.ResourceChange{
+						{Address: "random_id.test", Action: plan.Create},
+					},
+				}, u.plan)
+			},
+		},
+		{
+			name: "Completed plan without saved plan",
+			cr:   builders.CheckRun().Namespace("dev").Suite(12345, 0).Workspace("networks").Build(),
+			objs: []runtime.Object{
+				testobj.Workspace("dev", "networks", testobj.WithWorkingDir("networks")),
+				testobj.Run("dev", "12345-0-networks-0", "sh", testobj.WithCondition(v1alpha1.RunCompleteCondition, v1alpha1.PodFailedReason)),
+			},
+			assertions: func(t *testutil.T, u *checkRunUpdate) {
+				assert.Nil(t, u.plan)
+			},
+		},
 	}
 	for _, tt := range tests {
 		testutil.Run(t, tt.name, func(t *testutil.T) {
@@ -128,12 +172,17 @@ func TestCheckRunController(t *testing.T) {
 				Client:   client,
 				sender:   sender,
 				streamer: &fakeStreamer{},
+				hostname: "github.example.com",
 			}
 
 			req := requestFromObject(tt.cr)
 			_, err := reconciler.Reconcile(context.Background(), req)
 			require.NoError(t, err)
 
+			if sender.u != nil {
+				assert.Equal(t, "github.example.com", sender.hostname)
+			}
+
 			if tt.assertions != nil {
 				tt.assertions(t, sender.u)
 			}
@@ -151,11 +200,13 @@ func (s *fakeStreamer) Stream(ctx context.Context, key runtimeclient.ObjectKey)
 }
 
 type fakeSender struct {
-	u *checkRunUpdate
+	u        *checkRunUpdate
+	hostname string
 }
 
-func (s *fakeSender) Send(_ int64, _ string, inv githubclient.Invokable) error {
+func (s *fakeSender) Send(_ int64, hostname string, inv githubclient.Invokable) error {
 	s.u = inv.(*checkRunUpdate)
+	s.hostname = hostname
 
 	return nil
 }
@@ -82,10 +82,53 @@ func TestCheckRun(t *testing.T) {
 	assert.Equal(t, 2, len(cr.CheckRun.Status.Iterations))
 	assert.Equal(t, "12345-networks-1", cr.CheckRun.Status.Iterations[1].Run)
 	assert.False(t, cr.CheckRun.Status.Iterations[1].Completed)
+	assert.False(t, cr.cancelRequested())
+
+	//
+	// Event #4: requested_action=cancel
+	//
+	cr.Status.Events = append(cr.Status.Events, &v1alpha1.CheckRunEvent{
+		RequestedAction: &v1alpha1.CheckRunRequestedActionEvent{Action: "cancel"},
+	})
+
+	// Cancellation does not start a new iteration
+	assert.Equal(t, 1, cr.currentIteration())
+	assert.Equal(t, "12345-networks-1", cr.etokRunName())
+	assert.Equal(t, applyCmd, cr.command())
+	assert.True(t, cr.cancelRequested())
 
 	//
 	// Mimic controller marking current iteration as complete
 	//
 	cr.setIterationStatus(true)
 	assert.True(t, cr.CheckRun.Status.Iterations[1].Completed)
+
+	//
+	// Event #5: comment requesting a plan with additional arguments
+	//
+	cr.Status.Events = append(cr.Status.Events, &v1alpha1.CheckRunEvent{
+		Comment: &v1alpha1.CheckRunCommentEvent{Command: "plan", Args: []string{"-target=module.vpc", "-var=owner='bob'"}},
+	})
+
+	assert.Equal(t, 2, cr.currentIteration())
+	assert.Equal(t, "12345-networks-2", cr.etokRunName())
+	assert.Equal(t, planCmd, cr.command())
+	assert.Equal(t,
+		`terraform init -no-color -input=false && terraform plan -no-color -input=false '-target=module.vpc' '-var=owner='\''bob'\''' -out=/plans/12345-networks-2`,
+		cr.script())
+
+	cr.setIterationStatus(true)
+
+	//
+	// Event #6: comment requesting an apply
+	//
+	cr.Status.Events = append(cr.Status.Events, &v1alpha1.CheckRunEvent{
+		Comment: &v1alpha1.CheckRunCommentEvent{Command: "apply"},
+	})
+
+	assert.Equal(t, 3, cr.currentIteration())
+	assert.Equal(t, applyCmd, cr.command())
+	assert.Equal(t,
+		"terraform init -no-color -input=false && terraform apply -no-color -input=false /plans/12345-networks-2",
+		cr.script())
 }
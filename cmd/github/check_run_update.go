@@ -8,6 +8,7 @@ import (
 
 	"github.com/google/go-github/v31/github"
 	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/pkg/plan"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/klog/v2"
@@ -43,6 +44,13 @@ type checkRunUpdate struct {
 	// Logs are streamed into this byte array
 	logs []byte
 
+	// Plan produced by a completed plan run. Nil if the run did not produce a
+	// plan.
+	plan *plan.Plan
+
+	// Apply requirements the pull does not meet
+	unmet []string
+
 	reconcileErr error
 
 	stripRefreshing bool
@@ -66,19 +74,20 @@ func (u *checkRunUpdate) name() string {
 		return name + "planning"
 	}
 
+	if u.cancelled() {
+		return name + "cancelled"
+	}
+
 	switch u.command() {
 	case planCmd:
 		switch u.status() {
 		case "completed":
 			// Upon completion of a plan, instead of showing 'planned', show
 			// summary of changes
-			plan, err := parsePlanOutput(string(u.logs))
-			if err != nil {
-				// Just fallback to showing 'plan' and log error
-				klog.Errorf("error parsing plan output for %s: %s", u.run, err.Error())
+			if u.plan == nil {
 				name += "plan failed"
 			} else {
-				name += plan.summary()
+				name += shortPlanSummary(u.plan)
 			}
 		default:
 			name += "planning"
@@ -147,14 +156,19 @@ func (u *checkRunUpdate) update(ctx context.Context, client *github.Client) erro
 // Return list of buttons to show on check run UI
 func (u *checkRunUpdate) actions() (actions []*github.CheckRunAction) {
 	if u.status() != "completed" {
+		// Permit cancelling the command, unless already requested
+		if !u.cancelRequested() {
+			actions = append(actions, &github.CheckRunAction{Label: "Cancel", Description: "Cancel " + string(u.command()), Identifier: cancelAction})
+		}
 		return
 	}
 
 	actions = append(actions, &github.CheckRunAction{Label: "Plan", Description: "Re-run plan", Identifier: "plan"})
 
-	// Only show apply button when last command was a plan, and the pull is
-	// mergeable
-	if u.command() == planCmd && u.suite.Status.Mergeable {
+	// Only show apply button when last command was a plan that did not
+	// violate policy, and the pull is mergeable and meets the apply
+	// requirements
+	if u.command() == planCmd && !u.policyFailed() && u.suite.Status.Mergeable && len(u.unmet) == 0 {
 		actions = append(actions, &github.CheckRunAction{Label: "Apply", Description: "Apply plan", Identifier: "apply"})
 	}
 
@@ -198,6 +212,20 @@ func (u *checkRunUpdate) conclusion() *string {
 		return nil
 	}
 
+	if u.policyFailed() {
+		// Plan cannot be applied until it no longer violates policy
+		return github.String("action_required")
+	}
+
+	if failed := meta.FindStatusCondition(u.run.Conditions, v1alpha1.RunFailedCondition); failed != nil && failed.Status == metav1.ConditionTrue {
+		switch failed.Reason {
+		case v1alpha1.RunCancelledReason:
+			return github.String("cancelled")
+		case v1alpha1.MaxDurationExceededReason:
+			return github.String("timed_out")
+		}
+	}
+
 	cond := u.run.Conditions[0]
 	if cond.Type == v1alpha1.RunFailedCondition && cond.Status == metav1.ConditionTrue {
 		if cond.Reason == v1alpha1.RunEnqueueTimeoutReason || cond.Reason == v1alpha1.QueueTimeoutReason {
@@ -231,8 +259,30 @@ func (u *checkRunUpdate) failureMessage() *string {
 	return nil
 }
 
+// cancelled determines whether the run was cancelled, either by the user or
+// because it exceeded its maximum duration
+func (u *checkRunUpdate) cancelled() bool {
+	if u.run == nil {
+		return false
+	}
+	failed := meta.FindStatusCondition(u.run.Conditions, v1alpha1.RunFailedCondition)
+	if failed == nil || failed.Status != metav1.ConditionTrue {
+		return false
+	}
+	return failed.Reason == v1alpha1.RunCancelledReason || failed.Reason == v1alpha1.MaxDurationExceededReason
+}
+
+// policyFailed determines whether the run's plan violates the workspace's
+// policy
+func (u *checkRunUpdate) policyFailed() bool {
+	return u.run != nil && meta.IsStatusConditionTrue(u.run.Conditions, v1alpha1.RunPolicyFailedCondition)
+}
+
 // Provide the 'title' of a check run
 func (u *checkRunUpdate) title() string {
+	if u.plan != nil {
+		return u.plan.Summary()
+	}
 	return u.run.Name
 }
 
@@ -246,7 +296,43 @@ func (u *checkRunUpdate) summary() string {
 		return fmt.Sprintf("%s reconcile error: %s\n", u.Name, u.reconcileErr.Error())
 	}
 
-	return fmt.Sprintf("Note: you can also view logs by running: \n```bash\nkubectl logs -n %s pods/%s\n```", u.Namespace, u.etokRunName())
+	note := fmt.Sprintf("Note: you can also view logs by running: \n```bash\nkubectl logs -n %s pods/%s\n```", u.Namespace, u.etokRunName())
+
+	// Lead with the reason a plan cannot be applied
+	var blocker string
+	if u.policyFailed() {
+		cond := meta.FindStatusCondition(u.run.Conditions, v1alpha1.RunPolicyFailedCondition)
+		blocker = fmt.Sprintf("**Policy check failed**: %s\n\n", cond.Message)
+	} else if u.command() == planCmd && u.status() == "completed" && len(u.unmet) > 0 {
+		blocker = "**Apply requirements not met**:\n"
+		for _, req := range u.unmet {
+			blocker += fmt.Sprintf("* %s\n", req)
+		}
+		blocker += "\n"
+	}
+
+	if u.plan != nil && !u.plan.HasNoChanges() {
+		// Follow with the blast radius of the changes
+		var blastRadius string
+		if u.run.BlastRadius != nil {
+			br, err := generateBlastRadius(u.run.BlastRadius)
+			if err != nil {
+				klog.Errorf("error generating blast radius for %s: %s", u.run, err.Error())
+			} else {
+				blastRadius = br + "\n"
+			}
+		}
+
+		// And then a table of the resources to be changed
+		table, err := generatePlanTable(u.plan, u.maxFieldSize-len(blocker)-len(blastRadius)-len(note)-1)
+		if err != nil {
+			klog.Errorf("error generating plan table for %s: %s", u.run, err.Error())
+			return blocker + blastRadius + note
+		}
+		return blocker + blastRadius + table + "\n" + note
+	}
+
+	return blocker + note
 }
 
 // Populate the 'details' text field of a check run
@@ -256,6 +342,15 @@ func (u *checkRunUpdate) details() *string {
 		return nil
 	}
 
+	if u.plan != nil && !u.plan.HasNoChanges() {
+		// Show each resource's changes in place of the logs
+		diffs, err := generatePlanDiffs(u.plan, u.maxFieldSize)
+		if err == nil {
+			return github.String(diffs)
+		}
+		klog.Errorf("error generating plan diffs for %s: %s", u.run, err.Error())
+	}
+
 	if len(u.logs) == 0 {
 		return nil
 	}
@@ -2,11 +2,13 @@ package github
 
 import (
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/google/go-github/v31/github"
 	"github.com/leg100/etok/api/etok.dev/v1alpha1"
 	"github.com/leg100/etok/pkg/builders"
+	"github.com/leg100/etok/pkg/plan"
 	"github.com/leg100/etok/pkg/testobj"
 	"github.com/leg100/etok/pkg/testutil"
 	"github.com/stretchr/testify/assert"
@@ -79,6 +81,21 @@ line 3
 		t.Override(&u.run,
 			testobj.Run("dev", "12345-0-networks-0", "plan", testobj.WithCondition(v1alpha1.RunCompleteCondition)))
 		t.Override(&u.logs, t.ReadFile("fixtures/plan.txt"))
+		t.Override(&u.plan, &plan.Plan{
+			ResourceChanges: []plan.ResourceChange{
+				{
+					Address:    "module.random.random_id.test",
+					Action:     plan.Replace,
+					Attributes: []plan.AttributeChange{{Name: "byte_length", Before: "2", After: "4"}},
+				},
+				{
+					Address:    "null_resource.example",
+					Action:     plan.Create,
+					Sensitive:  true,
+					Attributes: []plan.AttributeChange{{Name: "triggers", After: "(sensitive)", Sensitive: true}},
+				},
+			},
+		})
 		t.Override(&u.Status.Events, []*v1alpha1.CheckRunEvent{
 			{
 				Created: &v1alpha1.CheckRunCreatedEvent{ID: 987},
@@ -87,7 +104,10 @@ line 3
 
 		assert.Equal(t, "completed", u.status())
 		assert.Equal(t, "success", *u.conclusion())
-		assert.Equal(t, "dev/networks | +2/~0/−0", u.name())
+		assert.Equal(t, "dev/networks | +2/~0/−1", u.name())
+		assert.Equal(t, "Plan: 2 to add, 0 to change, 1 to destroy.", u.title())
+		assert.Equal(t, string(t.ReadFile("fixtures/want_plan_summary.md")), u.summary())
+		assert.Equal(t, string(t.ReadFile("fixtures/want_plan_details.md")), *u.details())
 		assert.Equal(t, []*github.CheckRunAction{
 			{Label: "Plan", Description: "Re-run plan", Identifier: "plan"},
 		}, u.actions())
@@ -99,6 +119,98 @@ line 3
 		})
 	})
 
+	testutil.Run(t, "plan does not meet apply requirements", func(t *testutil.T) {
+		t.Override(&u.run,
+			testobj.Run("dev", "12345-0-networks-0", "plan", testobj.WithCondition(v1alpha1.RunCompleteCondition, v1alpha1.PodSucceededReason)))
+		t.Override(&u.suite.Status.Mergeable, true)
+		t.Override(&u.unmet, []string{"1 of 2 required approving reviews", "failing checks: lint"})
+		t.Override(&u.Status.Events, []*v1alpha1.CheckRunEvent{
+			{
+				Created: &v1alpha1.CheckRunCreatedEvent{ID: 987},
+			},
+		})
+
+		assert.Equal(t, "success", *u.conclusion())
+		assert.True(t, strings.HasPrefix(u.summary(), "**Apply requirements not met**:\n* 1 of 2 required approving reviews\n* failing checks: lint\n\n"))
+		// Apply button should not be visible even though pull is mergeable
+		assert.Equal(t, []*github.CheckRunAction{
+			{Label: "Plan", Description: "Re-run plan", Identifier: "plan"},
+		}, u.actions())
+	})
+
+	testutil.Run(t, "plan violates policy", func(t *testutil.T) {
+		t.Override(&u.run,
+			testobj.Run("dev", "12345-0-networks-0", "plan",
+				testobj.WithCondition(v1alpha1.RunCompleteCondition, v1alpha1.PodSucceededReason),
+				testobj.WithCondition(v1alpha1.RunPolicyFailedCondition, v1alpha1.PolicyViolationReason, "protect-databases: deletion of aws_db_instance.main is forbidden")))
+		t.Override(&u.suite.Status.Mergeable, true)
+		t.Override(&u.Status.Events, []*v1alpha1.CheckRunEvent{
+			{
+				Created: &v1alpha1.CheckRunCreatedEvent{ID: 987},
+			},
+		})
+
+		assert.Equal(t, "action_required", *u.conclusion())
+		assert.True(t, strings.HasPrefix(u.summary(), "**Policy check failed**: protect-databases: deletion of aws_db_instance.main is forbidden\n\n"))
+		// Apply button should not be visible even though pull is mergeable
+		assert.Equal(t, []*github.CheckRunAction{
+			{Label: "Plan", Description: "Re-run plan", Identifier: "plan"},
+		}, u.actions())
+	})
+
+	testutil.Run(t, "plan with blast radius", func(t *testutil.T) {
+		t.Override(&u.run,
+			testobj.Run("dev", "12345-0-networks-0", "plan",
+				testobj.WithCondition(v1alpha1.RunCompleteCondition, v1alpha1.PodSucceededReason),
+				testobj.WithBlastRadius(&v1alpha1.BlastRadius{
+					Providers: map[string]int{"registry.terraform.io/hashicorp/random": 1, "registry.terraform.io/hashicorp/null": 1},
+					Modules:   map[string]int{"root": 1, "module.random": 1},
+					Critical:  []string{"module.random.random_id.test"},
+				})))
+		t.Override(&u.plan, &plan.Plan{
+			ResourceChanges: []plan.ResourceChange{
+				{Address: "module.random.random_id.test", Action: plan.Replace},
+				{Address: "null_resource.example", Action: plan.Create},
+			},
+		})
+		t.Override(&u.Status.Events, []*v1alpha1.CheckRunEvent{
+			{
+				Created: &v1alpha1.CheckRunCreatedEvent{ID: 987},
+			},
+		})
+
+		assert.True(t, strings.HasPrefix(u.summary(), `**Blast radius**
+
+:warning: Critical resource `+"`module.random.random_id.test`"+` is to be replaced or deleted
+
+| Provider | Resources |
+| --- | --- |
+| `+"`registry.terraform.io/hashicorp/null`"+` | 1 |
+| `+"`registry.terraform.io/hashicorp/random`"+` | 1 |
+
+| Module | Resources |
+| --- | --- |
+| `+"`module.random`"+` | 1 |
+| `+"`root`"+` | 1 |
+
+| Resource | Action | Sensitive |
+`))
+	})
+
+	testutil.Run(t, "failed plan", func(t *testutil.T) {
+		t.Override(&u.run,
+			testobj.Run("dev", "12345-0-networks-0", "plan", testobj.WithCondition(v1alpha1.RunCompleteCondition, v1alpha1.PodFailedReason)))
+		t.Override(&u.Status.Events, []*v1alpha1.CheckRunEvent{
+			{
+				Created: &v1alpha1.CheckRunCreatedEvent{ID: 987},
+			},
+		})
+
+		assert.Equal(t, "failure", *u.conclusion())
+		assert.Equal(t, "dev/networks | plan failed", u.name())
+		assert.Equal(t, "12345-0-networks-0", u.title())
+	})
+
 	testutil.Run(t, "incomplete apply", func(t *testutil.T) {
 		// Run w/o Completed Condition
 		t.Override(&u.run, testobj.Run("dev", "12345-0-networks-0", "apply"))
@@ -113,10 +225,52 @@ line 3
 			},
 		})
 
+		assert.Equal(t, "dev/networks | applying", u.name())
+		assert.Equal(t, []*github.CheckRunAction{
+			{Label: "Cancel", Description: "Cancel apply", Identifier: "cancel"},
+		}, u.actions())
+	})
+
+	testutil.Run(t, "cancellation requested", func(t *testutil.T) {
+		t.Override(&u.run, testobj.Run("dev", "12345-0-networks-0", "apply"))
+		t.Override(&u.Status.Events, []*v1alpha1.CheckRunEvent{
+			{
+				Created: &v1alpha1.CheckRunCreatedEvent{ID: 987},
+			},
+			{
+				RequestedAction: &v1alpha1.CheckRunRequestedActionEvent{Action: "apply"},
+			},
+			{
+				RequestedAction: &v1alpha1.CheckRunRequestedActionEvent{Action: "cancel"},
+			},
+		})
+
 		assert.Equal(t, "dev/networks | applying", u.name())
 		assert.Empty(t, u.actions())
 	})
 
+	testutil.Run(t, "cancelled apply", func(t *testutil.T) {
+		t.Override(&u.run,
+			testobj.Run("dev", "12345-0-networks-0", "apply",
+				testobj.WithCondition(v1alpha1.RunCompleteCondition, v1alpha1.PodFailedReason),
+				testobj.WithCondition(v1alpha1.RunFailedCondition, v1alpha1.RunCancelledReason, "Run cancelled")))
+		t.Override(&u.Status.Events, []*v1alpha1.CheckRunEvent{
+			{
+				Created: &v1alpha1.CheckRunCreatedEvent{ID: 987},
+			},
+			{
+				RequestedAction: &v1alpha1.CheckRunRequestedActionEvent{Action: "apply"},
+			},
+			{
+				RequestedAction: &v1alpha1.CheckRunRequestedActionEvent{Action: "cancel"},
+			},
+		})
+
+		assert.Equal(t, "completed", u.status())
+		assert.Equal(t, "cancelled", *u.conclusion())
+		assert.Equal(t, "dev/networks | cancelled", u.name())
+	})
+
 	testutil.Run(t, "successfully completed apply", func(t *testutil.T) {
 		t.Override(&u.run,
 			testobj.Run("dev", "12345-0-networks-0", "apply", testobj.WithCondition(v1alpha1.RunCompleteCondition)))
@@ -2,12 +2,14 @@ package github
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/leg100/etok/api/etok.dev/v1alpha1"
 	"github.com/leg100/etok/pkg/builders"
 	"github.com/leg100/etok/pkg/scheme"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
@@ -20,14 +22,22 @@ type checkSuiteReconciler struct {
 	Scheme *runtime.Scheme
 	runtimeclient.Client
 	*repoManager
+
+	// getter retrieves github clients for listing the files changed by pulls
+	getter clientGetter
+
+	// Github hostname
+	hostname string
 }
 
 // Constructor for run reconciler
-func newCheckSuiteReconciler(client runtimeclient.Client, provider tokenProvider, cloneDir string) *checkSuiteReconciler {
+func newCheckSuiteReconciler(client runtimeclient.Client, provider tokenProvider, getter clientGetter, cloneDir, hostname string) *checkSuiteReconciler {
 	return &checkSuiteReconciler{
 		Scheme:      scheme.Scheme,
 		Client:      client,
-		repoManager: newRepoManager(cloneDir, provider),
+		repoManager: newRepoManager(cloneDir, provider, hostname),
+		getter:      getter,
+		hostname:    hostname,
 	}
 }
 
@@ -58,7 +68,7 @@ func (r *checkSuiteReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, err
 	}
 	for _, ws := range workspaces.Items {
-		if ws.Spec.VCS.Repository == suite.Spec.CloneURL {
+		if isConnected(&ws, suite) {
 			connected.Items = append(connected.Items, ws)
 		}
 	}
@@ -84,19 +94,19 @@ func (r *checkSuiteReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, err
 	}
 
-	// Ensure there is a CheckRun for each connected workspace
-	for _, ws := range connected.Items {
-		check := builders.CheckRun().
-			Namespace(ws.Namespace).
-			Suite(suite.Spec.ID, suite.Spec.Rerequests).
-			Workspace(ws.Name).
-			Build()
+	triggered, err := r.triggered(ctx, suite, repo.path, connected.Items)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
 
-		if err := controllerutil.SetOwnerReference(suite, check, r.Scheme); err != nil {
+	// Ensure there is a CheckRun for each triggered workspace
+	for _, ws := range triggered {
+		check, err := newCheckRun(suite, &ws)
+		if err != nil {
 			return ctrl.Result{}, err
 		}
 
-		err := r.Client.Get(ctx, client.ObjectKeyFromObject(check), check)
+		err = r.Client.Get(ctx, client.ObjectKeyFromObject(check), check)
 		if kerrors.IsNotFound(err) {
 			if err := r.Client.Create(ctx, check); err != nil {
 				return ctrl.Result{}, err
@@ -109,6 +119,55 @@ func (r *checkSuiteReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	return ctrl.Result{}, r.Status().Update(ctx, suite)
 }
 
+// newCheckRun constructs a CheckRun for a workspace, belonging to the suite's
+// current re-request
+func newCheckRun(suite *v1alpha1.CheckSuite, ws *v1alpha1.Workspace) (*v1alpha1.CheckRun, error) {
+	check := builders.CheckRun().
+		Namespace(ws.Namespace).
+		Suite(suite.Spec.ID, suite.Spec.Rerequests).
+		Workspace(ws.Name).
+		Build()
+
+	if err := controllerutil.SetOwnerReference(suite, check, scheme.Scheme); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+// triggered filters connected workspaces, returning those whose working
+// directory or local modules are changed by the suite's pull. If the pull is
+// unknown then all connected workspaces are returned.
+func (r *checkSuiteReconciler) triggered(ctx context.Context, suite *v1alpha1.CheckSuite, repoPath string, connected []v1alpha1.Workspace) ([]v1alpha1.Workspace, error) {
+	if suite.Spec.PullNumber == 0 {
+		return connected, nil
+	}
+
+	gclient, err := r.getter.Get(suite.Spec.InstallID, r.hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	changed, err := listChangedFiles(ctx, gclient.PullRequests, suite.Spec.Owner, suite.Spec.Repo, suite.Spec.PullNumber)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list files changed by pull: %w", err)
+	}
+
+	var triggered []v1alpha1.Workspace
+	for _, ws := range connected {
+		ok, err := isTriggered(&ws, repoPath, changed)
+		if err != nil {
+			// Err on the side of caution and plan the workspace
+			log.FromContext(ctx).Error(err, "unable to determine workspace's modules", "workspace", klog.KObj(&ws))
+			ok = true
+		}
+		if ok {
+			triggered = append(triggered, ws)
+		}
+	}
+
+	return triggered, nil
+}
+
 func (r *checkSuiteReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	blder := ctrl.NewControllerManagedBy(mgr)
 
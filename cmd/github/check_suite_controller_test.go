@@ -2,9 +2,18 @@ package github
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
 	"testing"
 
+	"github.com/google/go-github/v31/github"
 	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	githubclient "github.com/leg100/etok/cmd/github/client"
+	clientfixtures "github.com/leg100/etok/cmd/github/client/fixtures"
 	"github.com/leg100/etok/pkg/builders"
 	"github.com/leg100/etok/pkg/scheme"
 	"github.com/leg100/etok/pkg/testobj"
@@ -16,9 +25,11 @@ import (
 
 func TestCheckSuiteController(t *testing.T) {
 	tests := []struct {
-		name               string
-		suite              *v1alpha1.CheckSuite
-		workspaces         []*v1alpha1.Workspace
+		name       string
+		suite      *v1alpha1.CheckSuite
+		workspaces []*v1alpha1.Workspace
+		// Files changed by the suite's pull
+		changed            []string
 		suiteAssertions    func(*testutil.T, *v1alpha1.CheckSuite)
 		checkRunAssertions func(*testutil.T, *v1alpha1.CheckRunList)
 	}{
@@ -36,6 +47,65 @@ func TestCheckSuiteController(t *testing.T) {
 				assert.Equal(t, 2, len(checkRuns.Items))
 			},
 		},
+		{
+			name:  "Pull changes working directory",
+			suite: builders.CheckSuite(12345).Pull(1, "master").DefaultBranch("master").Build(),
+			workspaces: []*v1alpha1.Workspace{
+				testobj.Workspace("dev", "subdir", testobj.WithWorkingDir("subdir")),
+				testobj.Workspace("dev", "subdir2", testobj.WithWorkingDir("subdir2")),
+			},
+			changed: []string{"subdir/main.tf"},
+			checkRunAssertions: func(t *testutil.T, checkRuns *v1alpha1.CheckRunList) {
+				assert.Equal(t, []string{"subdir"}, checkRunWorkspaces(checkRuns))
+			},
+		},
+		{
+			name:  "Pull changes local module",
+			suite: builders.CheckSuite(12345).Pull(1, "master").DefaultBranch("master").Build(),
+			workspaces: []*v1alpha1.Workspace{
+				testobj.Workspace("dev", "subdir", testobj.WithWorkingDir("subdir")),
+				testobj.Workspace("dev", "subdir2", testobj.WithWorkingDir("subdir2")),
+			},
+			changed: []string{"modules/suffix/main.tf"},
+			checkRunAssertions: func(t *testutil.T, checkRuns *v1alpha1.CheckRunList) {
+				assert.Equal(t, []string{"subdir2"}, checkRunWorkspaces(checkRuns))
+			},
+		},
+		{
+			name:  "Pull changes root of repo",
+			suite: builders.CheckSuite(12345).Pull(1, "master").DefaultBranch("master").Build(),
+			workspaces: []*v1alpha1.Workspace{
+				testobj.Workspace("dev", "root"),
+				testobj.Workspace("dev", "subdir", testobj.WithWorkingDir("subdir")),
+			},
+			changed: []string{"main.tf"},
+			checkRunAssertions: func(t *testutil.T, checkRuns *v1alpha1.CheckRunList) {
+				assert.Equal(t, []string{"root"}, checkRunWorkspaces(checkRuns))
+			},
+		},
+		{
+			name:  "Pull merges into different branch",
+			suite: builders.CheckSuite(12345).Pull(1, "master").DefaultBranch("master").Build(),
+			workspaces: []*v1alpha1.Workspace{
+				testobj.Workspace("dev", "develop", testobj.WithBranch("develop")),
+				testobj.Workspace("dev", "master", testobj.WithBranch("master")),
+				testobj.Workspace("dev", "default"),
+			},
+			changed: []string{"main.tf"},
+			checkRunAssertions: func(t *testutil.T, checkRuns *v1alpha1.CheckRunList) {
+				assert.Equal(t, []string{"default", "master"}, checkRunWorkspaces(checkRuns))
+			},
+		},
+		{
+			name:  "Repository URL without .git suffix",
+			suite: builders.CheckSuite(12345).Build(),
+			workspaces: []*v1alpha1.Workspace{
+				testobj.Workspace("dev", "networks", testobj.WithRepository("file://{{repo}}")),
+			},
+			checkRunAssertions: func(t *testutil.T, checkRuns *v1alpha1.CheckRunList) {
+				assert.Equal(t, 1, len(checkRuns.Items))
+			},
+		},
 	}
 	for _, tt := range tests {
 		testutil.Run(t, tt.name, func(t *testutil.T) {
@@ -52,12 +122,18 @@ func TestCheckSuiteController(t *testing.T) {
 
 			bldr := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(tt.suite)
 			for _, ws := range tt.workspaces {
-				ws.Spec.VCS.Repository = "file://" + repo
+				if ws.Spec.VCS.Repository == "" {
+					ws.Spec.VCS.Repository = "file://" + repo
+				} else {
+					ws.Spec.VCS.Repository = strings.Replace(ws.Spec.VCS.Repository, "{{repo}}", strings.TrimSuffix(repo, ".git"), 1)
+				}
 				bldr = bldr.WithObjects(ws)
 			}
 
+			getter := newFakePullFilesGetter(t, tt.changed)
+
 			cloneDir := t.NewTempDir().Root()
-			reconciler := newCheckSuiteReconciler(bldr.Build(), &fakeTokenProvider{}, cloneDir)
+			reconciler := newCheckSuiteReconciler(bldr.Build(), &fakeTokenProvider{}, getter, cloneDir, "github.com")
 
 			req := requestFromObject(tt.suite)
 			_, err := reconciler.Reconcile(context.Background(), req)
@@ -76,3 +152,90 @@ func TestCheckSuiteController(t *testing.T) {
 		})
 	}
 }
+
+// TestCheckSuiteControllerEnterprise tests the controller against a GitHub
+// Enterprise Server, from which it obtains an access token for cloning the
+// repo, and lists the files changed by the suite's pull
+func TestCheckSuiteControllerEnterprise(t *testing.T) {
+	testutil.DisableSSLVerification(t)
+
+	server := clientfixtures.NewEnterpriseServer()
+	server.ChangedFiles = []string{"subdir/main.tf"}
+	defer server.Close()
+
+	key := testutil.TempFile(t, "", []byte(clientfixtures.GithubPrivateKey))
+	mgr, err := githubclient.NewManager(key, 123)
+	require.NoError(t, err)
+
+	repo, sha := initializeRepo(&testutil.T{T: t}, "fixtures/repo")
+
+	suite := builders.CheckSuite(12345).Pull(1, "master").DefaultBranch("master").Build()
+	suite.Spec.CloneURL = "file://" + repo
+	suite.Spec.SHA = sha
+	suite.Spec.Branch = "changes"
+	suite.Spec.Owner = "bob"
+	suite.Spec.Repo = "myrepo"
+	suite.Spec.InstallID = 456
+
+	client := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+		suite,
+		testobj.Workspace("dev", "subdir", testobj.WithRepository("file://"+repo), testobj.WithWorkingDir("subdir")),
+		testobj.Workspace("dev", "subdir2", testobj.WithRepository("file://"+repo), testobj.WithWorkingDir("subdir2")),
+	).Build()
+
+	cloneDir := testutil.NewTempDir(t).Root()
+	reconciler := newCheckSuiteReconciler(client, mgr, mgr, cloneDir, server.Hostname())
+
+	_, err = reconciler.Reconcile(context.Background(), requestFromObject(suite))
+	require.NoError(t, err)
+
+	checkRuns := &v1alpha1.CheckRunList{}
+	require.NoError(t, reconciler.List(context.Background(), checkRuns))
+	assert.Equal(t, []string{"subdir"}, checkRunWorkspaces(checkRuns))
+
+	assert.Contains(t, server.Paths(), "/api/v3/app/installations/456/access_tokens")
+	assert.Contains(t, server.Paths(), "/api/v3/repos/bob/myrepo/pulls/1/files")
+}
+
+// checkRunWorkspaces returns the sorted names of the workspaces of check runs
+func checkRunWorkspaces(checkRuns *v1alpha1.CheckRunList) []string {
+	var workspaces []string
+	for _, cr := range checkRuns.Items {
+		workspaces = append(workspaces, cr.Spec.Workspace)
+	}
+	sort.Strings(workspaces)
+	return workspaces
+}
+
+// fakePullFilesGetter provides clients for a fake GitHub API that lists the
+// same changed files for any pull
+type fakePullFilesGetter struct {
+	url *url.URL
+}
+
+func newFakePullFilesGetter(t *testutil.T, changed []string) *fakePullFilesGetter {
+	files := []*github.CommitFile{}
+	for _, f := range changed {
+		files = append(files, &github.CommitFile{Filename: github.String(f)})
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/repos/bob/myrepo/pulls/") || !strings.HasSuffix(r.URL.Path, "/files") {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(files)
+	}))
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL + "/")
+	require.NoError(t, err)
+
+	return &fakePullFilesGetter{url: u}
+}
+
+func (g *fakePullFilesGetter) Get(_ int64, _ string) (*github.Client, error) {
+	client := github.NewClient(nil)
+	client.BaseURL = g.url
+	return client, nil
+}
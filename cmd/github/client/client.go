@@ -29,7 +29,9 @@ func NewInstall(hostname string, appID int64, installID int64, key []byte) (*git
 // Returns an http.RoundTripper implementation that provides Github Apps
 // authentication as an installation
 func newTransport(hostname string, appID int64, installID int64, key []byte) (*ghinstallation.Transport, error) {
-	transport, err := ghinstallation.New(http.DefaultTransport, appID, installID, key)
+	base := &instrumentedTransport{base: http.DefaultTransport, installID: installID}
+
+	transport, err := ghinstallation.New(base, appID, installID, key)
 	if err != nil {
 		return nil, err
 	}
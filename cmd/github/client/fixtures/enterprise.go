@@ -0,0 +1,76 @@
+package fixtures
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sync"
+)
+
+var (
+	accessTokensPath = regexp.MustCompile(`^/api/v3/app/installations/\d+/access_tokens$`)
+	pullFilesPath    = regexp.MustCompile(`^/api/v3/repos/[\w-]+/[\w-]+/pulls/\d+/files$`)
+)
+
+// EnterpriseServer is an httptest stub of a GitHub Enterprise Server API,
+// served beneath /api/v3. It issues installation access tokens and lists the
+// files changed by pulls. It records the path of every request it receives.
+type EnterpriseServer struct {
+	*httptest.Server
+
+	// Files changed by any pull
+	ChangedFiles []string
+
+	mu    sync.Mutex
+	paths []string
+}
+
+// NewEnterpriseServer starts a TLS server. Clients must skip verification of
+// its certificate.
+func NewEnterpriseServer() *EnterpriseServer {
+	s := &EnterpriseServer{}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Hostname returns the hostname, including port, of the server
+func (s *EnterpriseServer) Hostname() string {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		panic(err.Error())
+	}
+	return u.Host
+}
+
+// Paths returns the paths of the requests received thus far
+func (s *EnterpriseServer) Paths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.paths...)
+}
+
+func (s *EnterpriseServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.paths = append(s.paths, r.URL.Path)
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case r.Method == "POST" && accessTokensPath.MatchString(r.URL.Path):
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, GithubAppTokenJSON)
+	case r.Method == "GET" && pullFilesPath.MatchString(r.URL.Path):
+		files := []map[string]string{}
+		for _, f := range s.ChangedFiles {
+			files = append(files, map[string]string{"filename": f})
+		}
+		json.NewEncoder(w).Encode(files)
+	default:
+		http.NotFound(w, r)
+	}
+}
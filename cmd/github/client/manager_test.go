@@ -2,9 +2,6 @@ package client
 
 import (
 	"context"
-	"net/http"
-	"net/http/httptest"
-	"net/url"
 	"testing"
 
 	"github.com/leg100/etok/cmd/github/client/fixtures"
@@ -17,27 +14,18 @@ func TestManager(t *testing.T) {
 	t.Run("access token", func(t *testing.T) {
 		testutil.DisableSSLVerification(t)
 
-		server := httptest.NewTLSServer(
-			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				switch r.RequestURI {
-				case "/api/v3/app/installations/123/access_tokens":
-					w.Write([]byte(fixtures.GithubAppTokenJSON))
-				default:
-					t.Errorf("got unexpected request at %q", r.RequestURI)
-					http.Error(w, "not found", http.StatusNotFound)
-				}
-			}))
-
-		url, err := url.Parse(server.URL)
-		require.NoError(t, err)
+		server := fixtures.NewEnterpriseServer()
+		defer server.Close()
+
 		key := testutil.TempFile(t, "", []byte(fixtures.GithubPrivateKey))
 
 		mgr, err := NewManager(key, 123)
 		require.NoError(t, err)
 
-		token, err := mgr.Token(context.Background(), 123, url.Host)
+		token, err := mgr.Token(context.Background(), 123, server.Hostname())
 		require.NoError(t, err)
 		assert.NotEmpty(t, token)
+		assert.Equal(t, []string{"/api/v3/app/installations/123/access_tokens"}, server.Paths())
 	})
 
 	t.Run("cache", func(t *testing.T) {
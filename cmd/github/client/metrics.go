@@ -0,0 +1,51 @@
+package client
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	apiCallsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "etok",
+		Subsystem: "github",
+		Name:      "api_calls_total",
+		Help:      "Number of calls made to the Github API, by method and response code.",
+	}, []string{"method", "code"})
+
+	rateLimitRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "etok",
+		Subsystem: "github",
+		Name:      "rate_limit_remaining",
+		Help:      "Number of requests remaining in the current rate limit window, by installation.",
+	}, []string{"installation"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(apiCallsTotal, rateLimitRemaining)
+}
+
+// instrumentedTransport is an http.RoundTripper that records metrics for calls
+// made to the Github API on behalf of an installation
+type instrumentedTransport struct {
+	base      http.RoundTripper
+	installID int64
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		apiCallsTotal.WithLabelValues(req.Method, "error").Inc()
+		return nil, err
+	}
+	apiCallsTotal.WithLabelValues(req.Method, strconv.Itoa(resp.StatusCode)).Inc()
+
+	if remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+		rateLimitRemaining.WithLabelValues(strconv.FormatInt(t.installID, 10)).Set(float64(remaining))
+	}
+
+	return resp, nil
+}
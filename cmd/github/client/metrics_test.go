@@ -0,0 +1,29 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumentedTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	calls := testutil.ToFloat64(apiCallsTotal.WithLabelValues("POST", "201"))
+
+	client := &http.Client{Transport: &instrumentedTransport{base: http.DefaultTransport, installID: 456}}
+	resp, err := client.Post(server.URL, "application/json", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, calls+1, testutil.ToFloat64(apiCallsTotal.WithLabelValues("POST", "201")))
+	assert.Equal(t, float64(4999), testutil.ToFloat64(rateLimitRemaining.WithLabelValues("456")))
+}
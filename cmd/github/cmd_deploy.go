@@ -97,6 +97,7 @@ func deployCmd(f *cmdutil.Factory) (*cobra.Command, *deployOptions) {
 
 			// Deploy webhook k8s resources
 			o.deployer.namespace = o.namespace
+			o.deployer.hostname = o.githubHostname
 			o.deployer.port = defaultWebhookPort
 			o.deployer.timeout = 60 * time.Second
 			o.deployer.interval = 1 * time.Second
@@ -6,6 +6,7 @@ import (
 	"golang.org/x/sync/errgroup"
 	ctrl "sigs.k8s.io/controller-runtime"
 
+	"github.com/leg100/etok/cmd/flags"
 	githubclient "github.com/leg100/etok/cmd/github/client"
 	cmdutil "github.com/leg100/etok/cmd/util"
 	"github.com/leg100/etok/pkg/scheme"
@@ -28,11 +29,11 @@ type runOptions struct {
 	// Github app ID
 	appID int64
 
-	// Github hostname
-	githubHostname string
-
 	// Path to github app private key
 	keyPath string
+
+	// Namespace in which the app is deployed
+	namespace string
 }
 
 // runCmd creates a cobra command for running github app
@@ -76,7 +77,9 @@ func runCmd(f *cmdutil.Factory) (*cobra.Command, *runOptions) {
 			if err := newCheckSuiteReconciler(
 				mgr.GetClient(),
 				gmgr,
+				gmgr,
 				o.cloneDir,
+				o.hostname,
 			).SetupWithManager(mgr); err != nil {
 				return fmt.Errorf("unable to create check suite controller: %w", err)
 			}
@@ -85,13 +88,15 @@ func runCmd(f *cmdutil.Factory) (*cobra.Command, *runOptions) {
 				mgr.GetClient(),
 				kclient.KubeClient,
 				gmgr,
+				o.hostname,
+				o.namespace,
 				o.stripRefreshing,
 			).SetupWithManager(mgr); err != nil {
 				return fmt.Errorf("unable to create check run controller: %w", err)
 			}
 
 			// Configure webhook server to forward events to the github app
-			o.webhookServer.app = newApp(client.RuntimeClient)
+			o.webhookServer.app = newApp(client.RuntimeClient, o.appID, o.namespace)
 			o.webhookServer.getter = gmgr
 
 			// Ensure webhook server is properly constructed since we're not
@@ -116,10 +121,13 @@ func runCmd(f *cmdutil.Factory) (*cobra.Command, *runOptions) {
 		},
 	}
 
-	cmd.Flags().StringVar(&o.githubHostname, "hostname", "github.com", "Github hostname")
+	cmd.Flags().StringVar(&o.hostname, "hostname", "github.com", "Github hostname")
 	cmd.Flags().Int64Var(&o.appID, "app-id", 0, "Github app ID")
 	cmd.Flags().StringVar(&o.keyPath, "key-path", "", "Github app private key path")
 
+	// The namespace in which the apply requirements for repos are set
+	flags.AddNamespaceFlag(cmd, &o.namespace)
+
 	cmd.Flags().IntVar(&o.port, "port", defaultWebhookPort, "Webhook port")
 	cmd.Flags().StringVar(&o.webhookSecret, "webhook-secret", "", "Github app webhook secret")
 
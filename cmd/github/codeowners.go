@@ -0,0 +1,52 @@
+package github
+
+import (
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// Paths at which github looks for a CODEOWNERS file, in order of precedence:
+//
+// https://docs.github.com/en/github/creating-cloning-and-archiving-repositories/about-code-owners
+var codeOwnersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// codeOwners are the rules of a CODEOWNERS file, each assigning owners to the
+// paths matching a gitignore-style pattern
+type codeOwners []codeOwnersRule
+
+type codeOwnersRule struct {
+	pattern gitignore.Pattern
+	owners  []string
+}
+
+func parseCodeOwners(content string) codeOwners {
+	var rules codeOwners
+	for _, line := range strings.Split(content, "\n") {
+		// Strip comments
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		rules = append(rules, codeOwnersRule{
+			pattern: gitignore.ParsePattern(fields[0], nil),
+			owners:  fields[1:],
+		})
+	}
+	return rules
+}
+
+// owners returns the owners of a file. The last rule matching the file takes
+// precedence. A matching rule without owners leaves the file without owners.
+func (c codeOwners) owners(path string) []string {
+	parts := strings.Split(path, "/")
+	for i := len(c) - 1; i >= 0; i-- {
+		if c[i].pattern.Match(parts, false) == gitignore.Exclude {
+			return c[i].owners
+		}
+	}
+	return nil
+}
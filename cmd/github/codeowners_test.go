@@ -0,0 +1,34 @@
+package github
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodeOwners(t *testing.T) {
+	rules := parseCodeOwners(`# Default owners
+*       @acme/platform
+
+*.md    @alice # docs
+
+/networks/        @bob @acme/networking
+modules/**/db     @carol
+/networks/README.md
+`)
+
+	tests := []struct {
+		path   string
+		owners []string
+	}{
+		{"main.tf", []string{"@acme/platform"}},
+		{"README.md", []string{"@alice"}},
+		{"networks/main.tf", []string{"@bob", "@acme/networking"}},
+		{"networks/vpc/main.tf", []string{"@bob", "@acme/networking"}},
+		{"modules/aws/db/main.tf", []string{"@carol"}},
+		{"networks/README.md", []string{}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.owners, rules.owners(tt.path), tt.path)
+	}
+}
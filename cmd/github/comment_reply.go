@@ -0,0 +1,44 @@
+package github
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v31/github"
+)
+
+// Reactions with which a comment command is acknowledged
+const (
+	reactionAccepted = "+1"
+	reactionDenied   = "-1"
+	reactionInvalid  = "confused"
+)
+
+// commentReply acknowledges a comment command with a reaction and replies to
+// it with a comment summarising the outcome
+type commentReply struct {
+	issues    issuesClient
+	reactions reactionsClient
+
+	owner, repo string
+
+	// Number of the pull on which the comment was made
+	number int
+
+	// ID of the comment containing the command
+	commentID int64
+}
+
+// send the reaction and reply, returning the reply as the result of handling
+// the comment
+func (r *commentReply) send(ctx context.Context, reaction, body string) (string, error) {
+	if _, _, err := r.reactions.CreateIssueCommentReaction(ctx, r.owner, r.repo, r.commentID, reaction); err != nil {
+		return "", fmt.Errorf("unable to react to comment: %w", err)
+	}
+
+	if _, _, err := r.issues.CreateComment(ctx, r.owner, r.repo, r.number, &github.IssueComment{Body: github.String(body)}); err != nil {
+		return "", fmt.Errorf("unable to reply to comment: %w", err)
+	}
+
+	return fmt.Sprintf("replied to comment: %s", body), nil
+}
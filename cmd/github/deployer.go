@@ -21,6 +21,9 @@ type deployer struct {
 
 	image string
 
+	// Github hostname for the webhook server
+	hostname string
+
 	// Toggle only installing CRDs
 	crdsOnly bool
 
@@ -77,6 +80,18 @@ func (d *deployer) deploy(ctx context.Context, client runtimeclient.Client) erro
 				panic(err.Error())
 			}
 
+			// Set github hostname
+			if d.hostname != "" {
+				env, _, err := unstructured.NestedSlice(containers[0].(map[string]interface{}), "env")
+				if err != nil {
+					panic(err.Error())
+				}
+				env = append(env, map[string]interface{}{"name": "ETOK_HOSTNAME", "value": d.hostname})
+				if err := unstructured.SetNestedSlice(containers[0].(map[string]interface{}), env, "env"); err != nil {
+					panic(err.Error())
+				}
+			}
+
 			// Update deployment with updated container
 			if err := unstructured.SetNestedSlice(obj.Object, containers, "spec", "template", "spec", "containers"); err != nil {
 				panic(err.Error())
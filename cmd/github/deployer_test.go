@@ -72,6 +72,31 @@ func TestDeployer(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "hostname setting",
+			deployer: deployer{
+				hostname: "github.example.com",
+			},
+			assertions: func(t *testutil.T, client runtimeclient.Client) {
+				// Get deployment
+				deployment := &unstructured.Unstructured{}
+				deployment.SetGroupVersionKind(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"})
+				err := client.Get(context.Background(), runtimeclient.ObjectKey{Namespace: "github", Name: "webhook"}, deployment)
+				if assert.NoError(t, err) {
+
+					// Get container
+					containers, found, err := unstructured.NestedSlice(deployment.Object, "spec", "template", "spec", "containers")
+					if assert.True(t, found) && assert.NoError(t, err) && assert.Equal(t, 1, len(containers)) {
+
+						// Get env vars
+						env, found, err := unstructured.NestedSlice(containers[0].(map[string]interface{}), "env")
+						if assert.True(t, found) && assert.NoError(t, err) {
+							assert.Contains(t, env, map[string]interface{}{"name": "ETOK_HOSTNAME", "value": "github.example.com"})
+						}
+					}
+				}
+			},
+		},
 	}
 	for _, tt := range tests {
 		testutil.Run(t, tt.name, func(t *testutil.T) {
@@ -0,0 +1,5 @@
+variable "suffix" {}
+
+output "suffix" {
+  value = var.suffix
+}
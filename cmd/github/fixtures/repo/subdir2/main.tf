@@ -4,6 +4,11 @@ resource "random_id" "test" {
   byte_length = 2
 }
 
+module "suffix" {
+  source = "../modules/suffix"
+  suffix = var.suffix
+}
+
 output "random_string" {
-  value = "${random_id.test.hex}-${var.suffix}"
+  value = "${random_id.test.hex}-${module.suffix.suffix}"
 }
@@ -0,0 +1,14 @@
+package github
+
+import (
+	"fmt"
+
+	"github.com/leg100/etok/pkg/plan"
+)
+
+// Print summary in the format '+a/~c/−d'
+func shortPlanSummary(p *plan.Plan) string {
+	// − is a proper minus sign; an ascii hyphen is too narrow and looks
+	// incongruous alongside the wider '+' and '~' characters.
+	return fmt.Sprintf("+%d/~%d/−%d", p.Adds(), p.Changes(), p.Deletions())
+}
@@ -34,15 +34,19 @@ type repoManager struct {
 
 	// Provides token for authenticating and cloning repo from github
 	tokenProvider
+
+	// Github hostname
+	hostname string
 }
 
-func newRepoManager(cloneDir string, provider tokenProvider) *repoManager {
+func newRepoManager(cloneDir string, provider tokenProvider, hostname string) *repoManager {
 	return &repoManager{
 		cloneDir: cloneDir,
 		managed:  make(map[string]*repo),
 		// Repos are deleted at least one hour after they were last cloned
 		ttl:           time.Hour,
 		tokenProvider: provider,
+		hostname:      hostname,
 	}
 }
 
@@ -85,7 +89,7 @@ func (m *repoManager) doClone(url, branch, sha, owner, name string, installID in
 	}
 
 	// Get fresh access token for cloning repo
-	token, err := m.Token(context.Background(), installID, "github.com")
+	token, err := m.Token(context.Background(), installID, m.hostname)
 	if err != nil {
 		return nil, err
 	}
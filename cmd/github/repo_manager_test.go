@@ -20,7 +20,7 @@ func TestRepoManager(t *testing.T) {
 	path, sha := initializeRepo(&testutil.T{T: t}, "./fixtures/repo")
 	cloneDir := testutil.NewTempDir(t).Root()
 
-	mgr := newRepoManager(cloneDir, &fakeTokenProvider{})
+	mgr := newRepoManager(cloneDir, &fakeTokenProvider{}, "github.com")
 
 	// Test cloning
 	_, err := mgr.clone(
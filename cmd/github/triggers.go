@@ -0,0 +1,83 @@
+package github
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-github/v31/github"
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/pkg/archive"
+	etokrepo "github.com/leg100/etok/pkg/repo"
+)
+
+// isConnected determines whether a workspace is connected to the repository of
+// a check suite, and to the branch into which its pull request is to be merged.
+// A workspace without a branch is connected to the repository's default branch.
+func isConnected(ws *v1alpha1.Workspace, suite *v1alpha1.CheckSuite) bool {
+	if etokrepo.CanonicalUrl(ws.Spec.VCS.Repository) != etokrepo.CanonicalUrl(suite.Spec.CloneURL) {
+		return false
+	}
+
+	if suite.Spec.BaseBranch == "" {
+		return true
+	}
+
+	branch := ws.Spec.VCS.Branch
+	if branch == "" {
+		branch = suite.Spec.DefaultBranch
+	}
+	return branch == "" || branch == suite.Spec.BaseBranch
+}
+
+// listChangedFiles lists the paths of the files changed by a pull request,
+// relative to the root of the repository. Renamed files are listed under both
+// their previous and new paths.
+func listChangedFiles(ctx context.Context, client pullFilesClient, owner, repo string, number int) ([]string, error) {
+	var changed []string
+
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		files, resp, err := client.ListFiles(ctx, owner, repo, number, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			changed = append(changed, f.GetFilename())
+			if f.GetPreviousFilename() != "" {
+				changed = append(changed, f.GetPreviousFilename())
+			}
+		}
+		if resp.NextPage == 0 {
+			return changed, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// isTriggered determines whether any of the changed files belong to the
+// workspace's working directory or to the local modules it calls, directly or
+// indirectly. The working directory is relative to the root of the repository
+// at repoPath.
+func isTriggered(ws *v1alpha1.Workspace, repoPath string, changed []string) (bool, error) {
+	mods, err := archive.LocalModules(filepath.Join(repoPath, ws.Spec.VCS.WorkingDir))
+	if err != nil {
+		return false, err
+	}
+
+	for _, mod := range mods {
+		dir, err := filepath.Rel(repoPath, mod)
+		if err != nil {
+			return false, err
+		}
+		dir = filepath.ToSlash(dir)
+
+		for _, f := range changed {
+			if dir == "." || strings.HasPrefix(f, dir+"/") {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
@@ -36,6 +36,9 @@ type webhookServer struct {
 	// getter permits the webhook server to retrieve github clients for
 	// different installations
 	getter clientGetter
+
+	// Github hostname
+	hostname string
 }
 
 func (s *webhookServer) validate() error {
@@ -43,6 +46,10 @@ func (s *webhookServer) validate() error {
 		return fmt.Errorf("webhook secret cannot be an empty string")
 	}
 
+	if s.hostname == "" {
+		return fmt.Errorf("github hostname cannot be an empty string")
+	}
+
 	return nil
 }
 
@@ -110,14 +117,18 @@ func (s *webhookServer) eventHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Retrieve github clients for install
-	client, err := s.getter.Get(event.GetInstallation().GetID(), "github.com")
+	client, err := s.getter.Get(event.GetInstallation().GetID(), s.hostname)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 	gclients := githubClients{
-		checks: client.Checks,
-		pulls:  client.PullRequests,
+		checks:    client.Checks,
+		pulls:     client.PullRequests,
+		issues:    client.Issues,
+		reactions: client.Reactions,
+		repos:     client.Repositories,
+		teams:     client.Teams,
 	}
 
 	result, id, err := s.app.handleEvent(event, gclients)
@@ -129,11 +140,14 @@ func (s *webhookServer) eventHandler(w http.ResponseWriter, r *http.Request) {
 		"action", event.GetAction(),
 	}
 	if err != nil {
+		eventsTotal.WithLabelValues(name, "error").Inc()
 		klog.ErrorS(err, "handled event", logFields...)
 		// Punt stacktrace back to github for debugging
 		panic(err.Error())
 	}
 
+	eventsTotal.WithLabelValues(name, "success").Inc()
+
 	logFields = append(logFields, []interface{}{"result", result}...)
 	klog.InfoS("handled event", logFields...)
 
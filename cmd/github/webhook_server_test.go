@@ -8,6 +8,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -20,8 +21,9 @@ func (a *fakeApp) handleEvent(_ event, _ githubClients) (string, int64, error) {
 
 func TestWebhookServer(t *testing.T) {
 	server := webhookServer{
-		app:    &fakeApp{},
-		getter: &fakeClientGetter{},
+		app:      &fakeApp{},
+		getter:   &fakeClientGetter{},
+		hostname: "github.com",
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -46,10 +48,14 @@ func TestWebhookServer(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set(githubHeader, "check_suite")
 
+	processed := testutil.ToFloat64(eventsTotal.WithLabelValues("check_suite", "success"))
+
 	res, err := http.DefaultClient.Do(req)
 	require.NoError(t, err)
 	assert.Equal(t, 200, res.StatusCode)
 
+	assert.Equal(t, processed+1, testutil.ToFloat64(eventsTotal.WithLabelValues("check_suite", "success")))
+
 	cancel()
 	require.NoError(t, <-errch)
 }
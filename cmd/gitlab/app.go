@@ -0,0 +1,327 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/cmd/gitlab/client"
+	"github.com/leg100/etok/pkg/archive"
+	etokrepo "github.com/leg100/etok/pkg/repo"
+	"github.com/leg100/etok/pkg/vcs"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// Emoji with which a comment command is acknowledged
+const (
+	emojiAccepted = "thumbsup"
+	emojiDenied   = "thumbsdown"
+	emojiInvalid  = "confused"
+)
+
+type app struct {
+	// K8s controller-runtime client
+	runtimeclient.Client
+
+	// Client for the GitLab API
+	gitlab gitlabClient
+
+	// Access token with which to clone repos
+	token string
+
+	// Path to a directory in which to clone repos
+	cloneDir string
+}
+
+// Handle incoming gitlab events
+func (a *app) handleEvent(name string, payload []byte) (string, error) {
+	ctx := context.Background()
+
+	switch name {
+	case mergeRequestHook:
+		ev := &mergeRequestEvent{}
+		if err := json.Unmarshal(payload, ev); err != nil {
+			return "", fmt.Errorf("unable to decode merge request event: %w", err)
+		}
+		return a.handleMergeRequestEvent(ctx, ev)
+	case noteHook:
+		ev := &noteEvent{}
+		if err := json.Unmarshal(payload, ev); err != nil {
+			return "", fmt.Errorf("unable to decode note event: %w", err)
+		}
+		return a.handleNoteEvent(ctx, ev)
+	default:
+		return "ignored", nil
+	}
+}
+
+// Handle incoming merge request events. Upon a merge request being opened or
+// new commits being pushed to it, a plan is run for every connected workspace.
+// Upon it being closed or merged, its runs are deleted.
+func (a *app) handleMergeRequestEvent(ctx context.Context, ev *mergeRequestEvent) (string, error) {
+	mr := &ev.ObjectAttributes
+
+	switch mr.Action {
+	case "open", "reopen":
+	case "update":
+		if mr.OldRev == "" {
+			// No new commits
+			return "ignored", nil
+		}
+	case "close", "merge":
+		return a.deleteRuns(ctx, ev.Project.ID, mr.IID)
+	default:
+		return "ignored", nil
+	}
+
+	plan := &vcs.Command{Name: vcs.PlanCommand}
+
+	workspaces, err := a.connectedWorkspaces(ctx, &ev.Project, mr, plan)
+	if err != nil {
+		return "", err
+	}
+	if len(workspaces) == 0 {
+		return "no connected workspaces", nil
+	}
+
+	runs, err := a.createRuns(ctx, &ev.Project, mr, plan, workspaces, nil, ev.User.Username)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("created runs: %s", strings.Join(runNames(runs), ", ")), nil
+}
+
+// Handle incoming note events. A comment on an open merge request containing an
+// etok command is authorised against the commenter's membership of the
+// project, and then run against the workspaces it targets. The comment is
+// acknowledged with an emoji and a reply summarising the outcome.
+func (a *app) handleNoteEvent(ctx context.Context, ev *noteEvent) (string, error) {
+	mr := ev.MergeRequest
+	if ev.ObjectAttributes.NoteableType != "MergeRequest" || mr == nil || mr.State != "opened" {
+		return "ignored", nil
+	}
+
+	cmd, err := vcs.ParseComment(ev.ObjectAttributes.Note)
+	if err == nil && cmd == nil {
+		return "ignored", nil
+	}
+
+	reply := &noteReply{
+		gitlab:  a.gitlab,
+		project: ev.Project.ID,
+		iid:     mr.IID,
+		note:    ev.ObjectAttributes.ID,
+	}
+
+	if err != nil {
+		return reply.send(ctx, emojiInvalid, fmt.Sprintf("Unable to parse command: %s.", err))
+	}
+
+	authorised, err := a.isAuthorised(ctx, ev.Project.ID, ev.User.ID)
+	if err != nil {
+		return "", fmt.Errorf("unable to retrieve membership of commenter: %w", err)
+	}
+	if !authorised {
+		return reply.send(ctx, emojiDenied, fmt.Sprintf("@%s does not have permission to run `%s`: at least developer access to the project is required.", ev.User.Username, cmd))
+	}
+
+	workspaces, err := a.connectedWorkspaces(ctx, &ev.Project, mr, cmd)
+	if err != nil {
+		return "", err
+	}
+	if len(workspaces) == 0 {
+		return reply.send(ctx, emojiInvalid, fmt.Sprintf("`%s` does not match any workspaces connected to this merge request.", cmd))
+	}
+
+	// An apply applies each workspace's most recent plan for the merge
+	// request's latest commit
+	var plans map[string]string
+	if cmd.Name == vcs.ApplyCommand {
+		var refusals []string
+		plans, refusals, err = a.plansToApply(ctx, &ev.Project, mr, workspaces)
+		if err != nil {
+			return "", err
+		}
+		if len(refusals) > 0 {
+			return reply.send(ctx, emojiInvalid, fmt.Sprintf("Unable to run `%s`:\n\n%s", cmd, strings.Join(refusals, "\n")))
+		}
+	}
+
+	runs, err := a.createRuns(ctx, &ev.Project, mr, cmd, workspaces, plans, ev.User.Username)
+	if err != nil {
+		return "", err
+	}
+
+	var targets []string
+	for _, run := range runs {
+		targets = append(targets, fmt.Sprintf("`%s/%s` (run `%s`)", run.Namespace, run.Workspace, run.Name))
+	}
+	return reply.send(ctx, emojiAccepted, fmt.Sprintf("Running `%s` for workspaces: %s.", cmd, strings.Join(targets, ", ")))
+}
+
+// Commenters must have at least developer access to a project to run comment
+// commands
+func (a *app) isAuthorised(ctx context.Context, project, user int) (bool, error) {
+	member, err := a.gitlab.GetMember(ctx, project, user)
+	if errors.Is(err, client.ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return member.AccessLevel >= client.DeveloperAccess, nil
+}
+
+// +kubebuilder:rbac:groups=etok.dev,resources=workspaces,verbs=list
+
+// Retrieve the workspaces connected to the project's repository, and to the
+// branch into which the merge request is to be merged, that are targeted by
+// the command. A workspace without a branch is connected to the project's
+// default branch.
+func (a *app) connectedWorkspaces(ctx context.Context, proj *project, mr *mergeRequest, cmd *vcs.Command) ([]*v1alpha1.Workspace, error) {
+	workspaces := &v1alpha1.WorkspaceList{}
+	if err := a.Client.List(ctx, workspaces); err != nil {
+		return nil, fmt.Errorf("unable to list workspace kubernetes resources: %w", err)
+	}
+
+	var connected []*v1alpha1.Workspace
+	for i, ws := range workspaces.Items {
+		if etokrepo.CanonicalUrl(ws.Spec.VCS.Repository) != etokrepo.CanonicalUrl(proj.GitHTTPURL) {
+			continue
+		}
+		branch := ws.Spec.VCS.Branch
+		if branch == "" {
+			branch = proj.DefaultBranch
+		}
+		if branch != "" && branch != mr.TargetBranch {
+			continue
+		}
+		if !cmd.Targets(ws.Namespace, ws.Name) {
+			continue
+		}
+		connected = append(connected, &workspaces.Items[i])
+	}
+	return connected, nil
+}
+
+// +kubebuilder:rbac:groups=etok.dev,resources=runs,verbs=list
+
+// Retrieve the names of the plan runs to apply, keyed by the namespace/name of
+// their workspace. Reasons are returned for any workspace that cannot apply a
+// plan: its most recent plan for the merge request's latest commit must have
+// succeeded without violating policy, and the merge request must be mergeable.
+func (a *app) plansToApply(ctx context.Context, proj *project, mr *mergeRequest, workspaces []*v1alpha1.Workspace) (map[string]string, []string, error) {
+	runs := &v1alpha1.RunList{}
+	if err := a.Client.List(ctx, runs, mergeRequestLabels(proj.ID, mr.IID)); err != nil {
+		return nil, nil, fmt.Errorf("unable to list run kubernetes resources: %w", err)
+	}
+
+	plans := make(map[string]string)
+	var refusals []string
+	for _, ws := range workspaces {
+		var reason string
+		plan := latestPlan(runs.Items, ws, mr.LastCommit.ID)
+		switch {
+		case !mr.mergeable():
+			reason = "merge request is not mergeable"
+		case plan == nil:
+			reason = "there is no plan to apply for the latest commit"
+		case !plan.IsDone():
+			reason = "plan is in progress"
+		case !succeeded(plan):
+			reason = "plan failed or violates policy"
+		case !plan.CompliesWithPolicy(ws):
+			reason = "plan has not passed policy"
+		default:
+			plans[ws.Namespace+"/"+ws.Name] = plan.Name
+			continue
+		}
+		refusals = append(refusals, fmt.Sprintf("* `%s/%s`: %s", ws.Namespace, ws.Name, reason))
+	}
+	return plans, refusals, nil
+}
+
+// +kubebuilder:rbac:groups=etok.dev,resources=runs,verbs=create
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=create
+
+// Create a run for each workspace, along with the config map containing the
+// merge request's configuration. Workspaces own their runs, so if a workspace
+// is deleted so are its runs.
+func (a *app) createRuns(ctx context.Context, proj *project, mr *mergeRequest, cmd *vcs.Command, workspaces []*v1alpha1.Workspace, plans map[string]string, author string) ([]*v1alpha1.Run, error) {
+	path, err := cloneRepo(ctx, a.cloneDir, proj.GitHTTPURL, mr.SourceBranch, a.token)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(path)
+
+	var runs []*v1alpha1.Run
+	for _, ws := range workspaces {
+		run := buildRun(ws, proj.ID, mr, cmd, plans[ws.Namespace+"/"+ws.Name], author)
+
+		configMap, err := archive.ConfigMap(ws.Namespace, run.Name, filepath.Join(path, ws.Spec.VCS.WorkingDir), path)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := controllerutil.SetOwnerReference(ws, run, a.Client.Scheme()); err != nil {
+			return nil, err
+		}
+		if err := a.Client.Create(ctx, run); err != nil {
+			return nil, fmt.Errorf("unable to create run kubernetes resource: %w", err)
+		}
+
+		if err := controllerutil.SetOwnerReference(run, configMap, a.Client.Scheme()); err != nil {
+			return nil, err
+		}
+		if err := a.Client.Create(ctx, configMap); err != nil {
+			return nil, fmt.Errorf("unable to create config map kubernetes resource: %w", err)
+		}
+
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// +kubebuilder:rbac:groups=etok.dev,resources=runs,verbs=list;delete
+
+// Delete the completed plan runs of a merge request. Their config maps are
+// garbage collected along with them. Applies are left alone, both to permit an
+// apply in progress to complete and to keep a record of what was applied; they
+// are deleted by the run retention policy instead.
+func (a *app) deleteRuns(ctx context.Context, projectID, iid int) (string, error) {
+	runs := &v1alpha1.RunList{}
+	if err := a.Client.List(ctx, runs, mergeRequestLabels(projectID, iid)); err != nil {
+		return "", fmt.Errorf("unable to list run kubernetes resources: %w", err)
+	}
+
+	var deleted []string
+	for i := range runs.Items {
+		if runs.Items[i].Labels[commandLabel] != vcs.PlanCommand || !runs.Items[i].IsDone() {
+			continue
+		}
+		if err := a.Client.Delete(ctx, &runs.Items[i], runtimeclient.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !kerrors.IsNotFound(err) {
+			return "", fmt.Errorf("unable to delete run kubernetes resource: %w", err)
+		}
+		deleted = append(deleted, klog.KObj(&runs.Items[i]).String())
+	}
+
+	if len(deleted) == 0 {
+		return "no run kubernetes resources to delete", nil
+	}
+	return fmt.Sprintf("deleted run kubernetes resources: %s", strings.Join(deleted, ", ")), nil
+}
+
+func runNames(runs []*v1alpha1.Run) (names []string) {
+	for _, run := range runs {
+		names = append(names, klog.KObj(run).String())
+	}
+	return
+}
@@ -0,0 +1,301 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/cmd/gitlab/client"
+	"github.com/leg100/etok/cmd/gitlab/client/fixtures"
+	"github.com/leg100/etok/pkg/scheme"
+	"github.com/leg100/etok/pkg/testobj"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/leg100/etok/pkg/vcs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestHandleEvent(t *testing.T) {
+	// Run of a plan for the merge request's latest commit that has succeeded
+	planRun := func(sha string) *v1alpha1.Run {
+		return testobj.Run("dev", "mr-plan1", "sh",
+			testobj.WithWorkspace("networks"),
+			testobj.WithRunPhase(v1alpha1.RunPhaseCompleted),
+			testobj.WithCondition(v1alpha1.RunCompleteCondition, v1alpha1.PodSucceededReason),
+			testobj.WithLabels(projectLabel, "5", mergeRequestLabel, "7", shaLabel, sha, commandLabel, vcs.PlanCommand))
+	}
+
+	tests := []struct {
+		name string
+		// Name of event
+		event string
+		// Merge request event to send
+		mergeRequest func(*mergeRequest)
+		// Comment to send in a note event
+		note string
+		// Access level of commenter; zero if not a member
+		accessLevel int
+		objs        func(sha string) []runtimeclient.Object
+		assertions  func(*testutil.T, runtimeclient.Client, *fixtures.Server)
+	}{
+		{
+			name:  "merge request opened",
+			event: mergeRequestHook,
+			mergeRequest: func(mr *mergeRequest) {
+				mr.Action = "open"
+			},
+			assertions: func(t *testutil.T, c runtimeclient.Client, srv *fixtures.Server) {
+				runs := listRuns(t, c)
+				if assert.Equal(t, 1, len(runs)) {
+					assert.Equal(t, "networks", runs[0].Workspace)
+					assert.Equal(t, vcs.PlanCommand, runs[0].Labels[commandLabel])
+					assert.True(t, runs[0].SavePlan)
+					assert.Contains(t, runs[0].Args[0], "terraform plan")
+
+					// Run should have its own config map
+					assert.NoError(t, c.Get(context.Background(), runtimeclient.ObjectKey{Namespace: "dev", Name: runs[0].Name}, &corev1.ConfigMap{}))
+				}
+				assert.Empty(t, srv.Requests())
+			},
+		},
+		{
+			name:  "merge request updated without new commits",
+			event: mergeRequestHook,
+			mergeRequest: func(mr *mergeRequest) {
+				mr.Action = "update"
+			},
+			assertions: func(t *testutil.T, c runtimeclient.Client, srv *fixtures.Server) {
+				assert.Empty(t, listRuns(t, c))
+			},
+		},
+		{
+			name:  "merge request updated with new commits",
+			event: mergeRequestHook,
+			mergeRequest: func(mr *mergeRequest) {
+				mr.Action = "update"
+				mr.OldRev = "da1560886d4f094c3e6c9ef40349f7d38b5d27d7"
+			},
+			assertions: func(t *testutil.T, c runtimeclient.Client, srv *fixtures.Server) {
+				assert.Equal(t, 1, len(listRuns(t, c)))
+			},
+		},
+		{
+			name:  "merge request merged",
+			event: mergeRequestHook,
+			mergeRequest: func(mr *mergeRequest) {
+				mr.Action = "merge"
+			},
+			objs: func(sha string) []runtimeclient.Object {
+				return []runtimeclient.Object{
+					planRun(sha),
+					// Plan in progress
+					testobj.Run("dev", "mr-plan2", "sh",
+						testobj.WithWorkspace("networks"),
+						testobj.WithLabels(projectLabel, "5", mergeRequestLabel, "7", shaLabel, sha, commandLabel, vcs.PlanCommand)),
+					// Apply in progress
+					testobj.Run("dev", "mr-apply1", "sh",
+						testobj.WithWorkspace("networks"),
+						testobj.WithLabels(projectLabel, "5", mergeRequestLabel, "7", shaLabel, sha, commandLabel, vcs.ApplyCommand)),
+					// Completed apply
+					testobj.Run("dev", "mr-apply2", "sh",
+						testobj.WithWorkspace("networks"),
+						testobj.WithRunPhase(v1alpha1.RunPhaseCompleted),
+						testobj.WithCondition(v1alpha1.RunCompleteCondition, v1alpha1.PodSucceededReason),
+						testobj.WithLabels(projectLabel, "5", mergeRequestLabel, "7", shaLabel, sha, commandLabel, vcs.ApplyCommand)),
+				}
+			},
+			assertions: func(t *testutil.T, c runtimeclient.Client, srv *fixtures.Server) {
+				var names []string
+				for _, run := range listRuns(t, c) {
+					names = append(names, run.Name)
+				}
+				assert.ElementsMatch(t, []string{"mr-plan2", "mr-apply1", "mr-apply2"}, names)
+			},
+		},
+		{
+			name:        "plan comment",
+			event:       noteHook,
+			note:        "etok plan -w dev/networks -- -target=module.vpc",
+			accessLevel: client.DeveloperAccess,
+			assertions: func(t *testutil.T, c runtimeclient.Client, srv *fixtures.Server) {
+				runs := listRuns(t, c)
+				if assert.Equal(t, 1, len(runs)) {
+					assert.Contains(t, runs[0].Args[0], "terraform plan -no-color -input=false '-target=module.vpc' -out=")
+					assert.Equal(t, "alice", runs[0].LaunchedBy())
+				}
+				assertReplied(t, srv, "thumbsup", "Running `etok plan -w dev/networks -- -target=module.vpc`")
+			},
+		},
+		{
+			name:        "apply comment",
+			event:       noteHook,
+			note:        "etok apply",
+			accessLevel: client.DeveloperAccess,
+			objs: func(sha string) []runtimeclient.Object {
+				return []runtimeclient.Object{planRun(sha)}
+			},
+			assertions: func(t *testutil.T, c runtimeclient.Client, srv *fixtures.Server) {
+				runs := listRuns(t, c)
+				if assert.Equal(t, 2, len(runs)) {
+					for _, run := range runs {
+						if run.Name != "mr-plan1" {
+							assert.Contains(t, run.Args[0], "terraform apply -no-color -input=false /plans/mr-plan1")
+							assert.Equal(t, "true", run.Labels["apply-script"])
+						}
+					}
+				}
+				assertReplied(t, srv, "thumbsup", "Running `etok apply`")
+			},
+		},
+		{
+			name:        "apply comment without plan for latest commit",
+			event:       noteHook,
+			note:        "etok apply",
+			accessLevel: client.DeveloperAccess,
+			objs: func(sha string) []runtimeclient.Object {
+				return []runtimeclient.Object{planRun("0000000000000000000000000000000000000000")}
+			},
+			assertions: func(t *testutil.T, c runtimeclient.Client, srv *fixtures.Server) {
+				assert.Equal(t, 1, len(listRuns(t, c)))
+				assertReplied(t, srv, "confused", "there is no plan to apply for the latest commit")
+			},
+		},
+		{
+			name:        "comment targeting unconnected workspace",
+			event:       noteHook,
+			note:        "etok plan -w prod/networks",
+			accessLevel: client.DeveloperAccess,
+			assertions: func(t *testutil.T, c runtimeclient.Client, srv *fixtures.Server) {
+				assert.Empty(t, listRuns(t, c))
+				assertReplied(t, srv, "confused", "does not match any workspaces")
+			},
+		},
+		{
+			name:        "unauthorised commenter",
+			event:       noteHook,
+			note:        "etok plan",
+			accessLevel: 20,
+			assertions: func(t *testutil.T, c runtimeclient.Client, srv *fixtures.Server) {
+				assert.Empty(t, listRuns(t, c))
+				assertReplied(t, srv, "thumbsdown", "does not have permission")
+			},
+		},
+		{
+			name:  "commenter not a member",
+			event: noteHook,
+			note:  "etok plan",
+			assertions: func(t *testutil.T, c runtimeclient.Client, srv *fixtures.Server) {
+				assert.Empty(t, listRuns(t, c))
+				assertReplied(t, srv, "thumbsdown", "does not have permission")
+			},
+		},
+		{
+			name:        "unknown command",
+			event:       noteHook,
+			note:        "etok destroy",
+			accessLevel: client.DeveloperAccess,
+			assertions: func(t *testutil.T, c runtimeclient.Client, srv *fixtures.Server) {
+				assertReplied(t, srv, "confused", "Unable to parse command")
+			},
+		},
+		{
+			name:        "not a command",
+			event:       noteHook,
+			note:        "looks good to me",
+			accessLevel: client.DeveloperAccess,
+			assertions: func(t *testutil.T, c runtimeclient.Client, srv *fixtures.Server) {
+				assert.Empty(t, srv.Requests())
+			},
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			repo, sha := initializeRepo(t)
+
+			bldr := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+				testobj.Workspace("dev", "networks", testobj.WithRepository("file://"+repo)),
+				testobj.Workspace("prod", "networks", testobj.WithRepository("file://"+repo), testobj.WithBranch("production")),
+			)
+			if tt.objs != nil {
+				bldr = bldr.WithObjects(tt.objs(sha)...)
+			}
+			rclient := bldr.Build()
+
+			srv := fixtures.NewServer()
+			defer srv.Close()
+			if tt.accessLevel > 0 {
+				srv.Members[3] = tt.accessLevel
+			}
+
+			gclient, err := client.NewClient(srv.URL, "token")
+			require.NoError(t, err)
+
+			app := &app{
+				Client:   rclient,
+				gitlab:   gclient,
+				cloneDir: t.NewTempDir().Root(),
+			}
+
+			proj := project{ID: 5, PathWithNamespace: "bob/myrepo", GitHTTPURL: "file://" + repo, DefaultBranch: "master"}
+			mr := mergeRequest{
+				IID:          7,
+				SourceBranch: "changes",
+				TargetBranch: "master",
+				State:        "opened",
+				MergeStatus:  "can_be_merged",
+				LastCommit:   commit{ID: sha},
+			}
+			author := user{ID: 3, Username: "alice"}
+
+			var ev interface{}
+			switch tt.event {
+			case mergeRequestHook:
+				tt.mergeRequest(&mr)
+				ev = &mergeRequestEvent{User: author, Project: proj, ObjectAttributes: mr}
+			case noteHook:
+				ev = &noteEvent{
+					User:             author,
+					Project:          proj,
+					ObjectAttributes: note{ID: 302, Note: tt.note, NoteableType: "MergeRequest"},
+					MergeRequest:     &mr,
+				}
+			}
+			payload, err := json.Marshal(ev)
+			require.NoError(t, err)
+
+			_, err = app.handleEvent(tt.event, payload)
+			require.NoError(t, err)
+
+			if tt.assertions != nil {
+				tt.assertions(t, rclient, srv)
+			}
+		})
+	}
+}
+
+func listRuns(t *testutil.T, c runtimeclient.Client) []v1alpha1.Run {
+	runs := &v1alpha1.RunList{}
+	require.NoError(t, c.List(context.Background(), runs))
+	return runs.Items
+}
+
+// assertReplied asserts the comment was acknowledged with the emoji and a reply
+// containing the text
+func assertReplied(t *testutil.T, srv *fixtures.Server, emoji, text string) {
+	var awarded, replied bool
+	for _, req := range srv.Requests() {
+		switch {
+		case strings.HasSuffix(req.Path, "/notes/302/award_emoji"):
+			awarded = assert.Contains(t, req.Body, emoji)
+		case strings.HasSuffix(req.Path, "/merge_requests/7/notes"):
+			replied = assert.Contains(t, req.Body, text)
+		}
+	}
+	assert.True(t, awarded, "expected emoji to be awarded")
+	assert.True(t, replied, "expected reply")
+}
@@ -0,0 +1,163 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// tokenHeader is the header with which a personal, project or group access
+// token authenticates against the GitLab API
+const tokenHeader = "PRIVATE-TOKEN"
+
+// Commit status states
+const (
+	StatePending  = "pending"
+	StateRunning  = "running"
+	StateSuccess  = "success"
+	StateFailed   = "failed"
+	StateCanceled = "canceled"
+)
+
+// DeveloperAccess is the minimum access level a project member requires to
+// push to the project's repository
+const DeveloperAccess = 30
+
+var ErrNotFound = errors.New("not found")
+
+// Client is a minimal client for the GitLab REST API
+// (https://docs.gitlab.com/ee/api/), providing only those endpoints that etok
+// uses
+type Client struct {
+	// URL of the API, e.g. https://gitlab.com/api/v4/
+	BaseURL *url.URL
+
+	token      string
+	httpClient *http.Client
+}
+
+// NewClient returns a client for the GitLab instance at the URL, authenticating
+// with an access token
+func NewClient(gitlabURL, token string) (*Client, error) {
+	u, err := url.Parse(gitlabURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse gitlab URL: %w", err)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/api/v4/"
+
+	return &Client{
+		BaseURL:    u,
+		token:      token,
+		httpClient: http.DefaultClient,
+	}, nil
+}
+
+// CommitStatus is the status of a commit, as reported by an external system
+type CommitStatus struct {
+	State       string `json:"state"`
+	Ref         string `json:"ref,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	TargetURL   string `json:"target_url,omitempty"`
+}
+
+// SetCommitStatus creates or updates the status of a commit. Statuses are
+// identified by their name, so setting a status with the same name as an
+// existing status replaces it.
+func (c *Client) SetCommitStatus(ctx context.Context, project int, sha string, status CommitStatus) error {
+	return c.do(ctx, "POST", fmt.Sprintf("projects/%d/statuses/%s", project, sha), status, nil)
+}
+
+// Note is a comment on a merge request
+type Note struct {
+	ID   int    `json:"id,omitempty"`
+	Body string `json:"body"`
+}
+
+// CreateMergeRequestNote comments on a merge request
+func (c *Client) CreateMergeRequestNote(ctx context.Context, project, iid int, body string) (*Note, error) {
+	note := &Note{}
+	if err := c.do(ctx, "POST", fmt.Sprintf("projects/%d/merge_requests/%d/notes", project, iid), &Note{Body: body}, note); err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// AwardNoteEmoji awards an emoji, e.g. thumbsup, to a comment on a merge
+// request
+func (c *Client) AwardNoteEmoji(ctx context.Context, project, iid, note int, name string) error {
+	body := struct {
+		Name string `json:"name"`
+	}{Name: name}
+	return c.do(ctx, "POST", fmt.Sprintf("projects/%d/merge_requests/%d/notes/%d/award_emoji", project, iid, note), body, nil)
+}
+
+// Member is a member of a project, either directly or via a group
+type Member struct {
+	ID          int    `json:"id"`
+	Username    string `json:"username"`
+	AccessLevel int    `json:"access_level"`
+}
+
+// GetMember retrieves a member of a project, including members inherited from
+// its groups. ErrNotFound is returned if the user is not a member.
+func (c *Client) GetMember(ctx context.Context, project, user int) (*Member, error) {
+	member := &Member{}
+	if err := c.do(ctx, "GET", fmt.Sprintf("projects/%d/members/all/%d", project, user), nil, member); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// do sends a request to the API with an optional JSON body, and decodes the
+// JSON response into v, unless v is nil
+func (c *Client) do(ctx context.Context, method, path string, body, v interface{}) error {
+	u, err := c.BaseURL.Parse(path)
+	if err != nil {
+		return err
+	}
+
+	var buf io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		buf = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(tokenHeader, c.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s %s", ErrNotFound, method, u.Path)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s %s: unexpected status code: %d: %s", method, u.Path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
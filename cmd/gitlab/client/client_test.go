@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/leg100/etok/cmd/gitlab/client/fixtures"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewClient(t *testing.T) {
+	client, err := NewClient("https://gitlab.com", "token")
+	require.NoError(t, err)
+	assert.Equal(t, &url.URL{Scheme: "https", Host: "gitlab.com", Path: "/api/v4/"}, client.BaseURL)
+
+	client, err = NewClient("https://git.example.com/gitlab/", "token")
+	require.NoError(t, err)
+	assert.Equal(t, &url.URL{Scheme: "https", Host: "git.example.com", Path: "/gitlab/api/v4/"}, client.BaseURL)
+}
+
+func TestClient(t *testing.T) {
+	srv := fixtures.NewServer()
+	defer srv.Close()
+	srv.Members[7] = DeveloperAccess
+
+	client, err := NewClient(srv.URL, "token")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	require.NoError(t, client.SetCommitStatus(ctx, 5, "abc123", CommitStatus{State: StateRunning, Name: "etok/dev/networking"}))
+
+	note, err := client.CreateMergeRequestNote(ctx, 5, 1, "hello")
+	require.NoError(t, err)
+	assert.Equal(t, 302, note.ID)
+
+	require.NoError(t, client.AwardNoteEmoji(ctx, 5, 1, 302, "thumbsup"))
+
+	member, err := client.GetMember(ctx, 5, 7)
+	require.NoError(t, err)
+	assert.Equal(t, DeveloperAccess, member.AccessLevel)
+
+	_, err = client.GetMember(ctx, 5, 8)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	assert.Equal(t, []fixtures.Request{
+		{Method: "POST", Path: "/api/v4/projects/5/statuses/abc123", Body: `{"state":"running","name":"etok/dev/networking"}`},
+		{Method: "POST", Path: "/api/v4/projects/5/merge_requests/1/notes", Body: `{"body":"hello"}`},
+		{Method: "POST", Path: "/api/v4/projects/5/merge_requests/1/notes/302/award_emoji", Body: `{"name":"thumbsup"}`},
+		{Method: "GET", Path: "/api/v4/projects/5/members/all/7"},
+		{Method: "GET", Path: "/api/v4/projects/5/members/all/8"},
+	}, srv.Requests())
+}
+
+func TestClientUnauthorized(t *testing.T) {
+	srv := fixtures.NewServer()
+	defer srv.Close()
+
+	client, err := NewClient(srv.URL, "")
+	require.NoError(t, err)
+
+	err = client.SetCommitStatus(context.Background(), 5, "abc123", CommitStatus{State: StatePending})
+	assert.EqualError(t, err, `POST /api/v4/projects/5/statuses/abc123: unexpected status code: 401: {"message":"401 Unauthorized"}`)
+}
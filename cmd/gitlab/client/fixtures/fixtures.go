@@ -0,0 +1,161 @@
+package fixtures
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+const NoteJSON = `{
+	"id": 302,
+	"body": "closed",
+	"attachment": null,
+	"author": {
+		"id": 1,
+		"username": "pipin",
+		"email": "admin@example.com",
+		"name": "Pip",
+		"state": "active",
+		"created_at": "2013-09-30T13:46:01Z"
+	},
+	"created_at": "2013-10-02T09:22:45Z",
+	"updated_at": "2013-10-02T10:22:45Z",
+	"system": true,
+	"noteable_id": 377,
+	"noteable_type": "MergeRequest",
+	"noteable_iid": 377,
+	"resolvable": false,
+	"confidential": false
+}`
+
+const CommitStatusJSON = `{
+	"author": {
+		"web_url": "https://gitlab.example.com/janedoe",
+		"name": "Jane Doe",
+		"avatar_url": "https://gitlab.example.com/uploads/user/avatar/3/avatar.png",
+		"username": "janedoe",
+		"state": "active",
+		"id": 3
+	},
+	"name": "etok/dev/networking",
+	"sha": "18f3e63d05582537db6d183d9d557be09e1f90c8",
+	"status": "success",
+	"coverage": null,
+	"description": null,
+	"id": 93,
+	"target_url": null,
+	"ref": null,
+	"started_at": null,
+	"created_at": "2016-01-19T09:05:50.355Z",
+	"allow_failure": false,
+	"finished_at": "2016-01-19T09:05:50.365Z"
+}`
+
+const AwardEmojiJSON = `{
+	"id": 345,
+	"name": "thumbsup",
+	"user": {
+		"name": "Administrator",
+		"username": "root",
+		"id": 1,
+		"state": "active",
+		"avatar_url": "http://www.gravatar.com/avatar/e64c7d89f26bd1972efa854d13d7dd61?s=80&d=identicon",
+		"web_url": "http://gitlab.example.com/root"
+	},
+	"created_at": "2016-06-17T19:59:55.888Z",
+	"updated_at": "2016-06-17T19:59:55.888Z",
+	"awardable_id": 302,
+	"awardable_type": "Note"
+}`
+
+// MemberJSON returns a project member with the given ID and access level
+func MemberJSON(id, accessLevel int) string {
+	return fmt.Sprintf(`{
+	"id": %d,
+	"username": "raymond_smith",
+	"name": "Raymond Smith",
+	"state": "active",
+	"avatar_url": "https://www.gravatar.com/avatar/c2525a7f58ae3776070e44c106c48e15?s=80&d=identicon",
+	"web_url": "http://192.168.1.8:3000/root",
+	"expires_at": "2012-10-22T14:13:35Z",
+	"access_level": %d,
+	"group_saml_identity": null
+}`, id, accessLevel)
+}
+
+// Request is a request received by the stub server
+type Request struct {
+	Method string
+	Path   string
+	Body   string
+}
+
+var memberPath = regexp.MustCompile(`^/api/v4/projects/\d+/members/all/(\d+)$`)
+
+// Server is an httptest stub of the GitLab API, serving the endpoints used by
+// etok. It records every request it receives.
+type Server struct {
+	*httptest.Server
+
+	// Access levels of project members, keyed by user ID. Users not in the map
+	// are not members.
+	Members map[int]int
+
+	mu       sync.Mutex
+	requests []Request
+}
+
+func NewServer() *Server {
+	s := &Server{Members: make(map[int]int)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Requests returns the requests received thus far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request{}, s.requests...)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Body: string(body)})
+	s.mu.Unlock()
+
+	if r.Header.Get("PRIVATE-TOKEN") == "" {
+		http.Error(w, `{"message":"401 Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case r.Method == "GET" && memberPath.MatchString(r.URL.Path):
+		id, _ := strconv.Atoi(memberPath.FindStringSubmatch(r.URL.Path)[1])
+		level, ok := s.Members[id]
+		if !ok {
+			http.Error(w, `{"message":"404 Not found"}`, http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, MemberJSON(id, level))
+	case r.Method == "POST" && regexp.MustCompile(`/statuses/\w+$`).MatchString(r.URL.Path):
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, CommitStatusJSON)
+	case r.Method == "POST" && regexp.MustCompile(`/award_emoji$`).MatchString(r.URL.Path):
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, AwardEmojiJSON)
+	case r.Method == "POST" && regexp.MustCompile(`/notes$`).MatchString(r.URL.Path):
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, NoteJSON)
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,18 @@
+package gitlab
+
+import (
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/spf13/cobra"
+)
+
+func GitlabCmd(f *cmdutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gitlab",
+		Short: "Manage gitlab integration",
+	}
+
+	run, _ := runCmd(f)
+	cmd.AddCommand(run)
+
+	return cmd
+}
@@ -0,0 +1,112 @@
+package gitlab
+
+import (
+	"fmt"
+
+	"golang.org/x/sync/errgroup"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	gitlabclient "github.com/leg100/etok/cmd/gitlab/client"
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/leg100/etok/pkg/scheme"
+	"github.com/spf13/cobra"
+	"k8s.io/klog/v2/klogr"
+)
+
+const (
+	defaultWebhookPort = 9002
+)
+
+// runOptions are the options for running the gitlab integration
+type runOptions struct {
+	*webhookServer
+
+	cloneDir string
+
+	// URL of the gitlab instance
+	gitlabURL string
+
+	// Access token for the gitlab API and for cloning repos
+	token string
+}
+
+// runCmd creates a cobra command for running the gitlab integration
+func runCmd(f *cmdutil.Factory) (*cobra.Command, *runOptions) {
+	o := &runOptions{
+		webhookServer: &webhookServer{},
+	}
+	cmd := &cobra.Command{
+		Use:    "run",
+		Short:  "Run gitlab integration",
+		Hidden: true,
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			if o.token == "" {
+				return fmt.Errorf("gitlab access token cannot be an empty string")
+			}
+
+			// Create runtime client
+			client, err := f.CreateRuntimeClient("")
+			if err != nil {
+				return err
+			}
+
+			gclient, err := gitlabclient.NewClient(o.gitlabURL, o.token)
+			if err != nil {
+				return err
+			}
+
+			ctrl.SetLogger(klogr.NewWithOptions(klogr.WithFormat(klogr.FormatKlog)))
+
+			// Manager for reconcilers
+			mgr, err := ctrl.NewManager(client.Config, ctrl.Options{
+				Scheme: scheme.Scheme,
+			})
+			if err != nil {
+				return fmt.Errorf("unable to create controller manager: %w", err)
+			}
+
+			if err := newRunReporter(mgr.GetClient(), gclient).SetupWithManager(mgr); err != nil {
+				return fmt.Errorf("unable to create run reporter: %w", err)
+			}
+
+			// Configure webhook server to forward events to the gitlab app
+			o.webhookServer.app = &app{
+				Client:   client.RuntimeClient,
+				gitlab:   gclient,
+				token:    o.token,
+				cloneDir: o.cloneDir,
+			}
+
+			// Ensure webhook server is properly constructed since we're not
+			// using a constructor
+			if err := o.webhookServer.validate(); err != nil {
+				return err
+			}
+
+			// Start controller mgr and webhook server concurrently. If either
+			// returns an error, both are cancelled.
+			g, gctx := errgroup.WithContext(cmd.Context())
+
+			g.Go(func() error {
+				return mgr.Start(gctx)
+			})
+
+			g.Go(func() error {
+				return o.webhookServer.run(gctx)
+			})
+
+			return g.Wait()
+		},
+	}
+
+	cmd.Flags().StringVar(&o.gitlabURL, "url", "https://gitlab.com", "URL of the gitlab instance")
+	cmd.Flags().StringVar(&o.token, "token", "", "Gitlab access token")
+
+	cmd.Flags().IntVar(&o.port, "port", defaultWebhookPort, "Webhook port")
+	cmd.Flags().StringVar(&o.webhookSecret, "webhook-secret", "", "Gitlab webhook secret token")
+
+	// Default to /repos, the mountpoint of a dedicated k8s volume
+	cmd.Flags().StringVar(&o.cloneDir, "clone-path", "/repos", "Path to a directory in which to clone repos")
+
+	return cmd, o
+}
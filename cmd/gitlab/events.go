@@ -0,0 +1,72 @@
+package gitlab
+
+// Webhook event names, as provided by the X-Gitlab-Event header
+const (
+	mergeRequestHook = "Merge Request Hook"
+	noteHook         = "Note Hook"
+)
+
+// The subset of GitLab's webhook payloads that etok uses:
+//
+// https://docs.gitlab.com/ee/user/project/integrations/webhook_events.html
+
+type user struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+}
+
+type project struct {
+	ID                int    `json:"id"`
+	PathWithNamespace string `json:"path_with_namespace"`
+	GitHTTPURL        string `json:"git_http_url"`
+	DefaultBranch     string `json:"default_branch"`
+}
+
+type commit struct {
+	ID string `json:"id"`
+}
+
+type mergeRequest struct {
+	IID          int    `json:"iid"`
+	SourceBranch string `json:"source_branch"`
+	TargetBranch string `json:"target_branch"`
+	State        string `json:"state"`
+	MergeStatus  string `json:"merge_status"`
+	LastCommit   commit `json:"last_commit"`
+	URL          string `json:"url"`
+
+	// Action is only populated in merge request events
+	Action string `json:"action,omitempty"`
+
+	// OldRev is only populated in merge request events for updates that push
+	// new commits
+	OldRev string `json:"oldrev,omitempty"`
+}
+
+// mergeable determines whether the merge request can be merged
+func (mr *mergeRequest) mergeable() bool {
+	return mr.MergeStatus == "can_be_merged"
+}
+
+// mergeRequestEvent is sent when a merge request is opened, updated, merged,
+// closed, etc.
+type mergeRequestEvent struct {
+	User             user         `json:"user"`
+	Project          project      `json:"project"`
+	ObjectAttributes mergeRequest `json:"object_attributes"`
+}
+
+type note struct {
+	ID           int    `json:"id"`
+	Note         string `json:"note"`
+	NoteableType string `json:"noteable_type"`
+}
+
+// noteEvent is sent when a comment is made on a merge request, issue, commit
+// or snippet
+type noteEvent struct {
+	User             user          `json:"user"`
+	Project          project       `json:"project"`
+	ObjectAttributes note          `json:"object_attributes"`
+	MergeRequest     *mergeRequest `json:"merge_request,omitempty"`
+}
@@ -0,0 +1,17 @@
+package gitlab
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var eventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "etok",
+	Subsystem: "gitlab",
+	Name:      "events_total",
+	Help:      "Number of GitLab events processed by the webhook server, by event and result.",
+}, []string{"event", "result"})
+
+func init() {
+	metrics.Registry.MustRegister(eventsTotal)
+}
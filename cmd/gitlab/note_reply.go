@@ -0,0 +1,34 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+)
+
+// noteReply acknowledges a comment command with an emoji and replies to it
+// with a comment summarising the outcome
+type noteReply struct {
+	gitlab gitlabClient
+
+	project int
+
+	// IID of the merge request on which the comment was made
+	iid int
+
+	// ID of the comment containing the command
+	note int
+}
+
+// send the emoji and reply, returning the reply as the result of handling the
+// comment
+func (r *noteReply) send(ctx context.Context, emoji, body string) (string, error) {
+	if err := r.gitlab.AwardNoteEmoji(ctx, r.project, r.iid, r.note, emoji); err != nil {
+		return "", fmt.Errorf("unable to award emoji to comment: %w", err)
+	}
+
+	if _, err := r.gitlab.CreateMergeRequestNote(ctx, r.project, r.iid, body); err != nil {
+		return "", fmt.Errorf("unable to reply to comment: %w", err)
+	}
+
+	return fmt.Sprintf("replied to comment: %s", body), nil
+}
@@ -0,0 +1,39 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	neturl "net/url"
+	"os"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// cloneRepo clones the head of a branch of a repo to a new directory in dir,
+// returning its path. Repos served over http(s) are authenticated with the
+// token. The caller is responsible for removing the clone.
+func cloneRepo(ctx context.Context, dir, url, branch, token string) (string, error) {
+	path, err := os.MkdirTemp(dir, "etok-gitlab-")
+	if err != nil {
+		return "", err
+	}
+
+	opts := &git.CloneOptions{
+		URL:           url,
+		Depth:         1,
+		SingleBranch:  true,
+		ReferenceName: plumbing.NewBranchReferenceName(branch),
+	}
+	if u, err := neturl.Parse(url); err == nil && (u.Scheme == "http" || u.Scheme == "https") && token != "" {
+		opts.Auth = &http.BasicAuth{Username: "oauth2", Password: token}
+	}
+
+	if _, err := git.PlainCloneContext(ctx, path, false, opts); err != nil {
+		os.RemoveAll(path)
+		return "", fmt.Errorf("unable to clone %s: %w", url, err)
+	}
+
+	return path, nil
+}
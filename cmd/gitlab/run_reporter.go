@@ -0,0 +1,153 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/cmd/gitlab/client"
+	"github.com/leg100/etok/pkg/vcs"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// runReporter reports the progress of merge requests' runs to GitLab. It sets
+// a commit status for each run, named after the run's workspace, and upon the
+// run finishing it comments on the merge request with the outcome.
+type runReporter struct {
+	runtimeclient.Client
+
+	gitlab gitlabClient
+}
+
+func newRunReporter(rclient runtimeclient.Client, gitlab gitlabClient) *runReporter {
+	return &runReporter{
+		Client: rclient,
+		gitlab: gitlab,
+	}
+}
+
+// +kubebuilder:rbac:groups=etok.dev,resources=runs,verbs=get;list;watch;update
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get
+
+func (r *runReporter) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.V(3).Info("Reconciling")
+
+	run := &v1alpha1.Run{}
+	if err := r.Get(ctx, req.NamespacedName, run); err != nil {
+		return ctrl.Result{}, runtimeclient.IgnoreNotFound(err)
+	}
+
+	// Skip runs whose current state has already been reported
+	state := runState(run)
+	if run.Annotations[reportedAnnotation] == state {
+		return ctrl.Result{}, nil
+	}
+
+	project, err := strconv.Atoi(run.Labels[projectLabel])
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("invalid project label: %w", err)
+	}
+	iid, err := strconv.Atoi(run.Labels[mergeRequestLabel])
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("invalid merge request label: %w", err)
+	}
+
+	var summary string
+	if run.IsDone() && run.SavePlan {
+		if summary, err = r.planSummary(ctx, run); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	status := client.CommitStatus{
+		State:       state,
+		Name:        fmt.Sprintf("etok/%s/%s", run.Namespace, run.Workspace),
+		Description: fmt.Sprintf("%s: %s", run.Labels[commandLabel], describe(run, summary)),
+	}
+	if err := r.gitlab.SetCommitStatus(ctx, project, run.Labels[shaLabel], status); err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to set commit status: %w", err)
+	}
+
+	if run.IsDone() {
+		if _, err := r.gitlab.CreateMergeRequestNote(ctx, project, iid, outcome(run, summary)); err != nil {
+			return ctrl.Result{}, fmt.Errorf("unable to comment on merge request: %w", err)
+		}
+	}
+
+	// Record state so that it is only reported once
+	if run.Annotations == nil {
+		run.Annotations = make(map[string]string)
+	}
+	run.Annotations[reportedAnnotation] = state
+	if err := r.Update(ctx, run); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	log.Info("Reported run", "state", state)
+
+	return ctrl.Result{}, nil
+}
+
+// planSummary returns the summary of the plan saved by the run, or an empty
+// string if the run did not save a plan
+func (r *runReporter) planSummary(ctx context.Context, run *v1alpha1.Run) (string, error) {
+	cm := &corev1.ConfigMap{}
+	if err := r.Get(ctx, runtimeclient.ObjectKey{Namespace: run.Namespace, Name: run.PlanConfigMapName()}, cm); err != nil {
+		return "", runtimeclient.IgnoreNotFound(err)
+	}
+	return cm.Data[v1alpha1.PlanSummaryKey], nil
+}
+
+// describe the run's current state in a few words
+func describe(run *v1alpha1.Run, summary string) string {
+	if failed := meta.FindStatusCondition(run.Conditions, v1alpha1.RunPolicyFailedCondition); failed != nil && failed.Status == metav1.ConditionTrue {
+		return "plan violates policy"
+	}
+	if summary != "" {
+		return summary
+	}
+	if run.Phase == "" {
+		return string(v1alpha1.RunPhaseWaiting)
+	}
+	return string(run.Phase)
+}
+
+// outcome composes a comment reporting the outcome of a finished run
+func outcome(run *v1alpha1.Run, summary string) string {
+	body := fmt.Sprintf("`%s %s` for workspace `%s/%s` (run `%s`): **%s**\n",
+		vcs.CommentPrefix, run.Labels[commandLabel], run.Namespace, run.Workspace, run.Name, runState(run))
+
+	if summary != "" {
+		body += fmt.Sprintf("\n%s\n", summary)
+	}
+
+	for _, condType := range []string{v1alpha1.RunPolicyFailedCondition, v1alpha1.RunFailedCondition} {
+		if cond := meta.FindStatusCondition(run.Conditions, condType); cond != nil && cond.Status == metav1.ConditionTrue && cond.Message != "" {
+			body += fmt.Sprintf("\n%s: %s\n", cond.Reason, cond.Message)
+		}
+	}
+
+	body += fmt.Sprintf("\nView logs by running: `kubectl logs -n %s pods/%s`\n", run.Namespace, run.Name)
+	return body
+}
+
+func (r *runReporter) SetupWithManager(mgr ctrl.Manager) error {
+	// Only reconcile runs created for merge requests
+	hasMergeRequest := predicate.NewPredicateFuncs(func(o runtimeclient.Object) bool {
+		_, ok := o.GetLabels()[mergeRequestLabel]
+		return ok
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.Run{}, builder.WithPredicates(hasMergeRequest)).
+		Complete(r)
+}
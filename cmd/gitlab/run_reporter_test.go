@@ -0,0 +1,129 @@
+package gitlab
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/cmd/gitlab/client"
+	"github.com/leg100/etok/cmd/gitlab/client/fixtures"
+	"github.com/leg100/etok/pkg/scheme"
+	"github.com/leg100/etok/pkg/testobj"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/leg100/etok/pkg/vcs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRunReporter(t *testing.T) {
+	mrLabels := []string{projectLabel, "5", mergeRequestLabel, "7", shaLabel, "abc123", commandLabel, vcs.PlanCommand}
+
+	tests := []struct {
+		name string
+		run  *v1alpha1.Run
+		objs []runtimeclient.Object
+		// Expected commit status state
+		state string
+		// Expected description of commit status
+		description string
+		// Expected outcome comment; empty if none expected
+		comment string
+	}{
+		{
+			name:        "queued",
+			run:         testobj.Run("dev", "mr-12345", "sh", testobj.WithWorkspace("networks"), testobj.WithLabels(mrLabels...)),
+			state:       client.StatePending,
+			description: "plan: waiting",
+		},
+		{
+			name:        "running",
+			run:         testobj.Run("dev", "mr-12345", "sh", testobj.WithWorkspace("networks"), testobj.WithLabels(mrLabels...), testobj.WithRunPhase(v1alpha1.RunPhaseRunning)),
+			state:       client.StateRunning,
+			description: "plan: running",
+		},
+		{
+			name: "plan succeeded",
+			run: testobj.Run("dev", "mr-12345", "sh",
+				testobj.WithWorkspace("networks"),
+				testobj.WithLabels(mrLabels...),
+				testobj.WithSavePlan(),
+				testobj.WithRunPhase(v1alpha1.RunPhaseCompleted),
+				testobj.WithCondition(v1alpha1.RunCompleteCondition, v1alpha1.PodSucceededReason)),
+			objs: []runtimeclient.Object{
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "dev", Name: v1alpha1.RunPlanConfigMapName("mr-12345")},
+					Data:       map[string]string{v1alpha1.PlanSummaryKey: "Plan: 1 to add, 0 to change, 0 to destroy."},
+				},
+			},
+			state:       client.StateSuccess,
+			description: "plan: Plan: 1 to add, 0 to change, 0 to destroy.",
+			comment:     "`etok plan` for workspace `dev/networks` (run `mr-12345`): **success**",
+		},
+		{
+			name: "plan violates policy",
+			run: testobj.Run("dev", "mr-12345", "sh",
+				testobj.WithWorkspace("networks"),
+				testobj.WithLabels(mrLabels...),
+				testobj.WithRunPhase(v1alpha1.RunPhaseCompleted),
+				testobj.WithCondition(v1alpha1.RunCompleteCondition, v1alpha1.PodSucceededReason),
+				testobj.WithCondition(v1alpha1.RunPolicyFailedCondition, "PolicyViolation", "deletes database")),
+			state:       client.StateFailed,
+			description: "plan: plan violates policy",
+			comment:     "PolicyViolation: deletes database",
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			rclient := fake.NewClientBuilder().
+				WithScheme(scheme.Scheme).
+				WithObjects(append(tt.objs, tt.run)...).
+				Build()
+
+			srv := fixtures.NewServer()
+			defer srv.Close()
+
+			gclient, err := client.NewClient(srv.URL, "token")
+			require.NoError(t, err)
+
+			reporter := newRunReporter(rclient, gclient)
+
+			req := ctrl.Request{NamespacedName: runtimeclient.ObjectKeyFromObject(tt.run)}
+			_, err = reporter.Reconcile(context.Background(), req)
+			require.NoError(t, err)
+
+			var status, comment string
+			for _, r := range srv.Requests() {
+				switch {
+				case strings.HasSuffix(r.Path, "/statuses/abc123"):
+					status = r.Body
+				case strings.HasSuffix(r.Path, "/merge_requests/7/notes"):
+					comment = r.Body
+				}
+			}
+			assert.Contains(t, status, tt.state)
+			assert.Contains(t, status, tt.description)
+			assert.Contains(t, status, "etok/dev/networks")
+			if tt.comment != "" {
+				assert.Contains(t, comment, tt.comment)
+			} else {
+				assert.Empty(t, comment)
+			}
+
+			// State should be recorded and not reported again
+			run := &v1alpha1.Run{}
+			require.NoError(t, rclient.Get(context.Background(), req.NamespacedName, run))
+			assert.Equal(t, tt.state, run.Annotations[reportedAnnotation])
+
+			reported := len(srv.Requests())
+			_, err = reporter.Reconcile(context.Background(), req)
+			require.NoError(t, err)
+			assert.Equal(t, reported, len(srv.Requests()))
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package gitlab
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/cmd/gitlab/client"
+	"github.com/leg100/etok/pkg/builders"
+	"github.com/leg100/etok/pkg/controllers"
+	"github.com/leg100/etok/pkg/labels"
+	"github.com/leg100/etok/pkg/util"
+	"github.com/leg100/etok/pkg/vcs"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Labels identifying the merge request, and the commit and command, for which
+// a run was created
+const (
+	projectLabel      = "gitlab-project"
+	mergeRequestLabel = "gitlab-merge-request"
+	shaLabel          = "gitlab-sha"
+	commandLabel      = "gitlab-command"
+)
+
+// reportedAnnotation records the commit status state last reported for a run
+const reportedAnnotation = "gitlab.etok.dev/reported"
+
+// mergeRequestLabels returns the labels identifying runs created for a merge
+// request
+func mergeRequestLabels(projectID, iid int) runtimeclient.MatchingLabels {
+	return runtimeclient.MatchingLabels{
+		projectLabel:      strconv.Itoa(projectID),
+		mergeRequestLabel: strconv.Itoa(iid),
+	}
+}
+
+// buildRun builds a run for a merge request that runs the command against the
+// workspace. An apply applies the plan saved by the named plan run.
+func buildRun(ws *v1alpha1.Workspace, projectID int, mr *mergeRequest, cmd *vcs.Command, plan, author string) *v1alpha1.Run {
+	name := fmt.Sprintf("mr-%s", util.GenerateRandomString(5))
+
+	bldr := builders.Run(ws.Namespace, name, ws.Name, "sh", script(name, cmd, plan)).
+		SetLabel(projectLabel, strconv.Itoa(projectID)).
+		SetLabel(mergeRequestLabel, strconv.Itoa(mr.IID)).
+		SetLabel(shaLabel, mr.LastCommit.ID).
+		SetLabel(commandLabel, cmd.Name).
+		LaunchedBy(author)
+	switch cmd.Name {
+	case vcs.PlanCommand:
+		bldr = bldr.SavePlan()
+	case vcs.ApplyCommand:
+		// Identify the run as an apply, for the benefit of the run retention
+		// policy
+		bldr = bldr.SetLabel(labels.ApplyScript.Name, labels.ApplyScript.Value)
+	}
+	return bldr.Build()
+}
+
+// script generates the script a run executes. A plan saves its plan file under
+// the run's name, and an apply applies the plan file saved by the named plan
+// run.
+func script(name string, cmd *vcs.Command, plan string) string {
+	initCmd := "terraform init -no-color -input=false"
+
+	// Quote args to prevent their interpretation by the shell
+	var args string
+	for _, arg := range cmd.Args {
+		args += " " + vcs.ShellQuote(arg)
+	}
+
+	if cmd.Name == vcs.ApplyCommand {
+		// Arguments are never passed to apply, which applies the saved plan
+		// as is
+		return fmt.Sprintf("%s && terraform apply -no-color -input=false %s", initCmd, filepath.Join(controllers.PlansMountPath, plan))
+	}
+	return fmt.Sprintf("%s && terraform plan -no-color -input=false%s -out=%s", initCmd, args, filepath.Join(controllers.PlansMountPath, name))
+}
+
+// latestPlan returns the most recent plan run of a workspace for a commit, or
+// nil if there is none
+func latestPlan(runs []v1alpha1.Run, ws *v1alpha1.Workspace, sha string) *v1alpha1.Run {
+	var plans []*v1alpha1.Run
+	for i, run := range runs {
+		if run.Namespace != ws.Namespace || run.Workspace != ws.Name {
+			continue
+		}
+		if run.Labels[shaLabel] != sha || run.Labels[commandLabel] != vcs.PlanCommand {
+			continue
+		}
+		plans = append(plans, &runs[i])
+	}
+	if len(plans) == 0 {
+		return nil
+	}
+
+	sort.Slice(plans, func(i, j int) bool {
+		return plans[i].CreationTimestamp.Before(&plans[j].CreationTimestamp)
+	})
+	return plans[len(plans)-1]
+}
+
+// runState maps the phase of a run to the state of a commit status
+func runState(run *v1alpha1.Run) string {
+	switch run.Phase {
+	case v1alpha1.RunPhaseRunning:
+		return client.StateRunning
+	case v1alpha1.RunPhaseCompleted:
+		if !succeeded(run) {
+			return client.StateFailed
+		}
+		return client.StateSuccess
+	case v1alpha1.RunPhaseFailed:
+		return client.StateFailed
+	case v1alpha1.RunPhaseCancelled:
+		return client.StateCanceled
+	default:
+		return client.StatePending
+	}
+}
+
+// succeeded determines whether the run completed successfully, and, if it
+// saved a plan, whether the plan complies with policy
+func succeeded(run *v1alpha1.Run) bool {
+	if meta.IsStatusConditionTrue(run.Conditions, v1alpha1.RunPolicyFailedCondition) {
+		return false
+	}
+	complete := meta.FindStatusCondition(run.Conditions, v1alpha1.RunCompleteCondition)
+	return complete != nil && complete.Status == metav1.ConditionTrue && complete.Reason == v1alpha1.PodSucceededReason
+}
@@ -0,0 +1,42 @@
+package gitlab
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/leg100/etok/pkg/testutil"
+)
+
+// initializeRepo creates a local mock of an upstream gitlab repo, with a
+// 'changes' branch to serve as a merge request's source branch. Returns the
+// path to the repo and the SHA of the branch's head commit.
+func initializeRepo(t *testutil.T) (string, string) {
+	tmpdir := t.NewTempDir().Mkdir("bob/myrepo.git")
+	repo := filepath.Join(tmpdir.Root(), "bob", "myrepo.git")
+
+	runCmdInRepo(t, repo, "git", "init")
+	runCmdInRepo(t, repo, "touch", ".gitkeep")
+	runCmdInRepo(t, repo, "git", "add", ".gitkeep")
+
+	runCmdInRepo(t, repo, "git", "config", "--local", "user.email", "etok@etok.dev")
+	runCmdInRepo(t, repo, "git", "config", "--local", "user.name", "etok")
+	runCmdInRepo(t, repo, "git", "commit", "-m", "initial commit")
+	runCmdInRepo(t, repo, "git", "checkout", "-b", "changes")
+	runCmdInRepo(t, repo, "touch", "main.tf")
+	runCmdInRepo(t, repo, "git", "add", ".")
+	runCmdInRepo(t, repo, "git", "commit", "-am", "changes commit")
+	headSHA := runCmdInRepo(t, repo, "git", "rev-parse", "HEAD")
+
+	return repo, strings.Trim(headSHA, "\n")
+}
+
+func runCmdInRepo(t *testutil.T, dir string, name string, args ...string) string {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Errorf("%s %s failed: %s", name, args, out)
+	}
+	return string(out)
+}
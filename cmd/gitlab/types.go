@@ -0,0 +1,19 @@
+package gitlab
+
+import (
+	"context"
+
+	"github.com/leg100/etok/cmd/gitlab/client"
+)
+
+// gitlabClient provides the GitLab API endpoints used by the integration
+type gitlabClient interface {
+	SetCommitStatus(ctx context.Context, project int, sha string, status client.CommitStatus) error
+	CreateMergeRequestNote(ctx context.Context, project, iid int, body string) (*client.Note, error)
+	AwardNoteEmoji(ctx context.Context, project, iid, note int, name string) error
+	GetMember(ctx context.Context, project, user int) (*client.Member, error)
+}
+
+type gitlabApp interface {
+	handleEvent(string, []byte) (string, error)
+}
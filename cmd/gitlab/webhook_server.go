@@ -0,0 +1,114 @@
+package gitlab
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/leg100/etok/cmd/github"
+	"github.com/urfave/negroni"
+	"k8s.io/klog/v2"
+)
+
+const (
+	gitlabEventHeader = "X-Gitlab-Event"
+	gitlabTokenHeader = "X-Gitlab-Token"
+)
+
+// webhookServer listens for gitlab events and dispatches them to the gitlab
+// app. Events are authenticated with the secret token configured for the
+// project's webhook.
+type webhookServer struct {
+	// Port on which to listen for gitlab events
+	port int
+
+	// Secret token with which incoming events are validated
+	webhookSecret string
+
+	// The gitlab app to which to dispatch received events
+	app gitlabApp
+}
+
+func (s *webhookServer) validate() error {
+	if s.webhookSecret == "" {
+		return fmt.Errorf("webhook secret cannot be an empty string")
+	}
+
+	return nil
+}
+
+func (s *webhookServer) run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", s.port))
+	if err != nil {
+		return err
+	}
+	klog.Infof("Listening on %s\n", listener.Addr())
+
+	// Record port for testing purposes (a test may want to know which port was
+	// dynamically assigned)
+	s.port = listener.Addr().(*net.TCPAddr).Port
+
+	r := mux.NewRouter()
+	r.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(nil)
+	})
+	r.HandleFunc("/events", s.eventHandler).Methods("POST")
+
+	// Add middleware
+	n := negroni.New()
+	n.Use(negroni.NewRecovery())
+	n.Use(github.NewLogger())
+	n.UseHandler(r)
+
+	server := &http.Server{Handler: n}
+	go func() {
+		if err := server.Serve(listener); err != http.ErrServerClosed {
+			klog.Error(err.Error())
+		}
+	}()
+
+	<-ctx.Done()
+
+	klog.V(1).Info("Shutting down webhook server")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return server.Shutdown(ctx)
+}
+
+func (s *webhookServer) eventHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.Header.Get(gitlabEventHeader)
+	if name == "" {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+
+	token := r.Header.Get(gitlabTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.webhookSecret)) != 1 {
+		http.Error(w, "invalid webhook token", http.StatusUnauthorized)
+		return
+	}
+
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.app.handleEvent(name, payload)
+	if err != nil {
+		eventsTotal.WithLabelValues(name, "error").Inc()
+		klog.ErrorS(err, "handled event", "name", name)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	eventsTotal.WithLabelValues(name, "success").Inc()
+	klog.InfoS("handled event", "name", name, "result", result)
+
+	w.Write(nil)
+}
@@ -0,0 +1,67 @@
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeApp struct{}
+
+func (a *fakeApp) handleEvent(_ string, _ []byte) (string, error) {
+	return "", nil
+}
+
+func TestWebhookServer(t *testing.T) {
+	server := webhookServer{
+		app:           &fakeApp{},
+		webhookSecret: "secret",
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errch := make(chan error)
+	go func() {
+		errch <- server.run(ctx)
+	}()
+
+	// Wait for dynamic port to be assigned
+	for {
+		if server.port != 0 {
+			break
+		}
+	}
+
+	requestJSON, _ := os.ReadFile("fixtures/mergeRequestOpenedEvent.json")
+
+	send := func(token string) *http.Response {
+		url := fmt.Sprintf("http://localhost:%d/events", server.port)
+		req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestJSON))
+		require.NoError(t, err)
+
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(gitlabEventHeader, mergeRequestHook)
+		req.Header.Set(gitlabTokenHeader, token)
+
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return res
+	}
+
+	processed := testutil.ToFloat64(eventsTotal.WithLabelValues(mergeRequestHook, "success"))
+
+	assert.Equal(t, 401, send("wrong-secret").StatusCode)
+	assert.Equal(t, processed, testutil.ToFloat64(eventsTotal.WithLabelValues(mergeRequestHook, "success")))
+
+	assert.Equal(t, 200, send("secret").StatusCode)
+	assert.Equal(t, processed+1, testutil.ToFloat64(eventsTotal.WithLabelValues(mergeRequestHook, "success")))
+
+	cancel()
+	require.NoError(t, <-errch)
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/spf13/pflag"
 	"sigs.k8s.io/yaml"
 
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	yamlserializer "k8s.io/apimachinery/pkg/runtime/serializer/yaml"
 )
@@ -227,6 +228,16 @@ func (o *installOptions) install(ctx context.Context, client runtimeclient.Clien
 				}
 			}
 
+			// Mount volume required by backup provider
+			if vol, mount := o.backupCfg.Volume(); vol != nil {
+				if err := appendNestedObj(containers[0].(map[string]interface{}), mount, "volumeMounts"); err != nil {
+					panic(err.Error())
+				}
+				if err := appendNestedObj(obj.Object, vol, "spec", "template", "spec", "volumes"); err != nil {
+					panic(err.Error())
+				}
+			}
+
 			// Update deployment with updated container
 			if err := unstructured.SetNestedSlice(obj.Object, containers, "spec", "template", "spec", "containers"); err != nil {
 				panic(err.Error())
@@ -285,3 +296,19 @@ func (o *installOptions) install(ctx context.Context, client runtimeclient.Clien
 
 	return nil
 }
+
+// appendNestedObj converts obj to its unstructured representation and appends
+// it to the slice found at fields within u, creating the slice if necessary
+func appendNestedObj(u map[string]interface{}, obj interface{}, fields ...string) error {
+	converted, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+
+	existing, _, err := unstructured.NestedSlice(u, fields...)
+	if err != nil {
+		return err
+	}
+
+	return unstructured.SetNestedSlice(u, append(existing, converted), fields...)
+}
@@ -186,6 +186,46 @@ func TestInstall(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "fresh install with fs backups on pvc",
+			args: []string{"install", "--wait=false", "--backup-provider=fs", "--fs-pvc=backups-pvc"},
+			assertions: func(t *testutil.T, client runtimeclient.Client) {
+				deployment := &appsv1.Deployment{}
+				require.NoError(t, client.Get(context.Background(), runtimeclient.ObjectKey{Namespace: "etok", Name: "etok"}, deployment))
+
+				assert.Contains(t, deployment.Spec.Template.Spec.Volumes, corev1.Volume{
+					Name: "backups",
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+							ClaimName: "backups-pvc",
+						},
+					},
+				})
+				assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+					Name:      "backups",
+					MountPath: "/backups",
+				})
+			},
+		},
+		{
+			name: "fresh install with azure backups",
+			args: []string{"install", "--wait=false", "--backup-provider=azure", "--azure-storage-account=etok", "--azure-container=backups"},
+			assertions: func(t *testutil.T, client runtimeclient.Client) {
+				deployment := &appsv1.Deployment{}
+				require.NoError(t, client.Get(context.Background(), runtimeclient.ObjectKey{Namespace: "etok", Name: "etok"}, deployment))
+
+				assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{
+					Name:  "ETOK_AZURE_CONTAINER",
+					Value: "backups",
+				})
+				assert.Empty(t, deployment.Spec.Template.Spec.Volumes)
+			},
+		},
+		{
+			name: "fs backups with both pvc and host path",
+			args: []string{"install", "--wait=false", "--backup-provider=fs", "--fs-pvc=backups-pvc", "--fs-host-path=/var/backups"},
+			err:  backup.ErrInvalidConfig,
+		},
 		{
 			name: "missing backup bucket name",
 			args: []string{"install", "--wait=false", "--backup-provider=gcs"},
@@ -201,7 +241,7 @@ func TestInstall(t *testing.T) {
 			args: []string{"install", "--dry-run"},
 			dryRunAssertions: func(t *testutil.T, out *bytes.Buffer) {
 				// Assert correct number of k8s objs are serialized to yaml
-				docs := strings.Split(out.String(), "---\n")
+				docs := strings.Split(out.String(), "\n---\n")
 				assert.Equal(t, 8, len(docs))
 			},
 		},
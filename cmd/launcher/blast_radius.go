@@ -0,0 +1,45 @@
+package launcher
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/fatih/color"
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+)
+
+// printBlastRadius prints a plan's blast radius: the number of resources
+// changed per provider and per module, followed by any critical resources that
+// are to be replaced or deleted.
+func printBlastRadius(out io.Writer, br *v1alpha1.BlastRadius) {
+	if len(br.Providers) == 0 {
+		return
+	}
+
+	fmt.Fprintln(out, "Blast radius:")
+	printCounts(out, "Providers", br.Providers)
+	printCounts(out, "Modules", br.Modules)
+
+	for _, addr := range br.Critical {
+		fmt.Fprintf(out, "%s critical resource %s is to be replaced or deleted\n", color.YellowString("Warning:"), addr)
+	}
+}
+
+// printCounts prints resource counts, sorted by key
+func printCounts(out io.Writer, heading string, counts map[string]int) {
+	var keys []string
+	var width int
+	for k := range counts {
+		keys = append(keys, k)
+		if len(k) > width {
+			width = len(k)
+		}
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(out, "  %s:\n", heading)
+	for _, k := range keys {
+		fmt.Fprintf(out, "    %-*s  %d\n", width, k, counts[k])
+	}
+}
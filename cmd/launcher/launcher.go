@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/user"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/fatih/color"
@@ -18,6 +20,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 	watchtools "k8s.io/client-go/tools/watch"
+	"k8s.io/client-go/util/retry"
 
 	"github.com/leg100/etok/api/etok.dev/v1alpha1"
 	"github.com/leg100/etok/cmd/flags"
@@ -28,6 +31,7 @@ import (
 	"github.com/leg100/etok/pkg/globals"
 	"github.com/leg100/etok/pkg/handlers"
 	"github.com/leg100/etok/pkg/k8s"
+	"github.com/leg100/etok/pkg/labels"
 	"github.com/leg100/etok/pkg/logstreamer"
 	"github.com/leg100/etok/pkg/monitors"
 	"github.com/leg100/etok/pkg/repo"
@@ -43,6 +47,9 @@ const (
 	defaultWorkspace        = "default"
 	defaultReconcileTimeout = 10 * time.Second
 
+	// timeout for cancelling the run upon the launcher being interrupted
+	defaultCancelTimeout = 10 * time.Second
+
 	// default namespace runs are created in
 	defaultNamespace  = "default"
 	defaultPodTimeout = time.Hour
@@ -53,6 +60,9 @@ var (
 	errWorkspaceNotFound = errors.New("workspace not found")
 	errWorkspaceNotReady = errors.New("workspace not ready")
 	errReconcileTimeout  = errors.New("timed out waiting for run to be reconciled")
+	errPlanNotFound      = errors.New("saved plan not found")
+	errStalePlan         = errors.New("saved plan is stale")
+	errPolicyViolation   = errors.New("saved plan violates policy")
 )
 
 // launcherOptions deploys a new Run. It monitors not only its progress, but
@@ -108,6 +118,18 @@ type launcherOptions struct {
 
 	// Git repo from which run is being launched
 	repo *repo.Repo
+
+	// Save the plan file produced by plan command
+	savePlan bool
+	// Name of plan run whose saved plan file is to be applied
+	plan string
+
+	// Maximum duration of the run, after which it is cancelled
+	maxDuration time.Duration
+
+	// Override workspace's timeouts for the run
+	queueTimeout      time.Duration
+	podPendingTimeout time.Duration
 }
 
 func launcherCommand(f *cmdutil.Factory, o *launcherOptions) *cobra.Command {
@@ -154,6 +176,14 @@ func launcherCommand(f *cmdutil.Factory, o *launcherOptions) *cobra.Command {
 			}
 
 			err = o.run(cmd.Context())
+			if err != nil && o.createdRun && cmd.Context().Err() != nil {
+				// The user interrupted the launcher. Cancel the run rather
+				// than leave it running or delete it, which would kill its
+				// pod and with it the command, whereas cancelling the run
+				// interrupts the command gracefully.
+				o.cancelRun()
+				return err
+			}
 			if err != nil {
 				// Cleanup resources upon error. An exit code error means the
 				// runner ran successfully but the program it executed failed
@@ -181,6 +211,16 @@ func launcherCommand(f *cmdutil.Factory, o *launcherOptions) *cobra.Command {
 	cmd.Flags().DurationVar(&o.handshakeTimeout, "handshake-timeout", v1alpha1.DefaultHandshakeTimeout, "timeout waiting for handshake")
 
 	cmd.Flags().DurationVar(&o.reconcileTimeout, "reconcile-timeout", defaultReconcileTimeout, "timeout for resource to be reconciled")
+	cmd.Flags().DurationVar(&o.maxDuration, "max-duration", 0, "maximum duration of the run, after which it is cancelled (0 means no maximum)")
+	cmd.Flags().DurationVar(&o.queueTimeout, "queue-timeout", 0, "maximum time the run can wait in the workspace queue (0 means the workspace's timeout)")
+	cmd.Flags().DurationVar(&o.podPendingTimeout, "pod-pending-timeout", 0, "maximum time the run's pod can remain pending (0 means the workspace's timeout)")
+
+	switch o.command.Path {
+	case "plan":
+		cmd.Flags().BoolVar(&o.savePlan, "save", false, "Save plan so that it can be applied with 'etok apply --plan <run>'")
+	case "apply":
+		cmd.Flags().StringVar(&o.plan, "plan", "", "Apply plan saved by the given plan run")
+	}
 
 	return cmd
 }
@@ -198,6 +238,13 @@ func lookupEnvFile(path string) (*env.Env, error) {
 }
 
 func (o *launcherOptions) run(ctx context.Context) error {
+	// Refuse to apply a saved plan that is no longer valid
+	if o.plan != "" {
+		if err := o.checkPlan(ctx); err != nil {
+			return err
+		}
+	}
+
 	// Tar up local config and deploy k8s resources
 	run, err := o.deploy(ctx)
 	if err != nil {
@@ -258,6 +305,21 @@ func (o *launcherOptions) run(ctx context.Context) error {
 		}
 	}
 
+	if o.command.Path == "plan" {
+		// The blast radius is recorded on the run upon completion
+		run, err = o.RunsClient(o.namespace).Get(ctx, run.Name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to retrieve run: %w", err)
+		}
+		if run.BlastRadius != nil {
+			printBlastRadius(o.Out, run.BlastRadius)
+		}
+	}
+
+	if o.savePlan {
+		fmt.Fprintf(o.Out, "Saved plan %s. To apply it run: etok apply --plan %[1]s\n", run.Name)
+	}
+
 	if o.command.UpdatesLockFile {
 		// Some commands (e.g. terraform init) update the lock file,
 		// .terraform.lock.hcl, and it's recommended that this be committed to
@@ -327,6 +389,64 @@ func (o *launcherOptions) checkWorkspace(ctx context.Context, run *v1alpha1.Run)
 	return nil
 }
 
+// checkPlan checks that the saved plan to be applied exists, that it does not
+// violate policy, and that the state has not been updated since the plan was
+// created.
+func (o *launcherOptions) checkPlan(ctx context.Context) error {
+	cm, err := o.ConfigMapsClient(o.namespace).Get(ctx, v1alpha1.RunPlanConfigMapName(o.plan), metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return fmt.Errorf("%w: %s", errPlanNotFound, o.plan)
+	}
+	if err != nil {
+		return err
+	}
+
+	if lbl := labels.Workspace(o.workspace); cm.Labels[lbl.Name] != lbl.Value {
+		return fmt.Errorf("%w: %s was not created for workspace %s", errPlanNotFound, o.plan, o.workspace)
+	}
+
+	planRun, err := o.RunsClient(o.namespace).Get(ctx, o.plan, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return fmt.Errorf("%w: %s", errPlanNotFound, o.plan)
+	}
+	if err != nil {
+		return err
+	}
+
+	ws, err := o.WorkspacesClient(o.namespace).Get(ctx, o.workspace, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return fmt.Errorf("%w: %s/%s", errWorkspaceNotFound, o.namespace, o.workspace)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !planRun.CompliesWithPolicy(ws) {
+		if cond := meta.FindStatusCondition(planRun.Conditions, v1alpha1.RunPolicyFailedCondition); cond != nil && cond.Status == metav1.ConditionTrue {
+			return fmt.Errorf("%w: %s", errPolicyViolation, cond.Message)
+		}
+		return fmt.Errorf("%w: plan %s has not been evaluated against policy", errPolicyViolation, o.plan)
+	}
+
+	// Absence of a serial is equivalent to a new, empty, state
+	var planSerial, currentSerial int
+	if s, ok := cm.Data[v1alpha1.PlanSerialKey]; ok {
+		planSerial, err = strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("invalid serial in saved plan %s: %w", o.plan, err)
+		}
+	}
+	if ws.Status.Serial != nil {
+		currentSerial = *ws.Status.Serial
+	}
+
+	if planSerial != currentSerial {
+		return fmt.Errorf("%w: plan %s was created from state #%d but the state is now #%d", errStalePlan, o.plan, planSerial, currentSerial)
+	}
+
+	return nil
+}
+
 // Deploy ConfigMap and Run resources in parallel
 func (o *launcherOptions) deploy(ctx context.Context) (run *v1alpha1.Run, err error) {
 	g, ctx := errgroup.WithContext(ctx)
@@ -368,6 +488,32 @@ func (o *launcherOptions) cleanup() {
 	}
 }
 
+// cancelRun sets the run to be cancelled. The launcher's context has been
+// cancelled by this point so a new context is used.
+func (o *launcherOptions) cancelRun() {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultCancelTimeout)
+	defer cancel()
+
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		run, err := o.RunsClient(o.namespace).Get(ctx, o.runName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if run.IsDone() {
+			return nil
+		}
+		run.Cancel = true
+		_, err = o.RunsClient(o.namespace).Update(ctx, run, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		fmt.Fprintf(o.Out, "%s unable to cancel run %s: %s\n", color.YellowString("Warning:"), o.runName, err.Error())
+		return
+	}
+
+	fmt.Fprintf(o.Out, "Cancelling run %s\n", o.runName)
+}
+
 func (o *launcherOptions) approveRun(ctx context.Context, ws *v1alpha1.Workspace, run *v1alpha1.Run) error {
 	klog.V(1).Infof("%v is a privileged command on workspace\n", o.command)
 	annotations := ws.GetAnnotations()
@@ -405,6 +551,34 @@ func (o *launcherOptions) createRun(ctx context.Context, name string) (*v1alpha1
 		bldr.Attach()
 	}
 
+	if o.savePlan {
+		bldr.SavePlan()
+	}
+
+	if o.plan != "" {
+		bldr.ApplyPlan(o.plan)
+	}
+
+	if o.maxDuration > 0 {
+		bldr.MaxDuration(o.maxDuration)
+	}
+
+	var timeouts v1alpha1.RunTimeouts
+	if o.queueTimeout > 0 {
+		timeouts.Queue = &metav1.Duration{Duration: o.queueTimeout}
+	}
+	if o.podPendingTimeout > 0 {
+		timeouts.PodPending = &metav1.Duration{Duration: o.podPendingTimeout}
+	}
+	bldr.Timeouts(timeouts)
+
+	// Record who launched the run, for the benefit of those inspecting the
+	// workspace queue. This is the local username, which is unverified and
+	// could differ from the user authenticated with the cluster.
+	if u, err := user.Current(); err == nil {
+		bldr.LaunchedBy(u.Username)
+	}
+
 	run, err := o.RunsClient(o.namespace).Create(ctx, bldr.Build(), metav1.CreateOptions{})
 	if err != nil {
 		return nil, err
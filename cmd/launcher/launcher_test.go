@@ -6,6 +6,7 @@ import (
 	"errors"
 	"io"
 	"testing"
+	"time"
 
 	"github.com/creack/pty"
 	"github.com/go-git/go-git/v5"
@@ -41,6 +42,8 @@ func TestLauncher(t *testing.T) {
 		size int
 		// Mock exit code of runner container
 		code int32
+		// Mock user interrupting the launcher whilst it streams logs
+		interrupt bool
 		// Override run status
 		overrideStatus   func(*v1alpha1.RunStatus)
 		factoryOverrides func(*cmdutil.Factory)
@@ -173,6 +176,19 @@ func TestLauncher(t *testing.T) {
 				assert.NoError(t, err)
 			},
 		},
+		{
+			name:      "cancel run upon interrupt",
+			objs:      []runtime.Object{testobj.Workspace("default", "default", testobj.WithCombinedQueue("run-12345"))},
+			interrupt: true,
+			err:       context.Canceled,
+			assertions: func(t *testutil.T, o *launcherOptions) {
+				run, err := o.RunsClient(o.namespace).Get(context.Background(), o.runName, metav1.GetOptions{})
+				require.NoError(t, err)
+				assert.True(t, run.Cancel)
+
+				assert.Contains(t, o.Out.(*bytes.Buffer).String(), "Cancelling run run-12345")
+			},
+		},
 		{
 			name: "resources are not cleaned up upon exit code error",
 			args: []string{},
@@ -256,6 +272,117 @@ func TestLauncher(t *testing.T) {
 			},
 			err: handlers.PrematurelySucceededPodError,
 		},
+		{
+			name: "save plan",
+			args: []string{"--save"},
+			objs: []runtime.Object{testobj.Workspace("default", "default")},
+			assertions: func(t *testutil.T, o *launcherOptions) {
+				run, err := o.RunsClient(o.namespace).Get(context.Background(), "run-12345", metav1.GetOptions{})
+				require.NoError(t, err)
+				assert.True(t, run.SavePlan)
+
+				assert.Contains(t, o.Out.(*bytes.Buffer).String(), "etok apply --plan run-12345")
+			},
+		},
+		{
+			name: "timeouts",
+			args: []string{"--queue-timeout", "3h", "--pod-pending-timeout", "10m"},
+			objs: []runtime.Object{testobj.Workspace("default", "default")},
+			assertions: func(t *testutil.T, o *launcherOptions) {
+				run, err := o.RunsClient(o.namespace).Get(context.Background(), "run-12345", metav1.GetOptions{})
+				require.NoError(t, err)
+				assert.Nil(t, run.Timeouts.Enqueue)
+				assert.Equal(t, &metav1.Duration{Duration: 3 * time.Hour}, run.Timeouts.Queue)
+				assert.Equal(t, &metav1.Duration{Duration: 10 * time.Minute}, run.Timeouts.PodPending)
+			},
+		},
+		{
+			name: "plan with blast radius",
+			args: []string{},
+			objs: []runtime.Object{testobj.Workspace("default", "default")},
+			overrideStatus: func(status *v1alpha1.RunStatus) {
+				status.BlastRadius = &v1alpha1.BlastRadius{
+					Providers: map[string]int{"registry.terraform.io/hashicorp/aws": 2},
+					Modules:   map[string]int{"root": 1, "module.db": 1},
+					Critical:  []string{"module.db.aws_db_instance.main"},
+				}
+			},
+			assertions: func(t *testutil.T, o *launcherOptions) {
+				assert.Contains(t, o.Out.(*bytes.Buffer).String(), `Blast radius:
+  Providers:
+    registry.terraform.io/hashicorp/aws  2
+  Modules:
+    module.db  1
+    root       1
+`)
+				assert.Contains(t, o.Out.(*bytes.Buffer).String(), "critical resource module.db.aws_db_instance.main is to be replaced or deleted")
+			},
+		},
+		{
+			name: "apply saved plan",
+			cmd:  &commands.Command{Path: "apply", Queueable: true},
+			args: []string{"--plan", "run-00001"},
+			objs: []runtime.Object{
+				testobj.Workspace("default", "default", testobj.WithCombinedQueue("run-12345"), testobj.WithSerial(3)),
+				testobj.ConfigMap("default", "run-00001-plan", testobj.WithSavedPlan("default", 3, "Plan: 1 to add, 0 to change, 0 to destroy.")),
+				testobj.Run("default", "run-00001", "plan", testobj.WithWorkspace("default")),
+			},
+			assertions: func(t *testutil.T, o *launcherOptions) {
+				run, err := o.RunsClient(o.namespace).Get(context.Background(), "run-12345", metav1.GetOptions{})
+				require.NoError(t, err)
+				assert.Equal(t, "run-00001", run.Plan)
+			},
+		},
+		{
+			name: "apply stale plan",
+			cmd:  &commands.Command{Path: "apply", Queueable: true},
+			args: []string{"--plan", "run-00001"},
+			objs: []runtime.Object{
+				testobj.Workspace("default", "default", testobj.WithCombinedQueue("run-12345"), testobj.WithSerial(4)),
+				testobj.ConfigMap("default", "run-00001-plan", testobj.WithSavedPlan("default", 3, "Plan: 1 to add, 0 to change, 0 to destroy.")),
+				testobj.Run("default", "run-00001", "plan", testobj.WithWorkspace("default")),
+			},
+			err: errStalePlan,
+		},
+		{
+			name: "apply plan that violates policy",
+			cmd:  &commands.Command{Path: "apply", Queueable: true},
+			args: []string{"--plan", "run-00001"},
+			objs: []runtime.Object{
+				testobj.Workspace("default", "default", testobj.WithCombinedQueue("run-12345"), testobj.WithSerial(3)),
+				testobj.ConfigMap("default", "run-00001-plan", testobj.WithSavedPlan("default", 3, "Plan: 0 to add, 0 to change, 1 to destroy.")),
+				testobj.Run("default", "run-00001", "plan", testobj.WithWorkspace("default"), testobj.WithCondition(v1alpha1.RunPolicyFailedCondition, v1alpha1.PolicyViolationReason, "protect-databases: deletion of aws_db_instance.main is forbidden")),
+			},
+			err: errPolicyViolation,
+		},
+		{
+			name: "apply plan that has not been evaluated against policy",
+			cmd:  &commands.Command{Path: "apply", Queueable: true},
+			args: []string{"--plan", "run-00001"},
+			objs: []runtime.Object{
+				testobj.Workspace("default", "default", testobj.WithCombinedQueue("run-12345"), testobj.WithSerial(3), testobj.WithPolicyRuleSets("policy")),
+				testobj.ConfigMap("default", "run-00001-plan", testobj.WithSavedPlan("default", 3, "Plan: 0 to add, 0 to change, 1 to destroy.")),
+				testobj.Run("default", "run-00001", "plan", testobj.WithWorkspace("default")),
+			},
+			err: errPolicyViolation,
+		},
+		{
+			name: "apply plan saved for another workspace",
+			cmd:  &commands.Command{Path: "apply", Queueable: true},
+			args: []string{"--plan", "run-00001"},
+			objs: []runtime.Object{
+				testobj.Workspace("default", "default", testobj.WithCombinedQueue("run-12345"), testobj.WithSerial(3)),
+				testobj.ConfigMap("default", "run-00001-plan", testobj.WithSavedPlan("other", 3, "Plan: 1 to add, 0 to change, 0 to destroy.")),
+			},
+			err: errPlanNotFound,
+		},
+		{
+			name: "apply non-existent plan",
+			cmd:  &commands.Command{Path: "apply", Queueable: true},
+			args: []string{"--plan", "run-00001"},
+			objs: []runtime.Object{testobj.Workspace("default", "default", testobj.WithCombinedQueue("run-12345"))},
+			err:  errPlanNotFound,
+		},
 		{
 			name: "config too big",
 			size: 1024*1024 + 1,
@@ -312,6 +439,15 @@ func TestLauncher(t *testing.T) {
 				tt.factoryOverrides(f)
 			}
 
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.interrupt {
+				f.GetLogsFunc = func(context.Context, logstreamer.Options) (io.ReadCloser, error) {
+					cancel()
+					return nil, context.Canceled
+				}
+			}
+
 			// Default to plan command
 			command := &commands.Command{Path: "plan"}
 			if tt.cmd != nil {
@@ -345,7 +481,7 @@ func TestLauncher(t *testing.T) {
 			cmd.SetOut(out)
 			cmd.SetArgs(tt.args)
 
-			err = cmd.ExecuteContext(context.Background())
+			err = cmd.ExecuteContext(ctx)
 			if !assert.True(t, errors.Is(err, tt.err)) {
 				t.Errorf("unexpected error: %w", err)
 			}
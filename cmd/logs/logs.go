@@ -0,0 +1,182 @@
+package logs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/leg100/etok/cmd/backup"
+	"github.com/leg100/etok/cmd/flags"
+	cmdutil "github.com/leg100/etok/cmd/util"
+	pkgbackup "github.com/leg100/etok/pkg/backup"
+	"github.com/leg100/etok/pkg/client"
+	"github.com/leg100/etok/pkg/env"
+	"github.com/leg100/etok/pkg/globals"
+	"github.com/leg100/etok/pkg/logstreamer"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// default namespace if .terraform/environment is not found
+	defaultNamespace = "default"
+)
+
+var (
+	errRunNotFound          = errors.New("run not found")
+	errLogsNotFound         = errors.New("logs not found: the run's pod no longer exists and its logs have not been archived")
+	errNoBackupProvider     = errors.New("the run's logs have been archived but no backup provider has been specified")
+	errArchivedLogsNotFound = errors.New("archived logs not found")
+)
+
+type logsOptions struct {
+	*cmdutil.Factory
+
+	*client.Client
+
+	path        string
+	namespace   string
+	kubeContext string
+
+	run string
+
+	// Follow the logs of a run that is running
+	follow bool
+
+	// Backup provider configuration, with which archived logs are retrieved
+	backupCfg *backup.Config
+
+	// Constructs the backup provider. Substitutable for testing.
+	createProvider func(context.Context) (pkgbackup.Provider, error)
+}
+
+func LogsCmd(f *cmdutil.Factory) (*cobra.Command, *logsOptions) {
+	o := &logsOptions{
+		Factory:   f,
+		namespace: defaultNamespace,
+		backupCfg: backup.NewConfig(),
+	}
+	o.createProvider = o.backupCfg.CreateSelectedProvider
+
+	cmd := &cobra.Command{
+		Use:   "logs <run>",
+		Short: "Print the logs of a run",
+		Long:  "Print the logs of a run. The logs are read from the run's pod if it still exists, otherwise they are read from the archive, even if the run itself has since been deleted, for which the backup provider with which the operator archives logs must be specified.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			o.run = args[0]
+
+			// Override default namespace with value from env file, unless
+			// flag is set
+			etokenv, err := env.Read(o.path)
+			if err != nil {
+				if !os.IsNotExist(err) {
+					return err
+				}
+			} else if !flags.IsFlagPassed(cmd.Flags(), "namespace") {
+				o.namespace = etokenv.Namespace
+			}
+
+			if err := o.backupCfg.Validate(cmd.Flags()); err != nil {
+				return err
+			}
+
+			o.Client, err = f.Create(o.kubeContext)
+			if err != nil {
+				return err
+			}
+
+			return o.logs(cmd.Context())
+		},
+	}
+
+	flags.AddPathFlag(cmd, &o.path)
+	flags.AddNamespaceFlag(cmd, &o.namespace)
+	flags.AddKubeContextFlag(cmd, &o.kubeContext)
+
+	cmd.Flags().BoolVarP(&o.follow, "follow", "f", false, "Follow the logs of a run that is running")
+
+	o.backupCfg.AddToFlagSet(cmd.Flags())
+
+	return cmd, o
+}
+
+func (o *logsOptions) logs(ctx context.Context) error {
+	run, err := o.RunsClient(o.namespace).Get(ctx, o.run, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		// The run may have been deleted, e.g. by the run retention policy,
+		// after its logs were archived
+		logs, err := o.retrieveArchivedLogs(ctx, "")
+		if err != nil && !errors.Is(err, errNoBackupProvider) {
+			return err
+		}
+		if logs == nil {
+			return fmt.Errorf("%w: %s/%s", errRunNotFound, o.namespace, o.run)
+		}
+		_, err = o.Out.Write(logs)
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	// Read logs from the pod if it still exists
+	_, err = o.PodsClient(o.namespace).Get(ctx, o.run, metav1.GetOptions{})
+	if err == nil {
+		stream, err := o.GetLogsFunc(ctx, logstreamer.Options{
+			PodsClient:    o.PodsClient(o.namespace),
+			PodName:       o.run,
+			PodLogOptions: &corev1.PodLogOptions{Follow: o.follow, Container: globals.RunnerContainerName},
+		})
+		if err != nil {
+			return err
+		}
+		defer stream.Close()
+
+		_, err = io.Copy(o.Out, stream)
+		return err
+	} else if !kerrors.IsNotFound(err) {
+		return err
+	}
+
+	// Otherwise read logs from the archive
+	if !run.LogsArchived {
+		return errLogsNotFound
+	}
+
+	logs, err := o.retrieveArchivedLogs(ctx, run.UID)
+	if err != nil {
+		return err
+	}
+	if logs == nil {
+		return fmt.Errorf("%w: %s/%s", errArchivedLogsNotFound, o.namespace, o.run)
+	}
+
+	_, err = o.Out.Write(logs)
+	return err
+}
+
+// retrieveArchivedLogs retrieves the logs of the run with the given UID from
+// the archive. If the UID is empty, i.e. the run no longer exists, the most
+// recently archived logs of a run with the run's name are retrieved. Nil is
+// returned if they are not found in the archive.
+func (o *logsOptions) retrieveArchivedLogs(ctx context.Context, uid types.UID) ([]byte, error) {
+	provider, err := o.createProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if provider == nil {
+		return nil, errNoBackupProvider
+	}
+
+	logs, err := provider.RetrieveLogs(ctx, runtimeclient.ObjectKey{Namespace: o.namespace, Name: o.run}, uid)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve archived logs: %w", err)
+	}
+	return logs, nil
+}
@@ -0,0 +1,145 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/leg100/etok/pkg/backup"
+	"github.com/leg100/etok/pkg/env"
+	"github.com/leg100/etok/pkg/testobj"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestLogs(t *testing.T) {
+	archived := func(run *v1alpha1.Run) {
+		run.UID = "uid-1"
+		run.LogsArchived = true
+	}
+	key := client.ObjectKey{Namespace: "default", Name: "run-12345"}
+
+	tests := []struct {
+		name string
+		objs []runtime.Object
+		args []string
+		env  *env.Env
+		// Logs in the archive
+		archive []*backup.FakeLogs
+		// Disable backup provider
+		noProvider bool
+		out        string
+		err        error
+	}{
+		{
+			name: "logs from pod",
+			objs: []runtime.Object{testobj.Run("default", "run-12345", "apply"), testobj.RunPod("default", "run-12345")},
+			args: []string{"run-12345"},
+			out:  "fake logs",
+		},
+		{
+			name: "follow logs from pod",
+			objs: []runtime.Object{testobj.Run("default", "run-12345", "apply"), testobj.RunPod("default", "run-12345")},
+			args: []string{"run-12345", "--follow"},
+			out:  "fake logs",
+		},
+		{
+			name: "namespace from environment file",
+			objs: []runtime.Object{testobj.Run("dev", "run-12345", "apply"), testobj.RunPod("dev", "run-12345")},
+			args: []string{"run-12345"},
+			env:  &env.Env{Namespace: "dev", Workspace: "networking"},
+			out:  "fake logs",
+		},
+		{
+			name: "logs from archive",
+			objs: []runtime.Object{testobj.Run("default", "run-12345", "apply", archived)},
+			args: []string{"run-12345"},
+			archive: []*backup.FakeLogs{
+				{Key: key, UID: "uid-1", Logs: []byte("Apply complete!\n")},
+			},
+			out: "Apply complete!\n",
+		},
+		{
+			name: "logs missing from archive",
+			objs: []runtime.Object{testobj.Run("default", "run-12345", "apply", archived)},
+			args: []string{"run-12345"},
+			err:  errArchivedLogsNotFound,
+		},
+		{
+			name: "only logs of an earlier run with the same name in archive",
+			objs: []runtime.Object{testobj.Run("default", "run-12345", "apply", archived)},
+			args: []string{"run-12345"},
+			archive: []*backup.FakeLogs{
+				{Key: key, UID: "uid-0", Logs: []byte("Plan: 1 to add\n")},
+			},
+			err: errArchivedLogsNotFound,
+		},
+		{
+			name:       "no backup provider",
+			objs:       []runtime.Object{testobj.Run("default", "run-12345", "apply", archived)},
+			args:       []string{"run-12345"},
+			noProvider: true,
+			err:        errNoBackupProvider,
+		},
+		{
+			name: "logs not archived",
+			objs: []runtime.Object{testobj.Run("default", "run-12345", "apply")},
+			args: []string{"run-12345"},
+			err:  errLogsNotFound,
+		},
+		{
+			name: "logs from archive of deleted run",
+			args: []string{"run-12345"},
+			archive: []*backup.FakeLogs{
+				{Key: key, UID: "uid-0", Logs: []byte("Plan: 1 to add\n")},
+				{Key: key, UID: "uid-1", Logs: []byte("Apply complete!\n")},
+			},
+			out: "Apply complete!\n",
+		},
+		{
+			name: "run not found",
+			args: []string{"run-12345"},
+			err:  errRunNotFound,
+		},
+		{
+			name:       "run not found without backup provider",
+			args:       []string{"run-12345"},
+			noProvider: true,
+			err:        errRunNotFound,
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			path := t.NewTempDir().Chdir().Root()
+
+			// Write .terraform/environment
+			if tt.env != nil {
+				require.NoError(t, tt.env.Write(path))
+			}
+
+			out := new(bytes.Buffer)
+			cmd, o := LogsCmd(cmdutil.NewFakeFactory(out, tt.objs...))
+			cmd.SetArgs(tt.args)
+
+			o.createProvider = func(context.Context) (backup.Provider, error) {
+				if tt.noProvider {
+					return nil, nil
+				}
+				return &backup.FakeProvider{Logs: tt.archive}, nil
+			}
+
+			err := cmd.ExecuteContext(context.Background())
+			if !assert.True(t, errors.Is(err, tt.err)) {
+				t.Errorf("unexpected error: %s", err)
+			}
+
+			assert.Equal(t, tt.out, out.String())
+		})
+	}
+}
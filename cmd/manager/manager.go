@@ -12,12 +12,14 @@ import (
 	"github.com/leg100/etok/cmd/flags"
 	cmdutil "github.com/leg100/etok/cmd/util"
 	"github.com/leg100/etok/pkg/controllers"
+	"github.com/leg100/etok/pkg/execer"
 	"github.com/leg100/etok/pkg/scheme"
 	"github.com/leg100/etok/pkg/version"
 	"github.com/spf13/cobra"
 
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
 func printVersion() {
@@ -43,6 +45,12 @@ type ManagerOptions struct {
 
 	// State backup configuration
 	backupCfg *backup.Config
+
+	// Default timeouts for runs
+	runTimeouts controllers.RunTimeouts
+
+	// Default retention policy for completed runs
+	runRetention controllers.RunRetentionPolicy
 }
 
 func ManagerCmd(f *cmdutil.Factory) *cobra.Command {
@@ -107,17 +115,44 @@ func ManagerCmd(f *cmdutil.Factory) *cobra.Command {
 				mgr.GetClient(),
 				o.Image,
 				controllers.WithBackupProvider(backupProvider),
+				controllers.WithBackupRetention(o.backupCfg.RetentionPolicy()),
 				controllers.WithEventRecorder(mgr.GetEventRecorderFor("workspace-controller")))
 
 			if err := workspaceReconciler.SetupWithManager(mgr); err != nil {
 				return fmt.Errorf("unable to create workspace controller: %w", err)
 			}
 
+			runOpts := []controllers.RunReconcilerOption{
+				controllers.WithDefaultRunTimeouts(o.runTimeouts),
+				controllers.WithPlanFileDeletion(*client.Config, execer.Exec),
+			}
+			retentionOpts := []controllers.RetentionReconcilerOption{controllers.WithDefaultRunRetention(o.runRetention)}
+			if backupProvider != nil && o.backupCfg.RunLogs() {
+				runOpts = append(runOpts, controllers.WithLogArchiver(backupProvider, client.KubeClient.CoreV1(), f.GetLogsFunc))
+				retentionOpts = append(retentionOpts, controllers.WithLogArchiving())
+				klog.V(0).Info("Archiving run logs")
+			}
+
 			// Setup run ctrl with mgr
-			if err := controllers.NewRunReconciler(mgr.GetClient(), o.Image).SetupWithManager(mgr); err != nil {
+			if err := controllers.NewRunReconciler(
+				mgr.GetClient(),
+				o.Image,
+				runOpts...).SetupWithManager(mgr); err != nil {
 				return fmt.Errorf("unable to create run controller: %w", err)
 			}
 
+			// Setup retention ctrl with mgr
+			if err := controllers.NewRetentionReconciler(
+				mgr.GetClient(),
+				retentionOpts...).SetupWithManager(mgr); err != nil {
+				return fmt.Errorf("unable to create retention controller: %w", err)
+			}
+
+			// Report metrics derived from the status of workspaces
+			if err := metrics.Registry.Register(controllers.NewWorkspaceCollector(mgr.GetClient())); err != nil {
+				return fmt.Errorf("unable to register workspace metrics: %w", err)
+			}
+
 			klog.V(0).Info("starting manager")
 			if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 				return fmt.Errorf("problem running manager: %w", err)
@@ -139,5 +174,13 @@ func ManagerCmd(f *cmdutil.Factory) *cobra.Command {
 			"Enabling this will ensure there is only one active controller manager.")
 	cmd.Flags().StringVar(&o.Image, "image", version.Image, "Docker image used for both the operator and the runner")
 
+	cmd.Flags().DurationVar(&o.runTimeouts.Enqueue, "run-enqueue-timeout", controllers.DefaultRunEnqueueTimeout, "Default maximum time a run can remain waiting to be added to the workspace queue")
+	cmd.Flags().DurationVar(&o.runTimeouts.Queue, "run-queue-timeout", controllers.DefaultRunQueueTimeout, "Default maximum time a run can remain waiting in the workspace queue")
+	cmd.Flags().DurationVar(&o.runTimeouts.PodPending, "run-pod-pending-timeout", controllers.DefaultRunPodPendingTimeout, "Default maximum time a run's pod can remain in the pending phase")
+
+	cmd.Flags().IntVar(&o.runRetention.KeepLast, "run-retention-keep-last", 0, "Default number of the most recently completed runs to retain per workspace (0 retains all runs)")
+	cmd.Flags().DurationVar(&o.runRetention.TTL, "run-retention-ttl", 0, "Default duration after which completed runs are deleted (0 retains runs indefinitely)")
+	cmd.Flags().BoolVar(&o.runRetention.KeepLastSuccessfulApply, "run-retention-keep-last-successful-apply", true, "Default to retaining the last successful apply of each workspace regardless of the other retention settings")
+
 	return cmd
 }
@@ -0,0 +1,23 @@
+package plans
+
+import (
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// default namespace and workspace if .terraform/environment is not found
+	defaultNamespace = "default"
+	defaultWorkspace = "default"
+)
+
+func PlansCmd(f *cmdutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "plans",
+		Short: "Etok saved plan management",
+	}
+
+	cmd.AddCommand(listCmd(f))
+
+	return cmd
+}
@@ -0,0 +1,82 @@
+package plans
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/cmd/flags"
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/leg100/etok/pkg/env"
+	"github.com/leg100/etok/pkg/labels"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8slabels "k8s.io/apimachinery/pkg/labels"
+)
+
+func listCmd(f *cmdutil.Factory) *cobra.Command {
+	var path, kubeContext string
+	var namespace = defaultNamespace
+	var workspace = defaultWorkspace
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List plans saved for the current workspace",
+		Long:  "List plans saved with 'etok plan --save', oldest first. A saved plan is identified by the name of the run that created it, and can be applied with 'etok apply --plan <run>'.",
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			// Override defaults with values from env file, unless flags are
+			// set
+			etokenv, err := env.Read(path)
+			if err != nil {
+				if !os.IsNotExist(err) {
+					return err
+				}
+			} else {
+				if !flags.IsFlagPassed(cmd.Flags(), "namespace") {
+					namespace = etokenv.Namespace
+				}
+				if !flags.IsFlagPassed(cmd.Flags(), "workspace") {
+					workspace = etokenv.Workspace
+				}
+			}
+
+			client, err := f.Create(kubeContext)
+			if err != nil {
+				return err
+			}
+
+			selector := k8slabels.SelectorFromSet(labels.MakeLabels(labels.PlanComponent, labels.Workspace(workspace)))
+			plans, err := client.ConfigMapsClient(namespace).List(cmd.Context(), metav1.ListOptions{LabelSelector: selector.String()})
+			if err != nil {
+				return err
+			}
+
+			sort.Slice(plans.Items, func(i, j int) bool {
+				return plans.Items[i].CreationTimestamp.Before(&plans.Items[j].CreationTimestamp)
+			})
+
+			for _, plan := range plans.Items {
+				serial, ok := plan.Data[v1alpha1.PlanSerialKey]
+				if !ok {
+					serial = "-"
+				}
+				fmt.Fprintf(f.Out, "%s\t%s\t%s\t%s\n",
+					v1alpha1.GetRunFromPlanConfigMapName(plan.Name),
+					plan.CreationTimestamp.UTC().Format(time.RFC3339),
+					serial,
+					plan.Data[v1alpha1.PlanSummaryKey])
+			}
+
+			return nil
+		},
+	}
+
+	flags.AddPathFlag(cmd, &path)
+	flags.AddNamespaceFlag(cmd, &namespace)
+	flags.AddWorkspaceFlag(cmd, &workspace)
+	flags.AddKubeContextFlag(cmd, &kubeContext)
+
+	return cmd
+}
@@ -0,0 +1,82 @@
+package plans
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/leg100/etok/pkg/env"
+	"github.com/leg100/etok/pkg/testobj"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestListPlans(t *testing.T) {
+	created := func(t time.Time) func(*corev1.ConfigMap) {
+		return func(cm *corev1.ConfigMap) {
+			cm.CreationTimestamp = metav1.NewTime(t)
+		}
+	}
+	earlier := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	later := earlier.Add(time.Hour)
+
+	tests := []struct {
+		name string
+		objs []runtime.Object
+		args []string
+		env  *env.Env
+		out  string
+	}{
+		{
+			name: "default workspace",
+			objs: []runtime.Object{
+				testobj.ConfigMap("default", "run-00002-plan", testobj.WithSavedPlan("default", 4, "No changes."), created(later)),
+				testobj.ConfigMap("default", "run-00001-plan", testobj.WithSavedPlan("default", 3, "Plan: 1 to add, 0 to change, 0 to destroy."), created(earlier)),
+				testobj.ConfigMap("default", "run-00003-plan", testobj.WithSavedPlan("other", 3, "No changes."), created(earlier)),
+			},
+			out: "run-00001\t2021-03-01T12:00:00Z\t3\tPlan: 1 to add, 0 to change, 0 to destroy.\nrun-00002\t2021-03-01T13:00:00Z\t4\tNo changes.\n",
+		},
+		{
+			name: "workspace from environment file",
+			objs: []runtime.Object{
+				testobj.ConfigMap("dev", "run-00001-plan", testobj.WithSavedPlan("networking", 3, "No changes."), created(earlier)),
+			},
+			env: &env.Env{Namespace: "dev", Workspace: "networking"},
+			out: "run-00001\t2021-03-01T12:00:00Z\t3\tNo changes.\n",
+		},
+		{
+			name: "workspace flag overrides environment file",
+			objs: []runtime.Object{
+				testobj.ConfigMap("dev", "run-00001-plan", testobj.WithSavedPlan("networking", 3, "No changes."), created(earlier)),
+				testobj.ConfigMap("dev", "run-00002-plan", testobj.WithSavedPlan("compute", 3, "No changes."), created(earlier)),
+			},
+			args: []string{"--workspace", "compute"},
+			env:  &env.Env{Namespace: "dev", Workspace: "networking"},
+			out:  "run-00002\t2021-03-01T12:00:00Z\t3\tNo changes.\n",
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			path := t.NewTempDir().Chdir().Root()
+
+			// Write .terraform/environment
+			if tt.env != nil {
+				require.NoError(t, tt.env.Write(path))
+			}
+
+			out := new(bytes.Buffer)
+			cmd := listCmd(cmdutil.NewFakeFactory(out, tt.objs...))
+			cmd.SetArgs(tt.args)
+
+			require.NoError(t, cmd.ExecuteContext(context.Background()))
+
+			assert.Equal(t, tt.out, out.String())
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/cmd/flags"
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/leg100/etok/pkg/client"
+	"github.com/leg100/etok/pkg/env"
+	"github.com/leg100/etok/pkg/util/slice"
+	"github.com/spf13/cobra"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// default namespace and workspace if .terraform/environment is not found
+	defaultNamespace = "default"
+	defaultWorkspace = "default"
+)
+
+var (
+	errRunNotFound       = errors.New("run not found")
+	errWorkspaceNotFound = errors.New("workspace not found")
+	errNotQueued         = errors.New("run is not queued")
+	errNotAuthorised     = errors.New("you are not authorised")
+)
+
+func QueueCmd(f *cmdutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "queue",
+		Short: "Etok workspace queue management",
+	}
+
+	showCmd, _ := showCmd(f)
+	cmd.AddCommand(showCmd)
+
+	promoteCmd, _ := promoteCmd(f)
+	cmd.AddCommand(promoteCmd)
+
+	removeCmd, _ := removeCmd(f)
+	cmd.AddCommand(removeCmd)
+
+	return cmd
+}
+
+// queueOptions are the options common to the queue commands
+type queueOptions struct {
+	*cmdutil.Factory
+
+	*client.Client
+
+	path        string
+	namespace   string
+	workspace   string
+	kubeContext string
+}
+
+func (o *queueOptions) addFlags(cmd *cobra.Command) {
+	flags.AddPathFlag(cmd, &o.path)
+	flags.AddNamespaceFlag(cmd, &o.namespace)
+	flags.AddWorkspaceFlag(cmd, &o.workspace)
+	flags.AddKubeContextFlag(cmd, &o.kubeContext)
+}
+
+// complete overrides the default namespace and workspace with values from the
+// env file, unless flags are set, and creates the client
+func (o *queueOptions) complete(cmd *cobra.Command) (err error) {
+	etokenv, err := env.Read(o.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		if !flags.IsFlagPassed(cmd.Flags(), "namespace") {
+			o.namespace = etokenv.Namespace
+		}
+		if !flags.IsFlagPassed(cmd.Flags(), "workspace") {
+			o.workspace = etokenv.Workspace
+		}
+	}
+
+	o.Client, err = o.Create(o.kubeContext)
+	return err
+}
+
+func (o *queueOptions) getWorkspace(ctx context.Context) (*v1alpha1.Workspace, error) {
+	ws, err := o.WorkspacesClient(o.namespace).Get(ctx, o.workspace, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil, fmt.Errorf("%w: %s/%s", errWorkspaceNotFound, o.namespace, o.workspace)
+	}
+	return ws, err
+}
+
+// getQueuedRun retrieves the named run, ensuring it is queued behind the
+// workspace's active run
+func (o *queueOptions) getQueuedRun(ctx context.Context, ws *v1alpha1.Workspace, name string) (*v1alpha1.Run, error) {
+	run, err := o.RunsClient(o.namespace).Get(ctx, name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil, fmt.Errorf("%w: %s/%s", errRunNotFound, o.namespace, name)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if !slice.ContainsString(ws.Status.Queue, run.Name) {
+		if ws.Status.Active == run.Name {
+			return nil, fmt.Errorf("%w: %s is the active run on workspace %s", errNotQueued, run.Name, ws.Name)
+		}
+		return nil, fmt.Errorf("%w: %s is not in the queue for workspace %s", errNotQueued, run.Name, ws.Name)
+	}
+
+	return run, nil
+}
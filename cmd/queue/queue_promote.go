@@ -0,0 +1,112 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/cmd/flags"
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/spf13/cobra"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type promoteOptions struct {
+	queueOptions
+
+	run      string
+	priority int
+}
+
+func promoteCmd(f *cmdutil.Factory) (*cobra.Command, *promoteOptions) {
+	o := &promoteOptions{
+		queueOptions: queueOptions{
+			Factory:   f,
+			namespace: defaultNamespace,
+			workspace: defaultWorkspace,
+		},
+	}
+	cmd := &cobra.Command{
+		Use:   "promote <run>",
+		Short: "Promote a queued run",
+		Long:  "Promote a queued run by raising its priority. By default the run is given a priority higher than that of any other queued run, placing it at the front of the queue, behind only the active run. Changing a run's priority requires permission to update the workspace.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o.run = args[0]
+
+			if err := o.complete(cmd); err != nil {
+				return err
+			}
+			return o.promote(cmd.Context(), flags.IsFlagPassed(cmd.Flags(), "priority"))
+		},
+	}
+
+	o.addFlags(cmd)
+	cmd.Flags().IntVar(&o.priority, "priority", 0, "Priority to give the run (defaults to the highest priority in the queue plus one)")
+
+	return cmd, o
+}
+
+func (o *promoteOptions) promote(ctx context.Context, prioritySet bool) error {
+	ws, err := o.getWorkspace(ctx)
+	if err != nil {
+		return err
+	}
+
+	run, err := o.getQueuedRun(ctx, ws, o.run)
+	if err != nil {
+		return err
+	}
+
+	if !prioritySet {
+		o.priority, err = o.frontOfQueue(ctx, ws)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The priority is only honoured once granted on the workspace, which
+	// requires permission to update the workspace
+	if ws.Annotations == nil {
+		ws.Annotations = make(map[string]string)
+	}
+	ws.Annotations[run.PriorityAnnotationKey()] = strconv.Itoa(o.priority)
+	if _, err := o.WorkspacesClient(o.namespace).Update(ctx, ws, metav1.UpdateOptions{}); err != nil {
+		if kerrors.IsForbidden(err) {
+			return fmt.Errorf("attempted to change priority of run %s: %w", run.Name, errNotAuthorised)
+		}
+		return fmt.Errorf("unable to grant priority: %w", err)
+	}
+
+	run.Priority = o.priority
+	if _, err := o.RunsClient(o.namespace).Update(ctx, run, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("unable to update run priority: %w", err)
+	}
+
+	fmt.Fprintf(o.Out, "Promoted run %s to priority %d\n", run.Name, o.priority)
+
+	return nil
+}
+
+// frontOfQueue returns the priority necessary to place the run at the front of
+// the queue
+func (o *promoteOptions) frontOfQueue(ctx context.Context, ws *v1alpha1.Workspace) (int, error) {
+	var highest int
+	for _, name := range ws.Status.Queue {
+		if name == o.run {
+			continue
+		}
+		queued, err := o.RunsClient(o.namespace).Get(ctx, name, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+		if p := ws.RunPriority(queued); p > highest {
+			highest = p
+		}
+	}
+	return highest + 1, nil
+}
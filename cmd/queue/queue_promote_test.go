@@ -0,0 +1,101 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/leg100/etok/pkg/testobj"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestPromote(t *testing.T) {
+	tests := []struct {
+		name       string
+		objs       []runtime.Object
+		args       []string
+		out        string
+		err        error
+		assertions func(*testutil.T, *promoteOptions)
+	}{
+		{
+			name: "promote to front of queue",
+			objs: []runtime.Object{
+				testobj.Workspace("default", "default", testobj.WithCombinedQueue("apply-1", "apply-2", "apply-3", "apply-4"), testobj.WithPriorityGrant("apply-2", 2)),
+				testobj.Run("default", "apply-1", "apply"),
+				testobj.Run("default", "apply-2", "apply", testobj.WithPriority(2)),
+				testobj.Run("default", "apply-3", "apply"),
+				testobj.Run("default", "apply-4", "apply"),
+			},
+			args: []string{"apply-4"},
+			out:  "Promoted run apply-4 to priority 3\n",
+			assertions: func(t *testutil.T, o *promoteOptions) {
+				ws, err := o.WorkspacesClient("default").Get(context.Background(), "default", metav1.GetOptions{})
+				require.NoError(t, err)
+				assert.Equal(t, "3", ws.Annotations["priorities.etok.dev/apply-4"])
+
+				run, err := o.RunsClient("default").Get(context.Background(), "apply-4", metav1.GetOptions{})
+				require.NoError(t, err)
+				assert.Equal(t, 3, run.Priority)
+			},
+		},
+		{
+			name: "promote with priority",
+			objs: []runtime.Object{
+				testobj.Workspace("default", "default", testobj.WithCombinedQueue("apply-1", "apply-2")),
+				testobj.Run("default", "apply-1", "apply"),
+				testobj.Run("default", "apply-2", "apply"),
+			},
+			args: []string{"apply-2", "--priority", "10"},
+			out:  "Promoted run apply-2 to priority 10\n",
+			assertions: func(t *testutil.T, o *promoteOptions) {
+				run, err := o.RunsClient("default").Get(context.Background(), "apply-2", metav1.GetOptions{})
+				require.NoError(t, err)
+				assert.Equal(t, 10, run.Priority)
+			},
+		},
+		{
+			name: "active run",
+			objs: []runtime.Object{
+				testobj.Workspace("default", "default", testobj.WithCombinedQueue("apply-1")),
+				testobj.Run("default", "apply-1", "apply"),
+			},
+			args: []string{"apply-1"},
+			err:  errNotQueued,
+		},
+		{
+			name: "run not found",
+			objs: []runtime.Object{
+				testobj.Workspace("default", "default", testobj.WithCombinedQueue("apply-1")),
+			},
+			args: []string{"apply-2"},
+			err:  errRunNotFound,
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			t.NewTempDir().Chdir()
+
+			out := new(bytes.Buffer)
+			cmd, o := promoteCmd(cmdutil.NewFakeFactory(out, tt.objs...))
+			cmd.SetArgs(tt.args)
+
+			err := cmd.ExecuteContext(context.Background())
+			if !assert.True(t, errors.Is(err, tt.err)) {
+				t.Errorf("unexpected error: %s", err)
+			}
+
+			assert.Equal(t, tt.out, out.String())
+
+			if tt.assertions != nil {
+				tt.assertions(t, o)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type removeOptions struct {
+	queueOptions
+
+	run string
+}
+
+func removeCmd(f *cmdutil.Factory) (*cobra.Command, *removeOptions) {
+	o := &removeOptions{
+		queueOptions: queueOptions{
+			Factory:   f,
+			namespace: defaultNamespace,
+			workspace: defaultWorkspace,
+		},
+	}
+	cmd := &cobra.Command{
+		Use:   "remove <run>",
+		Short: "Remove a run from the queue",
+		Long:  "Remove a run from the queue, cancelling the run. The active run cannot be removed from the queue; use 'etok cancel' instead.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o.run = args[0]
+
+			if err := o.complete(cmd); err != nil {
+				return err
+			}
+			return o.remove(cmd.Context())
+		},
+	}
+
+	o.addFlags(cmd)
+
+	return cmd, o
+}
+
+func (o *removeOptions) remove(ctx context.Context) error {
+	ws, err := o.getWorkspace(ctx)
+	if err != nil {
+		return err
+	}
+
+	run, err := o.getQueuedRun(ctx, ws, o.run)
+	if err != nil {
+		return err
+	}
+
+	// A cancelled run is removed from the queue by the workspace controller
+	run.Cancel = true
+	if _, err := o.RunsClient(o.namespace).Update(ctx, run, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("unable to remove run from queue: %w", err)
+	}
+
+	fmt.Fprintf(o.Out, "Removed run %s from queue\n", run.Name)
+
+	return nil
+}
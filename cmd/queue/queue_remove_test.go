@@ -0,0 +1,87 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/leg100/etok/pkg/testobj"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestRemove(t *testing.T) {
+	tests := []struct {
+		name       string
+		objs       []runtime.Object
+		args       []string
+		out        string
+		err        error
+		assertions func(*testutil.T, *removeOptions)
+	}{
+		{
+			name: "remove queued run",
+			objs: []runtime.Object{
+				testobj.Workspace("default", "default", testobj.WithCombinedQueue("apply-1", "apply-2")),
+				testobj.Run("default", "apply-1", "apply"),
+				testobj.Run("default", "apply-2", "apply"),
+			},
+			args: []string{"apply-2"},
+			out:  "Removed run apply-2 from queue\n",
+			assertions: func(t *testutil.T, o *removeOptions) {
+				run, err := o.RunsClient("default").Get(context.Background(), "apply-2", metav1.GetOptions{})
+				require.NoError(t, err)
+				assert.True(t, run.Cancel)
+			},
+		},
+		{
+			name: "active run",
+			objs: []runtime.Object{
+				testobj.Workspace("default", "default", testobj.WithCombinedQueue("apply-1")),
+				testobj.Run("default", "apply-1", "apply"),
+			},
+			args: []string{"apply-1"},
+			err:  errNotQueued,
+		},
+		{
+			name: "run not in queue",
+			objs: []runtime.Object{
+				testobj.Workspace("default", "default", testobj.WithCombinedQueue("apply-1")),
+				testobj.Run("default", "apply-1", "apply"),
+				testobj.Run("default", "plan-1", "plan"),
+			},
+			args: []string{"plan-1"},
+			err:  errNotQueued,
+		},
+		{
+			name: "workspace not found",
+			args: []string{"apply-1"},
+			err:  errWorkspaceNotFound,
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			t.NewTempDir().Chdir()
+
+			out := new(bytes.Buffer)
+			cmd, o := removeCmd(cmdutil.NewFakeFactory(out, tt.objs...))
+			cmd.SetArgs(tt.args)
+
+			err := cmd.ExecuteContext(context.Background())
+			if !assert.True(t, errors.Is(err, tt.err)) {
+				t.Errorf("unexpected error: %s", err)
+			}
+
+			assert.Equal(t, tt.out, out.String())
+
+			if tt.assertions != nil {
+				tt.assertions(t, o)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/spf13/cobra"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+type showOptions struct {
+	queueOptions
+
+	// Current time, overridden in tests
+	now func() time.Time
+}
+
+func showCmd(f *cmdutil.Factory) (*cobra.Command, *showOptions) {
+	o := &showOptions{
+		queueOptions: queueOptions{
+			Factory:   f,
+			namespace: defaultNamespace,
+			workspace: defaultWorkspace,
+		},
+		now: time.Now,
+	}
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Show the queue for the current workspace",
+		Long:  "Show the active run followed by the runs queued behind it, in the order in which they are to run. For each run the command, who launched it, how long ago it was launched, and its priority are shown.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.complete(cmd); err != nil {
+				return err
+			}
+			return o.show(cmd.Context())
+		},
+	}
+
+	o.addFlags(cmd)
+
+	return cmd, o
+}
+
+func (o *showOptions) show(ctx context.Context) error {
+	ws, err := o.getWorkspace(ctx)
+	if err != nil {
+		return err
+	}
+
+	if ws.Status.Active == "" {
+		fmt.Fprintf(o.Out, "Workspace %s has no active run\n", ws.Name)
+		return nil
+	}
+
+	// Who launched a run is reported by the client creating the run and is
+	// not verified, which the heading makes clear
+	fmt.Fprintf(o.Out, "POSITION\tRUN\tCOMMAND\tLAUNCHED BY (CLIENT-REPORTED)\tAGE\tPRIORITY\n")
+	if err := o.printRun(ctx, ws, "active", ws.Status.Active); err != nil {
+		return err
+	}
+	for i, name := range ws.Status.Queue {
+		if err := o.printRun(ctx, ws, fmt.Sprint(i+1), name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o *showOptions) printRun(ctx context.Context, ws *v1alpha1.Workspace, position, name string) error {
+	run, err := o.RunsClient(o.namespace).Get(ctx, name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		// Run has been deleted since the queue was last updated
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	launchedBy := run.LaunchedBy()
+	if launchedBy == "" {
+		launchedBy = "-"
+	}
+
+	fmt.Fprintf(o.Out, "%s\t%s\t%s\t%s\t%s\t%d\n",
+		position,
+		run.Name,
+		run.Command,
+		launchedBy,
+		duration.HumanDuration(o.now().Sub(run.CreationTimestamp.Time)),
+		ws.RunPriority(run))
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/leg100/etok/pkg/env"
+	"github.com/leg100/etok/pkg/testobj"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestShowQueue(t *testing.T) {
+	header := "POSITION\tRUN\tCOMMAND\tLAUNCHED BY (CLIENT-REPORTED)\tAGE\tPRIORITY\n"
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	created := func(ago time.Duration) func(*v1alpha1.Run) {
+		return func(run *v1alpha1.Run) {
+			run.CreationTimestamp = metav1.NewTime(now.Add(-ago))
+		}
+	}
+
+	tests := []struct {
+		name string
+		objs []runtime.Object
+		args []string
+		env  *env.Env
+		out  string
+		err  error
+	}{
+		{
+			name: "active and queued runs",
+			objs: []runtime.Object{
+				testobj.Workspace("default", "default", testobj.WithCombinedQueue("apply-1", "apply-3", "apply-2"), testobj.WithPriorityGrant("apply-3", 1)),
+				testobj.Run("default", "apply-1", "apply", created(10*time.Minute), testobj.WithLaunchedBy("alice")),
+				testobj.Run("default", "apply-2", "apply", created(5*time.Minute), testobj.WithLaunchedBy("bob")),
+				testobj.Run("default", "apply-3", "sh", created(90*time.Second), testobj.WithPriority(1)),
+			},
+			out: header + "active\tapply-1\tapply\talice\t10m\t0\n1\tapply-3\tsh\t-\t90s\t1\n2\tapply-2\tapply\tbob\t5m\t0\n",
+		},
+		{
+			name: "workspace from environment file",
+			objs: []runtime.Object{
+				testobj.Workspace("dev", "networking", testobj.WithCombinedQueue("apply-1")),
+				testobj.Run("dev", "apply-1", "apply", created(time.Minute), testobj.WithLaunchedBy("alice")),
+			},
+			env: &env.Env{Namespace: "dev", Workspace: "networking"},
+			out: header + "active\tapply-1\tapply\talice\t60s\t0\n",
+		},
+		{
+			name: "no active run",
+			objs: []runtime.Object{
+				testobj.Workspace("default", "default"),
+			},
+			out: "Workspace default has no active run\n",
+		},
+		{
+			name: "workspace not found",
+			err:  errWorkspaceNotFound,
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			path := t.NewTempDir().Chdir().Root()
+
+			// Write .terraform/environment
+			if tt.env != nil {
+				require.NoError(t, tt.env.Write(path))
+			}
+
+			out := new(bytes.Buffer)
+			cmd, o := showCmd(cmdutil.NewFakeFactory(out, tt.objs...))
+			o.now = func() time.Time { return now }
+			cmd.SetArgs(tt.args)
+
+			err := cmd.ExecuteContext(context.Background())
+			if !assert.True(t, errors.Is(err, tt.err)) {
+				t.Errorf("unexpected error: %s", err)
+			}
+
+			assert.Equal(t, tt.out, out.String())
+		})
+	}
+}
@@ -4,11 +4,17 @@ import (
 	"flag"
 	"strconv"
 
+	"github.com/leg100/etok/cmd/cancel"
 	"github.com/leg100/etok/cmd/github"
+	"github.com/leg100/etok/cmd/gitlab"
 	"github.com/leg100/etok/cmd/install"
 	"github.com/leg100/etok/cmd/launcher"
+	"github.com/leg100/etok/cmd/logs"
 	"github.com/leg100/etok/cmd/manager"
+	"github.com/leg100/etok/cmd/plans"
+	"github.com/leg100/etok/cmd/queue"
 	"github.com/leg100/etok/cmd/runner"
+	"github.com/leg100/etok/cmd/runs"
 	cmdutil "github.com/leg100/etok/cmd/util"
 	"github.com/leg100/etok/cmd/workspace"
 	"github.com/leg100/etok/pkg/executor"
@@ -36,6 +42,9 @@ func RootCmd(f *cmdutil.Factory) *cobra.Command {
 	cmd.AddCommand(versionCmd(f))
 
 	cmd.AddCommand(workspace.WorkspaceCmd(f))
+	cmd.AddCommand(plans.PlansCmd(f))
+	cmd.AddCommand(queue.QueueCmd(f))
+	cmd.AddCommand(runs.RunsCmd(f))
 	cmd.AddCommand(manager.ManagerCmd(f))
 
 	runnerCmd, _ := runner.RunnerCmd(f)
@@ -44,7 +53,14 @@ func RootCmd(f *cmdutil.Factory) *cobra.Command {
 	installCmd, _ := install.InstallCmd(f)
 	cmd.AddCommand(installCmd)
 
+	cancelCmd, _ := cancel.CancelCmd(f)
+	cmd.AddCommand(cancelCmd)
+
+	logsCmd, _ := logs.LogsCmd(f)
+	cmd.AddCommand(logsCmd)
+
 	cmd.AddCommand(github.GithubCmd(f))
+	cmd.AddCommand(gitlab.GitlabCmd(f))
 
 	// Terraform commands (and shell command)
 	launcher.AddToRoot(cmd, f)
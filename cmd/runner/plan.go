@@ -0,0 +1,161 @@
+package runner
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/pkg/executor"
+	"github.com/leg100/etok/pkg/labels"
+	"github.com/leg100/etok/pkg/plan"
+	"github.com/leg100/etok/pkg/scheme"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// maxPlanChangesSize is the maximum size in bytes of the resource changes
+// recorded for a plan, leaving room within the config map size limit of 1MiB
+// for the plan's summary and the config map's metadata.
+const maxPlanChangesSize = 768 * 1024
+
+// planPath returns the path to the plan file saved by the named run
+func (o *RunnerOptions) planPath(runName string) string {
+	return filepath.Join(o.plansDir, runName)
+}
+
+// planOutArg returns the path to which the terraform plan args direct the plan
+// file to be written. An empty string is returned if they do not specify a
+// path.
+func planOutArg(args []string) (path string) {
+	for i, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "-out="):
+			path = strings.TrimPrefix(arg, "-out=")
+		case arg == "-out" && i+1 < len(args):
+			path = args[i+1]
+		}
+	}
+	return path
+}
+
+// persistPlan records the details of the plan file at the given path, written
+// by this run, in a config map: the serial number of the state from which it was created, a
+// summary of its changes, and the changes to be made to each resource, less
+// those that would exceed the size of a config map.
+func (o *RunnerOptions) persistPlan(ctx context.Context, path string) error {
+	data := make(map[string]string)
+
+	serial, err := readPlanSerial(path)
+	if err != nil {
+		return fmt.Errorf("unable to read state serial from plan file: %w", err)
+	}
+	if serial != nil {
+		data[v1alpha1.PlanSerialKey] = strconv.Itoa(*serial)
+	}
+
+	p, err := o.showPlan(ctx, path)
+	if err != nil {
+		return fmt.Errorf("unable to parse plan: %w", err)
+	}
+	data[v1alpha1.PlanSummaryKey] = p.Summary()
+
+	// Persist the parsed resource changes rather than terraform's JSON
+	// representation, which does not mask sensitive values. Large plans are
+	// truncated to fit within the config map, whereas the summary above
+	// reflects all the changes.
+	if err := p.Truncate(maxPlanChangesSize); err != nil {
+		return err
+	}
+	if p.Omitted > 0 {
+		klog.Warningf("omitted the changes of %d resources from the recorded plan to limit its size", p.Omitted)
+	}
+	changes, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	data[v1alpha1.PlanChangesKey] = string(changes)
+
+	// Get run resource so that it can be set as owner of config map
+	run, err := o.RunsClient(o.namespace).Get(ctx, o.runName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to retrieve run: %w", err)
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: o.namespace,
+			Name:      run.PlanConfigMapName(),
+		},
+		Data: data,
+	}
+	// Set etok's common labels
+	labels.SetCommonLabels(configMap)
+	// Permit filtering plans by workspace
+	labels.SetLabel(configMap, labels.Workspace(run.Workspace))
+	// Permit filtering etok resources by component
+	labels.SetLabel(configMap, labels.PlanComponent)
+
+	// Make run owner of configmap, so if run is deleted so is its configmap
+	if err := controllerutil.SetOwnerReference(run, configMap, scheme.Scheme); err != nil {
+		return err
+	}
+
+	_, err = o.ConfigMapsClient(o.namespace).Create(ctx, configMap, metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+
+	klog.V(1).Infof("created config map: %s", klog.KObj(configMap))
+	return nil
+}
+
+// showPlan parses the JSON representation of the plan file at the given path
+func (o *RunnerOptions) showPlan(ctx context.Context, path string) (*plan.Plan, error) {
+	out := new(bytes.Buffer)
+	if err := o.exec.Execute(ctx, []string{"terraform", "show", "-json", path}, executor.WithStdout(out)); err != nil {
+		return nil, err
+	}
+	return plan.Parse(out.Bytes())
+}
+
+// readPlanSerial reads the serial number of the state from which the plan file
+// at the given path was created. Nil is returned if the plan was created
+// without a prior state.
+func readPlanSerial(path string) (*int, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	// A plan file is a zip archive, containing a snapshot of the prior state
+	for _, f := range r.File {
+		if f.Name != "tfstate" {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+
+		var state struct {
+			Serial int `json:"serial"`
+		}
+		if err := json.NewDecoder(rc).Decode(&state); err != nil {
+			return nil, err
+		}
+		return &state.Serial, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,37 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"k8s.io/klog/v2"
+)
+
+// cloneRepository shallow clones the git repository to the destination path.
+// The clone is authenticated if credentials have been provided, which is
+// necessary to clone a private repository.
+func (o *RunnerOptions) cloneRepository(ctx context.Context) error {
+	opts := &git.CloneOptions{URL: o.repository, Depth: 1, SingleBranch: true}
+	if o.branch != "" {
+		opts.ReferenceName = plumbing.NewBranchReferenceName(o.branch)
+	}
+	if o.gitPassword != "" {
+		// Hosts accepting a token in place of a password require a non-empty
+		// username but are otherwise indifferent to its value
+		username := o.gitUsername
+		if username == "" {
+			username = "etok"
+		}
+		opts.Auth = &http.BasicAuth{Username: username, Password: o.gitPassword}
+	}
+
+	if _, err := git.PlainCloneContext(ctx, o.dest, false, opts); err != nil {
+		return fmt.Errorf("unable to clone %s: %w", o.repository, err)
+	}
+
+	klog.V(1).Infof("cloned repository %s to %s", o.repository, o.dest)
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/leg100/etok/api/etok.dev/v1alpha1"
@@ -14,8 +15,11 @@ import (
 	"github.com/leg100/etok/pkg/archive"
 	"github.com/leg100/etok/pkg/client"
 	"github.com/leg100/etok/pkg/commands"
+	"github.com/leg100/etok/pkg/controllers"
 	"github.com/leg100/etok/pkg/executor"
 	"github.com/leg100/etok/pkg/globals"
+	"github.com/leg100/etok/pkg/handlers"
+	"github.com/leg100/etok/pkg/k8s"
 	"github.com/leg100/etok/pkg/labels"
 	"github.com/leg100/etok/pkg/scheme"
 	"github.com/spf13/cobra"
@@ -23,6 +27,7 @@ import (
 	"golang.org/x/sync/errgroup"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	watchtools "k8s.io/client-go/tools/watch"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
@@ -36,17 +41,38 @@ type RunnerOptions struct {
 
 	*client.Client
 
-	path        string
-	tarball     string
-	dest        string
+	path    string
+	tarball string
+	dest    string
+
+	// Git repository to clone in place of extracting a tarball, along with
+	// the branch to clone and the credentials with which to clone it
+	repository  string
+	branch      string
+	gitUsername string
+	gitPassword string
+
 	command     string
 	namespace   string
 	kubeContext string
 
 	runName string
 
+	// Save plan file produced by plan command
+	savePlan bool
+	// Name of run whose saved plan file is to be applied
+	plan string
+	// Directory in which plan files are saved
+	plansDir string
+
 	exec executor.Executor
 
+	// Maximum duration of the run, after which the command is interrupted
+	maxDuration time.Duration
+	// Path to which the reason for the command being interrupted is written,
+	// for the run controller to retrieve from the container's status
+	terminationMessagePath string
+
 	handshake        bool
 	handshakeTimeout time.Duration
 
@@ -55,14 +81,16 @@ type RunnerOptions struct {
 
 func RunnerCmd(opts *cmdutil.Factory) (*cobra.Command, *RunnerOptions) {
 	o := &RunnerOptions{
-		Factory: opts,
-		exec:    &executor.Exec{IOStreams: opts.IOStreams},
+		Factory:                opts,
+		exec:                   &executor.Exec{IOStreams: opts.IOStreams},
+		plansDir:               controllers.PlansMountPath,
+		terminationMessagePath: corev1.TerminationMessagePathDefault,
 	}
 
 	cmd := &cobra.Command{
 		Use:    "runner [args]",
 		Short:  "Run the etok runner",
-		Long:   "Runner runs the requested command on a Run's pod. Prior to running the command, it can optionally be requested to untar a tarball into a destination directory, and it can optionally be requested to await a 'handshake' on stdin - a string a client can send to inform the runner it has successfully attached to the pod's TTY, ensuring it doesn't miss any output from the command that the runner then runs.",
+		Long:   "Runner runs the requested command on a Run's pod. Prior to running the command, it can optionally be requested to untar a tarball, or clone a git repository, into a destination directory, and it can optionally be requested to await a 'handshake' on stdin - a string a client can send to inform the runner it has successfully attached to the pod's TTY, ensuring it doesn't miss any output from the command that the runner then runs.",
 		Hidden: true,
 		RunE: func(cmd *cobra.Command, args []string) (err error) {
 			if err := o.validate(); err != nil {
@@ -85,10 +113,17 @@ func RunnerCmd(opts *cmdutil.Factory) (*cobra.Command, *RunnerOptions) {
 
 	cmd.Flags().StringVar(&o.dest, "dest", "/workspace", "Destination path for tarball extraction")
 	cmd.Flags().StringVar(&o.tarball, "tarball", o.tarball, "Tarball filename")
+	cmd.Flags().StringVar(&o.repository, "repository", "", "URL of git repository to clone to the destination path in place of extracting a tarball")
+	cmd.Flags().StringVar(&o.branch, "branch", "", "Branch of git repository to clone (default is the repository's default branch)")
+	cmd.Flags().StringVar(&o.gitUsername, "git-username", "", "Username with which to clone git repository")
+	cmd.Flags().StringVar(&o.gitPassword, "git-password", "", "Password or token with which to clone git repository")
 	cmd.Flags().BoolVar(&o.handshake, "handshake", false, "Await handshake string on stdin")
 	cmd.Flags().DurationVar(&o.handshakeTimeout, "handshake-timeout", v1alpha1.DefaultHandshakeTimeout, "Timeout waiting for handshake")
 	cmd.Flags().StringVar(&o.runName, "run-name", "", "Name of run resource")
 	cmd.Flags().StringVar(&o.command, "command", "", "Etok command to run")
+	cmd.Flags().BoolVar(&o.savePlan, "save-plan", false, "Save plan file produced by plan command")
+	cmd.Flags().StringVar(&o.plan, "plan", "", "Name of run whose saved plan file is to be applied")
+	cmd.Flags().DurationVar(&o.maxDuration, "max-duration", 0, "Maximum duration of the run, after which the command is interrupted (0 means no maximum)")
 
 	return cmd, o
 }
@@ -115,11 +150,35 @@ func (o *RunnerOptions) validate() error {
 		}
 	}
 
+	if o.savePlan {
+		// A shell script is responsible for saving its own plan file
+		if o.command != "plan" && o.command != "sh" {
+			return errors.New("--save-plan is only valid with the plan or sh commands")
+		}
+		if o.runName == "" {
+			return errors.New("--save-plan requires --run-name")
+		}
+	}
+
+	if o.plan != "" && o.command != "apply" {
+		return errors.New("--plan is only valid with the apply command")
+	}
+
+	if o.repository != "" && o.tarball != "" {
+		return errors.New("--repository and --tarball are mutually exclusive")
+	}
+
 	return nil
 }
 
 func (o *RunnerOptions) Run(ctx context.Context) error {
-	g, gctx := errgroup.WithContext(ctx)
+	// Interrupt the command if the run is cancelled or exceeds its maximum
+	// duration
+	execCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	interrupted := o.interruptible(execCtx, cancel)
+
+	g, gctx := errgroup.WithContext(execCtx)
 
 	// Concurrently extract tarball
 	if o.tarball != "" {
@@ -138,6 +197,13 @@ func (o *RunnerOptions) Run(ctx context.Context) error {
 		})
 	}
 
+	// Concurrently clone repository
+	if o.repository != "" {
+		g.Go(func() error {
+			return o.cloneRepository(gctx)
+		})
+	}
+
 	// Concurrently wait for client to handshake
 	if o.handshake {
 		g.Go(func() error {
@@ -150,11 +216,51 @@ func (o *RunnerOptions) Run(ctx context.Context) error {
 		return err
 	}
 
+	args := prepareArgs(o.command, o.args...)
+
+	// Path to the plan file whose details are to be recorded
+	var planFile string
+	switch {
+	case o.savePlan:
+		planFile = o.planPath(o.runName)
+		if o.command == "plan" {
+			args = append(args, "-out="+planFile)
+		}
+	case o.command == "plan" && o.runName != "":
+		// Record the details of every plan run, not only those saving their
+		// plan for a later apply, so that each reports its blast radius. Use
+		// the user's choice of plan file if they have specified one.
+		if planFile = planOutArg(o.args); planFile == "" {
+			f, err := os.CreateTemp("", "plan")
+			if err != nil {
+				return err
+			}
+			f.Close()
+			defer os.Remove(f.Name())
+
+			planFile = f.Name()
+			args = append(args, "-out="+planFile)
+		}
+	}
+	if o.plan != "" {
+		// Plan file must be the last arg
+		args = append(args, o.planPath(o.plan))
+	}
+
 	// Execute requested command
-	if err := o.exec.Execute(ctx, prepareArgs(o.command, o.args...)); err != nil {
+	if err := o.exec.Execute(execCtx, args); err != nil {
+		if reason := interrupted(); reason != "" {
+			return o.terminate(reason, err)
+		}
 		return err
 	}
 
+	if planFile != "" {
+		if err := o.persistPlan(ctx, planFile); err != nil {
+			return fmt.Errorf("failed to persist plan details to config map: %w", err)
+		}
+	}
+
 	if commands.UpdatesLockFile(o.command) {
 		// This is a command that updates the lock file (such as terraform init)
 		// so persist it to a configmap
@@ -168,6 +274,59 @@ func (o *RunnerOptions) Run(ctx context.Context) error {
 	return nil
 }
 
+// interruptible calls cancel when the run is cancelled or exceeds its maximum
+// duration. It returns a func that reports the reason cancel was called, or an
+// empty string if it was not.
+func (o *RunnerOptions) interruptible(ctx context.Context, cancel context.CancelFunc) func() string {
+	var mu sync.Mutex
+	var reason string
+
+	interrupt := func(r string) {
+		mu.Lock()
+		// Only the first reason is kept
+		if reason == "" {
+			reason = r
+			klog.V(1).Infof("Interrupting command: %s", reason)
+		}
+		mu.Unlock()
+		cancel()
+	}
+
+	if o.runName != "" {
+		go func() {
+			lw := &k8s.RunListWatcher{Client: o.EtokClient, Name: o.runName, Namespace: o.namespace}
+			if _, err := watchtools.UntilWithSync(ctx, lw, &v1alpha1.Run{}, nil, handlers.RunCancelled(o.runName)); err == nil {
+				interrupt(v1alpha1.RunCancelledReason)
+			}
+		}()
+	}
+
+	if o.maxDuration > 0 {
+		timer := time.AfterFunc(o.maxDuration, func() {
+			interrupt(v1alpha1.MaxDurationExceededReason)
+		})
+		go func() {
+			<-ctx.Done()
+			timer.Stop()
+		}()
+	}
+
+	return func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return reason
+	}
+}
+
+// terminate writes the reason the command was interrupted to the termination
+// message path, from where the run controller retrieves it.
+func (o *RunnerOptions) terminate(reason string, err error) error {
+	if werr := os.WriteFile(o.terminationMessagePath, []byte(reason), 0644); werr != nil {
+		klog.Errorf("Unable to write termination message: %s", werr.Error())
+	}
+	return fmt.Errorf("%s: %w", reason, err)
+}
+
 // persistLockFile persists the lock file .terraform.lock.hcl to a config map.
 // If the lock file does not exist then it exits early without error.
 func (o *RunnerOptions) persistLockFile(ctx context.Context) error {
@@ -2,10 +2,12 @@ package runner
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"bytes"
 	"compress/gzip"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"os/exec"
@@ -19,6 +21,8 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/creack/pty"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/leg100/etok/cmd/envvars"
 	cmdutil "github.com/leg100/etok/cmd/util"
 	"github.com/leg100/etok/pkg/executor"
@@ -191,6 +195,246 @@ func TestRunnerLockFile(t *testing.T) {
 	})
 }
 
+func TestRunnerSavedPlan(t *testing.T) {
+	testutil.Run(t, "save plan", func(t *testutil.T) {
+		out := new(bytes.Buffer)
+		f := cmdutil.NewFakeFactory(out, testobj.Run("dev", "run-12345", "plan", testobj.WithWorkspace("foo")))
+		cmd, o := RunnerCmd(f)
+		cmd.SetOut(out)
+		cmd.SetArgs([]string{"--"})
+
+		o.plansDir = t.NewTempDir().Root()
+		o.exec = &fakePlanExecutor{
+			serial: 3,
+			json:   `{"format_version":"0.1","resource_changes":[{"address":"random_id.a","change":{"actions":["create"]}},{"address":"random_id.b","change":{"actions":["delete","create"]}},{"address":"random_id.c","change":{"actions":["no-op"]}}]}`,
+		}
+
+		// Set flag via env var since that's how runner is invoked on a pod
+		t.SetEnvs(map[string]string{
+			"ETOK_NAMESPACE": "dev",
+			"ETOK_COMMAND":   "plan",
+			"ETOK_RUN_NAME":  "run-12345",
+			"ETOK_SAVE_PLAN": "true",
+		})
+		envvars.SetFlagsFromEnvVariables(cmd)
+
+		require.NoError(t, cmd.ExecuteContext(context.Background()))
+
+		cm, err := o.ConfigMapsClient(o.namespace).Get(context.Background(), "run-12345-plan", metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"serial":  "3",
+			"summary": "Plan: 2 to add, 0 to change, 1 to destroy.",
+			"changes": `{"resourceChanges":[{"address":"random_id.a","action":"create"},{"address":"random_id.b","action":"replace"}]}`,
+		}, cm.Data)
+		assert.Equal(t, "foo", cm.Labels["workspace"])
+	})
+
+	testutil.Run(t, "record unsaved plan", func(t *testutil.T) {
+		out := new(bytes.Buffer)
+		f := cmdutil.NewFakeFactory(out, testobj.Run("dev", "run-12345", "plan", testobj.WithWorkspace("foo")))
+		cmd, o := RunnerCmd(f)
+		cmd.SetOut(out)
+		cmd.SetArgs([]string{"--"})
+
+		o.exec = &fakePlanExecutor{
+			serial: 3,
+			json:   `{"format_version":"0.1","resource_changes":[{"address":"random_id.a","change":{"actions":["create"]}}]}`,
+		}
+
+		// Set flag via env var since that's how runner is invoked on a pod
+		t.SetEnvs(map[string]string{
+			"ETOK_NAMESPACE": "dev",
+			"ETOK_COMMAND":   "plan",
+			"ETOK_RUN_NAME":  "run-12345",
+		})
+		envvars.SetFlagsFromEnvVariables(cmd)
+
+		require.NoError(t, cmd.ExecuteContext(context.Background()))
+
+		cm, err := o.ConfigMapsClient(o.namespace).Get(context.Background(), "run-12345-plan", metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "Plan: 1 to add, 0 to change, 0 to destroy.", cm.Data["summary"])
+	})
+
+	testutil.Run(t, "record unsaved plan written to user's choice of path", func(t *testutil.T) {
+		out := new(bytes.Buffer)
+		f := cmdutil.NewFakeFactory(out, testobj.Run("dev", "run-12345", "plan", testobj.WithWorkspace("foo")))
+		cmd, o := RunnerCmd(f)
+		cmd.SetOut(out)
+		cmd.SetArgs([]string{"--", "-out", filepath.Join(t.NewTempDir().Root(), "plan.out")})
+
+		o.exec = &fakePlanExecutor{
+			serial: 3,
+			json:   `{"format_version":"0.1"}`,
+		}
+
+		// Set flag via env var since that's how runner is invoked on a pod
+		t.SetEnvs(map[string]string{
+			"ETOK_NAMESPACE": "dev",
+			"ETOK_COMMAND":   "plan",
+			"ETOK_RUN_NAME":  "run-12345",
+		})
+		envvars.SetFlagsFromEnvVariables(cmd)
+
+		require.NoError(t, cmd.ExecuteContext(context.Background()))
+
+		cm, err := o.ConfigMapsClient(o.namespace).Get(context.Background(), "run-12345-plan", metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "No changes.", cm.Data["summary"])
+	})
+
+	testutil.Run(t, "save plan from shell script", func(t *testutil.T) {
+		out := new(bytes.Buffer)
+		f := cmdutil.NewFakeFactory(out, testobj.Run("dev", "run-12345", "sh", testobj.WithWorkspace("foo")))
+		cmd, o := RunnerCmd(f)
+		cmd.SetOut(out)
+
+		o.plansDir = t.NewTempDir().Root()
+		cmd.SetArgs([]string{"--", "terraform plan -out=" + o.planPath("run-12345")})
+		o.exec = &fakePlanExecutor{
+			serial: 3,
+			json:   `{"format_version":"0.1"}`,
+		}
+
+		// Set flag via env var since that's how runner is invoked on a pod
+		t.SetEnvs(map[string]string{
+			"ETOK_NAMESPACE": "dev",
+			"ETOK_COMMAND":   "sh",
+			"ETOK_RUN_NAME":  "run-12345",
+			"ETOK_SAVE_PLAN": "true",
+		})
+		envvars.SetFlagsFromEnvVariables(cmd)
+
+		require.NoError(t, cmd.ExecuteContext(context.Background()))
+
+		cm, err := o.ConfigMapsClient(o.namespace).Get(context.Background(), "run-12345-plan", metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "No changes.", cm.Data["summary"])
+	})
+
+	testutil.Run(t, "apply saved plan", func(t *testutil.T) {
+		out, cmd, opts := setupRunnerCmd(t, "--", "-no-color")
+
+		opts.plansDir = "/plans"
+		opts.exec = &executor.FakeExecutorEchoArgs{Out: out}
+
+		// Set flag via env var since that's how runner is invoked on a pod
+		t.SetEnvs(map[string]string{
+			"ETOK_NAMESPACE": "dev",
+			"ETOK_COMMAND":   "apply",
+			"ETOK_PLAN":      "run-12345",
+		})
+		envvars.SetFlagsFromEnvVariables(cmd)
+
+		require.NoError(t, cmd.ExecuteContext(context.Background()))
+
+		assert.Equal(t, "[terraform apply -no-color /plans/run-12345]", strings.TrimSpace(out.String()))
+	})
+
+	testutil.Run(t, "save plan with wrong command", func(t *testutil.T) {
+		_, cmd, _ := setupRunnerCmd(t)
+
+		// Set flag via env var since that's how runner is invoked on a pod
+		t.SetEnvs(map[string]string{
+			"ETOK_NAMESPACE": "dev",
+			"ETOK_COMMAND":   "apply",
+			"ETOK_RUN_NAME":  "run-12345",
+			"ETOK_SAVE_PLAN": "true",
+		})
+		envvars.SetFlagsFromEnvVariables(cmd)
+
+		assert.Error(t, cmd.ExecuteContext(context.Background()))
+	})
+}
+
+// fakePlanExecutor mocks terraform: plan, either invoked directly or via a
+// shell, writes a plan file containing a state with the given serial, and show
+// outputs the given json.
+type fakePlanExecutor struct {
+	serial int
+	json   string
+}
+
+func (e *fakePlanExecutor) Execute(ctx context.Context, args []string, opts ...executor.ExecOption) error {
+	if args[0] == "sh" {
+		args = strings.Fields(args[2])
+	}
+
+	switch args[1] {
+	case "plan":
+		f, err := os.Create(strings.TrimPrefix(args[len(args)-1], "-out="))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		zw := zip.NewWriter(f)
+		w, err := zw.Create("tfstate")
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, `{"version":4,"serial":%d}`, e.serial); err != nil {
+			return err
+		}
+		return zw.Close()
+	case "show":
+		cmd := &exec.Cmd{}
+		for _, o := range opts {
+			o(cmd)
+		}
+		_, err := io.WriteString(cmd.Stdout, e.json)
+		return err
+	}
+	return nil
+}
+
+func TestRunnerInterrupt(t *testing.T) {
+	testutil.Run(t, "cancelled run", func(t *testutil.T) {
+		out := new(bytes.Buffer)
+		f := cmdutil.NewFakeFactory(out, testobj.Run("dev", "run-12345", "sh", testobj.WithCancel()))
+		cmd, o := RunnerCmd(f)
+		cmd.SetOut(out)
+		cmd.SetArgs([]string{"--", "sleep 10"})
+
+		o.terminationMessagePath = filepath.Join(t.NewTempDir().Root(), "termination-log")
+
+		// Set flag via env var since that's how runner is invoked on a pod
+		t.SetEnvs(map[string]string{
+			"ETOK_NAMESPACE": "dev",
+			"ETOK_COMMAND":   "sh",
+			"ETOK_RUN_NAME":  "run-12345",
+		})
+		envvars.SetFlagsFromEnvVariables(cmd)
+
+		assert.Error(t, cmd.ExecuteContext(context.Background()))
+
+		msg, err := os.ReadFile(o.terminationMessagePath)
+		require.NoError(t, err)
+		assert.Equal(t, "Cancelled", string(msg))
+	})
+
+	testutil.Run(t, "max duration exceeded", func(t *testutil.T) {
+		_, cmd, o := setupRunnerCmd(t, "--", "sleep 10")
+
+		o.terminationMessagePath = filepath.Join(t.NewTempDir().Root(), "termination-log")
+
+		// Set flag via env var since that's how runner is invoked on a pod
+		t.SetEnvs(map[string]string{
+			"ETOK_NAMESPACE":    "dev",
+			"ETOK_COMMAND":      "sh",
+			"ETOK_MAX_DURATION": "100ms",
+		})
+		envvars.SetFlagsFromEnvVariables(cmd)
+
+		assert.Error(t, cmd.ExecuteContext(context.Background()))
+
+		msg, err := os.ReadFile(o.terminationMessagePath)
+		require.NoError(t, err)
+		assert.Equal(t, "MaxDurationExceeded", string(msg))
+	})
+}
+
 func TestRunnerHandshake(t *testing.T) {
 	tests := []struct {
 		name string
@@ -289,6 +533,51 @@ func TestRunnerTarball(t *testing.T) {
 	})
 }
 
+func TestRunnerRepository(t *testing.T) {
+	testutil.Run(t, "repository", func(t *testutil.T) {
+		// ls will check repository cloned successfully and to the expected path
+		_, cmd, _ := setupRunnerCmd(t, "--", "/bin/ls main.tf")
+
+		// Dest dir to clone repository to
+		dest := t.NewTempDir()
+		dest.Chdir()
+
+		t.SetEnvs(map[string]string{
+			"ETOK_NAMESPACE":  "dev",
+			"ETOK_REPOSITORY": createRepoWithFiles(t, "main.tf"),
+			"ETOK_COMMAND":    "sh",
+			"ETOK_DEST":       dest.Root(),
+		})
+		envvars.SetFlagsFromEnvVariables(cmd)
+
+		assert.NoError(t, cmd.ExecuteContext(context.Background()))
+	})
+}
+
+// createRepoWithFiles creates a git repository with a single commit adding the
+// files, returning its path
+func createRepoWithFiles(t *testutil.T, filenames ...string) string {
+	dir := t.NewTempDir()
+	for _, fname := range filenames {
+		dir.Write(fname, []byte{})
+	}
+
+	repo, err := git.PlainInit(dir.Root(), false)
+	require.NoError(t, err)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	for _, fname := range filenames {
+		_, err = wt.Add(fname)
+		require.NoError(t, err)
+	}
+	_, err = wt.Commit("initial commit", &git.CommitOptions{
+		Author: &object.Signature{Name: "etok", Email: "etok@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+
+	return dir.Root()
+}
+
 func createTarballWithFiles(t *testutil.T, name string, filenames ...string) {
 	f, err := os.Create(name)
 	zw := gzip.NewWriter(f)
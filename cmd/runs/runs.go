@@ -0,0 +1,188 @@
+package runs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/cmd/flags"
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/leg100/etok/pkg/client"
+	"github.com/leg100/etok/pkg/env"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// default namespace and workspace if .terraform/environment is not found
+	defaultNamespace = "default"
+	defaultWorkspace = "default"
+)
+
+var (
+	errRunNotFound   = errors.New("run not found")
+	errInvalidOutput = errors.New("invalid output format: must be one of 'json' or 'yaml'")
+)
+
+func RunsCmd(f *cmdutil.Factory) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "runs",
+		Short: "Etok run management",
+	}
+
+	listCmd, _ := listCmd(f)
+	cmd.AddCommand(listCmd)
+
+	showCmd, _ := showCmd(f)
+	cmd.AddCommand(showCmd)
+
+	watchCmd, _ := watchCmd(f)
+	cmd.AddCommand(watchCmd)
+
+	return cmd
+}
+
+// runsOptions are the options common to the runs commands
+type runsOptions struct {
+	*cmdutil.Factory
+
+	*client.Client
+
+	path        string
+	namespace   string
+	workspace   string
+	kubeContext string
+
+	// Output format: either empty, for human readable output, or json or yaml
+	output string
+
+	// Current time, overridden in tests
+	now func() time.Time
+}
+
+func newRunsOptions(f *cmdutil.Factory) runsOptions {
+	return runsOptions{
+		Factory:   f,
+		namespace: defaultNamespace,
+		workspace: defaultWorkspace,
+		now:       time.Now,
+	}
+}
+
+func (o *runsOptions) addFlags(cmd *cobra.Command) {
+	flags.AddPathFlag(cmd, &o.path)
+	flags.AddNamespaceFlag(cmd, &o.namespace)
+	flags.AddKubeContextFlag(cmd, &o.kubeContext)
+
+	cmd.Flags().StringVarP(&o.output, "output", "o", "", "Output format. One of: json|yaml")
+}
+
+// complete overrides the default namespace and workspace with values from the
+// env file, unless flags are set, validates the output format, and creates the
+// client
+func (o *runsOptions) complete(cmd *cobra.Command) (err error) {
+	switch o.output {
+	case "", "json", "yaml":
+	default:
+		return fmt.Errorf("%w: %s", errInvalidOutput, o.output)
+	}
+
+	etokenv, err := env.Read(o.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		if !flags.IsFlagPassed(cmd.Flags(), "namespace") {
+			o.namespace = etokenv.Namespace
+		}
+		if !flags.IsFlagPassed(cmd.Flags(), "workspace") {
+			o.workspace = etokenv.Workspace
+		}
+	}
+
+	o.Client, err = o.Create(o.kubeContext)
+	return err
+}
+
+// printObject prints the object in the selected output format. The object's
+// kind and api version are set first, because the typed clients strip them
+// from the objects they return.
+func (o *runsOptions) printObject(out io.Writer, obj runtime.Object) error {
+	setTypeMeta(obj)
+
+	var data []byte
+	var err error
+	switch o.output {
+	case "json":
+		data, err = json.MarshalIndent(obj, "", "    ")
+		data = append(data, '\n')
+	case "yaml":
+		data, err = yaml.Marshal(obj)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = out.Write(data)
+	return err
+}
+
+func setTypeMeta(obj runtime.Object) {
+	switch obj := obj.(type) {
+	case *v1alpha1.Run:
+		obj.SetGroupVersionKind(v1alpha1.SchemeGroupVersion.WithKind("Run"))
+	case *v1alpha1.RunList:
+		obj.SetGroupVersionKind(v1alpha1.SchemeGroupVersion.WithKind("RunList"))
+		for i := range obj.Items {
+			setTypeMeta(&obj.Items[i])
+		}
+	}
+}
+
+// printRow prints a run as a row in a table of runs
+func (o *runsOptions) printRow(out io.Writer, run *v1alpha1.Run) {
+	phase := string(run.Phase)
+	if phase == "" {
+		phase = "-"
+	}
+
+	fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\n",
+		run.Name,
+		run.Workspace,
+		run.Command,
+		phase,
+		duration.HumanDuration(o.now().Sub(run.CreationTimestamp.Time)))
+}
+
+// runFilter selects runs by workspace, phase and command. An empty field
+// matches any run.
+type runFilter struct {
+	workspace string
+	phase     string
+	command   string
+}
+
+func (f *runFilter) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.phase, "phase", "", "Only runs in this phase")
+	cmd.Flags().StringVar(&f.command, "command", "", "Only runs of this command")
+}
+
+func (f *runFilter) matches(run *v1alpha1.Run) bool {
+	if f.workspace != "" && run.Workspace != f.workspace {
+		return false
+	}
+	if f.phase != "" && string(run.Phase) != f.phase {
+		return false
+	}
+	if f.command != "" && run.Command != f.command {
+		return false
+	}
+	return true
+}
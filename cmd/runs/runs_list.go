@@ -0,0 +1,76 @@
+package runs
+
+import (
+	"context"
+	"sort"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/cmd/flags"
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type listOptions struct {
+	runsOptions
+
+	filter runFilter
+
+	// List runs of all workspaces in the namespace
+	allWorkspaces bool
+}
+
+func listCmd(f *cmdutil.Factory) (*cobra.Command, *listOptions) {
+	o := &listOptions{runsOptions: newRunsOptions(f)}
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List runs for the current workspace",
+		Long:  "List runs for the current workspace, oldest first. For each run its name, workspace, command, phase, and age are shown. Runs can be filtered by phase and command.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.complete(cmd); err != nil {
+				return err
+			}
+			if !o.allWorkspaces {
+				o.filter.workspace = o.workspace
+			}
+			return o.list(cmd.Context())
+		},
+	}
+
+	o.addFlags(cmd)
+	o.filter.addFlags(cmd)
+	flags.AddWorkspaceFlag(cmd, &o.workspace)
+	cmd.Flags().BoolVarP(&o.allWorkspaces, "all-workspaces", "A", false, "List runs of all workspaces in the namespace")
+
+	return cmd, o
+}
+
+func (o *listOptions) list(ctx context.Context) error {
+	runs, err := o.RunsClient(o.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	items := []v1alpha1.Run{}
+	for _, run := range runs.Items {
+		if o.filter.matches(&run) {
+			items = append(items, run)
+		}
+	}
+	runs.Items = items
+
+	sort.Slice(runs.Items, func(i, j int) bool {
+		return runs.Items[i].CreationTimestamp.Before(&runs.Items[j].CreationTimestamp)
+	})
+
+	if o.output != "" {
+		return o.printObject(o.Out, runs)
+	}
+
+	for i := range runs.Items {
+		o.printRow(o.Out, &runs.Items[i])
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package runs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/leg100/etok/pkg/env"
+	"github.com/leg100/etok/pkg/testobj"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestListRuns(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	created := func(ago time.Duration) func(*v1alpha1.Run) {
+		return func(run *v1alpha1.Run) {
+			run.CreationTimestamp = metav1.NewTime(now.Add(-ago))
+		}
+	}
+
+	runs := []runtime.Object{
+		testobj.Run("default", "apply-1", "apply", testobj.WithWorkspace("default"), testobj.WithRunPhase(v1alpha1.RunPhaseCompleted), created(10*time.Minute)),
+		testobj.Run("default", "plan-1", "plan", testobj.WithWorkspace("default"), testobj.WithRunPhase(v1alpha1.RunPhaseRunning), created(time.Minute)),
+		testobj.Run("default", "apply-2", "apply", testobj.WithWorkspace("default"), testobj.WithRunPhase(v1alpha1.RunPhaseQueued), created(5*time.Minute)),
+		testobj.Run("default", "apply-3", "apply", testobj.WithWorkspace("networking"), testobj.WithRunPhase(v1alpha1.RunPhaseCompleted), created(2*time.Minute)),
+		testobj.Run("dev", "apply-4", "apply", testobj.WithWorkspace("networking"), testobj.WithRunPhase(v1alpha1.RunPhaseCompleted), created(3*time.Minute)),
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		env  *env.Env
+		out  string
+		err  error
+	}{
+		{
+			name: "runs of current workspace",
+			out:  "apply-1\tdefault\tapply\tcompleted\t10m\napply-2\tdefault\tapply\tqueued\t5m\nplan-1\tdefault\tplan\trunning\t60s\n",
+		},
+		{
+			name: "runs of all workspaces",
+			args: []string{"--all-workspaces"},
+			out:  "apply-1\tdefault\tapply\tcompleted\t10m\napply-2\tdefault\tapply\tqueued\t5m\napply-3\tnetworking\tapply\tcompleted\t2m\nplan-1\tdefault\tplan\trunning\t60s\n",
+		},
+		{
+			name: "filter by phase",
+			args: []string{"--phase", "completed"},
+			out:  "apply-1\tdefault\tapply\tcompleted\t10m\n",
+		},
+		{
+			name: "filter by command",
+			args: []string{"--command", "plan"},
+			out:  "plan-1\tdefault\tplan\trunning\t60s\n",
+		},
+		{
+			name: "workspace from environment file",
+			env:  &env.Env{Namespace: "dev", Workspace: "networking"},
+			out:  "apply-4\tnetworking\tapply\tcompleted\t3m\n",
+		},
+		{
+			name: "no matching runs",
+			args: []string{"--workspace", "nonexistent"},
+		},
+		{
+			name: "json output",
+			args: []string{"--command", "plan", "-o", "json"},
+			out: `{
+    "kind": "RunList",
+    "apiVersion": "etok.dev/v1alpha1",
+    "metadata": {},
+    "items": [
+        {
+            "kind": "Run",
+            "apiVersion": "etok.dev/v1alpha1",
+            "metadata": {
+                "name": "plan-1",
+                "namespace": "default",
+                "creationTimestamp": "2021-03-01T11:59:00Z"
+            },
+            "spec": {
+                "command": "plan",
+                "configMap": "plan-1",
+                "configMapKey": "config.tar.gz",
+                "workspace": "default",
+                "timeouts": {},
+                "handshakeTimeout": "10s"
+            },
+            "status": {
+                "phase": "running"
+            }
+        }
+    ]
+}
+`,
+		},
+		{
+			name: "yaml output",
+			args: []string{"--workspace", "nonexistent", "-o", "yaml"},
+			out:  "apiVersion: etok.dev/v1alpha1\nitems: []\nkind: RunList\nmetadata: {}\n",
+		},
+		{
+			name: "invalid output",
+			args: []string{"-o", "xml"},
+			err:  errInvalidOutput,
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			path := t.NewTempDir().Chdir().Root()
+
+			// Write .terraform/environment
+			if tt.env != nil {
+				require.NoError(t, tt.env.Write(path))
+			}
+
+			out := new(bytes.Buffer)
+			cmd, o := listCmd(cmdutil.NewFakeFactory(out, runs...))
+			o.now = func() time.Time { return now }
+			cmd.SetArgs(tt.args)
+
+			err := cmd.ExecuteContext(context.Background())
+			if !assert.True(t, errors.Is(err, tt.err)) {
+				t.Errorf("unexpected error: %s", err)
+			}
+
+			assert.Equal(t, tt.out, out.String())
+		})
+	}
+}
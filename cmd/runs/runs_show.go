@@ -0,0 +1,180 @@
+package runs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+type showOptions struct {
+	runsOptions
+
+	run string
+}
+
+func showCmd(f *cmdutil.Factory) (*cobra.Command, *showOptions) {
+	o := &showOptions{runsOptions: newRunsOptions(f)}
+	cmd := &cobra.Command{
+		Use:   "show <run>",
+		Short: "Show the details of a run",
+		Long:  "Show the details of a run: its command and arguments, its phase, exit code, how long it waited in the queue, how long it ran for, and a summary of its plan, if it saved or applied one. Its conditions are listed in the order in which they last changed.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o.run = args[0]
+
+			if err := o.complete(cmd); err != nil {
+				return err
+			}
+			return o.show(cmd.Context())
+		},
+	}
+
+	o.addFlags(cmd)
+
+	return cmd, o
+}
+
+func (o *showOptions) show(ctx context.Context) error {
+	run, err := o.RunsClient(o.namespace).Get(ctx, o.run, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return fmt.Errorf("%w: %s/%s", errRunNotFound, o.namespace, o.run)
+	} else if err != nil {
+		return err
+	}
+
+	if o.output != "" {
+		return o.printObject(o.Out, run)
+	}
+
+	// The run's pod records when the run left the queue and started running
+	pod, err := o.PodsClient(o.namespace).Get(ctx, run.PodName(), metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		pod = nil
+	} else if err != nil {
+		return err
+	}
+
+	summary, err := o.planSummary(ctx, run)
+	if err != nil {
+		return err
+	}
+
+	o.printField("Name", run.Name)
+	o.printField("Namespace", run.Namespace)
+	o.printField("Workspace", run.Workspace)
+	o.printField("Command", run.Command)
+	o.printField("Args", strings.Join(run.Args, " "))
+	o.printField("Phase", string(run.Phase))
+	if run.ExitCode != nil {
+		o.printField("Exit Code", fmt.Sprint(*run.ExitCode))
+	} else {
+		o.printField("Exit Code", "")
+	}
+	o.printField("Queue Wait", o.queueWait(run, pod))
+	o.printField("Duration", o.duration(run, pod))
+	o.printField("Plan", summary)
+
+	conditions := append([]metav1.Condition{}, run.Conditions...)
+	sort.SliceStable(conditions, func(i, j int) bool {
+		return conditions[i].LastTransitionTime.Before(&conditions[j].LastTransitionTime)
+	})
+
+	fmt.Fprintln(o.Out, "Conditions:")
+	for _, cond := range conditions {
+		fmt.Fprintf(o.Out, "  %s\t%s\t%s\t%s\t%s\n",
+			cond.Type,
+			cond.Status,
+			cond.Reason,
+			duration.HumanDuration(o.now().Sub(cond.LastTransitionTime.Time)),
+			cond.Message)
+	}
+
+	return nil
+}
+
+// printField prints a named field, substituting a hyphen for an empty value
+func (o *showOptions) printField(name, value string) {
+	if value == "" {
+		value = "-"
+	}
+	fmt.Fprintf(o.Out, "%-12s%s\n", name+":", value)
+}
+
+// queueWait returns how long the run waited before its pod was created. If the
+// run is still waiting then the time waited thus far is returned.
+func (o *showOptions) queueWait(run *v1alpha1.Run, pod *corev1.Pod) string {
+	if pod != nil {
+		return duration.HumanDuration(pod.CreationTimestamp.Sub(run.CreationTimestamp.Time))
+	}
+	if !run.IsDone() {
+		return duration.HumanDuration(o.now().Sub(run.CreationTimestamp.Time))
+	}
+	return ""
+}
+
+// duration returns how long the run's pod ran for. If the run is still running
+// then the time it has run thus far is returned.
+func (o *showOptions) duration(run *v1alpha1.Run, pod *corev1.Pod) string {
+	if pod == nil {
+		return ""
+	}
+
+	start := pod.CreationTimestamp.Time
+	if pod.Status.StartTime != nil {
+		start = pod.Status.StartTime.Time
+	}
+
+	end := o.now()
+	if completed := completionTime(run); completed != nil {
+		end = *completed
+	}
+
+	return duration.HumanDuration(end.Sub(start))
+}
+
+// completionTime returns the time at which the run completed or failed, or
+// nil if it is yet to do so
+func completionTime(run *v1alpha1.Run) *time.Time {
+	for _, cond := range run.Conditions {
+		switch cond.Type {
+		case v1alpha1.RunCompleteCondition, v1alpha1.RunFailedCondition:
+			if cond.Status == metav1.ConditionTrue {
+				return &cond.LastTransitionTime.Time
+			}
+		}
+	}
+	return nil
+}
+
+// planSummary returns the summary of the plan the run saved or applied, or an
+// empty string if it did neither or the plan no longer exists
+func (o *showOptions) planSummary(ctx context.Context, run *v1alpha1.Run) (string, error) {
+	var name string
+	switch {
+	case run.SavePlan:
+		name = run.PlanConfigMapName()
+	case run.Plan != "":
+		name = v1alpha1.RunPlanConfigMapName(run.Plan)
+	default:
+		return "", nil
+	}
+
+	plan, err := o.ConfigMapsClient(o.namespace).Get(ctx, name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	return plan.Data[v1alpha1.PlanSummaryKey], nil
+}
@@ -0,0 +1,179 @@
+package runs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/leg100/etok/pkg/env"
+	"github.com/leg100/etok/pkg/testobj"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestShowRun(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	created := func(ago time.Duration) func(*v1alpha1.Run) {
+		return func(run *v1alpha1.Run) {
+			run.CreationTimestamp = metav1.NewTime(now.Add(-ago))
+		}
+	}
+	condition := func(condType string, status metav1.ConditionStatus, reason string, ago time.Duration) func(*v1alpha1.Run) {
+		return func(run *v1alpha1.Run) {
+			meta.SetStatusCondition(&run.Conditions, metav1.Condition{
+				Type:               condType,
+				Status:             status,
+				Reason:             reason,
+				LastTransitionTime: metav1.NewTime(now.Add(-ago)),
+			})
+		}
+	}
+	started := func(ago time.Duration) func(*corev1.Pod) {
+		return func(pod *corev1.Pod) {
+			pod.CreationTimestamp = metav1.NewTime(now.Add(-ago))
+			start := metav1.NewTime(now.Add(-ago + time.Second))
+			pod.Status.StartTime = &start
+		}
+	}
+
+	tests := []struct {
+		name string
+		objs []runtime.Object
+		args []string
+		env  *env.Env
+		out  string
+		err  error
+	}{
+		{
+			name: "completed run",
+			objs: []runtime.Object{
+				testobj.Run("default", "apply-1", "apply",
+					created(10*time.Minute),
+					testobj.WithWorkspace("default"),
+					testobj.WithArgs("-auto-approve", "-refresh=false"),
+					testobj.WithRunPhase(v1alpha1.RunPhaseCompleted),
+					testobj.WithRunExitCode(0),
+					condition(v1alpha1.RunCompleteCondition, metav1.ConditionTrue, v1alpha1.PodSucceededReason, 2*time.Minute),
+					condition(v1alpha1.RunPolicyFailedCondition, metav1.ConditionFalse, v1alpha1.PolicyPassedReason, 9*time.Minute)),
+				testobj.RunPod("default", "apply-1", started(7*time.Minute)),
+			},
+			args: []string{"apply-1"},
+			out: `Name:       apply-1
+Namespace:  default
+Workspace:  default
+Command:    apply
+Args:       -auto-approve -refresh=false
+Phase:      completed
+Exit Code:  0
+Queue Wait: 3m
+Duration:   4m59s
+Plan:       -
+Conditions:
+  PolicyFailed	False	PolicyPassed	9m	
+  Complete	True	PodSucceeded	2m	
+`,
+		},
+		{
+			name: "queued run",
+			objs: []runtime.Object{
+				testobj.Run("default", "apply-1", "apply",
+					created(90*time.Second),
+					testobj.WithRunPhase(v1alpha1.RunPhaseQueued),
+					condition(v1alpha1.RunCompleteCondition, metav1.ConditionFalse, v1alpha1.RunQueuedReason, 80*time.Second)),
+			},
+			args: []string{"apply-1"},
+			out: `Name:       apply-1
+Namespace:  default
+Workspace:  -
+Command:    apply
+Args:       -
+Phase:      queued
+Exit Code:  -
+Queue Wait: 90s
+Duration:   -
+Plan:       -
+Conditions:
+  Complete	False	Queued	80s	
+`,
+		},
+		{
+			name: "run that saved a plan",
+			objs: []runtime.Object{
+				testobj.Run("dev", "plan-1", "plan", created(time.Minute), testobj.WithSavePlan()),
+				testobj.ConfigMap("dev", "plan-1-plan", testobj.WithSavedPlan("networking", 3, "Plan: 1 to add, 0 to change, 0 to destroy.")),
+			},
+			args: []string{"plan-1"},
+			env:  &env.Env{Namespace: "dev", Workspace: "networking"},
+			out: `Name:       plan-1
+Namespace:  dev
+Workspace:  -
+Command:    plan
+Args:       -
+Phase:      -
+Exit Code:  -
+Queue Wait: 60s
+Duration:   -
+Plan:       Plan: 1 to add, 0 to change, 0 to destroy.
+Conditions:
+`,
+		},
+		{
+			name: "yaml output",
+			objs: []runtime.Object{
+				testobj.Run("default", "apply-1", "apply", created(time.Minute)),
+			},
+			args: []string{"apply-1", "-o", "yaml"},
+			out: `apiVersion: etok.dev/v1alpha1
+kind: Run
+metadata:
+  creationTimestamp: "2021-03-01T11:59:00Z"
+  name: apply-1
+  namespace: default
+spec:
+  command: apply
+  configMap: apply-1
+  configMapKey: config.tar.gz
+  handshakeTimeout: 10s
+  timeouts: {}
+  workspace: ""
+status: {}
+`,
+		},
+		{
+			name: "run not found",
+			args: []string{"apply-1"},
+			err:  errRunNotFound,
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			path := t.NewTempDir().Chdir().Root()
+
+			// Write .terraform/environment
+			if tt.env != nil {
+				require.NoError(t, tt.env.Write(path))
+			}
+
+			out := new(bytes.Buffer)
+			cmd, o := showCmd(cmdutil.NewFakeFactory(out, tt.objs...))
+			o.now = func() time.Time { return now }
+			cmd.SetArgs(tt.args)
+
+			err := cmd.ExecuteContext(context.Background())
+			if !assert.True(t, errors.Is(err, tt.err)) {
+				t.Errorf("unexpected error: %s", err)
+			}
+
+			assert.Equal(t, tt.out, out.String())
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package runs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/cmd/flags"
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/leg100/etok/pkg/k8s"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	watchtools "k8s.io/client-go/tools/watch"
+)
+
+type watchOptions struct {
+	runsOptions
+
+	filter runFilter
+
+	// Watch runs of all workspaces in the namespace
+	allWorkspaces bool
+
+	// Phase of each run last printed, keyed by run name
+	printed map[string]v1alpha1.RunPhase
+}
+
+func watchCmd(f *cmdutil.Factory) (*cobra.Command, *watchOptions) {
+	o := &watchOptions{
+		runsOptions: newRunsOptions(f),
+		printed:     make(map[string]v1alpha1.RunPhase),
+	}
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Watch runs for the current workspace",
+		Long:  "Watch runs for the current workspace. Existing runs are listed first, and thereafter a run is listed again whenever its phase changes. Runs can be filtered by phase and command.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.complete(cmd); err != nil {
+				return err
+			}
+			if !o.allWorkspaces {
+				o.filter.workspace = o.workspace
+			}
+			return o.watch(cmd.Context())
+		},
+	}
+
+	o.addFlags(cmd)
+	o.filter.addFlags(cmd)
+	flags.AddWorkspaceFlag(cmd, &o.workspace)
+	cmd.Flags().BoolVarP(&o.allWorkspaces, "all-workspaces", "A", false, "Watch runs of all workspaces in the namespace")
+
+	return cmd, o
+}
+
+// watch prints runs until the context is cancelled
+func (o *watchOptions) watch(ctx context.Context) error {
+	lw := &k8s.RunListWatcher{Client: o.EtokClient, Namespace: o.namespace}
+	_, err := watchtools.UntilWithSync(ctx, lw, &v1alpha1.Run{}, nil, func(event watch.Event) (bool, error) {
+		switch event.Type {
+		case watch.Added, watch.Modified:
+			run, ok := event.Object.(*v1alpha1.Run)
+			if !ok {
+				return false, fmt.Errorf("unexpected object: %T", event.Object)
+			}
+			return false, o.handle(run)
+		case watch.Deleted:
+			if run, ok := event.Object.(*v1alpha1.Run); ok {
+				delete(o.printed, run.Name)
+			}
+		}
+		return false, nil
+	})
+	if errors.Is(err, wait.ErrWaitTimeout) {
+		// Context has been cancelled
+		return nil
+	}
+	return err
+}
+
+// handle prints a run that matches the filter, unless it has already been
+// printed in its current phase
+func (o *watchOptions) handle(run *v1alpha1.Run) error {
+	if !o.filter.matches(run) {
+		return nil
+	}
+
+	if phase, ok := o.printed[run.Name]; ok && phase == run.Phase {
+		return nil
+	}
+	o.printed[run.Name] = run.Phase
+
+	if o.output != "" {
+		return o.printObject(o.Out, run)
+	}
+
+	o.printRow(o.Out, run)
+	return nil
+}
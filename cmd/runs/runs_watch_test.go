@@ -0,0 +1,148 @@
+package runs
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/leg100/etok/pkg/client"
+	"github.com/leg100/etok/pkg/testobj"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestWatchRuns(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	created := func(ago time.Duration) func(*v1alpha1.Run) {
+		return func(run *v1alpha1.Run) {
+			run.CreationTimestamp = metav1.NewTime(now.Add(-ago))
+		}
+	}
+
+	tests := []struct {
+		name string
+		objs []runtime.Object
+		args []string
+		// Phase to which apply-1 is updated once the existing runs have been
+		// printed
+		phase v1alpha1.RunPhase
+		// Number of lines to wait for before and after the update
+		before, after int
+		out           string
+	}{
+		{
+			name: "phase change",
+			objs: []runtime.Object{
+				testobj.Run("default", "apply-1", "apply", testobj.WithWorkspace("default"), testobj.WithRunPhase(v1alpha1.RunPhaseQueued), created(time.Minute)),
+				testobj.Run("default", "apply-2", "apply", testobj.WithWorkspace("networking"), testobj.WithRunPhase(v1alpha1.RunPhaseQueued), created(time.Minute)),
+			},
+			phase:  v1alpha1.RunPhaseRunning,
+			before: 1,
+			after:  1,
+			out:    "apply-1\tdefault\tapply\tqueued\t60s\napply-1\tdefault\tapply\trunning\t60s\n",
+		},
+		{
+			name: "filter by phase",
+			objs: []runtime.Object{
+				testobj.Run("default", "apply-1", "apply", testobj.WithWorkspace("default"), testobj.WithRunPhase(v1alpha1.RunPhaseRunning), created(time.Minute)),
+				testobj.Run("default", "apply-2", "apply", testobj.WithWorkspace("default"), testobj.WithRunPhase(v1alpha1.RunPhaseCompleted), created(2*time.Minute)),
+			},
+			args:   []string{"--phase", "completed"},
+			phase:  v1alpha1.RunPhaseCompleted,
+			before: 1,
+			after:  1,
+			out:    "apply-2\tdefault\tapply\tcompleted\t2m\napply-1\tdefault\tapply\tcompleted\t60s\n",
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			t.NewTempDir().Chdir()
+
+			out := &lineWriter{lines: make(chan string, 10)}
+			f := cmdutil.NewFakeFactory(out, tt.objs...)
+
+			// Share the client with the command so that the test can update
+			// runs whilst they're being watched
+			client, err := f.Create("")
+			require.NoError(t, err)
+			f.ClientCreator = &fixedClientCreator{client}
+
+			cmd, o := watchCmd(f)
+			o.now = func() time.Time { return now }
+			cmd.SetArgs(tt.args)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			go func() {
+				defer cancel()
+
+				waitForLines(out, tt.before)
+
+				run, err := client.RunsClient("default").Get(ctx, "apply-1", metav1.GetOptions{})
+				if err != nil {
+					return
+				}
+				run.Phase = tt.phase
+				if _, err := client.RunsClient("default").Update(ctx, run, metav1.UpdateOptions{}); err != nil {
+					return
+				}
+
+				waitForLines(out, tt.after)
+			}()
+
+			require.NoError(t, cmd.ExecuteContext(ctx))
+
+			assert.Equal(t, tt.out, out.String())
+		})
+	}
+}
+
+// lineWriter records what is written to it, and sends each line written on a
+// channel
+type lineWriter struct {
+	buf   bytes.Buffer
+	lines chan string
+	mu    sync.Mutex
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, line := range strings.SplitAfter(string(p), "\n") {
+		if line != "" {
+			w.lines <- line
+		}
+	}
+	return w.buf.Write(p)
+}
+
+func (w *lineWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.buf.String()
+}
+
+func waitForLines(w *lineWriter, n int) {
+	for i := 0; i < n; i++ {
+		<-w.lines
+	}
+}
+
+type fixedClientCreator struct {
+	client *client.Client
+}
+
+func (c *fixedClientCreator) Create(string) (*client.Client, error) {
+	return c.client, nil
+}
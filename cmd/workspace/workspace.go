@@ -29,6 +29,9 @@ func WorkspaceCmd(f *cmdutil.Factory) *cobra.Command {
 		deleteCmd(f),
 		showCmd(f),
 		selectCmd(f),
+		backupCmd(f),
+		backupsCmd(f),
+		restoreCmd(f),
 	)
 
 	return cmd
@@ -0,0 +1,151 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/cmd/flags"
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/leg100/etok/pkg/client"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	defaultBackupTimeout = 60 * time.Second
+)
+
+var (
+	errBackupTimeout = errors.New("timed out waiting for backup")
+	errBackupFailed  = errors.New("backup failed")
+)
+
+func backupCmd(f *cmdutil.Factory) *cobra.Command {
+	var kubeContext string
+	var namespace = defaultNamespace
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "backup <workspace>",
+		Short: "Backup an etok workspace's state",
+		Long:  "Request that the operator immediately backup the workspace's state, and wait for the backup to complete.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := f.Create(kubeContext)
+			if err != nil {
+				return err
+			}
+
+			// Identify the request in order to wait for the operator to record
+			// its outcome, rather than that of an earlier request
+			id := time.Now().Format(time.RFC3339Nano)
+
+			ws, err := annotateAndWait(cmd.Context(), client, namespace, args[0], map[string]string{v1alpha1.BackupRequestedAnnotationKey: id}, timeout, func(ws *v1alpha1.Workspace) bool {
+				return ws.Status.LastBackupRequest != nil && ws.Status.LastBackupRequest.ID == id
+			})
+			if err != nil {
+				if errors.Is(err, wait.ErrWaitTimeout) {
+					return errBackupTimeout
+				}
+				return err
+			}
+
+			if msg := ws.Status.LastBackupRequest.Error; msg != "" {
+				return fmt.Errorf("%w: %s", errBackupFailed, msg)
+			}
+			if ws.Status.BackupSerial == nil {
+				return errBackupFailed
+			}
+
+			fmt.Fprintf(f.Out, "Backed up state #%d of workspace %s/%s\n", *ws.Status.BackupSerial, namespace, ws.Name)
+
+			return nil
+		},
+	}
+
+	flags.AddNamespaceFlag(cmd, &namespace)
+	flags.AddKubeContextFlag(cmd, &kubeContext)
+
+	cmd.Flags().DurationVar(&timeout, "timeout", defaultBackupTimeout, "Time to wait for backup to complete")
+
+	return cmd
+}
+
+func backupsCmd(f *cmdutil.Factory) *cobra.Command {
+	var kubeContext string
+	var namespace = defaultNamespace
+
+	cmd := &cobra.Command{
+		Use:   "backups <workspace>",
+		Short: "List backups of an etok workspace's state",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := f.Create(kubeContext)
+			if err != nil {
+				return err
+			}
+
+			ws, err := client.WorkspacesClient(namespace).Get(cmd.Context(), args[0], metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+
+			// Mark backup of current state
+			var prefix string
+			for _, b := range ws.Status.Backups {
+				if ws.Status.Serial != nil && *ws.Status.Serial == b.Serial {
+					prefix = "*"
+				} else {
+					prefix = ""
+				}
+				fmt.Fprintf(f.Out, "%s\t%d\t%s\n", prefix, b.Serial, b.Created.UTC().Format(time.RFC3339))
+			}
+
+			return nil
+		},
+	}
+
+	flags.AddNamespaceFlag(cmd, &namespace)
+	flags.AddKubeContextFlag(cmd, &kubeContext)
+
+	return cmd
+}
+
+// annotateAndWait sets annotations on a workspace and waits for the operator to
+// handle them, returning the workspace once done reports that it has done so.
+func annotateAndWait(ctx context.Context, client *client.Client, namespace, name string, kvs map[string]string, timeout time.Duration, done func(*v1alpha1.Workspace) bool) (*v1alpha1.Workspace, error) {
+	ws, err := client.WorkspacesClient(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	annotations := ws.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	for k, v := range kvs {
+		annotations[k] = v
+	}
+	ws.SetAnnotations(annotations)
+
+	if _, err := client.WorkspacesClient(namespace).Update(ctx, ws, metav1.UpdateOptions{}); err != nil {
+		return nil, err
+	}
+
+	err = wait.PollImmediate(time.Second, timeout, func() (bool, error) {
+		ws, err = client.WorkspacesClient(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return done(ws), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ws, nil
+}
@@ -0,0 +1,159 @@
+package workspace
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/leg100/etok/pkg/client"
+	"github.com/leg100/etok/pkg/testobj"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	testcore "k8s.io/client-go/testing"
+)
+
+// mockOperator mocks the operator's handling of an annotation on a workspace,
+// calling fn to update the workspace's status and removing the annotation,
+// along with any others accompanying the request.
+func mockOperator(f *cmdutil.Factory, key string, fn func(*v1alpha1.Workspace), others ...string) {
+	f.ClientCreator.(*client.FakeClientCreator).PrependReactor("update", "workspaces", func(action testcore.Action) (bool, runtime.Object, error) {
+		ws := action.(testcore.UpdateAction).GetObject().(*v1alpha1.Workspace)
+		if _, ok := ws.Annotations[key]; ok {
+			fn(ws)
+			delete(ws.Annotations, key)
+			for _, k := range others {
+				delete(ws.Annotations, k)
+			}
+		}
+		// Leave it to the default reactor to persist the update
+		return false, nil, nil
+	})
+}
+
+func TestBackupWorkspace(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		objs     []runtime.Object
+		operator func(*v1alpha1.Workspace)
+		out      string
+		err      error
+	}{
+		{
+			name: "backup",
+			args: []string{"workspace-1"},
+			objs: []runtime.Object{testobj.Workspace("default", "workspace-1")},
+			operator: func(ws *v1alpha1.Workspace) {
+				serial := 4
+				ws.Status.Serial = &serial
+				ws.Status.BackupSerial = &serial
+				ws.Status.LastBackupRequest = &v1alpha1.BackupRequest{ID: ws.Annotations[v1alpha1.BackupRequestedAnnotationKey]}
+			},
+			out: "Backed up state #4 of workspace default/workspace-1\n",
+		},
+		{
+			name: "backup failed",
+			args: []string{"workspace-1"},
+			objs: []runtime.Object{testobj.Workspace("default", "workspace-1")},
+			operator: func(ws *v1alpha1.Workspace) {
+				ws.Status.LastBackupRequest = &v1alpha1.BackupRequest{ID: ws.Annotations[v1alpha1.BackupRequestedAnnotationKey], Error: "bucket not found"}
+			},
+			err: errBackupFailed,
+		},
+		{
+			// Demonstrate that the outcome of an earlier request is not
+			// mistaken for that of this request, even though the current serial
+			// has already been backed up
+			name: "backup failed with current serial already backed up",
+			args: []string{"workspace-1"},
+			objs: []runtime.Object{testobj.Workspace("default", "workspace-1", testobj.WithBackupSerial(4), func(ws *v1alpha1.Workspace) {
+				serial := 4
+				ws.Status.Serial = &serial
+				ws.Status.LastBackupRequest = &v1alpha1.BackupRequest{ID: "earlier"}
+			})},
+			operator: func(ws *v1alpha1.Workspace) {
+				ws.Status.LastBackupRequest = &v1alpha1.BackupRequest{ID: ws.Annotations[v1alpha1.BackupRequestedAnnotationKey], Error: "bucket not found"}
+			},
+			err: errBackupFailed,
+		},
+		{
+			name: "backup timeout",
+			args: []string{"workspace-1", "--timeout", "10ms"},
+			objs: []runtime.Object{testobj.Workspace("default", "workspace-1")},
+			err:  errBackupTimeout,
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			out := new(bytes.Buffer)
+			f := cmdutil.NewFakeFactory(out, tt.objs...)
+
+			if tt.operator != nil {
+				mockOperator(f, v1alpha1.BackupRequestedAnnotationKey, tt.operator)
+			}
+
+			cmd := backupCmd(f)
+			cmd.SetArgs(tt.args)
+
+			err := cmd.ExecuteContext(context.Background())
+			if !assert.True(t, errors.Is(err, tt.err)) {
+				t.Logf("wanted %v but got %v", tt.err, err)
+			}
+			assert.Equal(t, tt.out, out.String())
+		})
+	}
+}
+
+func TestListBackups(t *testing.T) {
+	created := metav1.NewTime(time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name string
+		args []string
+		objs []runtime.Object
+		out  string
+		err  bool
+	}{
+		{
+			name: "list",
+			args: []string{"workspace-1"},
+			objs: []runtime.Object{testobj.Workspace("default", "workspace-1", func(ws *v1alpha1.Workspace) {
+				serial := 5
+				ws.Status.Serial = &serial
+				ws.Status.Backups = []*v1alpha1.Backup{
+					{Serial: 4, Created: created},
+					{Serial: 5, Created: created},
+				}
+			})},
+			out: "\t4\t2021-03-01T12:00:00Z\n*\t5\t2021-03-01T12:00:00Z\n",
+		},
+		{
+			name: "no backups",
+			args: []string{"workspace-1"},
+			objs: []runtime.Object{testobj.Workspace("default", "workspace-1")},
+		},
+		{
+			name: "without workspace",
+			args: []string{"workspace-1"},
+			err:  true,
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			out := new(bytes.Buffer)
+			f := cmdutil.NewFakeFactory(out, tt.objs...)
+
+			cmd := backupsCmd(f)
+			cmd.SetArgs(tt.args)
+
+			t.CheckError(tt.err, cmd.ExecuteContext(context.Background()))
+			assert.Equal(t, tt.out, out.String())
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/leg100/etok/api/etok.dev/v1alpha1"
@@ -17,11 +18,13 @@ import (
 	"github.com/leg100/etok/pkg/labels"
 	"github.com/leg100/etok/pkg/monitors"
 	"github.com/leg100/etok/pkg/repo"
+	"github.com/leg100/etok/pkg/util/slice"
 	"github.com/spf13/cobra"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/leg100/etok/pkg/env"
 	"github.com/leg100/etok/pkg/logstreamer"
+	"github.com/robfig/cron/v3"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -42,6 +45,10 @@ var (
 	errReadyTimeout       = errors.New("timed out waiting for workspace to be ready")
 	errWorkspaceNameArg   = errors.New("expected single argument providing the workspace name")
 	errRepositoryNotFound = errors.New("repository not found: workspace path must be within a git repository")
+	errUpstreamOutput     = errors.New("upstream output must be specified as <workspace>/<output>")
+	errOnUpstreamChange   = errors.New("command to run upon upstream change must be plan or apply")
+	errOnUpstreamApply    = errors.New("apply cannot be run upon upstream change on a workspace with policy rule sets or a privileged apply")
+	errDriftDetection     = errors.New("invalid drift detection schedule")
 )
 
 type newOptions struct {
@@ -79,6 +86,9 @@ type newOptions struct {
 	variables            map[string]string
 	environmentVariables map[string]string
 
+	// Terraform variables set to the outputs of other workspaces
+	upstreamOutputs map[string]string
+
 	etokenv *env.Env
 
 	// Git repo from which run is being launched
@@ -100,6 +110,30 @@ func newCmd(f *cmdutil.Factory) (*cobra.Command, *newOptions) {
 
 			o.workspace = args[0]
 
+			if o.workspaceSpec.DriftDetection != "" {
+				if _, err := cron.ParseStandard(o.workspaceSpec.DriftDetection); err != nil {
+					return fmt.Errorf("%w: %s", errDriftDetection, err)
+				}
+			}
+
+			switch o.workspaceSpec.OnUpstreamChange {
+			case "", "plan":
+			case "apply":
+				// An upstream triggered apply would bypass policy and
+				// approval
+				if len(o.workspaceSpec.PolicyRuleSets) > 0 || slice.ContainsString(o.workspaceSpec.PrivilegedCommands, "apply") {
+					return errOnUpstreamApply
+				}
+			default:
+				return fmt.Errorf("%w: %s", errOnUpstreamChange, o.workspaceSpec.OnUpstreamChange)
+			}
+
+			for _, ref := range o.upstreamOutputs {
+				if parts := strings.Split(ref, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+					return fmt.Errorf("%w: %s", errUpstreamOutput, ref)
+				}
+			}
+
 			// Ensure path is within a git repository
 			o.repo, err = repo.Open(o.path)
 			if err != nil {
@@ -150,9 +184,14 @@ func newCmd(f *cmdutil.Factory) (*cobra.Command, *newOptions) {
 	cmd.Flags().DurationVar(&o.readyTimeout, "ready-timeout", defaultReadyTimeout, "timeout for ready condition to report true")
 
 	cmd.Flags().StringSliceVar(&o.workspaceSpec.PrivilegedCommands, "privileged-commands", []string{}, "Set privileged commands")
+	cmd.Flags().StringSliceVar(&o.workspaceSpec.PolicyRuleSets, "policy-rule-sets", []string{}, "Names of config maps containing policy rule sets against which saved plans are evaluated")
+	cmd.Flags().StringVar(&o.workspaceSpec.DriftDetection, "drift-detection", "", "Cron schedule, in UTC, on which to check for drift (e.g. @daily)")
+	cmd.Flags().StringSliceVar(&o.workspaceSpec.CriticalResources, "critical-resources", []string{}, "Glob patterns matching the addresses of critical resources, the replacement or deletion of which is flagged in a plan's blast radius")
 
 	cmd.Flags().StringToStringVar(&o.variables, "variables", map[string]string{}, "Set terraform variables")
 	cmd.Flags().StringToStringVar(&o.environmentVariables, "environment-variables", map[string]string{}, "Set environment variables")
+	cmd.Flags().StringToStringVar(&o.upstreamOutputs, "upstream-outputs", map[string]string{}, "Set terraform variables to the outputs of other workspaces (e.g. vpc_id=network/vpc_id)")
+	cmd.Flags().StringVar(&o.workspaceSpec.OnUpstreamChange, "on-upstream-change", "", "Command to run when upstream outputs change: plan or apply")
 
 	return cmd, o
 }
@@ -258,6 +297,16 @@ func (o *newOptions) createWorkspace(ctx context.Context) (*v1alpha1.Workspace,
 		ws.Spec.Variables = append(ws.Spec.Variables, &v1alpha1.Variable{Key: k, Value: v, EnvironmentVariable: true})
 	}
 
+	for k, v := range o.upstreamOutputs {
+		parts := strings.Split(v, "/")
+		ws.Spec.Variables = append(ws.Spec.Variables, &v1alpha1.Variable{
+			Key: k,
+			ValueFrom: &v1alpha1.VariableSource{
+				WorkspaceOutputRef: &v1alpha1.WorkspaceOutputSelector{Workspace: parts[0], Output: parts[1]},
+			},
+		})
+	}
+
 	ws, err = o.WorkspacesClient(o.namespace).Create(ctx, ws, metav1.CreateOptions{})
 	if err != nil {
 		return nil, err
@@ -217,6 +217,44 @@ func TestNewWorkspace(t *testing.T) {
 				assert.Contains(t, ws.Spec.Variables, &v1alpha1.Variable{Key: "baz", Value: "haj", EnvironmentVariable: true})
 			},
 		},
+		{
+			name: "set upstream outputs",
+			args: []string{"foo", "--upstream-outputs", "vpc_id=network/vpc_id", "--on-upstream-change", "plan"},
+			objs: []runtime.Object{testobj.WorkspacePod("default", "foo")},
+			assertions: func(t *testutil.T, o *newOptions) {
+				// Get workspace
+				ws, err := o.WorkspacesClient(o.namespace).Get(context.Background(), o.workspace, metav1.GetOptions{})
+				require.NoError(t, err)
+
+				assert.Contains(t, ws.Spec.Variables, &v1alpha1.Variable{
+					Key: "vpc_id",
+					ValueFrom: &v1alpha1.VariableSource{
+						WorkspaceOutputRef: &v1alpha1.WorkspaceOutputSelector{Workspace: "network", Output: "vpc_id"},
+					},
+				})
+				assert.Equal(t, "plan", ws.Spec.OnUpstreamChange)
+			},
+		},
+		{
+			name: "invalid upstream output",
+			args: []string{"foo", "--upstream-outputs", "vpc_id=vpc_id"},
+			err:  errUpstreamOutput,
+		},
+		{
+			name: "invalid command to run upon upstream change",
+			args: []string{"foo", "--on-upstream-change", "destroy"},
+			err:  errOnUpstreamChange,
+		},
+		{
+			name: "apply upon upstream change with privileged apply",
+			args: []string{"foo", "--on-upstream-change", "apply", "--privileged-commands", "apply"},
+			err:  errOnUpstreamApply,
+		},
+		{
+			name: "apply upon upstream change with policy",
+			args: []string{"foo", "--on-upstream-change", "apply", "--policy-rule-sets", "policy"},
+			err:  errOnUpstreamApply,
+		},
 		{
 			name: "set privileged commands",
 			args: []string{"foo", "--privileged-commands", "apply,destroy,sh"},
@@ -229,6 +267,47 @@ func TestNewWorkspace(t *testing.T) {
 				assert.Equal(t, []string{"apply", "destroy", "sh"}, ws.Spec.PrivilegedCommands)
 			},
 		},
+		{
+			name: "set policy rule sets",
+			args: []string{"foo", "--policy-rule-sets", "protect-databases,limit-changes"},
+			objs: []runtime.Object{testobj.WorkspacePod("default", "foo")},
+			assertions: func(t *testutil.T, o *newOptions) {
+				// Get workspace
+				ws, err := o.WorkspacesClient(o.namespace).Get(context.Background(), o.workspace, metav1.GetOptions{})
+				require.NoError(t, err)
+
+				assert.Equal(t, []string{"protect-databases", "limit-changes"}, ws.Spec.PolicyRuleSets)
+			},
+		},
+		{
+			name: "set critical resources",
+			args: []string{"foo", "--critical-resources", "aws_db_instance.*,module.network.*"},
+			objs: []runtime.Object{testobj.WorkspacePod("default", "foo")},
+			assertions: func(t *testutil.T, o *newOptions) {
+				// Get workspace
+				ws, err := o.WorkspacesClient(o.namespace).Get(context.Background(), o.workspace, metav1.GetOptions{})
+				require.NoError(t, err)
+
+				assert.Equal(t, []string{"aws_db_instance.*", "module.network.*"}, ws.Spec.CriticalResources)
+			},
+		},
+		{
+			name: "set drift detection schedule",
+			args: []string{"foo", "--drift-detection", "0 * * * *"},
+			objs: []runtime.Object{testobj.WorkspacePod("default", "foo")},
+			assertions: func(t *testutil.T, o *newOptions) {
+				// Get workspace
+				ws, err := o.WorkspacesClient(o.namespace).Get(context.Background(), o.workspace, metav1.GetOptions{})
+				require.NoError(t, err)
+
+				assert.Equal(t, "0 * * * *", ws.Spec.DriftDetection)
+			},
+		},
+		{
+			name: "invalid drift detection schedule",
+			args: []string{"foo", "--drift-detection", "hourly"},
+			err:  errDriftDetection,
+		},
 		{
 			// Mock a absent/misbehaving operator
 			name: "reconcile timeout exceeded",
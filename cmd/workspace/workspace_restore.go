@@ -0,0 +1,119 @@
+package workspace
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/cmd/flags"
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+var (
+	errRestoreTimeout   = errors.New("timed out waiting for restore")
+	errRestoreFailed    = errors.New("restore failed")
+	errRestoreAborted   = errors.New("restore aborted")
+	errBackupNotFound   = errors.New("backup not found")
+	errInvalidSerialArg = errors.New("serial number must be an integer")
+)
+
+func restoreCmd(f *cmdutil.Factory) *cobra.Command {
+	var kubeContext string
+	var namespace = defaultNamespace
+	var timeout time.Duration
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "restore <workspace> <serial>",
+		Short: "Restore a backup of an etok workspace's state",
+		Long:  "Request that the operator restore the backup with the given serial number over the workspace's current state, and wait for the restore to complete.",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			serial, err := strconv.Atoi(args[1])
+			if err != nil {
+				return errInvalidSerialArg
+			}
+
+			client, err := f.Create(kubeContext)
+			if err != nil {
+				return err
+			}
+
+			ws, err := client.WorkspacesClient(namespace).Get(cmd.Context(), args[0], metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+
+			// Check backup exists before bothering the operator
+			if !hasBackup(ws, serial) {
+				return fmt.Errorf("%w: workspace %s/%s has no backup with serial #%d", errBackupNotFound, namespace, ws.Name, serial)
+			}
+
+			if !yes {
+				current := "no state"
+				if ws.Status.Serial != nil {
+					current = fmt.Sprintf("state #%d", *ws.Status.Serial)
+				}
+				fmt.Fprintf(f.Out, "Restore state #%d of workspace %s/%s, overwriting %s? [y/N]: ", serial, namespace, ws.Name, current)
+
+				answer, err := bufio.NewReader(f.In).ReadString('\n')
+				if err != nil {
+					return err
+				}
+				if answer := strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+					return errRestoreAborted
+				}
+			}
+
+			// Identify the request in order to wait for the operator to record
+			// its outcome, rather than that of an earlier request
+			id := time.Now().Format(time.RFC3339Nano)
+
+			annotations := map[string]string{
+				v1alpha1.RestoreSerialAnnotationKey:    strconv.Itoa(serial),
+				v1alpha1.RestoreRequestIDAnnotationKey: id,
+			}
+			ws, err = annotateAndWait(cmd.Context(), client, namespace, ws.Name, annotations, timeout, func(ws *v1alpha1.Workspace) bool {
+				return ws.Status.LastRestoreRequest != nil && ws.Status.LastRestoreRequest.ID == id
+			})
+			if err != nil {
+				if errors.Is(err, wait.ErrWaitTimeout) {
+					return errRestoreTimeout
+				}
+				return err
+			}
+
+			if msg := ws.Status.LastRestoreRequest.Error; msg != "" {
+				return fmt.Errorf("%w: %s", errRestoreFailed, msg)
+			}
+
+			fmt.Fprintf(f.Out, "Restored state #%d of workspace %s/%s\n", serial, namespace, ws.Name)
+
+			return nil
+		},
+	}
+
+	flags.AddNamespaceFlag(cmd, &namespace)
+	flags.AddKubeContextFlag(cmd, &kubeContext)
+
+	cmd.Flags().DurationVar(&timeout, "timeout", defaultBackupTimeout, "Time to wait for restore to complete")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Skip confirmation prompt")
+
+	return cmd
+}
+
+func hasBackup(ws *v1alpha1.Workspace, serial int) bool {
+	for _, b := range ws.Status.Backups {
+		if b.Serial == serial {
+			return true
+		}
+	}
+	return false
+}
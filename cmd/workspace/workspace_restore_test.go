@@ -0,0 +1,128 @@
+package workspace
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	cmdutil "github.com/leg100/etok/cmd/util"
+	"github.com/leg100/etok/pkg/testobj"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestRestoreWorkspace(t *testing.T) {
+	// Workspace with state #5, and backups #4 and #5
+	workspace := func() *v1alpha1.Workspace {
+		return testobj.Workspace("default", "workspace-1", func(ws *v1alpha1.Workspace) {
+			serial := 5
+			ws.Status.Serial = &serial
+			ws.Status.BackupSerial = &serial
+			ws.Status.Backups = []*v1alpha1.Backup{{Serial: 4}, {Serial: 5}}
+		})
+	}
+
+	// Mock operator successfully restoring backup #4
+	restored := func(ws *v1alpha1.Workspace) {
+		serial := 4
+		ws.Status.BackupSerial = &serial
+		ws.Status.LastRestoreRequest = &v1alpha1.RestoreRequest{ID: ws.Annotations[v1alpha1.RestoreRequestIDAnnotationKey], Serial: serial}
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		in       string
+		objs     []runtime.Object
+		operator func(*v1alpha1.Workspace)
+		out      string
+		err      error
+	}{
+		{
+			name:     "restore",
+			args:     []string{"workspace-1", "4"},
+			in:       "y\n",
+			objs:     []runtime.Object{workspace()},
+			operator: restored,
+			out:      "Restore state #4 of workspace default/workspace-1, overwriting state #5? [y/N]: Restored state #4 of workspace default/workspace-1\n",
+		},
+		{
+			name:     "skip confirmation",
+			args:     []string{"workspace-1", "4", "--yes"},
+			objs:     []runtime.Object{workspace()},
+			operator: restored,
+			out:      "Restored state #4 of workspace default/workspace-1\n",
+		},
+		{
+			name:     "aborted",
+			args:     []string{"workspace-1", "4"},
+			in:       "n\n",
+			objs:     []runtime.Object{workspace()},
+			operator: restored,
+			out:      "Restore state #4 of workspace default/workspace-1, overwriting state #5? [y/N]: ",
+			err:      errRestoreAborted,
+		},
+		{
+			name: "backup not found",
+			args: []string{"workspace-1", "3", "--yes"},
+			objs: []runtime.Object{workspace()},
+			err:  errBackupNotFound,
+		},
+		{
+			name: "invalid serial",
+			args: []string{"workspace-1", "four"},
+			err:  errInvalidSerialArg,
+		},
+		{
+			name: "restore failed",
+			args: []string{"workspace-1", "4", "--yes"},
+			objs: []runtime.Object{workspace()},
+			operator: func(ws *v1alpha1.Workspace) {
+				// Operator fails to restore state
+				ws.Status.LastRestoreRequest = &v1alpha1.RestoreRequest{ID: ws.Annotations[v1alpha1.RestoreRequestIDAnnotationKey], Serial: 4, Error: "backup not found"}
+			},
+			err: errRestoreFailed,
+		},
+		{
+			name: "outcome of earlier request",
+			args: []string{"workspace-1", "4", "--yes", "--timeout", "10ms"},
+			objs: []runtime.Object{workspace()},
+			operator: func(ws *v1alpha1.Workspace) {
+				// Operator removes annotations but has yet to record the
+				// outcome of this request
+				ws.Status.LastRestoreRequest = &v1alpha1.RestoreRequest{ID: "earlier", Serial: 4}
+			},
+			err: errRestoreTimeout,
+		},
+		{
+			name: "restore timeout",
+			args: []string{"workspace-1", "4", "--yes", "--timeout", "10ms"},
+			objs: []runtime.Object{workspace()},
+			err:  errRestoreTimeout,
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			out := new(bytes.Buffer)
+			f := cmdutil.NewFakeFactory(out, tt.objs...)
+			f.In = strings.NewReader(tt.in)
+
+			if tt.operator != nil {
+				mockOperator(f, v1alpha1.RestoreSerialAnnotationKey, tt.operator, v1alpha1.RestoreRequestIDAnnotationKey)
+			}
+
+			cmd := restoreCmd(f)
+			cmd.SetArgs(tt.args)
+
+			err := cmd.ExecuteContext(context.Background())
+			if !assert.True(t, errors.Is(err, tt.err)) {
+				t.Logf("wanted %v but got %v", tt.err, err)
+			}
+			assert.Equal(t, tt.out, out.String())
+		})
+	}
+}
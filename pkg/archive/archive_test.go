@@ -108,6 +108,24 @@ func TestWalk(t *testing.T) {
 	assert.Equal(t, want, got)
 }
 
+func TestLocalModules(t *testing.T) {
+	got, err := LocalModules("testdata/config-dir/m0")
+	require.NoError(t, err)
+
+	// Module walk is non-deterministic, other than the first module
+	assert.Equal(t, "testdata/config-dir/m0", got[0])
+	sort.Strings(got[1:])
+
+	want := []string{
+		"testdata/config-dir/m0",
+		"testdata/config-dir/m0/m2",
+		"testdata/config-dir/m0/m3",
+		"testdata/config-dir/m1",
+	}
+
+	assert.Equal(t, want, got)
+}
+
 func TestUnpack(t *testing.T) {
 	// Create archive with path to the root module (m0)
 	arc, err := NewArchive("testdata/config-dir/m0", "testdata/config-dir")
@@ -8,6 +8,16 @@ import (
 	"k8s.io/klog/v2"
 )
 
+// LocalModules returns the path of the module at path followed by the paths of
+// the local modules it calls, directly and indirectly.
+func LocalModules(path string) ([]string, error) {
+	mods, err := walk(path)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{path}, mods...), nil
+}
+
 // On each iteration, parse the module for calls, add those calls to list of
 // found modules, and then recurse over those modules.  Return the final list of
 // all found modules.
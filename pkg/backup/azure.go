@@ -0,0 +1,183 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+type azureProvider struct {
+	container string
+	client    azblob.ContainerURL
+}
+
+// NewAzureProvider constructs a provider backing up to an Azure Blob Storage
+// container. serviceURL is the URL of the storage account's blob service, e.g.
+// https://<account>.blob.core.windows.net/
+func NewAzureProvider(ctx context.Context, container string, serviceURL *url.URL, cred azblob.Credential) (Provider, error) {
+	p := azblob.NewPipeline(cred, azblob.PipelineOptions{})
+	containerURL := azblob.NewServiceURL(*serviceURL, p).NewContainerURL(container)
+
+	// Check container exists
+	_, err := containerURL.GetProperties(ctx, azblob.LeaseAccessConditions{})
+	if isAzureServiceCode(err, azblob.ServiceCodeContainerNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrBucketNotFound, container)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &azureProvider{
+		container: container,
+		client:    containerURL,
+	}, nil
+}
+
+func (p *azureProvider) Backup(ctx context.Context, secret *corev1.Secret, serial int) error {
+	// Marshal state file first to json then to yaml
+	y, err := yaml.Marshal(secret)
+	if err != nil {
+		return err
+	}
+
+	// Copy state file to blob storage
+	return p.put(ctx, versionPath(client.ObjectKeyFromObject(secret), serial, time.Now()), y)
+}
+
+func (p *azureProvider) Restore(ctx context.Context, key client.ObjectKey) (*corev1.Secret, error) {
+	versions, err := p.List(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		// Fallback to legacy unversioned backup
+		return p.get(ctx, path(key))
+	}
+	return p.get(ctx, versions[len(versions)-1].path)
+}
+
+func (p *azureProvider) RestoreSerial(ctx context.Context, key client.ObjectKey, serial int) (*corev1.Secret, error) {
+	versions, err := p.List(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	v := latestWithSerial(versions, serial)
+	if v == nil {
+		return nil, nil
+	}
+	return p.get(ctx, v.path)
+}
+
+func (p *azureProvider) List(ctx context.Context, key client.ObjectKey) ([]Version, error) {
+	var versions []Version
+
+	for marker := (azblob.Marker{}); marker.NotDone(); {
+		resp, err := p.client.ListBlobsFlatSegment(ctx, marker, azblob.ListBlobsSegmentOptions{Prefix: prefix(key)})
+		if err != nil {
+			return nil, err
+		}
+		for _, blob := range resp.Segment.BlobItems {
+			if v, ok := versionFromPath(key, blob.Name, blob.Properties.LastModified); ok {
+				versions = append(versions, v)
+			}
+		}
+		marker = resp.NextMarker
+	}
+
+	sortVersions(versions)
+
+	return versions, nil
+}
+
+func (p *azureProvider) Delete(ctx context.Context, v Version) error {
+	_, err := p.client.NewBlobURL(v.path).Delete(ctx, azblob.DeleteSnapshotsOptionNone, azblob.BlobAccessConditions{})
+	if isAzureServiceCode(err, azblob.ServiceCodeBlobNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (p *azureProvider) ArchiveLogs(ctx context.Context, key client.ObjectKey, uid types.UID, logs []byte) error {
+	return p.put(ctx, logsPath(key, uid), logs)
+}
+
+func (p *azureProvider) RetrieveLogs(ctx context.Context, key client.ObjectKey, uid types.UID) ([]byte, error) {
+	if uid != "" {
+		return p.read(ctx, logsPath(key, uid))
+	}
+
+	var archives []archivedLogs
+	for marker := (azblob.Marker{}); marker.NotDone(); {
+		resp, err := p.client.ListBlobsFlatSegment(ctx, marker, azblob.ListBlobsSegmentOptions{Prefix: logsPrefix(key)})
+		if err != nil {
+			return nil, err
+		}
+		for _, blob := range resp.Segment.BlobItems {
+			archives = append(archives, archivedLogs{path: blob.Name, created: blob.Properties.LastModified})
+		}
+		marker = resp.NextMarker
+	}
+	return p.read(ctx, latestLogsPath(key, archives))
+}
+
+// get retrieves the backup blob at the given path. Nil is returned if it
+// doesn't exist.
+func (p *azureProvider) get(ctx context.Context, path string) (*corev1.Secret, error) {
+	// Copy state file from blob storage
+	buf, err := p.read(ctx, path)
+	if err != nil || buf == nil {
+		return nil, err
+	}
+
+	// Unmarshal state file into secret obj
+	var secret corev1.Secret
+	if err := yaml.Unmarshal(buf, &secret); err != nil {
+		return nil, err
+	}
+
+	return &secret, nil
+}
+
+// put writes data to the blob at the given path
+func (p *azureProvider) put(ctx context.Context, path string, data []byte) error {
+	bb := p.client.NewBlockBlobURL(path)
+	_, err := azblob.UploadBufferToBlockBlob(ctx, data, bb, azblob.UploadToBlockBlobOptions{})
+	return err
+}
+
+// read reads the blob at the given path. Nil is returned if it doesn't exist.
+func (p *azureProvider) read(ctx context.Context, path string) ([]byte, error) {
+	resp, err := p.client.NewBlobURL(path).Download(ctx, 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false, azblob.ClientProvidedKeyOptions{})
+	if isAzureServiceCode(err, azblob.ServiceCodeBlobNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	body := resp.Body(azblob.RetryReaderOptions{})
+	buf, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// isAzureServiceCode determines whether err is a storage error with the given
+// service code
+func isAzureServiceCode(err error, code azblob.ServiceCodeType) bool {
+	serr, ok := err.(azblob.StorageError)
+	return ok && serr.ServiceCode() == code
+}
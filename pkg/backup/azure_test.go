@@ -0,0 +1,168 @@
+package backup
+
+import (
+	"context"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+type azureTestProvider struct {
+	*azureProvider
+}
+
+func (p *azureTestProvider) createProviderWithBuckets(t *testutil.T, providerBucket string, createBuckets ...string) (Provider, error) {
+	// fake blob storage
+	faker := newFakeAzureServer(createBuckets...)
+	ts := httptest.NewServer(faker)
+	t.Cleanup(ts.Close)
+
+	// Address storage account path-style, as Azurite does
+	serviceURL, err := url.Parse(ts.URL + "/devstoreaccount1")
+	require.NoError(t, err)
+
+	return NewAzureProvider(context.Background(), providerBucket, serviceURL, azblob.NewAnonymousCredential())
+}
+
+func (p *azureTestProvider) createLegacyBackup(t *testutil.T, provider Provider, secret *corev1.Secret) {
+	y, err := yaml.Marshal(secret)
+	require.NoError(t, err)
+
+	ap := provider.(*azureProvider)
+	bb := ap.client.NewBlockBlobURL(path(client.ObjectKeyFromObject(secret)))
+	_, err = azblob.UploadBufferToBlockBlob(context.Background(), y, bb, azblob.UploadToBlockBlobOptions{})
+	require.NoError(t, err)
+}
+
+type fakeAzureBlob struct {
+	data     []byte
+	modified time.Time
+}
+
+// fakeAzureServer implements the subset of the Azure Blob Storage REST API
+// used by the azure provider, addressing containers path-style:
+// /<account>/<container>/<blob>
+type fakeAzureServer struct {
+	containers map[string]map[string]*fakeAzureBlob
+	mu         sync.Mutex
+}
+
+func newFakeAzureServer(containers ...string) *fakeAzureServer {
+	s := &fakeAzureServer{containers: make(map[string]map[string]*fakeAzureBlob)}
+	for _, c := range containers {
+		s.containers[c] = make(map[string]*fakeAzureBlob)
+	}
+	return s
+}
+
+func (s *fakeAzureServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Strip account and split into container and blob name
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 3)
+	if len(parts) < 2 {
+		http.Error(w, "", http.StatusBadRequest)
+		return
+	}
+	blobs, ok := s.containers[parts[1]]
+	if !ok {
+		s.error(w, http.StatusNotFound, azblob.ServiceCodeContainerNotFound)
+		return
+	}
+
+	if len(parts) == 2 {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Query().Get("comp") == "list":
+			s.list(w, blobs, r.URL.Query().Get("prefix"))
+		case r.Method == http.MethodGet:
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.Error(w, "", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	name := parts[2]
+	switch r.Method {
+	case http.MethodPut:
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		blobs[name] = &fakeAzureBlob{data: data, modified: time.Now()}
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodGet:
+		blob, ok := blobs[name]
+		if !ok {
+			s.error(w, http.StatusNotFound, azblob.ServiceCodeBlobNotFound)
+			return
+		}
+		w.Header().Set("Last-Modified", blob.modified.UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+		w.Write(blob.data)
+	case http.MethodDelete:
+		if _, ok := blobs[name]; !ok {
+			s.error(w, http.StatusNotFound, azblob.ServiceCodeBlobNotFound)
+			return
+		}
+		delete(blobs, name)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		http.Error(w, "", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *fakeAzureServer) list(w http.ResponseWriter, blobs map[string]*fakeAzureBlob, prefix string) {
+	type properties struct {
+		LastModified string `xml:"Last-Modified"`
+	}
+	type item struct {
+		Name       string     `xml:"Name"`
+		Properties properties `xml:"Properties"`
+	}
+	type results struct {
+		XMLName    xml.Name `xml:"EnumerationResults"`
+		Blobs      []item   `xml:"Blobs>Blob"`
+		NextMarker string   `xml:"NextMarker"`
+	}
+
+	var names []string
+	for name := range blobs {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var res results
+	for _, name := range names {
+		res.Blobs = append(res.Blobs, item{
+			Name:       name,
+			Properties: properties{LastModified: blobs[name].modified.UTC().Format(http.TimeFormat)},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	xml.NewEncoder(w).Encode(&res)
+}
+
+func (s *fakeAzureServer) error(w http.ResponseWriter, status int, code azblob.ServiceCodeType) {
+	w.Header().Set("x-ms-error-code", string(code))
+	w.WriteHeader(status)
+}
@@ -0,0 +1,296 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// encryptionAnnotationKey is set on encrypted backups, its value
+	// identifying the encryption scheme
+	encryptionAnnotationKey = "backups.etok.dev/encryption"
+
+	// envelopeScheme identifies backups encrypted with a data key using
+	// AES-256-GCM, the data key itself wrapped with a KeyWrapper
+	envelopeScheme = "envelope-aes256-gcm"
+
+	// Keys of encrypted backup's data: the encrypted state secret data, and
+	// the wrapped data key with which it was encrypted
+	ciphertextKey  = "ciphertext"
+	wrappedDataKey = "key"
+
+	// dataKeySize is the size in bytes of keys used for AES-256
+	dataKeySize = 32
+
+	// encryptedLogsHeader prefixes encrypted run logs, and is followed by an
+	// encrypted secret containing the logs
+	encryptedLogsHeader = "# etok encrypted logs\n"
+
+	// Key of the secret's data containing run logs
+	logsKey = "logs"
+)
+
+var (
+	ErrEncryptionKeyRequired = errors.New("backup is encrypted but no encryption key has been configured")
+	ErrInvalidEncryptionKey  = errors.New("invalid backup encryption key")
+)
+
+// KeyWrapper encrypts and decrypts the data keys with which backups are
+// encrypted. Implementations may hold a key locally or delegate to a KMS.
+type KeyWrapper interface {
+	// WrapKey encrypts a data key
+	WrapKey(context.Context, []byte) ([]byte, error)
+
+	// UnwrapKey decrypts a data key previously encrypted with WrapKey
+	UnwrapKey(context.Context, []byte) ([]byte, error)
+}
+
+// encryptingProvider wraps a provider, encrypting backups before they are
+// handed to the provider, and decrypting them upon restore.
+type encryptingProvider struct {
+	Provider
+	wrapper KeyWrapper
+}
+
+// NewEncryptingProvider wraps provider p with envelope encryption: each backup
+// is encrypted with its own randomly generated data key, which is wrapped
+// using w and stored alongside the backup. Backups made without encryption can
+// still be restored. If w is nil then backups are not encrypted, and restoring
+// an encrypted backup returns ErrEncryptionKeyRequired.
+func NewEncryptingProvider(p Provider, w KeyWrapper) Provider {
+	return &encryptingProvider{Provider: p, wrapper: w}
+}
+
+func (p *encryptingProvider) Backup(ctx context.Context, secret *corev1.Secret, serial int) error {
+	if p.wrapper == nil {
+		return p.Provider.Backup(ctx, secret, serial)
+	}
+
+	encrypted, err := p.encrypt(ctx, secret)
+	if err != nil {
+		return fmt.Errorf("unable to encrypt backup: %w", err)
+	}
+	return p.Provider.Backup(ctx, encrypted, serial)
+}
+
+func (p *encryptingProvider) Restore(ctx context.Context, key client.ObjectKey) (*corev1.Secret, error) {
+	secret, err := p.Provider.Restore(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return p.decrypt(ctx, secret)
+}
+
+func (p *encryptingProvider) RestoreSerial(ctx context.Context, key client.ObjectKey, serial int) (*corev1.Secret, error) {
+	secret, err := p.Provider.RestoreSerial(ctx, key, serial)
+	if err != nil {
+		return nil, err
+	}
+	return p.decrypt(ctx, secret)
+}
+
+func (p *encryptingProvider) ArchiveLogs(ctx context.Context, key client.ObjectKey, uid types.UID, logs []byte) error {
+	if p.wrapper == nil {
+		return p.Provider.ArchiveLogs(ctx, key, uid, logs)
+	}
+
+	// Encrypt logs using a secret as a vehicle
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+		Data:       map[string][]byte{logsKey: logs},
+	}
+	encrypted, err := p.encrypt(ctx, secret)
+	if err != nil {
+		return fmt.Errorf("unable to encrypt logs: %w", err)
+	}
+
+	y, err := yaml.Marshal(encrypted)
+	if err != nil {
+		return err
+	}
+	return p.Provider.ArchiveLogs(ctx, key, uid, append([]byte(encryptedLogsHeader), y...))
+}
+
+func (p *encryptingProvider) RetrieveLogs(ctx context.Context, key client.ObjectKey, uid types.UID) ([]byte, error) {
+	logs, err := p.Provider.RetrieveLogs(ctx, key, uid)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(logs, []byte(encryptedLogsHeader)) {
+		// Unencrypted logs
+		return logs, nil
+	}
+
+	var secret corev1.Secret
+	if err := yaml.Unmarshal(logs[len(encryptedLogsHeader):], &secret); err != nil {
+		return nil, err
+	}
+	decrypted, err := p.decrypt(ctx, &secret)
+	if err != nil {
+		return nil, err
+	}
+	return decrypted.Data[logsKey], nil
+}
+
+// encrypt returns a copy of secret with its data encrypted
+func (p *encryptingProvider) encrypt(ctx context.Context, secret *corev1.Secret) (*corev1.Secret, error) {
+	// Merge string data into data, as the API server would
+	data := make(map[string][]byte, len(secret.Data)+len(secret.StringData))
+	for k, v := range secret.Data {
+		data[k] = v
+	}
+	for k, v := range secret.StringData {
+		data[k] = []byte(v)
+	}
+
+	plaintext, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := seal(dataKey, plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := p.wrapper.WrapKey(ctx, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted := secret.DeepCopy()
+	if encrypted.Annotations == nil {
+		encrypted.Annotations = make(map[string]string)
+	}
+	encrypted.Annotations[encryptionAnnotationKey] = envelopeScheme
+	encrypted.Data = map[string][]byte{
+		ciphertextKey:  ciphertext,
+		wrappedDataKey: wrapped,
+	}
+	encrypted.StringData = nil
+
+	return encrypted, nil
+}
+
+// decrypt returns a copy of secret with its data decrypted. A secret that is
+// not encrypted is returned as-is.
+func (p *encryptingProvider) decrypt(ctx context.Context, secret *corev1.Secret) (*corev1.Secret, error) {
+	if secret == nil {
+		return nil, nil
+	}
+
+	scheme, ok := secret.Annotations[encryptionAnnotationKey]
+	if !ok {
+		// Unencrypted backup
+		return secret, nil
+	}
+	if scheme != envelopeScheme {
+		return nil, fmt.Errorf("unsupported backup encryption scheme: %s", scheme)
+	}
+	if p.wrapper == nil {
+		return nil, ErrEncryptionKeyRequired
+	}
+
+	dataKey, err := p.wrapper.UnwrapKey(ctx, secret.Data[wrappedDataKey])
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt backup: %w", err)
+	}
+
+	plaintext, err := open(dataKey, secret.Data[ciphertextKey])
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt backup: %w", err)
+	}
+
+	var data map[string][]byte
+	if err := json.Unmarshal(plaintext, &data); err != nil {
+		return nil, err
+	}
+
+	decrypted := secret.DeepCopy()
+	delete(decrypted.Annotations, encryptionAnnotationKey)
+	if len(decrypted.Annotations) == 0 {
+		decrypted.Annotations = nil
+	}
+	decrypted.Data = data
+
+	return decrypted, nil
+}
+
+// localKeyWrapper wraps data keys with a locally held key
+type localKeyWrapper struct {
+	key []byte
+}
+
+// NewLocalKeyWrapper constructs a key wrapper that wraps data keys using
+// AES-256-GCM with the given 32 byte key
+func NewLocalKeyWrapper(key []byte) (KeyWrapper, error) {
+	if len(key) != dataKeySize {
+		return nil, fmt.Errorf("%w: must be %d bytes", ErrInvalidEncryptionKey, dataKeySize)
+	}
+	return &localKeyWrapper{key: key}, nil
+}
+
+func (w *localKeyWrapper) WrapKey(ctx context.Context, dataKey []byte) ([]byte, error) {
+	return seal(w.key, dataKey)
+}
+
+func (w *localKeyWrapper) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	return open(w.key, wrapped)
+}
+
+// seal encrypts plaintext using AES-GCM, prepending the randomly generated
+// nonce to the returned ciphertext
+func seal(key, plaintext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open decrypts ciphertext produced by seal
+func open(key, ciphertext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
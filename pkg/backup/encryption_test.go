@@ -0,0 +1,162 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/leg100/etok/pkg/testobj"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestEncryptingProvider(t *testing.T) {
+	key := bytes.Repeat([]byte{0x01}, 32)
+	otherKey := bytes.Repeat([]byte{0x02}, 32)
+
+	state := func() *corev1.Secret {
+		secret := testobj.Secret("default", "tfstate-default-workspace-1")
+		secret.Data = map[string][]byte{"tfstate": []byte(`{"serial": 4, "secret": "hunter2"}`)}
+		return secret
+	}
+
+	tests := []struct {
+		name string
+		// Key with which to backup; nil disables encryption
+		backupKey []byte
+		// Key with which to restore; nil disables encryption
+		restoreKey []byte
+		err        error
+	}{
+		{
+			name:       "encrypted",
+			backupKey:  key,
+			restoreKey: key,
+		},
+		{
+			name:       "legacy unencrypted backup",
+			restoreKey: key,
+		},
+		{
+			name: "encryption disabled",
+		},
+		{
+			name:      "encrypted backup without key",
+			backupKey: key,
+			err:       ErrEncryptionKeyRequired,
+		},
+	}
+
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			bucket := &FakeProvider{}
+
+			p := NewEncryptingProvider(bucket, newTestKeyWrapper(t, tt.backupKey))
+			require.NoError(t, p.Backup(context.Background(), state(), 4))
+
+			// Assert plaintext is not persisted when encrypting
+			if tt.backupKey != nil {
+				assert.NotContains(t, string(bucket.BucketObjs[0].Secret.Data[ciphertextKey]), "hunter2")
+				assert.Nil(t, bucket.BucketObjs[0].Secret.Data["tfstate"])
+			}
+
+			p = NewEncryptingProvider(bucket, newTestKeyWrapper(t, tt.restoreKey))
+			restored, err := p.Restore(context.Background(), client.ObjectKeyFromObject(state()))
+			if !assert.True(t, errors.Is(err, tt.err)) {
+				t.Errorf("no error in %v's chain matches %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			assert.Equal(t, state(), restored)
+		})
+	}
+
+	testutil.Run(t, "wrong key", func(t *testutil.T) {
+		bucket := &FakeProvider{}
+
+		p := NewEncryptingProvider(bucket, newTestKeyWrapper(t, key))
+		require.NoError(t, p.Backup(context.Background(), state(), 4))
+
+		p = NewEncryptingProvider(bucket, newTestKeyWrapper(t, otherKey))
+		_, err := p.RestoreSerial(context.Background(), client.ObjectKeyFromObject(state()), 4)
+		assert.Error(t, err)
+	})
+}
+
+func TestEncryptingProviderLogs(t *testing.T) {
+	key := bytes.Repeat([]byte{0x01}, 32)
+	run := client.ObjectKey{Namespace: "default", Name: "run-12345"}
+	logs := []byte("db_password = hunter2\n")
+
+	tests := []struct {
+		name string
+		// Key with which to archive; nil disables encryption
+		archiveKey []byte
+		// Key with which to retrieve; nil disables encryption
+		retrieveKey []byte
+		err         error
+	}{
+		{
+			name:        "encrypted",
+			archiveKey:  key,
+			retrieveKey: key,
+		},
+		{
+			name:        "unencrypted logs",
+			retrieveKey: key,
+		},
+		{
+			name: "encryption disabled",
+		},
+		{
+			name:       "encrypted logs without key",
+			archiveKey: key,
+			err:        ErrEncryptionKeyRequired,
+		},
+	}
+
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			bucket := &FakeProvider{}
+
+			p := NewEncryptingProvider(bucket, newTestKeyWrapper(t, tt.archiveKey))
+			require.NoError(t, p.ArchiveLogs(context.Background(), run, "uid-1", logs))
+
+			// Assert plaintext is not persisted when encrypting
+			if tt.archiveKey != nil {
+				assert.NotContains(t, string(bucket.Logs[0].Logs), "hunter2")
+			}
+
+			p = NewEncryptingProvider(bucket, newTestKeyWrapper(t, tt.retrieveKey))
+			retrieved, err := p.RetrieveLogs(context.Background(), run, "uid-1")
+			if !assert.True(t, errors.Is(err, tt.err)) {
+				t.Errorf("no error in %v's chain matches %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			assert.Equal(t, logs, retrieved)
+		})
+	}
+}
+
+func TestLocalKeyWrapper(t *testing.T) {
+	_, err := NewLocalKeyWrapper([]byte("too-short"))
+	assert.True(t, errors.Is(err, ErrInvalidEncryptionKey))
+}
+
+// newTestKeyWrapper constructs a local key wrapper, or returns nil if key is
+// nil
+func newTestKeyWrapper(t *testutil.T, key []byte) KeyWrapper {
+	if key == nil {
+		return nil
+	}
+	w, err := NewLocalKeyWrapper(key)
+	require.NoError(t, err)
+	return w
+}
@@ -2,27 +2,108 @@ package backup
 
 import (
 	"context"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// FakeObj is a backed up copy of a state secret held by a FakeProvider
+type FakeObj struct {
+	Secret  *corev1.Secret
+	Serial  int
+	Created time.Time
+}
+
 type FakeProvider struct {
-	BucketObjs []*corev1.Secret
+	BucketObjs []*FakeObj
+
+	// Archived run logs, in the order in which they were archived
+	Logs []*FakeLogs
+}
+
+// FakeLogs is an archived copy of the logs of a run held by a FakeProvider
+type FakeLogs struct {
+	Key  client.ObjectKey
+	UID  types.UID
+	Logs []byte
 }
 
-func (p *FakeProvider) Backup(ctx context.Context, secret *corev1.Secret) error {
-	p.BucketObjs = append(p.BucketObjs, secret)
+func (p *FakeProvider) Backup(ctx context.Context, secret *corev1.Secret, serial int) error {
+	// Copies are never overwritten, not even those with the same serial
+	p.BucketObjs = append(p.BucketObjs, &FakeObj{Secret: secret, Serial: serial, Created: time.Now()})
 
 	return nil
 }
 
 func (p *FakeProvider) Restore(ctx context.Context, key client.ObjectKey) (*corev1.Secret, error) {
+	versions, _ := p.List(ctx, key)
+	if len(versions) == 0 {
+		return nil, nil
+	}
+	return p.get(versions[len(versions)-1]), nil
+}
+
+func (p *FakeProvider) RestoreSerial(ctx context.Context, key client.ObjectKey, serial int) (*corev1.Secret, error) {
+	versions, _ := p.List(ctx, key)
+	if v := latestWithSerial(versions, serial); v != nil {
+		return p.get(*v), nil
+	}
+	return nil, nil
+}
+
+func (p *FakeProvider) List(ctx context.Context, key client.ObjectKey) ([]Version, error) {
+	var versions []Version
 	for _, obj := range p.BucketObjs {
-		if client.ObjectKeyFromObject(obj) == key {
-			return obj, nil
+		if client.ObjectKeyFromObject(obj.Secret) == key {
+			versions = append(versions, Version{Serial: obj.Serial, Created: obj.Created, path: p.path(obj)})
+		}
+	}
+
+	sortVersions(versions)
+
+	return versions, nil
+}
+
+func (p *FakeProvider) Delete(ctx context.Context, v Version) error {
+	for i, obj := range p.BucketObjs {
+		if p.path(obj) == v.path {
+			p.BucketObjs = append(p.BucketObjs[:i], p.BucketObjs[i+1:]...)
+			return nil
 		}
 	}
 
+	return nil
+}
+
+// get retrieves the copy of the given version
+func (p *FakeProvider) get(v Version) *corev1.Secret {
+	for _, obj := range p.BucketObjs {
+		if p.path(obj) == v.path {
+			return obj.Secret
+		}
+	}
+	return nil
+}
+
+// path returns the path at which a real provider would write the copy
+func (p *FakeProvider) path(obj *FakeObj) string {
+	return versionPath(client.ObjectKeyFromObject(obj.Secret), obj.Serial, obj.Created)
+}
+
+func (p *FakeProvider) ArchiveLogs(ctx context.Context, key client.ObjectKey, uid types.UID, logs []byte) error {
+	p.Logs = append(p.Logs, &FakeLogs{Key: key, UID: uid, Logs: logs})
+
+	return nil
+}
+
+func (p *FakeProvider) RetrieveLogs(ctx context.Context, key client.ObjectKey, uid types.UID) ([]byte, error) {
+	// Most recently archived first
+	for i := len(p.Logs) - 1; i >= 0; i-- {
+		if p.Logs[i].Key == key && (uid == "" || p.Logs[i].UID == uid) {
+			return p.Logs[i].Logs, nil
+		}
+	}
 	return nil, nil
 }
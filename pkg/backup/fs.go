@@ -0,0 +1,185 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// fsProvider backs up to a directory on the local filesystem, i.e. a mounted
+// persistent volume or host path. The directory plays the role of a bucket.
+type fsProvider struct {
+	dir string
+}
+
+func NewFSProvider(dir string) (Provider, error) {
+	// Check directory exists
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrBucketNotFound, dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%w: %s is not a directory", ErrBucketNotFound, dir)
+	}
+
+	return &fsProvider{dir: dir}, nil
+}
+
+func (p *fsProvider) Backup(ctx context.Context, secret *corev1.Secret, serial int) error {
+	// Marshal state file first to json then to yaml
+	y, err := yaml.Marshal(secret)
+	if err != nil {
+		return err
+	}
+
+	return p.put(versionPath(client.ObjectKeyFromObject(secret), serial, time.Now()), y)
+}
+
+func (p *fsProvider) Restore(ctx context.Context, key client.ObjectKey) (*corev1.Secret, error) {
+	versions, err := p.List(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		// Fallback to legacy unversioned backup
+		return p.get(path(key))
+	}
+	return p.get(versions[len(versions)-1].path)
+}
+
+func (p *fsProvider) RestoreSerial(ctx context.Context, key client.ObjectKey, serial int) (*corev1.Secret, error) {
+	versions, err := p.List(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	v := latestWithSerial(versions, serial)
+	if v == nil {
+		return nil, nil
+	}
+	return p.get(v.path)
+}
+
+func (p *fsProvider) List(ctx context.Context, key client.ObjectKey) ([]Version, error) {
+	var versions []Version
+
+	infos, err := ioutil.ReadDir(p.abs(prefix(key)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		if v, ok := versionFromPath(key, prefix(key)+info.Name(), info.ModTime()); ok {
+			versions = append(versions, v)
+		}
+	}
+
+	sortVersions(versions)
+
+	return versions, nil
+}
+
+func (p *fsProvider) Delete(ctx context.Context, v Version) error {
+	err := os.Remove(p.abs(v.path))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (p *fsProvider) ArchiveLogs(ctx context.Context, key client.ObjectKey, uid types.UID, logs []byte) error {
+	return p.put(logsPath(key, uid), logs)
+}
+
+func (p *fsProvider) RetrieveLogs(ctx context.Context, key client.ObjectKey, uid types.UID) ([]byte, error) {
+	if uid != "" {
+		return p.read(logsPath(key, uid))
+	}
+
+	infos, err := ioutil.ReadDir(p.abs(logsPrefix(key)))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var archives []archivedLogs
+	for _, info := range infos {
+		if !info.IsDir() {
+			archives = append(archives, archivedLogs{path: logsPrefix(key) + info.Name(), created: info.ModTime()})
+		}
+	}
+	return p.read(latestLogsPath(key, archives))
+}
+
+// get retrieves the backup file at the given path. Nil is returned if it
+// doesn't exist.
+func (p *fsProvider) get(path string) (*corev1.Secret, error) {
+	y, err := p.read(path)
+	if err != nil || y == nil {
+		return nil, err
+	}
+
+	// Unmarshal state file into secret obj
+	var secret corev1.Secret
+	if err := yaml.Unmarshal(y, &secret); err != nil {
+		return nil, err
+	}
+
+	return &secret, nil
+}
+
+// put writes data to the file at the given path
+func (p *fsProvider) put(path string, data []byte) error {
+	dst := p.abs(path)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	// Write to temporary file first and then rename it, ensuring a partially
+	// written file is never read
+	tmp, err := ioutil.TempFile(filepath.Dir(dst), ".backup-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), dst)
+}
+
+// read reads the file at the given path. Nil is returned if it doesn't exist.
+func (p *fsProvider) read(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(p.abs(path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// abs converts a slash-separated object path into a file path within the
+// backup directory
+func (p *fsProvider) abs(path string) string {
+	return filepath.Join(p.dir, filepath.FromSlash(path))
+}
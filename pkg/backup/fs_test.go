@@ -0,0 +1,38 @@
+package backup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+type fsTestProvider struct {
+	*fsProvider
+}
+
+func (p *fsTestProvider) createProviderWithBuckets(t *testutil.T, providerBucket string, createBuckets ...string) (Provider, error) {
+	// Each bucket is a directory within a temporary directory
+	root := t.TempDir()
+
+	for _, b := range createBuckets {
+		require.NoError(t, os.Mkdir(filepath.Join(root, b), 0755))
+	}
+
+	return NewFSProvider(filepath.Join(root, providerBucket))
+}
+
+func (p *fsTestProvider) createLegacyBackup(t *testutil.T, provider Provider, secret *corev1.Secret) {
+	y, err := yaml.Marshal(secret)
+	require.NoError(t, err)
+
+	fp := provider.(*fsProvider)
+	dst := fp.abs(path(client.ObjectKeyFromObject(secret)))
+	require.NoError(t, os.MkdirAll(filepath.Dir(dst), 0755))
+	require.NoError(t, ioutil.WriteFile(dst, y, 0644))
+}
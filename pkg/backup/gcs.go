@@ -5,9 +5,12 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"time"
 
 	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/yaml"
 )
@@ -42,46 +45,140 @@ func NewGCSProvider(ctx context.Context, bucket string, client *storage.Client)
 	}, nil
 }
 
-func (p *gcsProvider) Backup(ctx context.Context, secret *corev1.Secret) error {
-	bh := p.client.Bucket(p.bucket)
-	_, err := bh.Attrs(ctx)
+func (p *gcsProvider) Backup(ctx context.Context, secret *corev1.Secret, serial int) error {
+	// Marshal state file first to json then to yaml
+	y, err := yaml.Marshal(secret)
 	if err != nil {
 		return err
 	}
 
-	oh := bh.Object(path(client.ObjectKeyFromObject(secret)))
+	// Copy state file to GCS
+	return p.put(ctx, versionPath(client.ObjectKeyFromObject(secret), serial, time.Now()), y)
+}
 
-	// Marshal state file first to json then to yaml
-	y, err := yaml.Marshal(secret)
+func (p *gcsProvider) Restore(ctx context.Context, key client.ObjectKey) (*corev1.Secret, error) {
+	versions, err := p.List(ctx, key)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if len(versions) == 0 {
+		// Fallback to legacy unversioned backup
+		return p.get(ctx, path(key))
 	}
+	return p.get(ctx, versions[len(versions)-1].path)
+}
 
-	// Copy state file to GCS
-	owriter := oh.NewWriter(ctx)
-	_, err = io.Copy(owriter, bytes.NewBuffer(y))
+func (p *gcsProvider) RestoreSerial(ctx context.Context, key client.ObjectKey, serial int) (*corev1.Secret, error) {
+	versions, err := p.List(ctx, key)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	v := latestWithSerial(versions, serial)
+	if v == nil {
+		return nil, nil
 	}
+	return p.get(ctx, v.path)
+}
 
-	if err := owriter.Close(); err != nil {
-		return err
+func (p *gcsProvider) List(ctx context.Context, key client.ObjectKey) ([]Version, error) {
+	var versions []Version
+
+	it := p.client.Bucket(p.bucket).Objects(ctx, &storage.Query{Prefix: prefix(key)})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if v, ok := versionFromPath(key, attrs.Name, attrs.Created); ok {
+			versions = append(versions, v)
+		}
+	}
+
+	sortVersions(versions)
+
+	return versions, nil
+}
+
+func (p *gcsProvider) Delete(ctx context.Context, v Version) error {
+	err := p.client.Bucket(p.bucket).Object(v.path).Delete(ctx)
+	if err == storage.ErrObjectNotExist {
+		return nil
 	}
+	return err
+}
 
-	return nil
+func (p *gcsProvider) ArchiveLogs(ctx context.Context, key client.ObjectKey, uid types.UID, logs []byte) error {
+	return p.put(ctx, logsPath(key, uid), logs)
 }
 
-func (p *gcsProvider) Restore(ctx context.Context, key client.ObjectKey) (*corev1.Secret, error) {
+func (p *gcsProvider) RetrieveLogs(ctx context.Context, key client.ObjectKey, uid types.UID) ([]byte, error) {
+	if uid != "" {
+		return p.read(ctx, logsPath(key, uid))
+	}
+
+	var archives []archivedLogs
+	it := p.client.Bucket(p.bucket).Objects(ctx, &storage.Query{Prefix: logsPrefix(key)})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		archives = append(archives, archivedLogs{path: attrs.Name, created: attrs.Created})
+	}
+	return p.read(ctx, latestLogsPath(key, archives))
+}
+
+// get retrieves the backup object at the given path. Nil is returned if it
+// doesn't exist.
+func (p *gcsProvider) get(ctx context.Context, path string) (*corev1.Secret, error) {
+	// Copy state file from GCS
+	y, err := p.read(ctx, path)
+	if err != nil || y == nil {
+		return nil, err
+	}
+
+	// Unmarshal state file into secret obj
 	var secret corev1.Secret
+	if err := yaml.Unmarshal(y, &secret); err != nil {
+		return nil, err
+	}
 
+	return &secret, nil
+}
+
+// put writes data to the object at the given path
+func (p *gcsProvider) put(ctx context.Context, path string, data []byte) error {
+	bh := p.client.Bucket(p.bucket)
+	_, err := bh.Attrs(ctx)
+	if err != nil {
+		return err
+	}
+
+	owriter := bh.Object(path).NewWriter(ctx)
+	_, err = io.Copy(owriter, bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+
+	return owriter.Close()
+}
+
+// read reads the object at the given path. Nil is returned if it doesn't
+// exist.
+func (p *gcsProvider) read(ctx context.Context, path string) ([]byte, error) {
 	bh := p.client.Bucket(p.bucket)
 	_, err := bh.Attrs(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	// Try to retrieve existing backup
-	oh := bh.Object(path(key))
+	oh := bh.Object(path)
 	_, err = oh.Attrs(ctx)
 	if err == storage.ErrObjectNotExist {
 		return nil, nil
@@ -94,21 +191,14 @@ func (p *gcsProvider) Restore(ctx context.Context, key client.ObjectKey) (*corev
 		return nil, err
 	}
 
-	// Copy state file from GCS
-	buf := new(bytes.Buffer)
-	_, err = io.Copy(buf, oreader)
+	buf, err := io.ReadAll(oreader)
 	if err != nil {
 		return nil, err
 	}
 
-	// Unmarshal state file into secret obj
-	if err := yaml.Unmarshal(buf.Bytes(), &secret); err != nil {
-		return nil, err
-	}
-
 	if err := oreader.Close(); err != nil {
 		return nil, err
 	}
 
-	return &secret, nil
+	return buf, nil
 }
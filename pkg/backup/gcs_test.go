@@ -6,6 +6,9 @@ import (
 	"github.com/fsouza/fake-gcs-server/fakestorage"
 	"github.com/leg100/etok/pkg/testutil"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
 )
 
 type gcsTestProvider struct {
@@ -32,3 +35,14 @@ func (p *gcsTestProvider) createProviderWithBuckets(t *testutil.T, providerBucke
 
 	return NewGCSProvider(context.Background(), providerBucket, server.Client())
 }
+
+func (p *gcsTestProvider) createLegacyBackup(t *testutil.T, provider Provider, secret *corev1.Secret) {
+	y, err := yaml.Marshal(secret)
+	require.NoError(t, err)
+
+	gp := provider.(*gcsProvider)
+	owriter := gp.client.Bucket(gp.bucket).Object(path(client.ObjectKeyFromObject(secret))).NewWriter(context.Background())
+	_, err = owriter.Write(y)
+	require.NoError(t, err)
+	require.NoError(t, owriter.Close())
+}
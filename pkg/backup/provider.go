@@ -2,12 +2,54 @@ package backup
 
 import (
 	"context"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type Provider interface {
-	Backup(context.Context, *corev1.Secret) error
+	// Backup persists a copy of the state secret, keeping the copy alongside
+	// previous copies. An existing copy is never overwritten, not even one
+	// with the same serial number.
+	Backup(context.Context, *corev1.Secret, int) error
+
+	// Restore retrieves the most recently written copy of the state secret.
+	// Nil is returned if there is no copy.
 	Restore(context.Context, client.ObjectKey) (*corev1.Secret, error)
+
+	// RestoreSerial retrieves the most recently written copy of the state
+	// secret with the given serial number. Nil is returned if there is no such
+	// copy.
+	RestoreSerial(context.Context, client.ObjectKey, int) (*corev1.Secret, error)
+
+	// List lists the versions of the state secret that have been backed up,
+	// in the order in which they were written, oldest first.
+	List(context.Context, client.ObjectKey) ([]Version, error)
+
+	// Delete deletes the given version of the state secret
+	Delete(context.Context, Version) error
+
+	// ArchiveLogs persists a copy of the logs of the run with the given key
+	// and UID
+	ArchiveLogs(context.Context, client.ObjectKey, types.UID, []byte) error
+
+	// RetrieveLogs retrieves the copy of the logs of the run with the given
+	// key and UID. If the UID is empty then the most recently archived copy
+	// of the logs of any run with the key is retrieved, which is intended for
+	// runs that no longer exist. Nil is returned if there is no copy.
+	RetrieveLogs(context.Context, client.ObjectKey, types.UID) ([]byte, error)
+}
+
+// Version is a backed up copy of a state secret
+type Version struct {
+	// Serial number of the state
+	Serial int
+
+	// Time at which the copy was made
+	Created time.Time
+
+	// Path to the copy within the bucket
+	path string
 }
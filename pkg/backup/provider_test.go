@@ -3,7 +3,9 @@ package backup
 import (
 	"context"
 	"errors"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/leg100/etok/pkg/testobj"
 	"github.com/leg100/etok/pkg/testutil"
@@ -18,32 +20,113 @@ import (
 type testProvider interface {
 	Provider
 	createProviderWithBuckets(*testutil.T, string, ...string) (Provider, error)
+	// createLegacyBackup writes secret to the legacy unversioned path
+	createLegacyBackup(*testutil.T, Provider, *corev1.Secret)
 }
 
 var (
 	// testProviders is a collection of implementations of testProvider to be
 	// tested
 	testProviders = map[string]testProvider{
-		"gcs": &gcsTestProvider{},
-		"s3":  &s3TestProvider{},
+		"gcs":   &gcsTestProvider{},
+		"s3":    &s3TestProvider{},
+		"azure": &azureTestProvider{},
+		"fs":    &fsTestProvider{},
 	}
 )
 
+// stateSecret constructs a state secret with data that identifies its serial
+func stateSecret(serial int) *corev1.Secret {
+	return testobj.Secret("default", "tfstate-default-workspace-1", testobj.WithStringData("serial", strconv.Itoa(serial)))
+}
+
 func TestProviders(t *testing.T) {
 	tests := []struct {
 		name           string
 		providerBucket string
 		createBuckets  []string
-		backup         bool
-		restore        bool
-		wantSecret     *corev1.Secret
-		err            error
+		// Serial numbers of secret to backup
+		backups []int
+		// Backup secret to legacy path
+		legacy bool
+		// Serial number of secret to delete
+		delete *int
+		// Serial number of secret to restore; nil restores latest
+		restoreSerial *int
+		restore       bool
+		wantSecret    *corev1.Secret
+		wantSerials   []int
+		err           error
 	}{
 		{
 			name:           "backup-restore",
-			backup:         true,
+			backups:        []int{4},
+			restore:        true,
+			wantSecret:     stateSecret(4),
+			wantSerials:    []int{4},
+			providerBucket: "backups-bucket",
+			createBuckets:  []string{"backups-bucket"},
+		},
+		{
+			name:           "restore latest written",
+			backups:        []int{9, 10, 4},
+			restore:        true,
+			wantSecret:     stateSecret(4),
+			wantSerials:    []int{9, 10, 4},
+			providerBucket: "backups-bucket",
+			createBuckets:  []string{"backups-bucket"},
+		},
+		{
+			name:           "restore serial",
+			backups:        []int{4, 9, 10},
+			restore:        true,
+			restoreSerial:  intPtr(9),
+			wantSecret:     stateSecret(9),
+			wantSerials:    []int{4, 9, 10},
+			providerBucket: "backups-bucket",
+			createBuckets:  []string{"backups-bucket"},
+		},
+		{
+			name:           "backups of reused serials do not overwrite earlier backups",
+			backups:        []int{4, 5, 6, 5},
+			restore:        true,
+			wantSecret:     stateSecret(5),
+			wantSerials:    []int{4, 5, 6, 5},
+			providerBucket: "backups-bucket",
+			createBuckets:  []string{"backups-bucket"},
+		},
+		{
+			name:           "restore non-existent serial",
+			backups:        []int{4},
+			restore:        true,
+			restoreSerial:  intPtr(9),
+			wantSerials:    []int{4},
+			providerBucket: "backups-bucket",
+			createBuckets:  []string{"backups-bucket"},
+		},
+		{
+			name:           "delete serial",
+			backups:        []int{4, 9, 10},
+			delete:         intPtr(9),
+			wantSerials:    []int{4, 10},
+			providerBucket: "backups-bucket",
+			createBuckets:  []string{"backups-bucket"},
+		},
+		{
+			name:           "restore legacy backup",
+			legacy:         true,
 			restore:        true,
-			wantSecret:     testobj.Secret("default", "tfstate-default-workspace-1"),
+			wantSecret:     stateSecret(3),
+			providerBucket: "backups-bucket",
+			createBuckets:  []string{"backups-bucket"},
+		},
+		{
+			name:           "versioned backup takes precedence over legacy backup",
+			legacy:         true,
+			backups:        []int{4},
+			restore:        true,
+			wantSecret:     stateSecret(4),
+			wantSerials:    []int{4},
 			providerBucket: "backups-bucket",
 			createBuckets:  []string{"backups-bucket"},
 		},
@@ -71,15 +154,42 @@ func TestProviders(t *testing.T) {
 					return
 				}
 
-				secret := testobj.Secret("default", "tfstate-default-workspace-1")
+				key := client.ObjectKeyFromObject(stateSecret(0))
 
-				// Assert that backed up secret matches restored secret
-				if tt.backup {
-					require.NoError(t, p.Backup(context.Background(), secret))
+				if tt.legacy {
+					tp.createLegacyBackup(t, p, stateSecret(3))
+				}
+
+				for _, serial := range tt.backups {
+					require.NoError(t, p.Backup(context.Background(), stateSecret(serial), serial))
+				}
+
+				versions, err := p.List(context.Background(), key)
+				require.NoError(t, err)
+
+				if tt.delete != nil {
+					v := latestWithSerial(versions, *tt.delete)
+					require.NotNil(t, v)
+					require.NoError(t, p.Delete(context.Background(), *v))
+
+					versions, err = p.List(context.Background(), key)
+					require.NoError(t, err)
+				}
+
+				var serials []int
+				for _, v := range versions {
+					serials = append(serials, v.Serial)
 				}
+				assert.Equal(t, tt.wantSerials, serials)
 
+				// Assert that backed up secret matches restored secret
 				if tt.restore {
-					restored, err := p.Restore(context.Background(), client.ObjectKeyFromObject(secret))
+					var restored *corev1.Secret
+					if tt.restoreSerial != nil {
+						restored, err = p.RestoreSerial(context.Background(), key, *tt.restoreSerial)
+					} else {
+						restored, err = p.Restore(context.Background(), key)
+					}
 					require.NoError(t, err)
 					assert.Equal(t, tt.wantSecret, restored)
 				}
@@ -88,3 +198,54 @@ func TestProviders(t *testing.T) {
 	}
 
 }
+
+func TestProvidersLogs(t *testing.T) {
+	key := client.ObjectKey{Namespace: "default", Name: "run-12345"}
+
+	for providerName, tp := range testProviders {
+		testutil.Run(t, providerName, func(t *testutil.T) {
+			p, err := tp.createProviderWithBuckets(t, "backups-bucket", "backups-bucket")
+			require.NoError(t, err)
+
+			// Nothing archived yet
+			logs, err := p.RetrieveLogs(context.Background(), key, "uid-1")
+			require.NoError(t, err)
+			assert.Nil(t, logs)
+
+			logs, err = p.RetrieveLogs(context.Background(), key, "")
+			require.NoError(t, err)
+			assert.Nil(t, logs)
+
+			require.NoError(t, p.ArchiveLogs(context.Background(), key, "uid-1", []byte("Apply complete!\n")))
+
+			logs, err = p.RetrieveLogs(context.Background(), key, "uid-1")
+			require.NoError(t, err)
+			assert.Equal(t, "Apply complete!\n", string(logs))
+
+			// A later run with the same name does not overwrite the logs of
+			// the earlier run
+			time.Sleep(10 * time.Millisecond)
+			require.NoError(t, p.ArchiveLogs(context.Background(), key, "uid-2", []byte("Plan: 1 to add\n")))
+
+			logs, err = p.RetrieveLogs(context.Background(), key, "uid-1")
+			require.NoError(t, err)
+			assert.Equal(t, "Apply complete!\n", string(logs))
+
+			logs, err = p.RetrieveLogs(context.Background(), key, "uid-3")
+			require.NoError(t, err)
+			assert.Nil(t, logs)
+
+			// Without a UID the most recently archived logs are retrieved
+			logs, err = p.RetrieveLogs(context.Background(), key, "")
+			require.NoError(t, err)
+			assert.Equal(t, "Plan: 1 to add\n", string(logs))
+
+			// Archiving logs does not create a version of the state
+			versions, err := p.List(context.Background(), key)
+			require.NoError(t, err)
+			assert.Empty(t, versions)
+		})
+	}
+}
+
+func intPtr(i int) *int { return &i }
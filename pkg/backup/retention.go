@@ -0,0 +1,66 @@
+package backup
+
+import (
+	"context"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// RetentionPolicy determines which versions of a backup are kept. A version is
+// kept if it satisfies any one of the policy's rules. The zero value keeps all
+// versions.
+type RetentionPolicy struct {
+	// KeepLast keeps the latest N versions. Zero disables the rule.
+	KeepLast int
+
+	// KeepFor keeps versions created within this duration. Zero disables the
+	// rule.
+	KeepFor time.Duration
+}
+
+// IsZero reports whether the policy keeps all versions
+func (rp RetentionPolicy) IsZero() bool {
+	return rp.KeepLast == 0 && rp.KeepFor == 0
+}
+
+// Prune deletes versions of the backup for key that the policy does not keep,
+// returning the serial numbers of the deleted versions. The latest version is
+// always kept.
+func (rp RetentionPolicy) Prune(ctx context.Context, p Provider, key client.ObjectKey, now time.Time) ([]int, error) {
+	if rp.IsZero() {
+		return nil, nil
+	}
+
+	versions, err := p.List(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []int
+	for i, v := range versions {
+		if rp.keep(i, len(versions), v, now) {
+			continue
+		}
+		if err := p.Delete(ctx, v); err != nil {
+			return pruned, err
+		}
+		pruned = append(pruned, v.Serial)
+	}
+	return pruned, nil
+}
+
+// keep determines whether to keep the i-th of n versions, ordered oldest first
+func (rp RetentionPolicy) keep(i, n int, v Version, now time.Time) bool {
+	if i == n-1 {
+		// Always keep latest version
+		return true
+	}
+	if rp.KeepLast > 0 && i >= n-rp.KeepLast {
+		return true
+	}
+	if rp.KeepFor > 0 && now.Sub(v.Created) < rp.KeepFor {
+		return true
+	}
+	return false
+}
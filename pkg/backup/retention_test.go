@@ -0,0 +1,85 @@
+package backup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestRetentionPolicy(t *testing.T) {
+	now := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	// Backups of serials 1 through 5, one per day, with serial 5 made today
+	objs := func() []*FakeObj {
+		var objs []*FakeObj
+		for i := 1; i <= 5; i++ {
+			objs = append(objs, &FakeObj{
+				Secret:  stateSecret(i),
+				Serial:  i,
+				Created: now.Add(-time.Duration(5-i) * day),
+			})
+		}
+		return objs
+	}
+
+	tests := []struct {
+		name        string
+		policy      RetentionPolicy
+		wantPruned  []int
+		wantSerials []int
+	}{
+		{
+			name:        "keep all",
+			wantSerials: []int{1, 2, 3, 4, 5},
+		},
+		{
+			name:        "keep last",
+			policy:      RetentionPolicy{KeepLast: 2},
+			wantPruned:  []int{1, 2, 3},
+			wantSerials: []int{4, 5},
+		},
+		{
+			name:        "keep for",
+			policy:      RetentionPolicy{KeepFor: 2*day + time.Hour},
+			wantPruned:  []int{1, 2},
+			wantSerials: []int{3, 4, 5},
+		},
+		{
+			name:        "keep last or keep for",
+			policy:      RetentionPolicy{KeepLast: 4, KeepFor: day},
+			wantPruned:  []int{1},
+			wantSerials: []int{2, 3, 4, 5},
+		},
+		{
+			name:        "always keep latest",
+			policy:      RetentionPolicy{KeepFor: time.Hour},
+			wantPruned:  []int{1, 2, 3, 4},
+			wantSerials: []int{5},
+		},
+	}
+
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			p := &FakeProvider{BucketObjs: objs()}
+			key := client.ObjectKeyFromObject(stateSecret(0))
+
+			pruned, err := tt.policy.Prune(context.Background(), p, key, now)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPruned, pruned)
+
+			versions, err := p.List(context.Background(), key)
+			require.NoError(t, err)
+			var serials []int
+			for _, v := range versions {
+				serials = append(serials, v.Serial)
+			}
+			assert.Equal(t, tt.wantSerials, serials)
+		})
+	}
+}
@@ -5,12 +5,14 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/yaml"
 )
@@ -44,53 +46,141 @@ func NewS3Provider(ctx context.Context, bucket string, cfg *aws.Config) (Provide
 	}, nil
 }
 
-func (p *s3Provider) Backup(ctx context.Context, secret *corev1.Secret) error {
+func (p *s3Provider) Backup(ctx context.Context, secret *corev1.Secret, serial int) error {
 	// Marshal state file first to json then to yaml
 	y, err := yaml.Marshal(secret)
 	if err != nil {
 		return err
 	}
 
-	_, err = p.client.PutObject(&s3.PutObjectInput{
-		Body:   bytes.NewReader(y),
-		Bucket: aws.String(p.bucket),
-		Key:    aws.String(path(client.ObjectKeyFromObject(secret))),
-	})
+	return p.put(ctx, versionPath(client.ObjectKeyFromObject(secret), serial, time.Now()), y)
+}
 
+func (p *s3Provider) Restore(ctx context.Context, key client.ObjectKey) (*corev1.Secret, error) {
+	versions, err := p.List(ctx, key)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	if len(versions) == 0 {
+		// Fallback to legacy unversioned backup
+		return p.get(ctx, path(key))
+	}
+	return p.get(ctx, versions[len(versions)-1].path)
+}
 
-	return nil
+func (p *s3Provider) RestoreSerial(ctx context.Context, key client.ObjectKey, serial int) (*corev1.Secret, error) {
+	versions, err := p.List(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	v := latestWithSerial(versions, serial)
+	if v == nil {
+		return nil, nil
+	}
+	return p.get(ctx, v.path)
 }
 
-func (p *s3Provider) Restore(ctx context.Context, key client.ObjectKey) (*corev1.Secret, error) {
-	var secret corev1.Secret
+func (p *s3Provider) List(ctx context.Context, key client.ObjectKey) ([]Version, error) {
+	var versions []Version
 
-	resp, err := p.client.GetObject(&s3.GetObjectInput{
+	input := &s3.ListObjectsV2Input{
 		Bucket: aws.String(p.bucket),
-		Key:    aws.String(path(key)),
-	})
-	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			if aerr.Code() == s3.ErrCodeNoSuchKey {
-				// No backup to restore
-				return nil, nil
+		Prefix: aws.String(prefix(key)),
+	}
+	err := p.client.ListObjectsV2PagesWithContext(ctx, input, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			if v, ok := versionFromPath(key, aws.StringValue(obj.Key), aws.TimeValue(obj.LastModified)); ok {
+				versions = append(versions, v)
 			}
 		}
+		return true
+	})
+	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	buf, err := io.ReadAll(resp.Body)
+	sortVersions(versions)
+
+	return versions, nil
+}
+
+func (p *s3Provider) Delete(ctx context.Context, v Version) error {
+	_, err := p.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(p.bucket),
+		Key:    aws.String(v.path),
+	})
+	return err
+}
+
+func (p *s3Provider) ArchiveLogs(ctx context.Context, key client.ObjectKey, uid types.UID, logs []byte) error {
+	return p.put(ctx, logsPath(key, uid), logs)
+}
+
+func (p *s3Provider) RetrieveLogs(ctx context.Context, key client.ObjectKey, uid types.UID) ([]byte, error) {
+	if uid != "" {
+		return p.read(ctx, logsPath(key, uid))
+	}
+
+	var archives []archivedLogs
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(p.bucket),
+		Prefix: aws.String(logsPrefix(key)),
+	}
+	err := p.client.ListObjectsV2PagesWithContext(ctx, input, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			archives = append(archives, archivedLogs{path: aws.StringValue(obj.Key), created: aws.TimeValue(obj.LastModified)})
+		}
+		return true
+	})
 	if err != nil {
 		return nil, err
 	}
+	return p.read(ctx, latestLogsPath(key, archives))
+}
+
+// get retrieves the backup object at the given path. Nil is returned if it
+// doesn't exist.
+func (p *s3Provider) get(ctx context.Context, path string) (*corev1.Secret, error) {
+	buf, err := p.read(ctx, path)
+	if err != nil || buf == nil {
+		return nil, err
+	}
 
 	// Unmarshal state file into secret obj
+	var secret corev1.Secret
 	if err := yaml.Unmarshal(buf, &secret); err != nil {
 		return nil, err
 	}
 
 	return &secret, nil
 }
+
+// put writes data to the object at the given path
+func (p *s3Provider) put(ctx context.Context, path string, data []byte) error {
+	_, err := p.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Body:   bytes.NewReader(data),
+		Bucket: aws.String(p.bucket),
+		Key:    aws.String(path),
+	})
+	return err
+}
+
+// read reads the object at the given path. Nil is returned if it doesn't
+// exist.
+func (p *s3Provider) read(ctx context.Context, path string) ([]byte, error) {
+	resp, err := p.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(p.bucket),
+		Key:    aws.String(path),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			if aerr.Code() == s3.ErrCodeNoSuchKey {
+				return nil, nil
+			}
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
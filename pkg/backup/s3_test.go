@@ -1,6 +1,7 @@
 package backup
 
 import (
+	"bytes"
 	"context"
 	"net/http/httptest"
 
@@ -12,6 +13,9 @@ import (
 	"github.com/johannesboyne/gofakes3/backend/s3mem"
 	"github.com/leg100/etok/pkg/testutil"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
 )
 
 type s3TestProvider struct {
@@ -47,3 +51,16 @@ func (p *s3TestProvider) createProviderWithBuckets(t *testutil.T, providerBucket
 
 	return NewS3Provider(context.Background(), providerBucket, cfg)
 }
+
+func (p *s3TestProvider) createLegacyBackup(t *testutil.T, provider Provider, secret *corev1.Secret) {
+	y, err := yaml.Marshal(secret)
+	require.NoError(t, err)
+
+	sp := provider.(*s3Provider)
+	_, err = sp.client.PutObject(&s3.PutObjectInput{
+		Body:   bytes.NewReader(y),
+		Bucket: aws.String(sp.bucket),
+		Key:    aws.String(path(client.ObjectKeyFromObject(secret))),
+	})
+	require.NoError(t, err)
+}
@@ -3,7 +3,12 @@ package backup
 import (
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -11,6 +16,115 @@ var (
 	ErrBucketNotFound = errors.New("backup bucket could not be found")
 )
 
+// path returns the path to the legacy, unversioned, backup object for key. Each
+// backup used to overwrite this object, and it is still read when restoring a
+// backup for which there are no versions.
 func path(key client.ObjectKey) string {
 	return fmt.Sprintf("%s.yaml", key)
 }
+
+// prefix returns the path prefix shared by all versions of the backup for key
+func prefix(key client.ObjectKey) string {
+	return fmt.Sprintf("%s/", key)
+}
+
+// versionPath returns the path to the backup object for key with the given
+// state serial number, written at the given time. Including the time keeps
+// paths unique: once an earlier serial has been restored, subsequent backups
+// reuse the serial numbers of the abandoned history, and must not overwrite
+// its backups.
+func versionPath(key client.ObjectKey, serial int, written time.Time) string {
+	return fmt.Sprintf("%s%d-%d.yaml", prefix(key), serial, written.UnixNano())
+}
+
+// logsPrefix returns the path prefix shared by the archived logs of all runs
+// with the given key
+func logsPrefix(key client.ObjectKey) string {
+	return fmt.Sprintf("logs/%s/", key)
+}
+
+// logsPath returns the path to the archived logs of the run with the given key
+// and UID. Including the UID keeps paths unique: a run's name can be reused by
+// a later run once the run has been deleted.
+func logsPath(key client.ObjectKey, uid types.UID) string {
+	return fmt.Sprintf("%s%s.log", logsPrefix(key), uid)
+}
+
+// legacyLogsPath returns the path to which the logs of a run with the given key
+// were archived before the run's UID was included in the path
+func legacyLogsPath(key client.ObjectKey) string {
+	return fmt.Sprintf("logs/%s.log", key)
+}
+
+// archivedLogs is an archived copy of the logs of a run
+type archivedLogs struct {
+	path    string
+	created time.Time
+}
+
+// latestLogsPath returns the path of the most recently archived of the given
+// copies of the logs of runs with the given key. If there are none then the
+// legacy path is returned.
+func latestLogsPath(key client.ObjectKey, archives []archivedLogs) string {
+	var latest *archivedLogs
+	for i, a := range archives {
+		if !strings.HasPrefix(a.path, logsPrefix(key)) || !strings.HasSuffix(a.path, ".log") {
+			continue
+		}
+		if latest == nil || !a.created.Before(latest.created) {
+			latest = &archives[i]
+		}
+	}
+	if latest == nil {
+		return legacyLogsPath(key)
+	}
+	return latest.path
+}
+
+// versionFromPath parses the version of a versioned backup object from its
+// path. Created is the time at which the object was written, as recorded in its
+// path, or, for objects written before the time was recorded, the given time
+// reported by the provider. False is returned if the path is not that of a
+// versioned backup object for key.
+func versionFromPath(key client.ObjectKey, path string, created time.Time) (Version, bool) {
+	if !strings.HasPrefix(path, prefix(key)) || !strings.HasSuffix(path, ".yaml") {
+		return Version{}, false
+	}
+	parts := strings.SplitN(strings.TrimSuffix(strings.TrimPrefix(path, prefix(key)), ".yaml"), "-", 2)
+
+	serial, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return Version{}, false
+	}
+	if len(parts) == 2 {
+		nsec, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return Version{}, false
+		}
+		created = time.Unix(0, nsec)
+	}
+	return Version{Serial: serial, Created: created, path: path}, true
+}
+
+// sortVersions sorts versions in the order in which they were written, oldest
+// first
+func sortVersions(versions []Version) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		if versions[i].Created.Equal(versions[j].Created) {
+			return versions[i].Serial < versions[j].Serial
+		}
+		return versions[i].Created.Before(versions[j].Created)
+	})
+}
+
+// latestWithSerial returns the most recently written of the versions with the
+// given serial number, or nil if there is none. Versions are expected to be
+// sorted oldest first.
+func latestWithSerial(versions []Version, serial int) *Version {
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i].Serial == serial {
+			return &versions[i]
+		}
+	}
+	return nil
+}
@@ -69,3 +69,13 @@ func (b *CheckRunBuilder) ID(id int64) *CheckRunBuilder {
 	})
 	return b
 }
+
+// For testing purposes
+func (b *CheckRunBuilder) RequestedAction(action string) *CheckRunBuilder {
+	b.Status.Events = append(b.Status.Events, &v1alpha1.CheckRunEvent{
+		RequestedAction: &v1alpha1.CheckRunRequestedActionEvent{
+			Action: action,
+		},
+	})
+	return b
+}
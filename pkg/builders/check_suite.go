@@ -37,6 +37,7 @@ func CheckSuiteFromEvent(ev *github.CheckSuiteEvent) *checkSuiteBuilder {
 			Mergeable: isMergeable(ev.GetCheckSuite().PullRequests),
 		},
 	}
+	setPull(suite, ev.GetRepo().GetDefaultBranch(), ev.GetCheckSuite().PullRequests)
 	return &checkSuiteBuilder{suite}
 }
 
@@ -57,6 +58,7 @@ func CheckSuiteFromObj(obj *github.CheckSuite) *checkSuiteBuilder {
 			Mergeable: isMergeable(obj.PullRequests),
 		},
 	}
+	setPull(suite, obj.GetRepository().GetDefaultBranch(), obj.PullRequests)
 	return &checkSuiteBuilder{suite}
 }
 
@@ -75,6 +77,17 @@ func (b *checkSuiteBuilder) RepoPath(path string) *checkSuiteBuilder {
 	return b
 }
 
+func (b *checkSuiteBuilder) Pull(number int, base string) *checkSuiteBuilder {
+	b.Spec.PullNumber = number
+	b.Spec.BaseBranch = base
+	return b
+}
+
+func (b *checkSuiteBuilder) DefaultBranch(branch string) *checkSuiteBuilder {
+	b.Spec.DefaultBranch = branch
+	return b
+}
+
 func (b *checkSuiteBuilder) Build() *v1alpha1.CheckSuite {
 	return b.CheckSuite
 }
@@ -88,3 +101,15 @@ func isMergeable(pulls []*github.PullRequest) bool {
 	}
 	return true
 }
+
+// setPull records the repository's default branch along with the number and
+// base branch of the suite's pull request. Should there be more than one pull
+// request, i.e. the same branch is to be merged into several branches, the
+// first is recorded.
+func setPull(suite *v1alpha1.CheckSuite, defaultBranch string, pulls []*github.PullRequest) {
+	suite.Spec.DefaultBranch = defaultBranch
+	if len(pulls) > 0 {
+		suite.Spec.PullNumber = pulls[0].GetNumber()
+		suite.Spec.BaseBranch = pulls[0].GetBase().GetRef()
+	}
+}
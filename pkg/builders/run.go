@@ -5,6 +5,7 @@ import (
 
 	"github.com/leg100/etok/api/etok.dev/v1alpha1"
 	"github.com/leg100/etok/pkg/labels"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // Build a v1alpha1.Run resource using builder pattern
@@ -21,6 +22,16 @@ type RunBuilder struct {
 
 	configMapPath string
 
+	repository *v1alpha1.RunRepository
+
+	savePlan bool
+	plan     string
+
+	maxDuration time.Duration
+	timeouts    v1alpha1.RunTimeouts
+
+	launchedBy string
+
 	status    v1alpha1.RunStatus
 	verbosity int
 	workspace string
@@ -52,12 +63,50 @@ func (b *RunBuilder) SetLabel(name, value string) *RunBuilder {
 	return b
 }
 
+// SavePlan saves the plan file produced by a plan run
+func (b *RunBuilder) SavePlan() *RunBuilder {
+	b.savePlan = true
+	return b
+}
+
+// ApplyPlan applies the plan file saved by the named plan run
+func (b *RunBuilder) ApplyPlan(name string) *RunBuilder {
+	b.plan = name
+	return b
+}
+
+// MaxDuration sets the maximum duration of the run, after which it is
+// cancelled
+func (b *RunBuilder) MaxDuration(d time.Duration) *RunBuilder {
+	b.maxDuration = d
+	return b
+}
+
+// Timeouts sets the run's timeouts, overriding those of its workspace
+func (b *RunBuilder) Timeouts(timeouts v1alpha1.RunTimeouts) *RunBuilder {
+	b.timeouts = timeouts
+	return b
+}
+
+// LaunchedBy records who launched the run, as reported by the client
+func (b *RunBuilder) LaunchedBy(launcher string) *RunBuilder {
+	b.launchedBy = launcher
+	return b
+}
+
 // For testing purposes seed status
 func (b *RunBuilder) SetStatus(status v1alpha1.RunStatus) *RunBuilder {
 	b.status = status
 	return b
 }
 
+// Repository sets the git repository to be cloned on the run's pod, in place
+// of extracting a tarball
+func (b *RunBuilder) Repository(url, branch string) *RunBuilder {
+	b.repository = &v1alpha1.RunRepository{URL: url, Branch: branch}
+	return b
+}
+
 func (b *RunBuilder) SetVerbosity(v int) *RunBuilder {
 	b.verbosity = v
 	return b
@@ -83,6 +132,10 @@ func (b *RunBuilder) Build() *v1alpha1.Run {
 		labels.SetLabel(&run, l)
 	}
 
+	if b.launchedBy != "" {
+		run.SetAnnotations(map[string]string{v1alpha1.LaunchedByAnnotationKey: b.launchedBy})
+	}
+
 	run.Command = b.command
 	run.Args = b.args
 
@@ -94,8 +147,19 @@ func (b *RunBuilder) Build() *v1alpha1.Run {
 	// And always set config map key to the default
 	run.ConfigMapKey = v1alpha1.RunDefaultConfigMapKey
 
+	run.Repository = b.repository
+
 	run.Verbosity = b.verbosity
 
+	run.SavePlan = b.savePlan
+	run.Plan = b.plan
+
+	if b.maxDuration > 0 {
+		run.MaxDuration = &metav1.Duration{Duration: b.maxDuration}
+	}
+
+	run.Timeouts = b.timeouts
+
 	if b.attach {
 		run.AttachSpec.Handshake = true
 		run.AttachSpec.HandshakeTimeout = b.handshakeTimeout.String()
@@ -22,6 +22,12 @@ func NewFakeClientCreator(objs ...runtime.Object) ClientCreator {
 	return &FakeClientCreator{objs: objs}
 }
 
+// PrependReactor adds a reactor to the fake etok clientset, permitting tests to
+// mock the behaviour of the operator
+func (f *FakeClientCreator) PrependReactor(verb, resource string, reaction testing.ReactionFunc) {
+	f.reactors = append(f.reactors, testing.SimpleReactor{Verb: verb, Resource: resource, Reaction: reaction})
+}
+
 func (f *FakeClientCreator) Create(kubeCtx string) (*Client, error) {
 	var kubeObjs, etokObjs []runtime.Object
 	for _, obj := range f.objs {
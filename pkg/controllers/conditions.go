@@ -67,3 +67,20 @@ func runComplete(reason, message string) *metav1.Condition {
 		Message: message,
 	}
 }
+
+func policyFailed(reason, message string) *metav1.Condition {
+	return &metav1.Condition{
+		Type:    v1alpha1.RunPolicyFailedCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  reason,
+		Message: message,
+	}
+}
+
+func policyPassed() *metav1.Condition {
+	return &metav1.Condition{
+		Type:   v1alpha1.RunPolicyFailedCondition,
+		Status: metav1.ConditionFalse,
+		Reason: v1alpha1.PolicyPassedReason,
+	}
+}
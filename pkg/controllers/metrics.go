@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	v1alpha1 "github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const metricsNamespace = "etok"
+
+var (
+	// runDurationBuckets range from 1s to ~4.5h
+	runDurationBuckets = prometheus.ExponentialBuckets(1, 2, 15)
+
+	queueWaitSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "run_queue_wait_seconds",
+		Help:      "Time runs spend waiting before their pod is created.",
+		Buckets:   runDurationBuckets,
+	}, []string{"command"})
+
+	runDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "run_duration_seconds",
+		Help:      "Time from the creation of a run's pod until the run completes or fails.",
+		Buckets:   runDurationBuckets,
+	}, []string{"command"})
+
+	runOutcomesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "run_outcomes_total",
+		Help:      "Number of runs that have completed or failed, by the reason given by their conditions.",
+	}, []string{"command", "reason"})
+
+	backupsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "backups_total",
+		Help:      "Number of backups of state, by result.",
+	}, []string{"result"})
+
+	restoresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "restores_total",
+		Help:      "Number of restores of state, by result.",
+	}, []string{"result"})
+)
+
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		queueWaitSeconds,
+		runDurationSeconds,
+		runOutcomesTotal,
+		backupsTotal,
+		restoresTotal,
+	)
+}
+
+// runOutcome returns the reason a done run completed or failed
+func runOutcome(run *v1alpha1.Run) string {
+	if failed := meta.FindStatusCondition(run.Conditions, v1alpha1.RunFailedCondition); failed != nil && failed.Status == metav1.ConditionTrue {
+		return failed.Reason
+	}
+	if complete := meta.FindStatusCondition(run.Conditions, v1alpha1.RunCompleteCondition); complete != nil {
+		return complete.Reason
+	}
+	return v1alpha1.UnknownReason
+}
+
+var (
+	workspacesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "workspaces"),
+		"Number of workspaces, by phase.",
+		[]string{"phase"}, nil)
+
+	queueDepthDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "workspace", "queue_depth"),
+		"Number of runs queued behind a workspace's active run.",
+		[]string{"namespace", "workspace"}, nil)
+
+	lastBackupAgeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "workspace", "last_backup_age_seconds"),
+		"Time since the most recent backup of a workspace's state was made.",
+		[]string{"namespace", "workspace"}, nil)
+)
+
+// WorkspaceCollector collects metrics from the status of workspaces each time
+// metrics are scraped, which ensures metrics for deleted workspaces are no
+// longer reported.
+type WorkspaceCollector struct {
+	client.Reader
+
+	clock clock.Clock
+}
+
+func NewWorkspaceCollector(c client.Reader) *WorkspaceCollector {
+	return &WorkspaceCollector{Reader: c, clock: clock.RealClock{}}
+}
+
+func (c *WorkspaceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- workspacesDesc
+	ch <- queueDepthDesc
+	ch <- lastBackupAgeDesc
+}
+
+func (c *WorkspaceCollector) Collect(ch chan<- prometheus.Metric) {
+	var workspaces v1alpha1.WorkspaceList
+	if err := c.List(context.Background(), &workspaces); err != nil {
+		ch <- prometheus.NewInvalidMetric(workspacesDesc, err)
+		return
+	}
+
+	// Report phases without any workspaces too
+	phases := map[v1alpha1.WorkspacePhase]int{
+		v1alpha1.WorkspacePhaseInitializing: 0,
+		v1alpha1.WorkspacePhaseReady:        0,
+		v1alpha1.WorkspacePhaseError:        0,
+		v1alpha1.WorkspacePhaseUnknown:      0,
+		v1alpha1.WorkspacePhaseDeleting:     0,
+	}
+	for _, ws := range workspaces.Items {
+		phase := ws.Status.Phase
+		if phase == "" {
+			phase = v1alpha1.WorkspacePhaseUnknown
+		}
+		phases[phase]++
+
+		ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(len(ws.Status.Queue)), ws.Namespace, ws.Name)
+
+		if last := lastBackup(&ws); last != nil {
+			ch <- prometheus.MustNewConstMetric(lastBackupAgeDesc, prometheus.GaugeValue, c.clock.Since(*last).Seconds(), ws.Namespace, ws.Name)
+		}
+	}
+
+	for phase, count := range phases {
+		ch <- prometheus.MustNewConstMetric(workspacesDesc, prometheus.GaugeValue, float64(count), string(phase))
+	}
+}
+
+// lastBackup returns the time at which the most recent backup of the
+// workspace's state was made, or nil if there are no backups.
+func lastBackup(ws *v1alpha1.Workspace) *time.Time {
+	var last *time.Time
+	for _, b := range ws.Status.Backups {
+		if last == nil || b.Created.After(*last) {
+			created := b.Created.Time
+			last = &created
+		}
+	}
+	return last
+}
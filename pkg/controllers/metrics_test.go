@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	v1alpha1 "github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/pkg/scheme"
+	"github.com/leg100/etok/pkg/testobj"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRunMetrics(t *testing.T) {
+	tests := []struct {
+		name        string
+		run         *v1alpha1.Run
+		objs        []runtime.Object
+		wantOutcome string
+		wantWait    bool
+		wantRunTime bool
+	}{
+		{
+			name: "Pod created",
+			run:  testobj.Run("default", "plan-1", "plan", testobj.WithWorkspace("workspace-1")),
+			objs: []runtime.Object{
+				testobj.Workspace("default", "workspace-1"),
+			},
+			wantWait: true,
+		},
+		{
+			name: "Pod succeeded",
+			run:  testobj.Run("default", "plan-1", "plan", testobj.WithWorkspace("workspace-1")),
+			objs: []runtime.Object{
+				testobj.Workspace("default", "workspace-1"),
+				testobj.RunPod("default", "plan-1", testobj.WithPhase(corev1.PodSucceeded)),
+			},
+			wantOutcome: v1alpha1.PodSucceededReason,
+			wantRunTime: true,
+		},
+		{
+			name:        "Workspace not found",
+			run:         testobj.Run("default", "plan-1", "plan", testobj.WithWorkspace("workspace-1")),
+			wantOutcome: v1alpha1.WorkspaceNotFoundReason,
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			objs := append(tt.objs, runtime.Object(tt.run))
+			cl := fake.NewFakeClientWithScheme(scheme.Scheme, objs...)
+
+			waits := histogramCount(t, queueWaitSeconds, "plan")
+			runTimes := histogramCount(t, runDurationSeconds, "plan")
+			var outcomes float64
+			if tt.wantOutcome != "" {
+				outcomes = promtestutil.ToFloat64(runOutcomesTotal.WithLabelValues("plan", tt.wantOutcome))
+			}
+
+			_, err := NewRunReconciler(cl, "a.b.c/d:v1").Reconcile(context.Background(), requestFromObject(tt.run))
+			require.NoError(t, err)
+
+			if tt.wantOutcome != "" {
+				assert.Equal(t, outcomes+1, promtestutil.ToFloat64(runOutcomesTotal.WithLabelValues("plan", tt.wantOutcome)))
+			}
+			assert.Equal(t, tt.wantWait, histogramCount(t, queueWaitSeconds, "plan") == waits+1)
+			assert.Equal(t, tt.wantRunTime, histogramCount(t, runDurationSeconds, "plan") == runTimes+1)
+		})
+	}
+}
+
+// histogramCount returns the number of observations made by the histogram with
+// the given label values
+func histogramCount(t *testutil.T, h *prometheus.HistogramVec, lvs ...string) uint64 {
+	var m dto.Metric
+	require.NoError(t, h.WithLabelValues(lvs...).(prometheus.Metric).Write(&m))
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestWorkspaceCollector(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	withPhase := func(phase v1alpha1.WorkspacePhase) func(*v1alpha1.Workspace) {
+		return func(ws *v1alpha1.Workspace) {
+			ws.Status.Phase = phase
+		}
+	}
+	withBackups := func(ago ...time.Duration) func(*v1alpha1.Workspace) {
+		return func(ws *v1alpha1.Workspace) {
+			for i, a := range ago {
+				ws.Status.Backups = append(ws.Status.Backups, &v1alpha1.Backup{Serial: i, Created: metav1.NewTime(now.Add(-a))})
+			}
+		}
+	}
+
+	cl := fake.NewFakeClientWithScheme(scheme.Scheme,
+		testobj.Workspace("default", "workspace-1", withPhase(v1alpha1.WorkspacePhaseReady), testobj.WithCombinedQueue("apply-1", "apply-2", "apply-3"), withBackups(time.Hour, 10*time.Minute)),
+		testobj.Workspace("default", "workspace-2", withPhase(v1alpha1.WorkspacePhaseReady)),
+		testobj.Workspace("dev", "workspace-3", withPhase(v1alpha1.WorkspacePhaseError)),
+	)
+
+	collector := NewWorkspaceCollector(cl)
+	collector.clock = clock.NewFakeClock(now)
+
+	want := `
+# HELP etok_workspace_last_backup_age_seconds Time since the most recent backup of a workspace's state was made.
+# TYPE etok_workspace_last_backup_age_seconds gauge
+etok_workspace_last_backup_age_seconds{namespace="default",workspace="workspace-1"} 600
+# HELP etok_workspace_queue_depth Number of runs queued behind a workspace's active run.
+# TYPE etok_workspace_queue_depth gauge
+etok_workspace_queue_depth{namespace="default",workspace="workspace-1"} 2
+etok_workspace_queue_depth{namespace="default",workspace="workspace-2"} 0
+etok_workspace_queue_depth{namespace="dev",workspace="workspace-3"} 0
+# HELP etok_workspaces Number of workspaces, by phase.
+# TYPE etok_workspaces gauge
+etok_workspaces{phase="deleting"} 0
+etok_workspaces{phase="error"} 1
+etok_workspaces{phase="initializing"} 0
+etok_workspaces{phase="ready"} 2
+etok_workspaces{phase="unknown"} 0
+`
+	assert.NoError(t, promtestutil.CollectAndCompare(collector, strings.NewReader(want)))
+}
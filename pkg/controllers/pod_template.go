@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// applyPodTemplate merges a workspace's pod template into a pod that etok has
+// generated. Maps and lists are merged, with etok's labels taking precedence,
+// whereas other values replace those of the pod.
+func applyPodTemplate(pod *corev1.Pod, tmpl *v1alpha1.PodTemplate) {
+	if tmpl == nil {
+		return
+	}
+
+	for k, v := range tmpl.Labels {
+		if _, ok := pod.Labels[k]; ok {
+			// Don't override etok's labels, which it relies upon to select
+			// pods
+			continue
+		}
+		if pod.Labels == nil {
+			pod.Labels = make(map[string]string)
+		}
+		pod.Labels[k] = v
+	}
+
+	for k, v := range tmpl.Annotations {
+		if pod.Annotations == nil {
+			pod.Annotations = make(map[string]string)
+		}
+		pod.Annotations[k] = v
+	}
+
+	if tmpl.ServiceAccountName != "" {
+		pod.Spec.ServiceAccountName = tmpl.ServiceAccountName
+	}
+
+	for k, v := range tmpl.NodeSelector {
+		if pod.Spec.NodeSelector == nil {
+			pod.Spec.NodeSelector = make(map[string]string)
+		}
+		pod.Spec.NodeSelector[k] = v
+	}
+
+	if tmpl.Affinity != nil {
+		pod.Spec.Affinity = mergeAffinity(pod.Spec.Affinity, tmpl.Affinity.DeepCopy())
+	}
+
+	pod.Spec.Tolerations = append(pod.Spec.Tolerations, tmpl.Tolerations...)
+	pod.Spec.ImagePullSecrets = append(pod.Spec.ImagePullSecrets, tmpl.ImagePullSecrets...)
+
+	if tmpl.PriorityClassName != "" {
+		pod.Spec.PriorityClassName = tmpl.PriorityClassName
+	}
+
+	if tmpl.SecurityContext != nil {
+		pod.Spec.SecurityContext = tmpl.SecurityContext.DeepCopy()
+	}
+
+	// Apply unnamed container overrides before named overrides, so that the
+	// latter take precedence
+	for _, named := range []bool{false, true} {
+		for _, ct := range tmpl.Containers {
+			if (ct.Name != "") != named {
+				continue
+			}
+			for i := range pod.Spec.InitContainers {
+				applyContainerTemplate(&pod.Spec.InitContainers[i], &ct)
+			}
+			for i := range pod.Spec.Containers {
+				applyContainerTemplate(&pod.Spec.Containers[i], &ct)
+			}
+		}
+	}
+}
+
+// mergeAffinity merges affinity overrides into the pod's affinity. Pod affinity
+// terms are appended, so that a run pod retains its affinity for the workspace
+// pod, whereas node affinity and pod anti-affinity are replaced.
+func mergeAffinity(affinity, overrides *corev1.Affinity) *corev1.Affinity {
+	if affinity == nil {
+		return overrides
+	}
+
+	if overrides.NodeAffinity != nil {
+		affinity.NodeAffinity = overrides.NodeAffinity
+	}
+	if overrides.PodAntiAffinity != nil {
+		affinity.PodAntiAffinity = overrides.PodAntiAffinity
+	}
+	if overrides.PodAffinity != nil {
+		if affinity.PodAffinity == nil {
+			affinity.PodAffinity = &corev1.PodAffinity{}
+		}
+		affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution = append(affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution, overrides.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution...)
+		affinity.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(affinity.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution, overrides.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution...)
+	}
+
+	return affinity
+}
+
+// applyContainerTemplate applies a container override, provided it is either
+// unnamed or named after the container
+func applyContainerTemplate(container *corev1.Container, ct *v1alpha1.ContainerTemplate) {
+	if ct.Name != "" && ct.Name != container.Name {
+		return
+	}
+
+	if ct.Image != "" {
+		container.Image = ct.Image
+	}
+	if ct.ImagePullPolicy != "" {
+		container.ImagePullPolicy = ct.ImagePullPolicy
+	}
+	if ct.Resources != nil {
+		container.Resources = *ct.Resources.DeepCopy()
+	}
+	if ct.SecurityContext != nil {
+		container.SecurityContext = ct.SecurityContext.DeepCopy()
+	}
+}
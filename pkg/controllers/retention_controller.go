@@ -0,0 +1,301 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	v1alpha1 "github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/pkg/labels"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// OrphanedArchiveGracePeriod is the time after which an archive config map
+	// without a run is deleted. The launcher creates the config map and the
+	// run concurrently, so the run may not yet exist.
+	OrphanedArchiveGracePeriod = time.Hour
+)
+
+// RunRetentionPolicy determines which completed runs are retained
+type RunRetentionPolicy struct {
+	// Number of the most recently completed runs to retain. Zero retains all
+	// runs.
+	KeepLast int
+	// Duration after which completed runs are deleted. Zero retains runs
+	// indefinitely.
+	TTL time.Duration
+	// Retain the last successful apply regardless of the other settings
+	KeepLastSuccessfulApply bool
+}
+
+// RetentionReconciler deletes the completed runs of a workspace that its
+// retention policy no longer retains, along with archives orphaned by runs
+// that were never created
+type RetentionReconciler struct {
+	client.Client
+
+	// Policy for workspaces that do not override it
+	policy RunRetentionPolicy
+
+	// Whether the logs of completed runs are archived, in which case runs
+	// are retained until their logs have been archived
+	archivingLogs bool
+
+	// Clock against which run TTLs are measured
+	clock clock.Clock
+}
+
+type RetentionReconcilerOption func(r *RetentionReconciler)
+
+// WithDefaultRunRetention sets the policy for workspaces that do not override
+// it
+func WithDefaultRunRetention(policy RunRetentionPolicy) RetentionReconcilerOption {
+	return func(r *RetentionReconciler) {
+		r.policy = policy
+	}
+}
+
+// WithLogArchiving informs the reconciler that the logs of completed runs are
+// archived
+func WithLogArchiving() RetentionReconcilerOption {
+	return func(r *RetentionReconciler) {
+		r.archivingLogs = true
+	}
+}
+
+func WithRetentionClock(c clock.Clock) RetentionReconcilerOption {
+	return func(r *RetentionReconciler) {
+		r.clock = c
+	}
+}
+
+func NewRetentionReconciler(c client.Client, opts ...RetentionReconcilerOption) *RetentionReconciler {
+	r := &RetentionReconciler{
+		Client: c,
+		policy: RunRetentionPolicy{KeepLastSuccessfulApply: true},
+		clock:  clock.RealClock{},
+	}
+
+	for _, o := range opts {
+		o(r)
+	}
+
+	return r
+}
+
+// +kubebuilder:rbac:groups=etok.dev,resources=runs,verbs=list;delete
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=list;delete
+
+func (r *RetentionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.V(3).Info("Reconciling")
+
+	var ws v1alpha1.Workspace
+	if err := r.Get(ctx, req.NamespacedName, &ws); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// Deleting the workspace deletes its runs
+	if !ws.GetDeletionTimestamp().IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	var runs v1alpha1.RunList
+	if err := r.List(ctx, &runs, client.InNamespace(ws.Namespace)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	expired, requeue := r.expiredRuns(&ws, runs.Items)
+	for _, run := range expired {
+		if err := r.Delete(ctx, run, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !kerrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		log.V(1).Info("Deleted run", "run", run.Name)
+	}
+
+	if err := r.deleteOrphanedArchives(ctx, ws.Namespace, runs.Items); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Requeue in time to delete the next run to exceed its TTL, if any
+	return ctrl.Result{RequeueAfter: requeue}, nil
+}
+
+// runRetentionPolicy returns the workspace's retention policy. A setting set
+// on the workspace takes precedence over the reconciler's default.
+func (r *RetentionReconciler) runRetentionPolicy(ws *v1alpha1.Workspace) RunRetentionPolicy {
+	policy := r.policy
+	if ws.Spec.RunRetention.KeepLast != nil {
+		policy.KeepLast = *ws.Spec.RunRetention.KeepLast
+	}
+	if ws.Spec.RunRetention.TTL != nil {
+		policy.TTL = ws.Spec.RunRetention.TTL.Duration
+	}
+	if ws.Spec.RunRetention.KeepLastSuccessfulApply != nil {
+		policy.KeepLastSuccessfulApply = *ws.Spec.RunRetention.KeepLastSuccessfulApply
+	}
+	return policy
+}
+
+// expiredRuns returns the workspace's completed runs that its retention policy
+// no longer retains, excluding those whose logs are yet to be archived, along
+// with the duration until the next retained run
+// exceeds its TTL. Zero is returned if there is no such run.
+func (r *RetentionReconciler) expiredRuns(ws *v1alpha1.Workspace, runs []v1alpha1.Run) (expired []*v1alpha1.Run, requeue time.Duration) {
+	policy := r.runRetentionPolicy(ws)
+
+	// Saved plans referenced by runs that are yet to complete must be retained
+	referenced := make(map[string]bool)
+	for _, run := range runs {
+		if !run.IsDone() && run.Plan != "" {
+			referenced[run.Plan] = true
+		}
+	}
+
+	var completed []*v1alpha1.Run
+	for i, run := range runs {
+		if run.Workspace != ws.Name || !run.IsDone() {
+			continue
+		}
+		if run.Name == ws.Status.DriftRun || referenced[run.Name] {
+			continue
+		}
+		// Deleting a run deletes its pod, from which its logs are archived.
+		// A run without an exit code never ran its command, so there are no
+		// logs to archive.
+		if r.archivingLogs && !run.LogsArchived && run.ExitCode != nil {
+			continue
+		}
+		completed = append(completed, &runs[i])
+	}
+
+	// Most recently completed first
+	sort.SliceStable(completed, func(i, j int) bool {
+		return completedAt(completed[i]).After(completedAt(completed[j]))
+	})
+
+	var keptApply bool
+	for i, run := range completed {
+		if policy.KeepLastSuccessfulApply && !keptApply && isSuccessfulApply(run) {
+			keptApply = true
+			continue
+		}
+
+		if policy.KeepLast > 0 && i >= policy.KeepLast {
+			expired = append(expired, run)
+			continue
+		}
+
+		if policy.TTL > 0 {
+			until := completedAt(run).Add(policy.TTL).Sub(r.clock.Now())
+			if until <= 0 {
+				expired = append(expired, run)
+				continue
+			}
+			if requeue == 0 || until < requeue {
+				requeue = until
+			}
+		}
+	}
+	return expired, requeue
+}
+
+// deleteOrphanedArchives deletes archive config maps for which a run does not
+// exist, once the grace period has elapsed. Archives of existing runs are owned
+// by the run, and are deleted along with the run.
+func (r *RetentionReconciler) deleteOrphanedArchives(ctx context.Context, namespace string, runs []v1alpha1.Run) error {
+	var configMaps corev1.ConfigMapList
+	if err := r.List(ctx, &configMaps, client.InNamespace(namespace), client.MatchingLabels{labels.RunComponent.Name: labels.RunComponent.Value}); err != nil {
+		return err
+	}
+
+	exists := make(map[string]bool)
+	for _, run := range runs {
+		exists[run.Name] = true
+	}
+
+	for i, cm := range configMaps.Items {
+		if len(cm.OwnerReferences) > 0 || exists[cm.Name] {
+			continue
+		}
+		if r.clock.Since(cm.CreationTimestamp.Time) < OrphanedArchiveGracePeriod {
+			continue
+		}
+		if err := r.Delete(ctx, &configMaps.Items[i]); err != nil && !kerrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// completedAt returns the time at which a run completed
+func completedAt(run *v1alpha1.Run) time.Time {
+	var at time.Time
+	for _, cond := range run.Conditions {
+		if cond.Type != v1alpha1.RunCompleteCondition && cond.Type != v1alpha1.RunFailedCondition {
+			continue
+		}
+		if cond.Status == metav1.ConditionTrue && cond.LastTransitionTime.After(at) {
+			at = cond.LastTransitionTime.Time
+		}
+	}
+	if at.IsZero() {
+		return run.CreationTimestamp.Time
+	}
+	return at
+}
+
+// isSuccessfulApply determines if a run applied successfully. Runs that apply
+// via a script, such as those created by the VCS apps, are identified by their
+// label.
+func isSuccessfulApply(run *v1alpha1.Run) bool {
+	if run.Command != "apply" && run.Labels[labels.ApplyScript.Name] != labels.ApplyScript.Value {
+		return false
+	}
+	if meta.IsStatusConditionTrue(run.Conditions, v1alpha1.RunFailedCondition) {
+		return false
+	}
+	complete := meta.FindStatusCondition(run.Conditions, v1alpha1.RunCompleteCondition)
+	if complete == nil || complete.Reason != v1alpha1.PodSucceededReason {
+		return false
+	}
+	return run.ExitCode != nil && *run.ExitCode == 0
+}
+
+func (r *RetentionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Named so as not to clash with the workspace controller
+	blder := ctrl.NewControllerManagedBy(mgr).Named("retention")
+
+	// Watch workspaces, for changes to their retention policy
+	blder = blder.For(&v1alpha1.Workspace{})
+
+	// Watch runs and requeue their workspace, so that policy is enforced upon
+	// their completion
+	blder = blder.Watches(&source.Kind{Type: &v1alpha1.Run{}}, handler.EnqueueRequestsFromMapFunc(func(o client.Object) []ctrl.Request {
+		run := o.(*v1alpha1.Run)
+		if run.Workspace == "" || !run.IsDone() {
+			return []ctrl.Request{}
+		}
+		return []ctrl.Request{
+			{
+				NamespacedName: types.NamespacedName{
+					Name:      run.Workspace,
+					Namespace: o.GetNamespace(),
+				},
+			},
+		}
+	}))
+
+	return blder.Complete(r)
+}
@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1alpha1 "github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/pkg/labels"
+	"github.com/leg100/etok/pkg/scheme"
+	"github.com/leg100/etok/pkg/testobj"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileRetention(t *testing.T) {
+	keepLast := func(n int) *int { return &n }
+	keepApply := func(keep bool) *bool { return &keep }
+
+	// Completed runs, from most to least recently completed
+	completed := func() []runtime.Object {
+		return []runtime.Object{
+			testobj.Run("", "plan-1", "plan", testobj.WithWorkspace("workspace-1"), testobj.WithCompleteConditionForAge(v1alpha1.PodSucceededReason, time.Hour)),
+			testobj.Run("", "plan-2", "plan", testobj.WithWorkspace("workspace-1"), testobj.WithCompleteConditionForAge(v1alpha1.PodSucceededReason, 2*time.Hour)),
+			testobj.Run("", "plan-3", "plan", testobj.WithWorkspace("workspace-1"), testobj.WithCompleteConditionForAge(v1alpha1.PodSucceededReason, 3*time.Hour)),
+			testobj.Run("", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), testobj.WithCompleteConditionForAge(v1alpha1.PodSucceededReason, 4*time.Hour), testobj.WithRunExitCode(0)),
+		}
+	}
+
+	orphanedArchive := func(name string, age time.Duration) *corev1.ConfigMap {
+		return testobj.ConfigMap("", name, func(cm *corev1.ConfigMap) {
+			labels.SetLabel(cm, labels.RunComponent)
+			cm.CreationTimestamp = metav1.NewTime(time.Now().Add(-age))
+		})
+	}
+
+	tests := []struct {
+		name       string
+		workspace  *v1alpha1.Workspace
+		objs       []runtime.Object
+		opts       []RetentionReconcilerOption
+		wantRuns   []string
+		assertions func(*testutil.T, ctrl.Result, client.Client)
+	}{
+		{
+			name:      "Retain all runs by default",
+			workspace: testobj.Workspace("", "workspace-1"),
+			objs:      completed(),
+			wantRuns:  []string{"apply-1", "plan-1", "plan-2", "plan-3"},
+		},
+		{
+			name:      "Keep last two runs and last successful apply",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithRunRetention(v1alpha1.RunRetentionPolicy{KeepLast: keepLast(2)})),
+			objs:      completed(),
+			wantRuns:  []string{"apply-1", "plan-1", "plan-2"},
+		},
+		{
+			name:      "Keep last two runs only",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithRunRetention(v1alpha1.RunRetentionPolicy{KeepLast: keepLast(2), KeepLastSuccessfulApply: keepApply(false)})),
+			objs:      completed(),
+			wantRuns:  []string{"plan-1", "plan-2"},
+		},
+		{
+			name:      "Keep last two runs set by operator",
+			workspace: testobj.Workspace("", "workspace-1"),
+			objs:      completed(),
+			opts:      []RetentionReconcilerOption{WithDefaultRunRetention(RunRetentionPolicy{KeepLast: 2})},
+			wantRuns:  []string{"plan-1", "plan-2"},
+		},
+		{
+			name:      "Workspace overrides operator",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithRunRetention(v1alpha1.RunRetentionPolicy{KeepLast: keepLast(0)})),
+			objs:      completed(),
+			opts:      []RetentionReconcilerOption{WithDefaultRunRetention(RunRetentionPolicy{KeepLast: 2})},
+			wantRuns:  []string{"apply-1", "plan-1", "plan-2", "plan-3"},
+		},
+		{
+			name:      "Failed apply is not protected",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithRunRetention(v1alpha1.RunRetentionPolicy{KeepLast: keepLast(1)})),
+			objs: []runtime.Object{
+				testobj.Run("", "plan-1", "plan", testobj.WithWorkspace("workspace-1"), testobj.WithCompleteConditionForAge(v1alpha1.PodSucceededReason, time.Hour)),
+				testobj.Run("", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), testobj.WithCompleteConditionForAge(v1alpha1.PodSucceededReason, 2*time.Hour), testobj.WithRunExitCode(1)),
+			},
+			wantRuns: []string{"plan-1"},
+		},
+		{
+			name:      "Scripted apply is protected",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithRunRetention(v1alpha1.RunRetentionPolicy{KeepLast: keepLast(1)})),
+			objs: []runtime.Object{
+				testobj.Run("", "plan-1", "sh", testobj.WithWorkspace("workspace-1"), testobj.WithCompleteConditionForAge(v1alpha1.PodSucceededReason, time.Hour)),
+				testobj.Run("", "plan-2", "sh", testobj.WithWorkspace("workspace-1"), testobj.WithCompleteConditionForAge(v1alpha1.PodSucceededReason, 2*time.Hour), testobj.WithRunExitCode(0)),
+				testobj.Run("", "apply-1", "sh", testobj.WithWorkspace("workspace-1"), testobj.WithCompleteConditionForAge(v1alpha1.PodSucceededReason, 3*time.Hour), testobj.WithRunExitCode(0), testobj.WithLabels(labels.ApplyScript.Name, labels.ApplyScript.Value)),
+			},
+			wantRuns: []string{"apply-1", "plan-1"},
+		},
+		{
+			name:      "Delete runs after TTL",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithRunRetention(v1alpha1.RunRetentionPolicy{TTL: &metav1.Duration{Duration: 150 * time.Minute}})),
+			objs:      completed(),
+			wantRuns:  []string{"apply-1", "plan-1", "plan-2"},
+			assertions: func(t *testutil.T, result ctrl.Result, cl client.Client) {
+				// plan-2 is next to exceed TTL, in 30 minutes
+				assert.True(t, result.RequeueAfter > 29*time.Minute && result.RequeueAfter <= 30*time.Minute)
+			},
+		},
+		{
+			name:      "Retain incomplete runs, drift run, runs in other workspaces, and plans referenced by incomplete runs",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithRunRetention(v1alpha1.RunRetentionPolicy{KeepLast: keepLast(0), TTL: &metav1.Duration{Duration: time.Minute}}), testobj.WithDriftRun("drift-1")),
+			objs: []runtime.Object{
+				testobj.Run("", "plan-1", "plan", testobj.WithWorkspace("workspace-1"), testobj.WithCompleteConditionForAge(v1alpha1.PodSucceededReason, time.Hour)),
+				testobj.Run("", "plan-2", "plan", testobj.WithWorkspace("workspace-1"), testobj.WithCompleteConditionForAge(v1alpha1.PodSucceededReason, time.Hour), testobj.WithSavePlan()),
+				testobj.Run("", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), testobj.WithPlan("plan-2")),
+				testobj.Run("", "drift-1", "sh", testobj.WithWorkspace("workspace-1"), testobj.WithCompleteConditionForAge(v1alpha1.PodSucceededReason, time.Hour)),
+				testobj.Run("", "plan-3", "plan", testobj.WithWorkspace("workspace-2"), testobj.WithCompleteConditionForAge(v1alpha1.PodSucceededReason, time.Hour)),
+			},
+			wantRuns: []string{"apply-1", "drift-1", "plan-2", "plan-3"},
+		},
+		{
+			name:      "Retain runs whose logs are yet to be archived",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithRunRetention(v1alpha1.RunRetentionPolicy{KeepLast: keepLast(0), TTL: &metav1.Duration{Duration: time.Minute}})),
+			objs: []runtime.Object{
+				testobj.Run("", "plan-1", "plan", testobj.WithWorkspace("workspace-1"), testobj.WithCompleteConditionForAge(v1alpha1.PodSucceededReason, time.Hour), testobj.WithRunExitCode(0), func(run *v1alpha1.Run) {
+					run.LogsArchived = true
+				}),
+				testobj.Run("", "plan-2", "plan", testobj.WithWorkspace("workspace-1"), testobj.WithCompleteConditionForAge(v1alpha1.PodSucceededReason, time.Hour), testobj.WithRunExitCode(0)),
+				// Never ran its command so has no logs to archive
+				testobj.Run("", "plan-3", "plan", testobj.WithWorkspace("workspace-1"), testobj.WithCompleteConditionForAge(v1alpha1.PodSucceededReason, time.Hour)),
+			},
+			opts:     []RetentionReconcilerOption{WithLogArchiving()},
+			wantRuns: []string{"plan-2"},
+		},
+		{
+			name:      "Delete orphaned archives",
+			workspace: testobj.Workspace("", "workspace-1"),
+			objs: []runtime.Object{
+				testobj.Run("", "plan-1", "plan", testobj.WithWorkspace("workspace-1")),
+				orphanedArchive("plan-1", 2*time.Hour),
+				orphanedArchive("plan-2", 2*time.Hour),
+				orphanedArchive("plan-3", time.Minute),
+			},
+			wantRuns: []string{"plan-1"},
+			assertions: func(t *testutil.T, result ctrl.Result, cl client.Client) {
+				var configMaps corev1.ConfigMapList
+				require.NoError(t, cl.List(context.Background(), &configMaps))
+				var names []string
+				for _, cm := range configMaps.Items {
+					names = append(names, cm.Name)
+				}
+				assert.ElementsMatch(t, []string{"plan-1", "plan-3"}, names)
+			},
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			objs := append(tt.objs, runtime.Object(tt.workspace))
+			cl := fake.NewFakeClientWithScheme(scheme.Scheme, objs...)
+
+			opts := append([]RetentionReconcilerOption{WithRetentionClock(clock.NewFakeClock(time.Now()))}, tt.opts...)
+			r := NewRetentionReconciler(cl, opts...)
+			result, err := r.Reconcile(context.Background(), requestFromObject(tt.workspace))
+			require.NoError(t, err)
+
+			var runs v1alpha1.RunList
+			require.NoError(t, cl.List(context.Background(), &runs))
+			var names []string
+			for _, run := range runs.Items {
+				names = append(names, run.Name)
+			}
+			assert.ElementsMatch(t, tt.wantRuns, names)
+
+			if tt.assertions != nil {
+				tt.assertions(t, result, cl)
+			}
+		})
+	}
+}
@@ -3,12 +3,17 @@ package controllers
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	v1alpha1 "github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/pkg/backup"
 	"github.com/leg100/etok/pkg/commands"
+	"github.com/leg100/etok/pkg/execer"
 	"github.com/leg100/etok/pkg/globals"
 	"github.com/leg100/etok/pkg/k8s"
+	"github.com/leg100/etok/pkg/labels"
+	"github.com/leg100/etok/pkg/logstreamer"
 	"github.com/leg100/etok/pkg/scheme"
 	"github.com/leg100/etok/pkg/util/slice"
 	corev1 "k8s.io/api/core/v1"
@@ -17,6 +22,9 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	typedv1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -25,39 +33,117 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
+const (
+	// DefaultRunEnqueueTimeout is the default maximum time a run can remain
+	// waiting to be enqueued
+	DefaultRunEnqueueTimeout = 10 * time.Second
+	// DefaultRunQueueTimeout is the default maximum time a run can remain
+	// waiting in the queue
+	DefaultRunQueueTimeout = 60 * time.Minute
+	// DefaultRunPodPendingTimeout is the default maximum time a pod can remain
+	// in the pending phase
+	DefaultRunPodPendingTimeout = 60 * time.Second
+)
+
 var (
 	// List of functions that update the workspace status
 	runReconcileStatusChain []runUpdater
-	// runEnqueueTimeout is the maximum time a run can remain waiting to be
-	// enqueued
-	runEnqueueTimeout = 10 * time.Second
-	// runQueueTimeout is the maximum time a run can remain waiting in the queue
-	runQueueTimeout = 60 * time.Minute
-	// runPodPendingTimeout is the maximum time a pod can remain in the pending
-	// phase
-	runPodPendingTimeout = 60 * time.Second
 )
 
 type runUpdater func(context.Context, *v1alpha1.Run, v1alpha1.Workspace) (bool, error)
 
+// RunTimeouts are the maximum times a run can spend waiting before its command
+// is run
+type RunTimeouts struct {
+	Enqueue, Queue, PodPending time.Duration
+}
+
 type RunReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
 	Image  string
+
+	// Timeouts for runs that neither they nor their workspace override
+	timeouts RunTimeouts
+
+	// Clock against which timeouts are measured
+	clock clock.Clock
+
+	// Archives the logs of completed runs. Nil disables archiving.
+	logArchiver backup.Provider
+
+	// Clients for retrieving the logs of run pods
+	pods    typedv1.PodsGetter
+	getLogs logstreamer.GetLogsFunc
+
+	// Executes the deletion of the plan files of deleted runs. Nil disables
+	// their deletion.
+	cfg  rest.Config
+	exec execer.ExecFunc
+}
+
+type RunReconcilerOption func(r *RunReconciler)
+
+// WithLogArchiver archives the logs of completed runs using the backup
+// provider, retrieving the logs from their pods
+func WithLogArchiver(bp backup.Provider, pods typedv1.PodsGetter, getLogs logstreamer.GetLogsFunc) RunReconcilerOption {
+	return func(r *RunReconciler) {
+		r.logArchiver = bp
+		r.pods = pods
+		r.getLogs = getLogs
+	}
+}
+
+// WithPlanFileDeletion deletes the plan file saved by a run when the run is
+// deleted, executing the deletion on the workspace pod
+func WithPlanFileDeletion(cfg rest.Config, exec execer.ExecFunc) RunReconcilerOption {
+	return func(r *RunReconciler) {
+		r.cfg = cfg
+		r.exec = exec
+	}
+}
+
+// WithDefaultRunTimeouts sets the timeouts for runs that neither they nor their
+// workspace override
+func WithDefaultRunTimeouts(timeouts RunTimeouts) RunReconcilerOption {
+	return func(r *RunReconciler) {
+		r.timeouts = timeouts
+	}
 }
 
-func NewRunReconciler(c client.Client, image string) *RunReconciler {
+func WithRunClock(c clock.Clock) RunReconcilerOption {
+	return func(r *RunReconciler) {
+		r.clock = c
+	}
+}
+
+func NewRunReconciler(c client.Client, image string, opts ...RunReconcilerOption) *RunReconciler {
 	r := &RunReconciler{
 		Client: c,
 		Scheme: scheme.Scheme,
 		Image:  image,
+		timeouts: RunTimeouts{
+			Enqueue:    DefaultRunEnqueueTimeout,
+			Queue:      DefaultRunQueueTimeout,
+			PodPending: DefaultRunPodPendingTimeout,
+		},
+		clock: clock.RealClock{},
+	}
+
+	for _, o := range opts {
+		o(r)
 	}
 
 	// Build chain of status updaters, to be called one after the other in a
 	// reconcile
 	runReconcileStatusChain = []runUpdater{}
+	runReconcileStatusChain = append(runReconcileStatusChain, r.checkSpecUnmodified)
+	runReconcileStatusChain = append(runReconcileStatusChain, r.checkPlanPolicy)
+	runReconcileStatusChain = append(runReconcileStatusChain, r.manageCancellation)
 	runReconcileStatusChain = append(runReconcileStatusChain, r.manageQueue)
 	runReconcileStatusChain = append(runReconcileStatusChain, r.managePod)
+	runReconcileStatusChain = append(runReconcileStatusChain, r.evaluatePolicy)
+	runReconcileStatusChain = append(runReconcileStatusChain, r.recordBlastRadius)
 
 	return r
 }
@@ -65,6 +151,8 @@ func NewRunReconciler(c client.Client, image string) *RunReconciler {
 // +kubebuilder:rbac:groups=etok.dev,resources=runs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=etok.dev,resources=runs/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=pods/log,verbs=get
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
 
 func (r *RunReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	// set up a convenient log object so we don't have to type request over and
@@ -81,9 +169,15 @@ func (r *RunReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	// Don't reconcile failed or completed runs
+	// Ensure the plan file saved by the run is deleted along with the run
+	if deleting, err := r.managePlanFile(ctx, &run); err != nil || deleting {
+		return ctrl.Result{}, err
+	}
+
+	// Don't reconcile failed or completed runs, other than to archive their
+	// logs
 	if run.IsDone() {
-		return ctrl.Result{}, nil
+		return ctrl.Result{}, r.archiveLogs(ctx, req, &run)
 	}
 
 	// Fetch its Workspace object
@@ -96,6 +190,7 @@ func (r *RunReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		if err := r.updateStatus(ctx, req, run.RunStatus); err != nil {
 			return ctrl.Result{}, err
 		}
+		r.recordOutcome(ctx, &run)
 		return ctrl.Result{}, nil
 	} else if err != nil {
 		return ctrl.Result{}, err
@@ -126,7 +221,24 @@ func (r *RunReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, backoff
+	if run.IsDone() {
+		r.recordOutcome(ctx, &run)
+	}
+
+	// Requeue in time to enforce the run's current timeout, if any
+	return ctrl.Result{RequeueAfter: r.untilTimeout(&run, &ws)}, backoff
+}
+
+// recordOutcome records metrics for a run that has just completed or failed:
+// the reason it did so and, if its pod was created, how long its pod ran for.
+func (r *RunReconciler) recordOutcome(ctx context.Context, run *v1alpha1.Run) {
+	runOutcomesTotal.WithLabelValues(run.Command, runOutcome(run)).Inc()
+
+	var pod corev1.Pod
+	if err := r.Get(ctx, requestFromObject(run).NamespacedName, &pod); err != nil {
+		return
+	}
+	runDurationSeconds.WithLabelValues(run.Command).Observe(r.clock.Since(pod.CreationTimestamp.Time).Seconds())
 }
 
 func (r *RunReconciler) updateStatus(ctx context.Context, req ctrl.Request, newStatus v1alpha1.RunStatus) error {
@@ -153,7 +265,11 @@ func processRunReconcileStatusChain(ctx context.Context, run *v1alpha1.Run, ws v
 
 // setRunPhase maps the Run's conditions to a single phase
 func setRunPhase(run *v1alpha1.Run) v1alpha1.RunPhase {
-	if meta.IsStatusConditionTrue(run.Conditions, v1alpha1.RunFailedCondition) {
+	if failed := meta.FindStatusCondition(run.Conditions, v1alpha1.RunFailedCondition); failed != nil && failed.Status == metav1.ConditionTrue {
+		switch failed.Reason {
+		case v1alpha1.RunCancelledReason, v1alpha1.MaxDurationExceededReason:
+			return v1alpha1.RunPhaseCancelled
+		}
 		return v1alpha1.RunPhaseFailed
 	}
 
@@ -169,7 +285,7 @@ func setRunPhase(run *v1alpha1.Run) v1alpha1.RunPhase {
 			return v1alpha1.RunPhaseQueued
 		case v1alpha1.PodCreatedReason, v1alpha1.PodPendingReason:
 			return v1alpha1.RunPhaseProvisioning
-		case v1alpha1.PodRunningReason:
+		case v1alpha1.PodRunningReason, v1alpha1.PolicyPendingReason:
 			return v1alpha1.RunPhaseRunning
 		}
 	}
@@ -200,19 +316,21 @@ func (r *RunReconciler) manageQueue(ctx context.Context, run *v1alpha1.Run, ws v
 	complete := meta.FindStatusCondition(run.RunStatus.Conditions, v1alpha1.RunCompleteCondition)
 	lastUpdate := complete.LastTransitionTime.Time
 
+	timeouts := r.runTimeouts(run, &ws)
+
 	if complete.Reason == v1alpha1.RunUnqueuedReason {
-		if time.Now().After(lastUpdate.Add(runEnqueueTimeout)) {
+		if r.clock.Now().After(lastUpdate.Add(timeouts.Enqueue)) {
 			// Run has been waiting to be enqueued for too long
-			failed := runFailed(v1alpha1.RunEnqueueTimeoutReason, "Timed out waiting to be enqueued")
+			failed := runFailed(v1alpha1.RunEnqueueTimeoutReason, fmt.Sprintf("Timed out after %s waiting to be enqueued", timeouts.Enqueue))
 			meta.SetStatusCondition(&run.RunStatus.Conditions, *failed)
 			return true, errors.New("enqueue timeout exceeded")
 		}
 	}
 
 	if complete.Reason == v1alpha1.RunQueuedReason {
-		if time.Now().After(lastUpdate.Add(runQueueTimeout)) {
+		if r.clock.Now().After(lastUpdate.Add(timeouts.Queue)) {
 			// Run has been waiting in queue for too long
-			failed := runFailed(v1alpha1.QueueTimeoutReason, "Timed out waiting in the queue")
+			failed := runFailed(v1alpha1.QueueTimeoutReason, fmt.Sprintf("Timed out after %s waiting in the queue", timeouts.Queue))
 			meta.SetStatusCondition(&run.RunStatus.Conditions, *failed)
 			return true, errors.New("queue wait timeout exceeded")
 		}
@@ -222,6 +340,48 @@ func (r *RunReconciler) manageQueue(ctx context.Context, run *v1alpha1.Run, ws v
 	return true, nil
 }
 
+// secureSensitiveVariables moves the values of variables set to sensitive
+// upstream outputs into a secret owned by the run, and sets the variables to
+// reference the secret. Otherwise the values would be set directly on the
+// run's pod, readable by anyone permitted to read pods.
+func (r *RunReconciler) secureSensitiveVariables(ctx context.Context, run *v1alpha1.Run, ws *v1alpha1.Workspace, sensitive []int) error {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: run.Namespace, Name: run.UpstreamOutputsSecretName()}}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		secret.Data = make(map[string][]byte)
+		for _, i := range sensitive {
+			secret.Data[variableEnvName(ws.Spec.Variables[i])] = []byte(ws.Spec.Variables[i].Value)
+		}
+
+		// Set etok's common labels
+		labels.SetCommonLabels(secret)
+		// Permit filtering secrets by workspace
+		labels.SetLabel(secret, labels.Workspace(ws.Name))
+		// Permit filtering etok resources by component
+		labels.SetLabel(secret, labels.RunComponent)
+
+		// Delete secret along with the run
+		return controllerutil.SetControllerReference(run, secret, r.Scheme)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to create secret for sensitive upstream outputs: %w", err)
+	}
+
+	for _, i := range sensitive {
+		v := ws.Spec.Variables[i]
+		v.ValueFrom = &v1alpha1.VariableSource{
+			EnvVarSource: corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name},
+					Key:                  variableEnvName(v),
+				},
+			},
+		}
+		v.Value = ""
+	}
+	return nil
+}
+
 // Manage run's pod. Update run status to reflect pod status.
 func (r *RunReconciler) managePod(ctx context.Context, run *v1alpha1.Run, ws v1alpha1.Workspace) (bool, error) {
 	log := log.FromContext(ctx)
@@ -247,7 +407,26 @@ func (r *RunReconciler) managePod(ctx context.Context, run *v1alpha1.Run, ws v1a
 	var pod corev1.Pod
 	err = r.Get(ctx, requestFromObject(run).NamespacedName, &pod)
 	if kerrors.IsNotFound(err) {
-		pod = *runPod(run, &ws, secretFound, serviceAccountFound, r.Image)
+		// Set variables that reference the outputs of other workspaces
+		resolved, sensitive, err := resolveVariables(ctx, r.Client, &ws)
+		switch {
+		case errors.Is(err, errUpstreamNotFound):
+			meta.SetStatusCondition(&run.RunStatus.Conditions, *runFailed(v1alpha1.UpstreamNotFoundReason, err.Error()))
+			return true, nil
+		case errors.Is(err, errUpstreamOutputNotFound):
+			meta.SetStatusCondition(&run.RunStatus.Conditions, *runFailed(v1alpha1.UpstreamOutputNotFoundReason, err.Error()))
+			return true, nil
+		case err != nil:
+			return false, err
+		}
+
+		if len(sensitive) > 0 {
+			if err := r.secureSensitiveVariables(ctx, run, resolved, sensitive); err != nil {
+				return false, err
+			}
+		}
+
+		pod = *runPod(run, resolved, secretFound, serviceAccountFound, r.Image)
 
 		// Make run owner of pod
 		if err := controllerutil.SetControllerReference(run, &pod, r.Scheme); err != nil {
@@ -258,6 +437,8 @@ func (r *RunReconciler) managePod(ctx context.Context, run *v1alpha1.Run, ws v1a
 			log.Error(err, "unable to create pod")
 			return false, err
 		}
+		queueWaitSeconds.WithLabelValues(run.Command).Observe(r.clock.Since(run.CreationTimestamp.Time).Seconds())
+
 		meta.SetStatusCondition(&run.RunStatus.Conditions, *runIncomplete(v1alpha1.PodCreatedReason, ""))
 		return false, nil
 	} else if err != nil {
@@ -274,6 +455,11 @@ func (r *RunReconciler) managePod(ctx context.Context, run *v1alpha1.Run, ws v1a
 		}
 		run.RunStatus.ExitCode = &code
 
+		// Record whether the runner interrupted the command
+		if failed := runInterrupted(run, &pod); failed != nil {
+			meta.SetStatusCondition(&run.RunStatus.Conditions, *failed)
+		}
+
 		isCompleted = metav1.ConditionTrue
 	}
 
@@ -288,8 +474,9 @@ func (r *RunReconciler) managePod(ctx context.Context, run *v1alpha1.Run, ws v1a
 	if complete.Reason == v1alpha1.PodPendingReason {
 		lastUpdate := complete.LastTransitionTime.Time
 
-		if time.Now().After(lastUpdate.Add(runPodPendingTimeout)) {
-			failed := runFailed(v1alpha1.RunPendingTimeoutReason, "Timed out waiting for pod in pending phase")
+		timeout := r.runTimeouts(run, &ws).PodPending
+		if r.clock.Now().After(lastUpdate.Add(timeout)) {
+			failed := runFailed(v1alpha1.RunPendingTimeoutReason, fmt.Sprintf("Timed out after %s waiting for pod in pending phase", timeout))
 			meta.SetStatusCondition(&run.RunStatus.Conditions, *failed)
 		}
 	}
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+
+	v1alpha1 "github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/pkg/plan"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// rootModule is the key under which resources in the root module are counted
+const rootModule = "root"
+
+// recordBlastRadius records the blast radius of a completed run's plan. The
+// runner records the details of every plan, whether or not it is saved for a
+// later apply.
+func (r *RunReconciler) recordBlastRadius(ctx context.Context, run *v1alpha1.Run, ws v1alpha1.Workspace) (bool, error) {
+	complete := meta.FindStatusCondition(run.Conditions, v1alpha1.RunCompleteCondition)
+	if complete == nil || complete.Status != metav1.ConditionTrue || complete.Reason != v1alpha1.PodSucceededReason {
+		return false, nil
+	}
+
+	p, err := getRecordedPlan(ctx, r.Client, run)
+	if err != nil {
+		return false, err
+	}
+	if p == nil {
+		// Run did not produce a plan
+		return false, nil
+	}
+
+	run.BlastRadius = blastRadius(p, ws.Spec.CriticalResources)
+
+	return false, nil
+}
+
+// getRecordedPlan retrieves the changes recorded for a run's plan. Nil is
+// returned if the run did not produce a plan.
+func getRecordedPlan(ctx context.Context, c client.Client, run *v1alpha1.Run) (*plan.Plan, error) {
+	var planConfigMap corev1.ConfigMap
+	err := c.Get(ctx, types.NamespacedName{Namespace: run.Namespace, Name: run.PlanConfigMapName()}, &planConfigMap)
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	p := &plan.Plan{}
+	if err := json.Unmarshal([]byte(planConfigMap.Data[v1alpha1.PlanChangesKey]), p); err != nil {
+		return nil, fmt.Errorf("unable to decode plan changes: %w", err)
+	}
+	return p, nil
+}
+
+// blastRadius counts the resources a plan changes per provider and per module,
+// and flags the critical resources it replaces or deletes. Critical resources
+// are those with an address matching one of the given glob patterns. Changes
+// omitted from the plan are only counted in total.
+func blastRadius(p *plan.Plan, critical []string) *v1alpha1.BlastRadius {
+	br := &v1alpha1.BlastRadius{Omitted: p.Omitted}
+	for _, rc := range p.ResourceChanges {
+		if br.Providers == nil {
+			br.Providers = make(map[string]int)
+			br.Modules = make(map[string]int)
+		}
+		br.Providers[rc.Provider]++
+
+		module := rc.Module
+		if module == "" {
+			module = rootModule
+		}
+		br.Modules[module]++
+
+		if rc.Action != plan.Replace && rc.Action != plan.Delete {
+			continue
+		}
+		for _, pattern := range critical {
+			// An invalid pattern matches nothing
+			if match, _ := path.Match(pattern, rc.Address); match {
+				br.Critical = append(br.Critical, rc.Address)
+				break
+			}
+		}
+	}
+	sort.Strings(br.Critical)
+	return br
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	v1alpha1 "github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/pkg/globals"
+	"github.com/leg100/etok/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// manageCancellation cancels a run that is yet to run its command, i.e. it is
+// waiting to be queued, it is queued, or its pod is pending. Once its command
+// is running, it is left to the runner to interrupt the command upon
+// cancellation, and for managePod to record the run as cancelled.
+func (r *RunReconciler) manageCancellation(ctx context.Context, run *v1alpha1.Run, ws v1alpha1.Workspace) (bool, error) {
+	if !run.Cancel {
+		return false, nil
+	}
+
+	var pod corev1.Pod
+	err := r.Get(ctx, requestFromObject(run).NamespacedName, &pod)
+	if err == nil {
+		if pod.Status.Phase != corev1.PodPending {
+			// Runner is responsible for cancelling the run
+			return false, nil
+		}
+		if err := r.Delete(ctx, &pod); client.IgnoreNotFound(err) != nil {
+			return false, err
+		}
+	} else if !kerrors.IsNotFound(err) {
+		return false, err
+	}
+
+	meta.SetStatusCondition(&run.RunStatus.Conditions, *runFailed(v1alpha1.RunCancelledReason, "Run cancelled"))
+
+	// Bail out, do not proceed to queueing the run nor creating its pod
+	return true, nil
+}
+
+// runInterrupted returns a failed condition if the runner interrupted the
+// run's command, either because the run was cancelled or because it exceeded
+// its maximum duration. Otherwise nil is returned. The runner reports the
+// reason for the interruption in its container's termination message.
+func runInterrupted(run *v1alpha1.Run, pod *corev1.Pod) *metav1.Condition {
+	status := k8s.ContainerStatusByName(pod, globals.RunnerContainerName)
+	if status == nil || status.State.Terminated == nil {
+		return nil
+	}
+
+	switch status.State.Terminated.Message {
+	case v1alpha1.RunCancelledReason:
+		return runFailed(v1alpha1.RunCancelledReason, "Run cancelled")
+	case v1alpha1.MaxDurationExceededReason:
+		if run.MaxDuration != nil {
+			return runFailed(v1alpha1.MaxDurationExceededReason, fmt.Sprintf("Run exceeded maximum duration of %s", run.MaxDuration.Duration))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/pkg/globals"
+	"github.com/leg100/etok/pkg/k8s"
+	"github.com/leg100/etok/pkg/logstreamer"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// archiveLogs archives the logs of a completed run, retrieving them from the
+// run's pod. Nothing is archived if the pod no longer exists or its runner
+// container never ran.
+func (r *RunReconciler) archiveLogs(ctx context.Context, req ctrl.Request, run *v1alpha1.Run) error {
+	if r.logArchiver == nil || run.LogsArchived {
+		return nil
+	}
+
+	var pod corev1.Pod
+	if err := r.Get(ctx, req.NamespacedName, &pod); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	status := k8s.ContainerStatusByName(&pod, globals.RunnerContainerName)
+	if status == nil || status.State.Terminated == nil {
+		return nil
+	}
+
+	stream, err := r.getLogs(ctx, logstreamer.Options{
+		PodsClient:    r.pods.Pods(pod.Namespace),
+		PodName:       pod.Name,
+		PodLogOptions: &corev1.PodLogOptions{Container: globals.RunnerContainerName},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to retrieve logs: %w", err)
+	}
+	defer stream.Close()
+
+	logs, err := io.ReadAll(stream)
+	if err != nil {
+		return fmt.Errorf("unable to retrieve logs: %w", err)
+	}
+
+	if err := r.logArchiver.ArchiveLogs(ctx, client.ObjectKeyFromObject(run), run.UID, logs); err != nil {
+		return fmt.Errorf("unable to archive logs: %w", err)
+	}
+	log.FromContext(ctx).V(1).Info("Archived logs", "bytes", len(logs))
+
+	run.LogsArchived = true
+	return r.updateStatus(ctx, req, run.RunStatus)
+}
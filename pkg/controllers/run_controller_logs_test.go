@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	v1alpha1 "github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/pkg/backup"
+	"github.com/leg100/etok/pkg/logstreamer"
+	"github.com/leg100/etok/pkg/scheme"
+	"github.com/leg100/etok/pkg/testobj"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kfake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileRunLogs(t *testing.T) {
+	completed := func(opts ...func(*v1alpha1.Run)) *v1alpha1.Run {
+		opts = append([]func(*v1alpha1.Run){testobj.WithWorkspace("workspace-1"), testobj.WithCondition(v1alpha1.RunCompleteCondition, v1alpha1.PodSucceededReason)}, opts...)
+		return testobj.Run("operator-test", "apply-1", "apply", opts...)
+	}
+
+	tests := []struct {
+		name string
+		run  *v1alpha1.Run
+		objs []runtime.Object
+		// Disable archiving
+		disabled     bool
+		wantArchived bool
+		wantLogs     string
+	}{
+		{
+			name:         "Archive logs of completed run",
+			run:          completed(),
+			objs:         []runtime.Object{testobj.RunPod("operator-test", "apply-1")},
+			wantArchived: true,
+			wantLogs:     "fake logs",
+		},
+		{
+			name: "Logs already archived",
+			run: completed(func(run *v1alpha1.Run) {
+				run.LogsArchived = true
+			}),
+			objs:         []runtime.Object{testobj.RunPod("operator-test", "apply-1")},
+			wantArchived: true,
+		},
+		{
+			name: "Pod no longer exists",
+			run:  completed(),
+		},
+		{
+			name: "Runner never ran",
+			run:  completed(),
+			objs: []runtime.Object{testobj.RunPod("operator-test", "apply-1", func(pod *corev1.Pod) {
+				pod.Status.ContainerStatuses[0].State.Terminated = nil
+			})},
+		},
+		{
+			name:     "Archiving disabled",
+			run:      completed(),
+			objs:     []runtime.Object{testobj.RunPod("operator-test", "apply-1")},
+			disabled: true,
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			objs := append(tt.objs, runtime.Object(tt.run))
+			cl := fake.NewFakeClientWithScheme(scheme.Scheme, objs...)
+
+			provider := &backup.FakeProvider{}
+
+			var opts []RunReconcilerOption
+			if !tt.disabled {
+				opts = append(opts, WithLogArchiver(provider, kfake.NewSimpleClientset().CoreV1(), logstreamer.FakeGetLogs))
+			}
+
+			_, err := NewRunReconciler(cl, "a.b.c/d:v1", opts...).Reconcile(context.Background(), requestFromObject(tt.run))
+			require.NoError(t, err)
+
+			var run v1alpha1.Run
+			require.NoError(t, cl.Get(context.Background(), client.ObjectKeyFromObject(tt.run), &run))
+			assert.Equal(t, tt.wantArchived, run.LogsArchived)
+
+			logs, err := provider.RetrieveLogs(context.Background(), client.ObjectKeyFromObject(tt.run), tt.run.UID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantLogs, string(logs))
+		})
+	}
+}
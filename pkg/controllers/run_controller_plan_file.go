@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// planFileFinalizer prevents a run that saves its plan from being deleted
+// until its plan file has been deleted
+const planFileFinalizer = "etok.dev/plan-file"
+
+// managePlanFile ensures the plan file saved by a run is deleted along with the
+// run, adding a finalizer to the run that is only removed once the plan file
+// has been deleted. Returns true if the run is being deleted, in which case
+// there is nothing more to reconcile.
+func (r *RunReconciler) managePlanFile(ctx context.Context, run *v1alpha1.Run) (bool, error) {
+	if r.exec == nil || !run.SavePlan {
+		return false, nil
+	}
+
+	if run.DeletionTimestamp.IsZero() {
+		if controllerutil.ContainsFinalizer(run, planFileFinalizer) {
+			return false, nil
+		}
+		controllerutil.AddFinalizer(run, planFileFinalizer)
+		return false, r.Update(ctx, run)
+	}
+
+	if !controllerutil.ContainsFinalizer(run, planFileFinalizer) {
+		return true, nil
+	}
+	if err := r.deletePlanFile(ctx, run); err != nil {
+		return true, err
+	}
+	controllerutil.RemoveFinalizer(run, planFileFinalizer)
+	return true, r.Update(ctx, run)
+}
+
+// +kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
+
+// deletePlanFile deletes the plan file saved by a run from the workspace's
+// persistent volume, executing the deletion on the workspace pod, which mounts
+// the plan files. There is nothing to delete if the workspace, and with it its
+// persistent volume, is being deleted.
+func (r *RunReconciler) deletePlanFile(ctx context.Context, run *v1alpha1.Run) error {
+	var ws v1alpha1.Workspace
+	if err := r.Get(ctx, types.NamespacedName{Namespace: run.Namespace, Name: run.Workspace}, &ws); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !ws.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	var pod corev1.Pod
+	if err := r.Get(ctx, types.NamespacedName{Namespace: ws.Namespace, Name: ws.PodName()}, &pod); err != nil {
+		return fmt.Errorf("unable to retrieve workspace pod: %w", err)
+	}
+	if pod.Status.Phase != corev1.PodRunning {
+		return fmt.Errorf("unable to delete plan file: workspace pod is %s", pod.Status.Phase)
+	}
+
+	if err := r.exec(r.cfg, pod.Namespace, pod.Name, idlerContainerName, "rm", "-f", filepath.Join(PlansMountPath, run.Name)); err != nil {
+		return fmt.Errorf("unable to delete plan file: %w", err)
+	}
+	log.FromContext(ctx).V(1).Info("Deleted plan file")
+	return nil
+}
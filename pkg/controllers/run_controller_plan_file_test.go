@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	v1alpha1 "github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/pkg/scheme"
+	"github.com/leg100/etok/pkg/testobj"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileRunPlanFile(t *testing.T) {
+	deleted := func(run *v1alpha1.Run) {
+		now := metav1.Now()
+		run.DeletionTimestamp = &now
+		run.Finalizers = []string{planFileFinalizer}
+	}
+
+	plan := func(opts ...func(*v1alpha1.Run)) *v1alpha1.Run {
+		opts = append([]func(*v1alpha1.Run){testobj.WithWorkspace("workspace-1"), testobj.WithSavePlan()}, opts...)
+		return testobj.Run("operator-test", "plan-1", "plan", opts...)
+	}
+
+	tests := []struct {
+		name string
+		run  *v1alpha1.Run
+		objs []runtime.Object
+		// Disable deletion of plan files
+		disabled       bool
+		wantErr        bool
+		wantFinalizers []string
+		// Expected command executed on the workspace pod
+		wantExec []string
+	}{
+		{
+			name:           "Add finalizer to run saving its plan",
+			run:            plan(),
+			objs:           []runtime.Object{testobj.Workspace("operator-test", "workspace-1")},
+			wantFinalizers: []string{planFileFinalizer},
+		},
+		{
+			name: "Run not saving its plan",
+			run:  testobj.Run("operator-test", "plan-1", "plan", testobj.WithWorkspace("workspace-1")),
+			objs: []runtime.Object{testobj.Workspace("operator-test", "workspace-1")},
+		},
+		{
+			name: "Delete plan file of deleted run",
+			run:  plan(deleted),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1"),
+				testobj.WorkspacePod("operator-test", "workspace-1", testobj.WithPhase(corev1.PodRunning)),
+			},
+			wantExec: []string{"operator-test", "workspace-workspace-1", "idler", "rm", "-f", "/plans/plan-1"},
+		},
+		{
+			name: "Workspace pod not running",
+			run:  plan(deleted),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1"),
+				testobj.WorkspacePod("operator-test", "workspace-1"),
+			},
+			wantErr:        true,
+			wantFinalizers: []string{planFileFinalizer},
+		},
+		{
+			name: "Workspace being deleted",
+			run:  plan(deleted),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1", testobj.WithDeleteTimestamp()),
+			},
+		},
+		{
+			name: "Workspace not found",
+			run:  plan(deleted),
+		},
+		{
+			name:     "Deletion disabled",
+			run:      plan(),
+			objs:     []runtime.Object{testobj.Workspace("operator-test", "workspace-1")},
+			disabled: true,
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			objs := append(tt.objs, runtime.Object(tt.run))
+			cl := fake.NewFakeClientWithScheme(scheme.Scheme, objs...)
+
+			var executed []string
+			exec := func(cfg rest.Config, namespace, name, containerName string, command ...string) error {
+				executed = append([]string{namespace, name, containerName}, command...)
+				return nil
+			}
+
+			var opts []RunReconcilerOption
+			if !tt.disabled {
+				opts = append(opts, WithPlanFileDeletion(rest.Config{}, exec))
+			}
+
+			_, err := NewRunReconciler(cl, "a.b.c/d:v1", opts...).Reconcile(context.Background(), requestFromObject(tt.run))
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			var run v1alpha1.Run
+			require.NoError(t, cl.Get(context.Background(), client.ObjectKeyFromObject(tt.run), &run))
+			assert.Equal(t, tt.wantFinalizers, run.Finalizers)
+			assert.Equal(t, tt.wantExec, executed)
+		})
+	}
+}
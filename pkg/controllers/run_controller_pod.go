@@ -63,10 +63,6 @@ func runPod(run *v1alpha1.Run, ws *v1alpha1.Workspace, secretFound, serviceAccou
 							Name:  "ETOK_DEST",
 							Value: workspaceDir,
 						},
-						{
-							Name:  "ETOK_TARBALL",
-							Value: filepath.Join("/tarball", run.ConfigMapKey),
-						},
 						{
 							Name:  "ETOK_V",
 							Value: strconv.Itoa(run.Verbosity),
@@ -91,6 +87,14 @@ func runPod(run *v1alpha1.Run, ws *v1alpha1.Workspace, secretFound, serviceAccou
 							Name:  "ETOK_RUN_NAME",
 							Value: run.Name,
 						},
+						{
+							Name:  "ETOK_SAVE_PLAN",
+							Value: strconv.FormatBool(run.SavePlan),
+						},
+						{
+							Name:  "ETOK_PLAN",
+							Value: run.Plan,
+						},
 						{
 							Name:  "TF_VAR_namespace",
 							Value: ws.Namespace,
@@ -128,11 +132,6 @@ func runPod(run *v1alpha1.Run, ws *v1alpha1.Workspace, secretFound, serviceAccou
 							MountPath: filepath.Join(workspaceDir, ws.Spec.VCS.WorkingDir, ".terraform"),
 							SubPath:   dotTerraformSubPath,
 						},
-						{
-							Name:      "tarball",
-							MountPath: filepath.Join("/tarball", run.ConfigMapKey),
-							SubPath:   run.ConfigMapKey,
-						},
 						{
 							Name: "builtins",
 							// <WorkingDir>/_etok_variables.tf
@@ -159,16 +158,6 @@ func runPod(run *v1alpha1.Run, ws *v1alpha1.Workspace, secretFound, serviceAccou
 						},
 					},
 				},
-				{
-					Name: "tarball",
-					VolumeSource: corev1.VolumeSource{
-						ConfigMap: &corev1.ConfigMapVolumeSource{
-							LocalObjectReference: corev1.LocalObjectReference{
-								Name: run.ConfigMap,
-							},
-						},
-					},
-				},
 				{
 					Name: "builtins",
 					VolumeSource: corev1.VolumeSource{
@@ -192,6 +181,34 @@ func runPod(run *v1alpha1.Run, ws *v1alpha1.Workspace, secretFound, serviceAccou
 	// Permit filtering pods by the run command
 	labels.SetLabel(pod, labels.Command(run.Command))
 
+	if run.Repository != nil {
+		// Runner clones the repository in place of extracting a tarball
+		pod.Spec.Containers[0].Env = append(pod.Spec.Containers[0].Env,
+			corev1.EnvVar{Name: "ETOK_REPOSITORY", Value: run.Repository.URL},
+			corev1.EnvVar{Name: "ETOK_BRANCH", Value: run.Repository.Branch},
+		)
+	} else {
+		pod.Spec.Containers[0].Env = append(pod.Spec.Containers[0].Env, corev1.EnvVar{
+			Name:  "ETOK_TARBALL",
+			Value: filepath.Join("/tarball", run.ConfigMapKey),
+		})
+		pod.Spec.Containers[0].VolumeMounts = append(pod.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      "tarball",
+			MountPath: filepath.Join("/tarball", run.ConfigMapKey),
+			SubPath:   run.ConfigMapKey,
+		})
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+			Name: "tarball",
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: run.ConfigMap,
+					},
+				},
+			},
+		})
+	}
+
 	if serviceAccountFound {
 		pod.Spec.ServiceAccountName = "etok"
 	}
@@ -206,18 +223,20 @@ func runPod(run *v1alpha1.Run, ws *v1alpha1.Workspace, secretFound, serviceAccou
 		})
 	}
 
+	if run.MaxDuration != nil {
+		// Runner interrupts the command once the maximum duration is exceeded
+		pod.Spec.Containers[0].Env = append(pod.Spec.Containers[0].Env, corev1.EnvVar{
+			Name:  "ETOK_MAX_DURATION",
+			Value: run.MaxDuration.Duration.String(),
+		})
+	}
+
 	// Set workspace variables
 	for _, v := range ws.Spec.Variables {
-		var ev corev1.EnvVar
-
-		if v.EnvironmentVariable {
-			ev.Name = v.Key
-		} else {
-			ev.Name = fmt.Sprintf("TF_VAR_%s", v.Key)
-		}
+		ev := corev1.EnvVar{Name: variableEnvName(v)}
 
-		if ev.ValueFrom != nil {
-			ev.ValueFrom = v.ValueFrom
+		if v.ValueFrom != nil {
+			ev.ValueFrom = &v.ValueFrom.EnvVarSource
 		} else {
 			ev.Value = v.Value
 		}
@@ -225,5 +244,17 @@ func runPod(run *v1alpha1.Run, ws *v1alpha1.Workspace, secretFound, serviceAccou
 		pod.Spec.Containers[0].Env = append(pod.Spec.Containers[0].Env, ev)
 	}
 
+	// Apply workspace's overrides
+	applyPodTemplate(pod, ws.Spec.PodTemplate)
+
 	return pod
 }
+
+// variableEnvName returns the name of the environment variable that sets the
+// workspace variable on the run's pod
+func variableEnvName(v *v1alpha1.Variable) string {
+	if v.EnvironmentVariable {
+		return v.Key
+	}
+	return fmt.Sprintf("TF_VAR_%s", v.Key)
+}
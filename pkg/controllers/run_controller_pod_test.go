@@ -2,12 +2,19 @@ package controllers
 
 import (
 	"testing"
+	"time"
 
 	"github.com/leg100/etok/api/etok.dev/v1alpha1"
 	"github.com/leg100/etok/pkg/builders"
 	"github.com/leg100/etok/pkg/testobj"
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var (
+	trueValue  = true
+	falseValue = false
 )
 
 func TestRunPod(t *testing.T) {
@@ -110,6 +117,39 @@ func TestRunPod(t *testing.T) {
 				})
 			},
 		},
+		{
+			name:      "Save plan",
+			run:       builders.Run("default", "run-12345", "foo", "plan").SavePlan().Build(),
+			workspace: testobj.Workspace("default", "foo"),
+			assertions: func(pod *corev1.Pod) {
+				assert.Contains(t, pod.Spec.Containers[0].Env, corev1.EnvVar{
+					Name:  "ETOK_SAVE_PLAN",
+					Value: "true",
+				})
+			},
+		},
+		{
+			name:      "Apply saved plan",
+			run:       builders.Run("default", "run-12345", "foo", "apply").ApplyPlan("run-12344").Build(),
+			workspace: testobj.Workspace("default", "foo"),
+			assertions: func(pod *corev1.Pod) {
+				assert.Contains(t, pod.Spec.Containers[0].Env, corev1.EnvVar{
+					Name:  "ETOK_PLAN",
+					Value: "run-12344",
+				})
+			},
+		},
+		{
+			name:      "Max duration",
+			run:       builders.Run("default", "run-12345", "foo", "apply").MaxDuration(time.Hour).Build(),
+			workspace: testobj.Workspace("default", "foo"),
+			assertions: func(pod *corev1.Pod) {
+				assert.Contains(t, pod.Spec.Containers[0].Env, corev1.EnvVar{
+					Name:  "ETOK_MAX_DURATION",
+					Value: "1h0m0s",
+				})
+			},
+		},
 		{
 			name:        "Set environment variables for secrets",
 			run:         testobj.Run("default", "run-12345", "plan"),
@@ -147,6 +187,119 @@ func TestRunPod(t *testing.T) {
 				})
 			},
 		},
+		{
+			name: "Set workspace variable from secret",
+			run:  testobj.Run("default", "run-12345", "plan"),
+			workspace: testobj.Workspace("default", "foo", func(ws *v1alpha1.Workspace) {
+				ws.Spec.Variables = append(ws.Spec.Variables, &v1alpha1.Variable{
+					Key: "password",
+					ValueFrom: &v1alpha1.VariableSource{
+						EnvVarSource: corev1.EnvVarSource{
+							SecretKeyRef: &corev1.SecretKeySelector{Key: "password"},
+						},
+					},
+				})
+			}),
+			assertions: func(pod *corev1.Pod) {
+				assert.Contains(t, pod.Spec.Containers[0].Env, corev1.EnvVar{
+					Name: "TF_VAR_password",
+					ValueFrom: &corev1.EnvVarSource{
+						SecretKeyRef: &corev1.SecretKeySelector{Key: "password"},
+					},
+				})
+			},
+		},
+		{
+			name: "Pod template scheduling overrides",
+			run:  testobj.Run("default", "run-12345", "plan"),
+			workspace: testobj.Workspace("default", "foo", testobj.WithPodTemplate(&v1alpha1.PodTemplate{
+				NodeSelector:      map[string]string{"pool": "terraform"},
+				Tolerations:       []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}},
+				PriorityClassName: "high",
+				Affinity: &corev1.Affinity{
+					NodeAffinity: &corev1.NodeAffinity{
+						RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+							NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "zone", Operator: corev1.NodeSelectorOpIn, Values: []string{"a"}}}}},
+						},
+					},
+				},
+			})),
+			assertions: func(pod *corev1.Pod) {
+				assert.Equal(t, map[string]string{"pool": "terraform"}, pod.Spec.NodeSelector)
+				assert.Equal(t, []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}}, pod.Spec.Tolerations)
+				assert.Equal(t, "high", pod.Spec.PriorityClassName)
+				assert.NotNil(t, pod.Spec.Affinity.NodeAffinity)
+				// Retains affinity for workspace pod
+				assert.Len(t, pod.Spec.Affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution, 1)
+			},
+		},
+		{
+			name: "Pod template service account",
+			run:  testobj.Run("default", "run-12345", "plan"),
+			workspace: testobj.Workspace("default", "foo", testobj.WithPodTemplate(&v1alpha1.PodTemplate{
+				ServiceAccountName: "terraform",
+			})),
+			serviceAccountFound: true,
+			assertions: func(pod *corev1.Pod) {
+				assert.Equal(t, "terraform", pod.Spec.ServiceAccountName)
+			},
+		},
+		{
+			name: "Pod template labels and annotations",
+			run:  testobj.Run("default", "run-12345", "plan"),
+			workspace: testobj.Workspace("default", "foo", testobj.WithPodTemplate(&v1alpha1.PodTemplate{
+				Labels:      map[string]string{"team": "platform", "workspace": "bar"},
+				Annotations: map[string]string{"cluster-autoscaler.kubernetes.io/safe-to-evict": "false"},
+			})),
+			assertions: func(pod *corev1.Pod) {
+				assert.Equal(t, "platform", pod.Labels["team"])
+				// Etok's labels take precedence
+				assert.Equal(t, "foo", pod.Labels["workspace"])
+				assert.Equal(t, "false", pod.Annotations["cluster-autoscaler.kubernetes.io/safe-to-evict"])
+			},
+		},
+		{
+			name: "Pod template image and pull secrets",
+			run:  testobj.Run("default", "run-12345", "plan"),
+			workspace: testobj.Workspace("default", "foo", testobj.WithPodTemplate(&v1alpha1.PodTemplate{
+				ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}},
+				Containers: []v1alpha1.ContainerTemplate{
+					{Image: "registry.example.com/etok:latest", ImagePullPolicy: corev1.PullAlways},
+				},
+			})),
+			assertions: func(pod *corev1.Pod) {
+				assert.Equal(t, []corev1.LocalObjectReference{{Name: "registry"}}, pod.Spec.ImagePullSecrets)
+				assert.Equal(t, "registry.example.com/etok:latest", pod.Spec.Containers[0].Image)
+				assert.Equal(t, corev1.PullAlways, pod.Spec.Containers[0].ImagePullPolicy)
+			},
+		},
+		{
+			name: "Pod template security contexts and resources",
+			run:  testobj.Run("default", "run-12345", "plan"),
+			workspace: testobj.Workspace("default", "foo", testobj.WithPodTemplate(&v1alpha1.PodTemplate{
+				SecurityContext: &corev1.PodSecurityContext{RunAsNonRoot: &trueValue},
+				Containers: []v1alpha1.ContainerTemplate{
+					{
+						SecurityContext: &corev1.SecurityContext{AllowPrivilegeEscalation: &falseValue},
+						Resources:       &corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")}},
+					},
+					{
+						Name:      "runner",
+						Resources: &corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}},
+					},
+					{
+						Name:      "idler",
+						Resources: &corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("10m")}},
+					},
+				},
+			})),
+			assertions: func(pod *corev1.Pod) {
+				assert.Equal(t, &trueValue, pod.Spec.SecurityContext.RunAsNonRoot)
+				assert.Equal(t, &falseValue, pod.Spec.Containers[0].SecurityContext.AllowPrivilegeEscalation)
+				// Named override takes precedence
+				assert.Equal(t, resource.MustParse("1"), pod.Spec.Containers[0].Resources.Requests[corev1.ResourceCPU])
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	v1alpha1 "github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/pkg/globals"
+	"github.com/leg100/etok/pkg/k8s"
+	"github.com/leg100/etok/pkg/policy"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// planRecordTimeout is the maximum time after a run's pod terminates that the
+// plan it saved can take to become visible to the reconciler
+const planRecordTimeout = 30 * time.Second
+
+var errPlanNotRecorded = errors.New("plan has not been recorded")
+
+// checkPlanPolicy prevents an apply run from applying a saved plan that
+// violates the workspace's policy
+func (r *RunReconciler) checkPlanPolicy(ctx context.Context, run *v1alpha1.Run, ws v1alpha1.Workspace) (bool, error) {
+	if run.Plan == "" {
+		return false, nil
+	}
+
+	var planRun v1alpha1.Run
+	err := r.Get(ctx, types.NamespacedName{Namespace: run.Namespace, Name: run.Plan}, &planRun)
+	if kerrors.IsNotFound(err) {
+		if len(ws.Spec.PolicyRuleSets) == 0 {
+			// Leave it to terraform to report the missing plan
+			return false, nil
+		}
+		// The plan file may outlive its run, e.g. a run deleted by the
+		// retention controller, but without its run the plan cannot be vetted
+		// against policy
+		failed := runFailed(v1alpha1.PlanNotFoundReason, fmt.Sprintf("Plan %s not found: it cannot be checked against policy", run.Plan))
+		meta.SetStatusCondition(&run.RunStatus.Conditions, *failed)
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if !planRun.CompliesWithPolicy(&ws) {
+		failed := runFailed(v1alpha1.PolicyViolationReason, fmt.Sprintf("Plan %s has not passed policy", run.Plan))
+		meta.SetStatusCondition(&run.RunStatus.Conditions, *failed)
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// evaluatePolicy evaluates a completed run's saved plan against the
+// workspace's policy rule sets, recording the result as a condition. The run
+// is not complete until its plan has been evaluated.
+func (r *RunReconciler) evaluatePolicy(ctx context.Context, run *v1alpha1.Run, ws v1alpha1.Workspace) (bool, error) {
+	if !run.SavePlan || len(ws.Spec.PolicyRuleSets) == 0 {
+		return false, nil
+	}
+
+	complete := meta.FindStatusCondition(run.Conditions, v1alpha1.RunCompleteCondition)
+	if complete == nil || complete.Status != metav1.ConditionTrue || complete.Reason != v1alpha1.PodSucceededReason {
+		return false, nil
+	}
+
+	p, err := getRecordedPlan(ctx, r.Client, run)
+	if err == nil && p == nil {
+		err = errPlanNotRecorded
+	}
+	if err != nil {
+		// The runner records the plan before its pod terminates, so the plan
+		// may not yet be visible to the cache. Keep the run incomplete and try
+		// again, until the plan should have been visible, whereupon fail
+		// closed.
+		if r.clock.Since(r.runnerFinishedAt(ctx, run)) < planRecordTimeout {
+			meta.SetStatusCondition(&run.RunStatus.Conditions, *runIncomplete(v1alpha1.PolicyPendingReason, "Waiting to evaluate plan against policy"))
+			return true, err
+		}
+		meta.SetStatusCondition(&run.RunStatus.Conditions, *policyFailed(v1alpha1.PlanNotFoundReason, fmt.Sprintf("Unable to evaluate plan against policy: %s", err.Error())))
+		return false, nil
+	}
+
+	var violations []string
+	for _, name := range ws.Spec.PolicyRuleSets {
+		ruleSets, err := r.getRuleSets(ctx, run.Namespace, name)
+		if err != nil {
+			// Fail closed: a plan cannot be vetted against a rule set that
+			// cannot be read
+			meta.SetStatusCondition(&run.RunStatus.Conditions, *policyFailed(v1alpha1.RuleSetInvalidReason, err.Error()))
+			return false, nil
+		}
+		for _, rs := range ruleSets {
+			for _, v := range rs.Evaluate(p) {
+				violations = append(violations, v.String())
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		meta.SetStatusCondition(&run.RunStatus.Conditions, *policyFailed(v1alpha1.PolicyViolationReason, strings.Join(violations, "; ")))
+	} else {
+		meta.SetStatusCondition(&run.RunStatus.Conditions, *policyPassed())
+	}
+
+	return false, nil
+}
+
+// runnerFinishedAt returns the time at which the run's runner container
+// terminated. The zero time is returned if it cannot be determined.
+func (r *RunReconciler) runnerFinishedAt(ctx context.Context, run *v1alpha1.Run) time.Time {
+	var pod corev1.Pod
+	if err := r.Get(ctx, requestFromObject(run).NamespacedName, &pod); err != nil {
+		return time.Time{}
+	}
+	status := k8s.ContainerStatusByName(&pod, globals.RunnerContainerName)
+	if status == nil || status.State.Terminated == nil {
+		return time.Time{}
+	}
+	return status.State.Terminated.FinishedAt.Time
+}
+
+// getRuleSets retrieves the rule sets in the named config map, one per key
+func (r *RunReconciler) getRuleSets(ctx context.Context, namespace, name string) ([]*policy.RuleSet, error) {
+	var configMap corev1.ConfigMap
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &configMap); err != nil {
+		return nil, fmt.Errorf("unable to get rule set config map %s: %w", name, err)
+	}
+
+	// Sort keys for deterministic ordering of violations
+	var keys []string
+	for k := range configMap.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var ruleSets []*policy.RuleSet
+	for _, k := range keys {
+		rs, err := policy.Parse([]byte(configMap.Data[k]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule set %s/%s: %w", name, k, err)
+		}
+		ruleSets = append(ruleSets, rs)
+	}
+	return ruleSets, nil
+}
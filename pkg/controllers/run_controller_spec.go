@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	v1alpha1 "github.com/leg100/etok/api/etok.dev/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// checkSpecUnmodified fails a run whose spec has been modified since it was
+// first reconciled, other than to cancel it or to change its priority. Users
+// permitted to cancel a run are necessarily permitted to update it, whereas
+// approval of a privileged run is keyed only by the run's name, so a run's
+// spec must not change once it has been created. Its pod, if any, is deleted,
+// lest it run a command other than the one recorded.
+func (r *RunReconciler) checkSpecUnmodified(ctx context.Context, run *v1alpha1.Run, ws v1alpha1.Workspace) (bool, error) {
+	digest, err := specDigest(run)
+	if err != nil {
+		return false, err
+	}
+
+	if run.SpecDigest == "" {
+		// Record digest of spec upon first reconcile
+		run.SpecDigest = digest
+		return false, nil
+	}
+
+	if run.SpecDigest == digest {
+		return false, nil
+	}
+
+	pod := corev1.Pod{}
+	pod.Namespace, pod.Name = run.Namespace, run.Name
+	if err := r.Delete(ctx, &pod); client.IgnoreNotFound(err) != nil {
+		return false, err
+	}
+
+	meta.SetStatusCondition(&run.RunStatus.Conditions, *runFailed(v1alpha1.SpecModifiedReason, "Run spec modified after creation: only cancel and priority can be changed"))
+
+	// Bail out, do not proceed to queueing the run nor creating its pod
+	return true, nil
+}
+
+// specDigest returns a digest of the run's spec, excluding those fields that
+// can be changed after the run is created.
+func specDigest(run *v1alpha1.Run) (string, error) {
+	spec := run.RunSpec
+	spec.Cancel = false
+	spec.Priority = 0
+
+	data, err := json.Marshal(&spec)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
@@ -28,6 +28,7 @@ func TestRunReconciler(t *testing.T) {
 		runAssertions       func(*testutil.T, *v1alpha1.Run)
 		podAssertions       func(*testutil.T, *corev1.Pod)
 		configMapAssertions func(*testutil.T, *corev1.ConfigMap)
+		secretAssertions    func(*testutil.T, *corev1.Secret)
 		reconcileError      bool
 	}{
 		{
@@ -120,6 +121,196 @@ func TestRunReconciler(t *testing.T) {
 				assert.True(t, meta.IsStatusConditionTrue(run.Conditions, v1alpha1.RunCompleteCondition))
 			},
 		},
+		{
+			name: "Saved plan violates policy",
+			run:  testobj.Run("operator-test", "plan-1", "plan", testobj.WithWorkspace("workspace-1"), testobj.WithSavePlan()),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1", testobj.WithPolicyRuleSets("policy")),
+				testobj.RunPod("operator-test", "plan-1", testobj.WithPhase(corev1.PodSucceeded)),
+				testobj.ConfigMap("operator-test", "plan-1-plan", testobj.WithPlanChanges(`{"resourceChanges":[{"address":"aws_db_instance.main","action":"delete"}]}`)),
+				testobj.ConfigMap("operator-test", "policy", testobj.WithRuleSet("rules.yaml", "rules:\n- name: protect-databases\n  denyDelete: aws_db_instance.*\n")),
+			},
+			runAssertions: func(t *testutil.T, run *v1alpha1.Run) {
+				failed := meta.FindStatusCondition(run.Conditions, v1alpha1.RunPolicyFailedCondition)
+				if assert.NotNil(t, failed) {
+					assert.Equal(t, metav1.ConditionTrue, failed.Status)
+					assert.Equal(t, v1alpha1.PolicyViolationReason, failed.Reason)
+					assert.Equal(t, "protect-databases: deletion of aws_db_instance.main is forbidden", failed.Message)
+				}
+			},
+		},
+		{
+			name: "Saved plan passes policy",
+			run:  testobj.Run("operator-test", "plan-1", "plan", testobj.WithWorkspace("workspace-1"), testobj.WithSavePlan()),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1", testobj.WithPolicyRuleSets("policy")),
+				testobj.RunPod("operator-test", "plan-1", testobj.WithPhase(corev1.PodSucceeded)),
+				testobj.ConfigMap("operator-test", "plan-1-plan", testobj.WithPlanChanges(`{"resourceChanges":[{"address":"aws_db_instance.main","action":"update"}]}`)),
+				testobj.ConfigMap("operator-test", "policy", testobj.WithRuleSet("rules.yaml", "rules:\n- name: protect-databases\n  denyDelete: aws_db_instance.*\n")),
+			},
+			runAssertions: func(t *testutil.T, run *v1alpha1.Run) {
+				assert.True(t, meta.IsStatusConditionFalse(run.Conditions, v1alpha1.RunPolicyFailedCondition))
+			},
+		},
+		{
+			name: "Saved plan not yet visible",
+			run:  testobj.Run("operator-test", "plan-1", "plan", testobj.WithWorkspace("workspace-1"), testobj.WithSavePlan()),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1", testobj.WithPolicyRuleSets("policy")),
+				testobj.RunPod("operator-test", "plan-1", testobj.WithPhase(corev1.PodSucceeded), testobj.WithRunnerFinishedAt(time.Now())),
+			},
+			runAssertions: func(t *testutil.T, run *v1alpha1.Run) {
+				// Run is not complete until its plan has been evaluated
+				assert.False(t, run.IsDone())
+				assert.Equal(t, v1alpha1.RunPhaseRunning, run.Phase)
+				assert.Nil(t, meta.FindStatusCondition(run.Conditions, v1alpha1.RunPolicyFailedCondition))
+			},
+			reconcileError: true,
+		},
+		{
+			name: "Saved plan never recorded",
+			run:  testobj.Run("operator-test", "plan-1", "plan", testobj.WithWorkspace("workspace-1"), testobj.WithSavePlan()),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1", testobj.WithPolicyRuleSets("policy")),
+				testobj.RunPod("operator-test", "plan-1", testobj.WithPhase(corev1.PodSucceeded), testobj.WithRunnerFinishedAt(time.Now().Add(-time.Hour))),
+			},
+			runAssertions: func(t *testutil.T, run *v1alpha1.Run) {
+				assert.True(t, meta.IsStatusConditionTrue(run.Conditions, v1alpha1.RunCompleteCondition))
+				failed := meta.FindStatusCondition(run.Conditions, v1alpha1.RunPolicyFailedCondition)
+				if assert.NotNil(t, failed) {
+					assert.Equal(t, metav1.ConditionTrue, failed.Status)
+					assert.Equal(t, v1alpha1.PlanNotFoundReason, failed.Reason)
+				}
+			},
+		},
+		{
+			name: "Missing policy rule set",
+			run:  testobj.Run("operator-test", "plan-1", "plan", testobj.WithWorkspace("workspace-1"), testobj.WithSavePlan()),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1", testobj.WithPolicyRuleSets("policy")),
+				testobj.RunPod("operator-test", "plan-1", testobj.WithPhase(corev1.PodSucceeded)),
+				testobj.ConfigMap("operator-test", "plan-1-plan", testobj.WithPlanChanges(`{}`)),
+			},
+			runAssertions: func(t *testutil.T, run *v1alpha1.Run) {
+				failed := meta.FindStatusCondition(run.Conditions, v1alpha1.RunPolicyFailedCondition)
+				if assert.NotNil(t, failed) {
+					assert.Equal(t, metav1.ConditionTrue, failed.Status)
+					assert.Equal(t, v1alpha1.RuleSetInvalidReason, failed.Reason)
+				}
+			},
+		},
+		{
+			name: "Saved plan blast radius",
+			run:  testobj.Run("operator-test", "plan-1", "plan", testobj.WithWorkspace("workspace-1"), testobj.WithSavePlan()),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1", testobj.WithCriticalResources("aws_db_instance.*", "module.network.*")),
+				testobj.RunPod("operator-test", "plan-1", testobj.WithPhase(corev1.PodSucceeded)),
+				testobj.ConfigMap("operator-test", "plan-1-plan", testobj.WithPlanChanges(`{"resourceChanges":[
+					{"address":"aws_db_instance.main","action":"replace","provider":"aws"},
+					{"address":"aws_db_instance.replica","action":"update","provider":"aws"},
+					{"address":"module.network.aws_vpc.main","action":"delete","provider":"aws","module":"module.network"},
+					{"address":"module.network.random_id.suffix","action":"create","provider":"random","module":"module.network"}
+				]}`)),
+			},
+			runAssertions: func(t *testutil.T, run *v1alpha1.Run) {
+				assert.Equal(t, &v1alpha1.BlastRadius{
+					Providers: map[string]int{"aws": 3, "random": 1},
+					Modules:   map[string]int{"root": 2, "module.network": 2},
+					Critical:  []string{"aws_db_instance.main", "module.network.aws_vpc.main"},
+				}, run.BlastRadius)
+			},
+		},
+		{
+			name: "Truncated plan blast radius",
+			run:  testobj.Run("operator-test", "plan-1", "plan", testobj.WithWorkspace("workspace-1"), testobj.WithSavePlan()),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1"),
+				testobj.RunPod("operator-test", "plan-1", testobj.WithPhase(corev1.PodSucceeded)),
+				testobj.ConfigMap("operator-test", "plan-1-plan", testobj.WithPlanChanges(`{"resourceChanges":[
+					{"address":"random_id.suffix","action":"create","provider":"random"}
+				],"omitted":2,"omittedActions":{"delete":2}}`)),
+			},
+			runAssertions: func(t *testutil.T, run *v1alpha1.Run) {
+				assert.Equal(t, &v1alpha1.BlastRadius{
+					Providers: map[string]int{"random": 1},
+					Modules:   map[string]int{"root": 1},
+					Omitted:   2,
+				}, run.BlastRadius)
+			},
+		},
+		{
+			name: "Unsaved plan blast radius",
+			run:  testobj.Run("operator-test", "plan-1", "plan", testobj.WithWorkspace("workspace-1")),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1"),
+				testobj.RunPod("operator-test", "plan-1", testobj.WithPhase(corev1.PodSucceeded)),
+				testobj.ConfigMap("operator-test", "plan-1-plan", testobj.WithPlanChanges(`{"resourceChanges":[
+					{"address":"random_id.suffix","action":"create","provider":"random"}
+				]}`)),
+			},
+			runAssertions: func(t *testutil.T, run *v1alpha1.Run) {
+				assert.Equal(t, &v1alpha1.BlastRadius{
+					Providers: map[string]int{"random": 1},
+					Modules:   map[string]int{"root": 1},
+				}, run.BlastRadius)
+			},
+		},
+		{
+			name: "Saved plan without changes has empty blast radius",
+			run:  testobj.Run("operator-test", "plan-1", "plan", testobj.WithWorkspace("workspace-1"), testobj.WithSavePlan()),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1"),
+				testobj.RunPod("operator-test", "plan-1", testobj.WithPhase(corev1.PodSucceeded)),
+				testobj.ConfigMap("operator-test", "plan-1-plan", testobj.WithPlanChanges(`{}`)),
+			},
+			runAssertions: func(t *testutil.T, run *v1alpha1.Run) {
+				assert.Equal(t, &v1alpha1.BlastRadius{}, run.BlastRadius)
+			},
+		},
+		{
+			name: "Apply plan that violates policy",
+			run:  testobj.Run("operator-test", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), testobj.WithPlan("plan-1")),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1", testobj.WithCombinedQueue("apply-1")),
+				testobj.Run("operator-test", "plan-1", "plan", testobj.WithWorkspace("workspace-1"), testobj.WithCondition(v1alpha1.RunPolicyFailedCondition, v1alpha1.PolicyViolationReason)),
+			},
+			runAssertions: func(t *testutil.T, run *v1alpha1.Run) {
+				assert.Equal(t, v1alpha1.RunPhaseFailed, run.Phase)
+				failed := meta.FindStatusCondition(run.Conditions, v1alpha1.RunFailedCondition)
+				if assert.NotNil(t, failed) {
+					assert.Equal(t, v1alpha1.PolicyViolationReason, failed.Reason)
+				}
+			},
+		},
+		{
+			name: "Apply plan that has not been evaluated against policy",
+			run:  testobj.Run("operator-test", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), testobj.WithPlan("plan-1")),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1", testobj.WithCombinedQueue("apply-1"), testobj.WithPolicyRuleSets("policy")),
+				testobj.Run("operator-test", "plan-1", "plan", testobj.WithWorkspace("workspace-1")),
+			},
+			runAssertions: func(t *testutil.T, run *v1alpha1.Run) {
+				assert.Equal(t, v1alpha1.RunPhaseFailed, run.Phase)
+				failed := meta.FindStatusCondition(run.Conditions, v1alpha1.RunFailedCondition)
+				if assert.NotNil(t, failed) {
+					assert.Equal(t, v1alpha1.PolicyViolationReason, failed.Reason)
+				}
+			},
+		},
+		{
+			name: "Apply plan whose run is missing",
+			run:  testobj.Run("operator-test", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), testobj.WithPlan("plan-1")),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1", testobj.WithCombinedQueue("apply-1"), testobj.WithPolicyRuleSets("policy")),
+			},
+			runAssertions: func(t *testutil.T, run *v1alpha1.Run) {
+				assert.Equal(t, v1alpha1.RunPhaseFailed, run.Phase)
+				failed := meta.FindStatusCondition(run.Conditions, v1alpha1.RunFailedCondition)
+				if assert.NotNil(t, failed) {
+					assert.Equal(t, v1alpha1.PlanNotFoundReason, failed.Reason)
+				}
+			},
+		},
 		{
 			name: "Creates pod",
 			run:  testobj.Run("operator-test", "plan-1", "plan", testobj.WithWorkspace("workspace-1")),
@@ -130,6 +321,58 @@ func TestRunReconciler(t *testing.T) {
 				assert.NotEqual(t, &corev1.Pod{}, pod)
 			},
 		},
+		{
+			name: "Sets variable from upstream output",
+			run:  testobj.Run("operator-test", "plan-1", "plan", testobj.WithWorkspace("workspace-1")),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1", testobj.WithCombinedQueue("plan-1"), testobj.WithWorkspaceOutputVariable("vpc_id", "network", "vpc_id")),
+				testobj.Workspace("operator-test", "network", testobj.WithOutputs("vpc_id", "vpc-123")),
+			},
+			podAssertions: func(t *testutil.T, pod *corev1.Pod) {
+				assert.Contains(t, pod.Spec.Containers[0].Env, corev1.EnvVar{
+					Name:  "TF_VAR_vpc_id",
+					Value: "vpc-123",
+				})
+			},
+		},
+		{
+			name: "Sets variable from sensitive upstream output",
+			run:  testobj.Run("operator-test", "plan-1", "plan", testobj.WithWorkspace("workspace-1")),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1", testobj.WithCombinedQueue("plan-1"), testobj.WithWorkspaceOutputVariable("db_password", "database", "db_password")),
+				testobj.Workspace("operator-test", "database", func(ws *v1alpha1.Workspace) {
+					ws.Status.Outputs = []*v1alpha1.Output{{Key: "db_password", Type: "string", Sensitive: true}}
+				}),
+				testobj.Secret("operator-test", "tfstate-default-database", testobj.WithCompressedDataFromFile("tfstate", "testdata/tfstate_typed.json")),
+			},
+			podAssertions: func(t *testutil.T, pod *corev1.Pod) {
+				assert.Contains(t, pod.Spec.Containers[0].Env, corev1.EnvVar{
+					Name: "TF_VAR_db_password",
+					ValueFrom: &corev1.EnvVarSource{
+						SecretKeyRef: &corev1.SecretKeySelector{
+							LocalObjectReference: corev1.LocalObjectReference{Name: "plan-1-upstream-outputs"},
+							Key:                  "TF_VAR_db_password",
+						},
+					},
+				})
+			},
+			secretAssertions: func(t *testutil.T, secret *corev1.Secret) {
+				assert.Equal(t, "hunter2", string(secret.Data["TF_VAR_db_password"]))
+				assert.Equal(t, "plan-1", secret.OwnerReferences[0].Name)
+			},
+		},
+		{
+			name: "Upstream output not found",
+			run:  testobj.Run("operator-test", "plan-1", "plan", testobj.WithWorkspace("workspace-1")),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1", testobj.WithCombinedQueue("plan-1"), testobj.WithWorkspaceOutputVariable("vpc_id", "network", "vpc_id")),
+				testobj.Workspace("operator-test", "network"),
+			},
+			runAssertions: func(t *testutil.T, run *v1alpha1.Run) {
+				assert.Equal(t, v1alpha1.RunPhaseFailed, run.Phase)
+				assert.True(t, meta.IsStatusConditionTrue(run.Conditions, v1alpha1.RunFailedCondition))
+			},
+		},
 		{
 			name: "Secret found and environment variables source set",
 			run:  testobj.Run("operator-test", "plan-1", "plan", testobj.WithWorkspace("workspace-1")),
@@ -228,6 +471,67 @@ func TestRunReconciler(t *testing.T) {
 				assert.Equal(t, 5, *run.RunStatus.ExitCode)
 			},
 		},
+		{
+			name: "Cancel queued run",
+			run:  testobj.Run("operator-test", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), testobj.WithCancel()),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1", testobj.WithCombinedQueue("apply-0", "apply-1")),
+			},
+			runAssertions: func(t *testutil.T, run *v1alpha1.Run) {
+				assert.Equal(t, v1alpha1.RunPhaseCancelled, run.Phase)
+			},
+		},
+		{
+			name: "Cancel run with pending pod",
+			run:  testobj.Run("operator-test", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), testobj.WithCancel()),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1", testobj.WithCombinedQueue("apply-1")),
+				testobj.RunPod("operator-test", "apply-1", testobj.WithPhase(corev1.PodPending)),
+			},
+			runAssertions: func(t *testutil.T, run *v1alpha1.Run) {
+				assert.Equal(t, v1alpha1.RunPhaseCancelled, run.Phase)
+			},
+		},
+		{
+			name: "Cancel running run",
+			run:  testobj.Run("operator-test", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), testobj.WithCancel()),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1", testobj.WithCombinedQueue("apply-1")),
+				testobj.RunPod("operator-test", "apply-1"),
+			},
+			runAssertions: func(t *testutil.T, run *v1alpha1.Run) {
+				// Runner is responsible for interrupting the command
+				assert.Equal(t, v1alpha1.RunPhaseRunning, run.Phase)
+			},
+		},
+		{
+			name: "Run cancelled by runner",
+			run:  testobj.Run("operator-test", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), testobj.WithCancel()),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1", testobj.WithCombinedQueue("apply-1")),
+				testobj.RunPod("operator-test", "apply-1", testobj.WithPhase(corev1.PodFailed), testobj.WithRunnerExitCode(130), testobj.WithRunnerTerminationMessage("Cancelled")),
+			},
+			runAssertions: func(t *testutil.T, run *v1alpha1.Run) {
+				assert.Equal(t, v1alpha1.RunPhaseCancelled, run.Phase)
+				assert.Equal(t, 130, *run.RunStatus.ExitCode)
+			},
+		},
+		{
+			name: "Max duration exceeded",
+			run:  testobj.Run("operator-test", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), testobj.WithMaxDuration(time.Hour)),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1", testobj.WithCombinedQueue("apply-1")),
+				testobj.RunPod("operator-test", "apply-1", testobj.WithPhase(corev1.PodFailed), testobj.WithRunnerExitCode(130), testobj.WithRunnerTerminationMessage("MaxDurationExceeded")),
+			},
+			runAssertions: func(t *testutil.T, run *v1alpha1.Run) {
+				assert.Equal(t, v1alpha1.RunPhaseCancelled, run.Phase)
+				failed := meta.FindStatusCondition(run.Conditions, v1alpha1.RunFailedCondition)
+				if assert.NotNil(t, failed) {
+					assert.Equal(t, v1alpha1.MaxDurationExceededReason, failed.Reason)
+					assert.Equal(t, "Run exceeded maximum duration of 1h0m0s", failed.Message)
+				}
+			},
+		},
 		{
 			name: "Enqueue timeout exceeded",
 			run:  testobj.Run("operator-test", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), testobj.WithNotCompleteConditionForTimeout(v1alpha1.RunUnqueuedReason, time.Hour)),
@@ -262,6 +566,42 @@ func TestRunReconciler(t *testing.T) {
 				assert.Equal(t, v1alpha1.RunPhaseFailed, run.Phase)
 			},
 		},
+		{
+			name: "Record spec digest",
+			run:  testobj.Run("operator-test", "apply-1", "apply", testobj.WithWorkspace("workspace-1")),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1"),
+			},
+			runAssertions: func(t *testutil.T, run *v1alpha1.Run) {
+				digest, err := specDigest(run)
+				require.NoError(t, err)
+				assert.Equal(t, digest, run.SpecDigest)
+			},
+		},
+		{
+			name: "Spec modified after creation",
+			run: testobj.Run("operator-test", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), testobj.WithArgs("-target=null_resource.foo"),
+				withSpecDigestOf(testobj.Run("operator-test", "apply-1", "apply", testobj.WithWorkspace("workspace-1")))),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1", testobj.WithCombinedQueue("apply-1")),
+				testobj.RunPod("operator-test", "apply-1", testobj.WithPhase(corev1.PodPending)),
+			},
+			runAssertions: func(t *testutil.T, run *v1alpha1.Run) {
+				assert.Equal(t, v1alpha1.RunPhaseFailed, run.Phase)
+				assert.Equal(t, v1alpha1.SpecModifiedReason, meta.FindStatusCondition(run.Conditions, v1alpha1.RunFailedCondition).Reason)
+			},
+		},
+		{
+			name: "Cancel run and change its priority after creation",
+			run: testobj.Run("operator-test", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), testobj.WithCancel(), testobj.WithPriority(1),
+				withSpecDigestOf(testobj.Run("operator-test", "apply-1", "apply", testobj.WithWorkspace("workspace-1")))),
+			objs: []runtime.Object{
+				testobj.Workspace("operator-test", "workspace-1", testobj.WithCombinedQueue("apply-0", "apply-1")),
+			},
+			runAssertions: func(t *testutil.T, run *v1alpha1.Run) {
+				assert.Equal(t, v1alpha1.RunPhaseCancelled, run.Phase)
+			},
+		},
 	}
 	for _, tt := range tests {
 		testutil.Run(t, tt.name, func(t *testutil.T) {
@@ -298,6 +638,21 @@ func TestRunReconciler(t *testing.T) {
 
 				tt.configMapAssertions(t, &archive)
 			}
+
+			if tt.secretAssertions != nil {
+				var secret corev1.Secret
+				require.NoError(t, cl.Get(context.TODO(), types.NamespacedName{Namespace: tt.run.Namespace, Name: tt.run.UpstreamOutputsSecretName()}, &secret))
+
+				tt.secretAssertions(t, &secret)
+			}
 		})
 	}
 }
+
+// withSpecDigestOf sets the spec digest of a run to that of another run,
+// intended for faking the modification of a run's spec after its creation.
+func withSpecDigestOf(orig *v1alpha1.Run) func(*v1alpha1.Run) {
+	return func(run *v1alpha1.Run) {
+		run.SpecDigest, _ = specDigest(orig)
+	}
+}
@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// runTimeouts returns the timeouts for a run. A timeout set on the run takes
+// precedence over that set on its workspace, which in turn takes precedence
+// over the reconciler's default.
+func (r *RunReconciler) runTimeouts(run *v1alpha1.Run, ws *v1alpha1.Workspace) RunTimeouts {
+	return RunTimeouts{
+		Enqueue:    selectTimeout(r.timeouts.Enqueue, ws.Spec.RunTimeouts.Enqueue, run.Timeouts.Enqueue),
+		Queue:      selectTimeout(r.timeouts.Queue, ws.Spec.RunTimeouts.Queue, run.Timeouts.Queue),
+		PodPending: selectTimeout(r.timeouts.PodPending, ws.Spec.RunTimeouts.PodPending, run.Timeouts.PodPending),
+	}
+}
+
+// selectTimeout returns the last timeout that is set, falling back to the
+// default
+func selectTimeout(def time.Duration, overrides ...*metav1.Duration) time.Duration {
+	timeout := def
+	for _, o := range overrides {
+		if o != nil {
+			timeout = o.Duration
+		}
+	}
+	return timeout
+}
+
+// untilTimeout returns the duration until the run exceeds the timeout for its
+// current phase. Zero is returned if the current phase has no timeout.
+func (r *RunReconciler) untilTimeout(run *v1alpha1.Run, ws *v1alpha1.Workspace) time.Duration {
+	if run.IsDone() {
+		return 0
+	}
+
+	complete := meta.FindStatusCondition(run.Conditions, v1alpha1.RunCompleteCondition)
+	if complete == nil || complete.Status != metav1.ConditionFalse {
+		return 0
+	}
+
+	timeouts := r.runTimeouts(run, ws)
+
+	var timeout time.Duration
+	switch complete.Reason {
+	case v1alpha1.RunUnqueuedReason:
+		timeout = timeouts.Enqueue
+	case v1alpha1.RunQueuedReason:
+		timeout = timeouts.Queue
+	case v1alpha1.PodPendingReason:
+		timeout = timeouts.PodPending
+	default:
+		return 0
+	}
+
+	if until := complete.LastTransitionTime.Add(timeout).Sub(r.clock.Now()); until > 0 {
+		// Requeue just after the deadline, because the run only times out
+		// once the deadline has passed
+		return until + time.Second
+	}
+	return time.Second
+}
@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1alpha1 "github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/pkg/scheme"
+	"github.com/leg100/etok/pkg/testobj"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileRunTimeouts(t *testing.T) {
+	now := time.Date(2021, time.March, 3, 10, 30, 0, 0, time.UTC)
+
+	// Set the run's complete condition, with the given reason, as having
+	// transitioned the given duration ago
+	since := func(reason string, ago time.Duration) func(*v1alpha1.Run) {
+		return func(run *v1alpha1.Run) {
+			run.Conditions = append(run.Conditions, metav1.Condition{
+				Type:               v1alpha1.RunCompleteCondition,
+				Status:             metav1.ConditionFalse,
+				Reason:             reason,
+				LastTransitionTime: metav1.NewTime(now.Add(-ago)),
+			})
+		}
+	}
+	timeouts := func(enqueue, queue, podPending time.Duration) v1alpha1.RunTimeouts {
+		var t v1alpha1.RunTimeouts
+		if enqueue > 0 {
+			t.Enqueue = &metav1.Duration{Duration: enqueue}
+		}
+		if queue > 0 {
+			t.Queue = &metav1.Duration{Duration: queue}
+		}
+		if podPending > 0 {
+			t.PodPending = &metav1.Duration{Duration: podPending}
+		}
+		return t
+	}
+	withRunTimeouts := func(t v1alpha1.RunTimeouts) func(*v1alpha1.Run) {
+		return func(run *v1alpha1.Run) {
+			run.Timeouts = t
+		}
+	}
+	withWorkspaceRunTimeouts := func(t v1alpha1.RunTimeouts) func(*v1alpha1.Workspace) {
+		return func(ws *v1alpha1.Workspace) {
+			ws.Spec.RunTimeouts = t
+		}
+	}
+
+	tests := []struct {
+		name           string
+		run            *v1alpha1.Run
+		objs           []runtime.Object
+		defaults       *RunTimeouts
+		wantFailed     string
+		wantRequeue    time.Duration
+		reconcileError bool
+	}{
+		{
+			name: "Queued within default timeout",
+			run:  testobj.Run("default", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), since(v1alpha1.RunQueuedReason, 20*time.Minute)),
+			objs: []runtime.Object{
+				testobj.Workspace("default", "workspace-1", testobj.WithCombinedQueue("active-run-1", "apply-1")),
+			},
+			wantRequeue: 40*time.Minute + time.Second,
+		},
+		{
+			name: "Queue timeout exceeded",
+			run:  testobj.Run("default", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), since(v1alpha1.RunQueuedReason, 90*time.Minute)),
+			objs: []runtime.Object{
+				testobj.Workspace("default", "workspace-1", testobj.WithCombinedQueue("active-run-1", "apply-1")),
+			},
+			wantFailed:     "Timed out after 1h0m0s waiting in the queue",
+			reconcileError: true,
+		},
+		{
+			name: "Workspace queue timeout",
+			run:  testobj.Run("default", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), since(v1alpha1.RunQueuedReason, 90*time.Minute)),
+			objs: []runtime.Object{
+				testobj.Workspace("default", "workspace-1", testobj.WithCombinedQueue("active-run-1", "apply-1"), withWorkspaceRunTimeouts(timeouts(0, 3*time.Hour, 0))),
+			},
+			wantRequeue: 90*time.Minute + time.Second,
+		},
+		{
+			name: "Run queue timeout overrides workspace queue timeout",
+			run:  testobj.Run("default", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), since(v1alpha1.RunQueuedReason, 90*time.Minute), withRunTimeouts(timeouts(0, 80*time.Minute, 0))),
+			objs: []runtime.Object{
+				testobj.Workspace("default", "workspace-1", testobj.WithCombinedQueue("active-run-1", "apply-1"), withWorkspaceRunTimeouts(timeouts(0, 3*time.Hour, 0))),
+			},
+			wantFailed:     "Timed out after 1h20m0s waiting in the queue",
+			reconcileError: true,
+		},
+		{
+			name: "Default enqueue timeout",
+			run:  testobj.Run("default", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), since(v1alpha1.RunUnqueuedReason, 5*time.Second)),
+			objs: []runtime.Object{
+				testobj.Workspace("default", "workspace-1"),
+			},
+			defaults:    &RunTimeouts{Enqueue: 30 * time.Second, Queue: time.Hour, PodPending: time.Minute},
+			wantRequeue: 26 * time.Second,
+		},
+		{
+			name: "Enqueue timeout exceeded",
+			run:  testobj.Run("default", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), since(v1alpha1.RunUnqueuedReason, time.Minute)),
+			objs: []runtime.Object{
+				testobj.Workspace("default", "workspace-1"),
+			},
+			wantFailed:     "Timed out after 10s waiting to be enqueued",
+			reconcileError: true,
+		},
+		{
+			name: "Pod pending within workspace timeout",
+			run:  testobj.Run("default", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), since(v1alpha1.PodPendingReason, 2*time.Minute)),
+			objs: []runtime.Object{
+				testobj.Workspace("default", "workspace-1", testobj.WithCombinedQueue("apply-1"), withWorkspaceRunTimeouts(timeouts(0, 0, 10*time.Minute))),
+				testobj.RunPod("default", "apply-1", testobj.WithPhase(corev1.PodPending)),
+			},
+			wantRequeue: 8*time.Minute + time.Second,
+		},
+		{
+			name: "Pod pending timeout exceeded",
+			run:  testobj.Run("default", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), since(v1alpha1.PodPendingReason, 2*time.Minute)),
+			objs: []runtime.Object{
+				testobj.Workspace("default", "workspace-1", testobj.WithCombinedQueue("apply-1")),
+				testobj.RunPod("default", "apply-1", testobj.WithPhase(corev1.PodPending)),
+			},
+			wantFailed: "Timed out after 1m0s waiting for pod in pending phase",
+		},
+		{
+			name: "Running run has no timeout",
+			run:  testobj.Run("default", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), since(v1alpha1.PodRunningReason, 2*time.Minute)),
+			objs: []runtime.Object{
+				testobj.Workspace("default", "workspace-1", testobj.WithCombinedQueue("apply-1")),
+				testobj.RunPod("default", "apply-1", testobj.WithPhase(corev1.PodRunning)),
+			},
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			objs := append(tt.objs, runtime.Object(tt.run))
+			cl := fake.NewFakeClientWithScheme(scheme.Scheme, objs...)
+
+			opts := []RunReconcilerOption{WithRunClock(clock.NewFakeClock(now))}
+			if tt.defaults != nil {
+				opts = append(opts, WithDefaultRunTimeouts(*tt.defaults))
+			}
+
+			req := requestFromObject(tt.run)
+			result, err := NewRunReconciler(cl, "a.b.c/d:v1", opts...).Reconcile(context.Background(), req)
+			t.CheckError(tt.reconcileError, err)
+			assert.Equal(t, tt.wantRequeue, result.RequeueAfter)
+
+			var run v1alpha1.Run
+			require.NoError(t, cl.Get(context.Background(), req.NamespacedName, &run))
+
+			failed := meta.FindStatusCondition(run.Conditions, v1alpha1.RunFailedCondition)
+			if tt.wantFailed != "" {
+				if assert.NotNil(t, failed) {
+					assert.Equal(t, tt.wantFailed, failed.Message)
+				}
+			} else {
+				assert.Nil(t, failed)
+			}
+		})
+	}
+}
@@ -3,8 +3,11 @@ package controllers
 import (
 	"context"
 	"errors"
+	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/leg100/etok/api/etok.dev/v1alpha1"
 
@@ -18,6 +21,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -47,6 +51,12 @@ type WorkspaceReconciler struct {
 	Image          string
 	recorder       record.EventRecorder
 	BackupProvider backup.Provider
+
+	// Policy determining which backups to retain
+	retention backup.RetentionPolicy
+
+	// Clock against which drift checks are scheduled
+	clock clock.Clock
 }
 
 type WorkspaceReconcilerOption func(r *WorkspaceReconciler)
@@ -57,17 +67,30 @@ func WithBackupProvider(bp backup.Provider) WorkspaceReconcilerOption {
 	}
 }
 
+func WithBackupRetention(policy backup.RetentionPolicy) WorkspaceReconcilerOption {
+	return func(r *WorkspaceReconciler) {
+		r.retention = policy
+	}
+}
+
 func WithEventRecorder(recorder record.EventRecorder) WorkspaceReconcilerOption {
 	return func(r *WorkspaceReconciler) {
 		r.recorder = recorder
 	}
 }
 
+func WithClock(c clock.Clock) WorkspaceReconcilerOption {
+	return func(r *WorkspaceReconciler) {
+		r.clock = c
+	}
+}
+
 func NewWorkspaceReconciler(cl client.Client, image string, opts ...WorkspaceReconcilerOption) *WorkspaceReconciler {
 	r := &WorkspaceReconciler{
 		Client: cl,
 		Scheme: scheme.Scheme,
 		Image:  image,
+		clock:  clock.RealClock{},
 	}
 
 	for _, o := range opts {
@@ -82,8 +105,10 @@ func NewWorkspaceReconciler(cl client.Client, image string, opts ...WorkspaceRec
 	workspaceReconcileStatusChain = append(workspaceReconcileStatusChain, r.manageBuiltins)
 	workspaceReconcileStatusChain = append(workspaceReconcileStatusChain, r.manageRBACForNamespace)
 	workspaceReconcileStatusChain = append(workspaceReconcileStatusChain, r.manageState)
+	workspaceReconcileStatusChain = append(workspaceReconcileStatusChain, r.manageDependencies)
 	workspaceReconcileStatusChain = append(workspaceReconcileStatusChain, r.managePVC)
 	workspaceReconcileStatusChain = append(workspaceReconcileStatusChain, r.managePod)
+	workspaceReconcileStatusChain = append(workspaceReconcileStatusChain, r.manageDrift)
 
 	return r
 }
@@ -145,7 +170,7 @@ func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		}
 	}
 
-	// Prune approval annotations
+	// Prune approval and priority annotations
 	annotations, err := r.pruneApprovals(ctx, ws)
 	if err != nil {
 		return ctrl.Result{}, err
@@ -167,7 +192,8 @@ func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, backoff
+	// Requeue in time for the next drift check
+	return ctrl.Result{RequeueAfter: r.untilNextDriftCheck(&ws)}, backoff
 }
 
 // updateStatus actually calls the k8s API to update the workspace resource. To
@@ -238,12 +264,24 @@ func (r *WorkspaceReconciler) handleDeletion(ctx context.Context, ws *v1alpha1.W
 func (r *WorkspaceReconciler) manageState(ctx context.Context, ws *v1alpha1.Workspace) (bool, error) {
 	log := log.FromContext(ctx)
 
+	// Restore specific serial if user has requested it
+	if _, ok := ws.Annotations[v1alpha1.RestoreSerialAnnotationKey]; ok && r.BackupProvider != nil {
+		return r.restoreRequestedSerial(ctx, ws)
+	}
+
 	var secret corev1.Secret
 	err := r.Get(ctx, types.NamespacedName{Namespace: ws.Namespace, Name: ws.StateSecretName()}, &secret)
 	switch {
 	case kerrors.IsNotFound(err):
 		if r.BackupProvider != nil {
-			return r.restore(ctx, ws)
+			if id, ok := ws.Annotations[v1alpha1.BackupRequestedAnnotationKey]; ok {
+				r.recorder.Eventf(ws, "Warning", "BackupError", "There is no state to backup")
+				ws.Status.LastBackupRequest = &v1alpha1.BackupRequest{ID: id, Error: "there is no state to backup"}
+				if err := r.removeAnnotation(ctx, ws, v1alpha1.BackupRequestedAnnotationKey); err != nil {
+					return false, err
+				}
+			}
+			return r.restore(ctx, ws, nil)
 		}
 	case err != nil:
 		log.Error(err, "unable to get state secret")
@@ -269,32 +307,113 @@ func (r *WorkspaceReconciler) manageState(ctx context.Context, ws *v1alpha1.Work
 		ws.Status.Serial = &state.Serial
 
 		// Persist outputs from state file to workspace status
-		var outputs []*v1alpha1.Output
-		for k, v := range state.Outputs {
-			outputs = append(outputs, &v1alpha1.Output{Key: k, Value: v.Value})
+		outputs, err := state.statusOutputs()
+		if err != nil {
+			return false, err
 		}
 		if !reflect.DeepEqual(ws.Status.Outputs, outputs) {
 			ws.Status.Outputs = outputs
 		}
 
-		// Determine if backup should be made
-		if r.BackupProvider != nil && !ws.Spec.Ephemeral {
-			// Backup if current backup serial doesn't match serial of state
-			if ws.Status.BackupSerial == nil || *ws.Status.BackupSerial != state.Serial {
-				if err := r.BackupProvider.Backup(ctx, &secret); err != nil {
-					return r.sendWarningEvent(err, ws, "BackupError")
-				}
+		// Project outputs into secrets and config maps
+		if err := r.projectOutputs(ctx, ws, state); err != nil {
+			return false, err
+		}
 
-				ws.Status.BackupSerial = &state.Serial
+		if r.BackupProvider == nil {
+			return false, nil
+		}
 
-				r.recorder.Eventf(ws, "Normal", "BackupSuccessful", "Backed up state #%d", state.Serial)
+		// Backup if user has requested it
+		if id, ok := ws.Annotations[v1alpha1.BackupRequestedAnnotationKey]; ok {
+			// Record the outcome of the request so that the requester can
+			// distinguish it from that of earlier requests
+			bail, err := r.backup(ctx, ws, &secret, state.Serial)
+			ws.Status.LastBackupRequest = &v1alpha1.BackupRequest{ID: id}
+			if err != nil {
+				ws.Status.LastBackupRequest.Error = err.Error()
 			}
+			if err := r.removeAnnotation(ctx, ws, v1alpha1.BackupRequestedAnnotationKey); err != nil {
+				return false, err
+			}
+			return bail, err
+		}
+
+		// Otherwise backup if current backup serial doesn't match serial of
+		// state
+		if !ws.Spec.Ephemeral && (ws.Status.BackupSerial == nil || *ws.Status.BackupSerial != state.Serial) {
+			return r.backup(ctx, ws, &secret, state.Serial)
+		}
+
+		// Ensure available backups are recorded in status, i.e. for workspaces
+		// backed up prior to their being recorded.
+		if ws.Status.BackupSerial != nil && ws.Status.Backups == nil {
+			return r.listBackups(ctx, ws)
 		}
 	}
 
 	return false, nil
 }
 
+// backup backs up the state secret, deletes backups that are no longer to be
+// retained, and records the remaining backups in the workspace status
+func (r *WorkspaceReconciler) backup(ctx context.Context, ws *v1alpha1.Workspace, secret *corev1.Secret, serial int) (bool, error) {
+	if err := r.BackupProvider.Backup(ctx, secret, serial); err != nil {
+		backupsTotal.WithLabelValues(resultFailure).Inc()
+		return r.sendWarningEvent(err, ws, "BackupError")
+	}
+	backupsTotal.WithLabelValues(resultSuccess).Inc()
+
+	ws.Status.BackupSerial = &serial
+
+	r.recorder.Eventf(ws, "Normal", "BackupSuccessful", "Backed up state #%d", serial)
+
+	// Delete backups that are no longer to be retained
+	pruned, err := r.retention.Prune(ctx, r.BackupProvider, client.ObjectKeyFromObject(secret), time.Now())
+	if err != nil {
+		return r.sendWarningEvent(err, ws, "PruneError")
+	}
+	if len(pruned) > 0 {
+		r.recorder.Eventf(ws, "Normal", "PruneSuccessful", "Pruned %d backup(s) of state", len(pruned))
+	}
+
+	return r.listBackups(ctx, ws)
+}
+
+// listBackups records the available backups of the state in the workspace
+// status
+func (r *WorkspaceReconciler) listBackups(ctx context.Context, ws *v1alpha1.Workspace) (bool, error) {
+	versions, err := r.BackupProvider.List(ctx, types.NamespacedName{Namespace: ws.Namespace, Name: ws.StateSecretName()})
+	if err != nil {
+		return r.sendWarningEvent(err, ws, "ListBackupsError")
+	}
+
+	var backups []*v1alpha1.Backup
+	for _, v := range versions {
+		backups = append(backups, &v1alpha1.Backup{Serial: v.Serial, Created: metav1.NewTime(v.Created)})
+	}
+	ws.Status.Backups = backups
+
+	return false, nil
+}
+
+// removeAnnotation removes the annotation with the given key from the workspace
+func (r *WorkspaceReconciler) removeAnnotation(ctx context.Context, ws *v1alpha1.Workspace, keys ...string) error {
+	// Updating the workspace overwrites its status with the status currently
+	// persisted, so take a copy in order to reinstate the new status afterwards
+	status := ws.Status.DeepCopy()
+
+	for _, k := range keys {
+		delete(ws.Annotations, k)
+	}
+	if err := r.Update(ctx, ws); err != nil {
+		return err
+	}
+
+	ws.Status = *status
+	return nil
+}
+
 func (r *WorkspaceReconciler) addFinalizers(ctx context.Context, ws v1alpha1.Workspace) (v1alpha1.Workspace, error) {
 	// Set garbage collection to use foreground deletion in the event the
 	// workspace is deleted
@@ -304,8 +423,8 @@ func (r *WorkspaceReconciler) addFinalizers(ctx context.Context, ws v1alpha1.Wor
 	return ws, nil
 }
 
-// Prune invalid approval annotations. Invalid approvals are those that belong
-// to runs which are either completed or no longer exist.
+// Prune invalid approval and priority annotations. Invalid annotations are
+// those that belong to runs which are either completed or no longer exist.
 func (r *WorkspaceReconciler) pruneApprovals(ctx context.Context, ws v1alpha1.Workspace) (map[string]string, error) {
 	if ws.Annotations == nil {
 		// Nothing to prune
@@ -315,8 +434,8 @@ func (r *WorkspaceReconciler) pruneApprovals(ctx context.Context, ws v1alpha1.Wo
 	annotations := makeCopyOfMap(ws.Annotations)
 
 	for k := range annotations {
-		if !strings.HasPrefix(k, v1alpha1.ApprovedAnnotationKeyPrefix) {
-			// Skip non-approval annotations
+		if !strings.HasPrefix(k, v1alpha1.ApprovedAnnotationKeyPrefix) && !strings.HasPrefix(k, v1alpha1.PriorityAnnotationKeyPrefix) {
+			// Skip non-approval and non-priority annotations
 			continue
 		}
 
@@ -339,13 +458,56 @@ func (r *WorkspaceReconciler) pruneApprovals(ctx context.Context, ws v1alpha1.Wo
 	return annotations, nil
 }
 
-func (r *WorkspaceReconciler) restore(ctx context.Context, ws *v1alpha1.Workspace) (bool, error) {
+// restoreRequestedSerial restores the state with the serial number requested
+// via an annotation on the workspace, records the outcome of the request, and
+// then removes the annotations with which the request was made.
+func (r *WorkspaceReconciler) restoreRequestedSerial(ctx context.Context, ws *v1alpha1.Workspace) (bool, error) {
+	value := ws.Annotations[v1alpha1.RestoreSerialAnnotationKey]
+
+	// Record the outcome of the request so that the requester can distinguish
+	// it from that of earlier requests
+	outcome := &v1alpha1.RestoreRequest{ID: ws.Annotations[v1alpha1.RestoreRequestIDAnnotationKey]}
+
+	serial, err := strconv.Atoi(value)
+	if err != nil {
+		r.recorder.Eventf(ws, "Warning", "RestoreError", "Invalid serial number: %s", value)
+		outcome.Error = fmt.Sprintf("invalid serial number: %s", value)
+	} else if _, err := r.restore(ctx, ws, &serial); err != nil {
+		if !errors.Is(err, errBackupNotFound) {
+			// Leave request in place to be retried
+			return false, err
+		}
+		outcome.Error = err.Error()
+	}
+	outcome.Serial = serial
+	ws.Status.LastRestoreRequest = outcome
+
+	return false, r.removeAnnotation(ctx, ws, v1alpha1.RestoreSerialAnnotationKey, v1alpha1.RestoreRequestIDAnnotationKey)
+}
+
+var errBackupNotFound = errors.New("backup not found")
+
+// restore restores the state secret from backup. If serial is nil then the
+// latest backup is restored, otherwise the backup with the given serial is
+// restored. An existing state secret is overwritten.
+func (r *WorkspaceReconciler) restore(ctx context.Context, ws *v1alpha1.Workspace, serial *int) (bool, error) {
 	secretKey := types.NamespacedName{Namespace: ws.Namespace, Name: ws.StateSecretName()}
-	secret, err := r.BackupProvider.Restore(ctx, secretKey)
+
+	var secret *corev1.Secret
+	var err error
+	if serial != nil {
+		secret, err = r.BackupProvider.RestoreSerial(ctx, secretKey, *serial)
+	} else {
+		secret, err = r.BackupProvider.Restore(ctx, secretKey)
+	}
 	if err != nil {
+		restoresTotal.WithLabelValues(resultFailure).Inc()
 		return r.sendWarningEvent(err, ws, "RestoreError")
 	}
 	if secret == nil {
+		if serial != nil {
+			return r.sendWarningEvent(fmt.Errorf("%w: there is no state #%d to restore", errBackupNotFound, *serial), ws, "RestoreError")
+		}
 		r.recorder.Eventf(ws, "Normal", "RestoreSkipped", "There is no state to restore")
 		return false, nil
 	}
@@ -354,22 +516,44 @@ func (r *WorkspaceReconciler) restore(ctx context.Context, ws *v1alpha1.Workspac
 	secret.ResourceVersion = ""
 	secret.OwnerReferences = nil
 
-	if err := r.Create(ctx, secret); err != nil {
+	var existing corev1.Secret
+	err = r.Get(ctx, secretKey, &existing)
+	switch {
+	case kerrors.IsNotFound(err):
+		if err := r.Create(ctx, secret); err != nil {
+			return false, err
+		}
+	case err != nil:
 		return false, err
+	default:
+		// Overwrite existing state
+		existing.Data = secret.Data
+		if err := r.Update(ctx, &existing); err != nil {
+			return false, err
+		}
 	}
 
 	// Parse state file
 	state, err := readState(ctx, secret)
 	if err != nil {
+		restoresTotal.WithLabelValues(resultFailure).Inc()
 		return r.sendWarningEvent(err, ws, "RestoreError")
 	}
+	restoresTotal.WithLabelValues(resultSuccess).Inc()
+
+	r.recorder.Eventf(ws, "Normal", "RestoreSuccessful", "Restored state #%d", state.Serial)
+
+	if serial != nil {
+		// Back up the restored state afresh, making it the latest backup, so
+		// that it, rather than the abandoned history that followed it, is
+		// restored should the state be lost
+		return r.backup(ctx, ws, secret, state.Serial)
+	}
 
 	// Record in status that a backup with the given serial number exists.
 	ws.Status.BackupSerial = &state.Serial
 
-	r.recorder.Eventf(ws, "Normal", "RestoreSuccessful", "Restored state #%d", state.Serial)
-
-	return false, nil
+	return r.listBackups(ctx, ws)
 }
 
 // Send warning event as well as propagating error to caller
@@ -595,5 +779,9 @@ func (r *WorkspaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		return []ctrl.Request{}
 	}))
 
+	// Watch for changes to workspaces and requeue the workspaces whose variables
+	// reference their outputs
+	blder = blder.Watches(&source.Kind{Type: &v1alpha1.Workspace{}}, handler.EnqueueRequestsFromMapFunc(r.dependents))
+
 	return blder.Complete(r)
 }
@@ -11,6 +11,7 @@ import (
 
 const (
 	InstallerContainerName = "installer"
+	idlerContainerName     = "idler"
 	idlerCommand           = "trap \"exit 0\" SIGTERM; while true; do sleep 1; done"
 )
 
@@ -20,7 +21,9 @@ const (
 // reasons: it keeps a persistent volume attached to the kubernetes node, which
 // means when a run spins up a pod the volume can be mounted more quickly (that
 // does mean however that a run pod can only be scheduled to the same node as
-// the workspace pod...).
+// the workspace pod...). The idler also mounts the plan files saved by runs,
+// so that the plan file of a deleted run can be deleted by executing a command
+// on it.
 func workspacePod(ws *v1alpha1.Workspace, image string) (*corev1.Pod, error) {
 	script := new(bytes.Buffer)
 	if err := generateScript(script, ws); err != nil {
@@ -35,11 +38,18 @@ func workspacePod(ws *v1alpha1.Workspace, image string) (*corev1.Pod, error) {
 		Spec: corev1.PodSpec{
 			Containers: []corev1.Container{
 				{
-					Name:                     "idler",
+					Name:                     idlerContainerName,
 					Image:                    image,
 					ImagePullPolicy:          corev1.PullIfNotPresent,
 					Command:                  []string{"sh", "-c", idlerCommand},
 					TerminationMessagePolicy: "FallbackToLogsOnError",
+					VolumeMounts: []corev1.VolumeMount{
+						{
+							Name:      "cache",
+							MountPath: PlansMountPath,
+							SubPath:   plansSubPath,
+						},
+					},
 				},
 			},
 			InitContainers: []corev1.Container{
@@ -79,5 +89,8 @@ func workspacePod(ws *v1alpha1.Workspace, image string) (*corev1.Pod, error) {
 	// Permit filtering resources by component
 	labels.SetLabel(pod, labels.WorkspaceComponent)
 
+	// Apply workspace's overrides
+	applyPodTemplate(pod, ws.Spec.PodTemplate)
+
 	return pod, nil
 }
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/pkg/testobj"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestWorkspacePod(t *testing.T) {
+	tests := []struct {
+		name       string
+		workspace  *v1alpha1.Workspace
+		assertions func(*corev1.Pod)
+	}{
+		{
+			name:      "Defaults",
+			workspace: testobj.Workspace("default", "foo"),
+			assertions: func(pod *corev1.Pod) {
+				assert.Equal(t, "", pod.Spec.ServiceAccountName)
+				assert.Equal(t, "etok:latest", pod.Spec.Containers[0].Image)
+				assert.Equal(t, "etok:latest", pod.Spec.InitContainers[0].Image)
+				assert.Nil(t, pod.Spec.SecurityContext)
+			},
+		},
+		{
+			name: "Pod template scheduling overrides",
+			workspace: testobj.Workspace("default", "foo", testobj.WithPodTemplate(&v1alpha1.PodTemplate{
+				NodeSelector: map[string]string{"pool": "terraform"},
+				Tolerations:  []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}},
+				Affinity: &corev1.Affinity{
+					NodeAffinity: &corev1.NodeAffinity{},
+				},
+			})),
+			assertions: func(pod *corev1.Pod) {
+				assert.Equal(t, map[string]string{"pool": "terraform"}, pod.Spec.NodeSelector)
+				assert.Equal(t, []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}}, pod.Spec.Tolerations)
+				assert.Equal(t, &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{}}, pod.Spec.Affinity)
+			},
+		},
+		{
+			name: "Pod template service account",
+			workspace: testobj.Workspace("default", "foo", testobj.WithPodTemplate(&v1alpha1.PodTemplate{
+				ServiceAccountName: "terraform",
+			})),
+			assertions: func(pod *corev1.Pod) {
+				assert.Equal(t, "terraform", pod.Spec.ServiceAccountName)
+			},
+		},
+		{
+			name: "Pod template image",
+			workspace: testobj.Workspace("default", "foo", testobj.WithPodTemplate(&v1alpha1.PodTemplate{
+				Containers: []v1alpha1.ContainerTemplate{
+					{Image: "registry.example.com/etok:latest"},
+				},
+			})),
+			assertions: func(pod *corev1.Pod) {
+				assert.Equal(t, "registry.example.com/etok:latest", pod.Spec.Containers[0].Image)
+				assert.Equal(t, "registry.example.com/etok:latest", pod.Spec.InitContainers[0].Image)
+			},
+		},
+		{
+			name: "Pod template named container overrides",
+			workspace: testobj.Workspace("default", "foo", testobj.WithPodTemplate(&v1alpha1.PodTemplate{
+				Containers: []v1alpha1.ContainerTemplate{
+					{
+						Name:      "installer",
+						Resources: &corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")}},
+					},
+					{
+						Name:      "idler",
+						Resources: &corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("10m")}},
+					},
+					{
+						Name:      "runner",
+						Resources: &corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}},
+					},
+				},
+			})),
+			assertions: func(pod *corev1.Pod) {
+				assert.Equal(t, resource.MustParse("100m"), pod.Spec.InitContainers[0].Resources.Requests[corev1.ResourceCPU])
+				assert.Equal(t, resource.MustParse("10m"), pod.Spec.Containers[0].Resources.Requests[corev1.ResourceCPU])
+			},
+		},
+		{
+			name: "Pod template security contexts",
+			workspace: testobj.Workspace("default", "foo", testobj.WithPodTemplate(&v1alpha1.PodTemplate{
+				SecurityContext: &corev1.PodSecurityContext{RunAsNonRoot: &trueValue},
+				Containers: []v1alpha1.ContainerTemplate{
+					{SecurityContext: &corev1.SecurityContext{AllowPrivilegeEscalation: &falseValue}},
+				},
+			})),
+			assertions: func(pod *corev1.Pod) {
+				assert.Equal(t, &trueValue, pod.Spec.SecurityContext.RunAsNonRoot)
+				assert.Equal(t, &falseValue, pod.Spec.Containers[0].SecurityContext.AllowPrivilegeEscalation)
+				assert.Equal(t, &falseValue, pod.Spec.InitContainers[0].SecurityContext.AllowPrivilegeEscalation)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod, err := workspacePod(tt.workspace, "etok:latest")
+			require.NoError(t, err)
+			tt.assertions(pod)
+		})
+	}
+}
@@ -3,6 +3,7 @@ package controllers
 import (
 	"context"
 	"testing"
+	"time"
 
 	"cloud.google.com/go/storage"
 
@@ -31,12 +32,13 @@ func TestReconcileWorkspace(t *testing.T) {
 		name                string
 		workspace           *v1alpha1.Workspace
 		objs                []runtime.Object
-		bucketObjs          []*corev1.Secret
+		bucketObjs          []*backup.FakeObj
+		retention           backup.RetentionPolicy
 		workspaceAssertions func(*testutil.T, *v1alpha1.Workspace)
 		podAssertions       func(*testutil.T, *corev1.Pod)
 		pvcAssertions       func(*testutil.T, *corev1.PersistentVolumeClaim)
 		configMapAssertions func(*testutil.T, *corev1.ConfigMap)
-		backupAssertions    func(*testutil.T, []*corev1.Secret)
+		backupAssertions    func(*testutil.T, []*backup.FakeObj)
 		stateAssertions     func(*testutil.T, *corev1.Secret)
 		storageAssertions   func(*testutil.T, *storage.Client)
 		rbacAssertions      func(*testutil.T, *v1alpha1.Workspace, *rbacv1.Role, *rbacv1.RoleBinding, *corev1.ServiceAccount)
@@ -100,6 +102,19 @@ func TestReconcileWorkspace(t *testing.T) {
 				assert.Equal(t, want, ws.Annotations)
 			},
 		},
+		{
+			name:      "Pruned priority annotation for completed run",
+			workspace: testobj.Workspace("priorities", "workspace-1", testobj.WithPriorityGrant("apply-1", 1), testobj.WithPriorityGrant("apply-2", 1)),
+			objs: []runtime.Object{
+				testobj.WorkspacePod("priorities", "workspace-1"),
+				testobj.Run("priorities", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), testobj.WithRunPhase(v1alpha1.RunPhaseCompleted)),
+				testobj.Run("priorities", "apply-2", "apply", testobj.WithWorkspace("workspace-1"), testobj.WithPriority(1)),
+			},
+			workspaceAssertions: func(t *testutil.T, ws *v1alpha1.Workspace) {
+				want := map[string]string{"priorities.etok.dev/apply-2": "1"}
+				assert.Equal(t, want, ws.Annotations)
+			},
+		},
 		{
 			name:      "Initializing phase",
 			workspace: testobj.Workspace("", "workspace-1"),
@@ -219,10 +234,27 @@ func TestReconcileWorkspace(t *testing.T) {
 					{
 						Key:   "random_string",
 						Value: "f584-default-foo-foo",
+						Type:  "string",
 					},
 				}, ws.Status.Outputs)
 			},
 		},
+		{
+			name:      "Typed and sensitive outputs",
+			workspace: testobj.Workspace("", "workspace-1"),
+			objs: []runtime.Object{
+				testobj.WorkspacePod("", "workspace-1", testobj.WithPhase(corev1.PodRunning)),
+				testobj.Secret("", "tfstate-default-workspace-1", testobj.WithCompressedDataFromFile("tfstate", "testdata/tfstate_typed.json")),
+			},
+			workspaceAssertions: func(t *testutil.T, ws *v1alpha1.Workspace) {
+				assert.Equal(t, []*v1alpha1.Output{
+					{Key: "db_password", Type: "string", Sensitive: true},
+					{Key: "endpoint", Value: "db.example.com", Type: "string"},
+					{Key: "port", Value: "5432", Type: "number"},
+					{Key: "replicas", Value: `["replica-1","replica-2"]`, Type: `["list","string"]`},
+				}, ws.Status.Outputs)
+			},
+		},
 		{
 			// Demonstrate that the state secret is backed up into (fake) backup
 			// provider, and that the backup serial status gets updated
@@ -231,10 +263,10 @@ func TestReconcileWorkspace(t *testing.T) {
 			objs: []runtime.Object{
 				testobj.Secret("default", "tfstate-default-workspace-1", testobj.WithCompressedDataFromFile("tfstate", "testdata/tfstate.json")),
 			},
-			backupAssertions: func(t *testutil.T, stateFiles []*corev1.Secret) {
+			backupAssertions: func(t *testutil.T, stateFiles []*backup.FakeObj) {
 				wantKey := types.NamespacedName{Namespace: "default", Name: "tfstate-default-workspace-1"}
 				for _, s := range stateFiles {
-					if client.ObjectKeyFromObject(s) == wantKey {
+					if client.ObjectKeyFromObject(s.Secret) == wantKey && s.Serial == 4 {
 						return
 					}
 				}
@@ -242,6 +274,44 @@ func TestReconcileWorkspace(t *testing.T) {
 			},
 			workspaceAssertions: func(t *testutil.T, ws *v1alpha1.Workspace) {
 				assert.Equal(t, 4, *ws.Status.BackupSerial)
+				if assert.Equal(t, 1, len(ws.Status.Backups)) {
+					assert.Equal(t, 4, ws.Status.Backups[0].Serial)
+				}
+			},
+		},
+		{
+			// Demonstrate that a backup is made upon request even though the
+			// current serial has already been backed up, and that the request
+			// annotation is removed
+			name:      "Backup requested",
+			workspace: testobj.Workspace("default", "workspace-1", testobj.WithAnnotations(v1alpha1.BackupRequestedAnnotationKey, "req-1"), testobj.WithBackupSerial(4)),
+			objs: []runtime.Object{
+				testobj.Secret("default", "tfstate-default-workspace-1", testobj.WithCompressedDataFromFile("tfstate", "testdata/tfstate.json")),
+			},
+			backupAssertions: func(t *testutil.T, stateFiles []*backup.FakeObj) {
+				assert.Equal(t, 1, len(stateFiles))
+			},
+			workspaceAssertions: func(t *testutil.T, ws *v1alpha1.Workspace) {
+				assert.Equal(t, 4, *ws.Status.BackupSerial)
+				if assert.Equal(t, 1, len(ws.Status.Backups)) {
+					assert.Equal(t, 4, ws.Status.Backups[0].Serial)
+				}
+				assert.Equal(t, &v1alpha1.BackupRequest{ID: "req-1"}, ws.Status.LastBackupRequest)
+				assert.NotContains(t, ws.Annotations, v1alpha1.BackupRequestedAnnotationKey)
+			},
+		},
+		{
+			// Demonstrate that a backup request is removed when there is no
+			// state to backup
+			name:      "Backup requested without state",
+			workspace: testobj.Workspace("default", "workspace-1", testobj.WithAnnotations(v1alpha1.BackupRequestedAnnotationKey, "req-1")),
+			backupAssertions: func(t *testutil.T, stateFiles []*backup.FakeObj) {
+				assert.Equal(t, 0, len(stateFiles))
+			},
+			workspaceAssertions: func(t *testutil.T, ws *v1alpha1.Workspace) {
+				assert.Nil(t, ws.Status.BackupSerial)
+				assert.Equal(t, &v1alpha1.BackupRequest{ID: "req-1", Error: "there is no state to backup"}, ws.Status.LastBackupRequest)
+				assert.NotContains(t, ws.Annotations, v1alpha1.BackupRequestedAnnotationKey)
 			},
 		},
 		{
@@ -249,8 +319,11 @@ func TestReconcileWorkspace(t *testing.T) {
 			// backup serial status gets updated
 			name:      "Restore",
 			workspace: testobj.Workspace("default", "workspace-1"),
-			bucketObjs: []*corev1.Secret{
-				testobj.Secret("default", "tfstate-default-workspace-1", testobj.WithCompressedDataFromFile("tfstate", "testdata/tfstate.json")),
+			bucketObjs: []*backup.FakeObj{
+				{
+					Secret: testobj.Secret("default", "tfstate-default-workspace-1", testobj.WithCompressedDataFromFile("tfstate", "testdata/tfstate.json")),
+					Serial: 4,
+				},
 			},
 			stateAssertions: func(t *testutil.T, state *corev1.Secret) {
 				// Empty func causes test to check for existance of state secret
@@ -260,6 +333,77 @@ func TestReconcileWorkspace(t *testing.T) {
 				assert.Equal(t, 4, *ws.Status.BackupSerial)
 			},
 		},
+		{
+			// Demonstrate that backups are pruned according to the retention
+			// policy
+			name:      "Prune backups",
+			workspace: testobj.Workspace("default", "workspace-1"),
+			objs: []runtime.Object{
+				testobj.Secret("default", "tfstate-default-workspace-1", testobj.WithCompressedDataFromFile("tfstate", "testdata/tfstate.json")),
+			},
+			bucketObjs: []*backup.FakeObj{
+				{Secret: testobj.Secret("default", "tfstate-default-workspace-1"), Serial: 1},
+				{Secret: testobj.Secret("default", "tfstate-default-workspace-1"), Serial: 2},
+				{Secret: testobj.Secret("default", "tfstate-default-workspace-1"), Serial: 3},
+			},
+			retention: backup.RetentionPolicy{KeepLast: 2},
+			backupAssertions: func(t *testutil.T, stateFiles []*backup.FakeObj) {
+				var serials []int
+				for _, s := range stateFiles {
+					serials = append(serials, s.Serial)
+				}
+				assert.ElementsMatch(t, []int{3, 4}, serials)
+			},
+		},
+		{
+			// Demonstrate that a requested serial is restored over the
+			// existing state, and that the request annotation is removed
+			name:      "Restore requested serial",
+			workspace: testobj.Workspace("default", "workspace-1", testobj.WithAnnotations(v1alpha1.RestoreSerialAnnotationKey, "4", v1alpha1.RestoreRequestIDAnnotationKey, "req-1"), testobj.WithBackupSerial(9)),
+			objs: []runtime.Object{
+				testobj.Secret("default", "tfstate-default-workspace-1"),
+			},
+			bucketObjs: []*backup.FakeObj{
+				{
+					Secret: testobj.Secret("default", "tfstate-default-workspace-1", testobj.WithCompressedDataFromFile("tfstate", "testdata/tfstate.json")),
+					Serial: 4,
+				},
+				{
+					Secret:  testobj.Secret("default", "tfstate-default-workspace-1"),
+					Serial:  9,
+					Created: time.Now().Add(-time.Hour),
+				},
+			},
+			stateAssertions: func(t *testutil.T, state *corev1.Secret) {
+				tfstate, err := readState(context.Background(), state)
+				require.NoError(t, err)
+				assert.Equal(t, 4, tfstate.Serial)
+			},
+			workspaceAssertions: func(t *testutil.T, ws *v1alpha1.Workspace) {
+				assert.Equal(t, 4, *ws.Status.BackupSerial)
+				assert.Equal(t, &v1alpha1.RestoreRequest{ID: "req-1", Serial: 4}, ws.Status.LastRestoreRequest)
+				assert.NotContains(t, ws.Annotations, v1alpha1.RestoreSerialAnnotationKey)
+				assert.NotContains(t, ws.Annotations, v1alpha1.RestoreRequestIDAnnotationKey)
+			},
+			backupAssertions: func(t *testutil.T, objs []*backup.FakeObj) {
+				// The restored state is backed up afresh, superseding the
+				// abandoned history as the latest backup
+				require.Equal(t, 3, len(objs))
+				assert.Equal(t, 4, objs[2].Serial)
+			},
+		},
+		{
+			// Demonstrate that a request to restore a non-existent serial is
+			// removed
+			name:      "Restore non-existent serial",
+			workspace: testobj.Workspace("default", "workspace-1", testobj.WithAnnotations(v1alpha1.RestoreSerialAnnotationKey, "7", v1alpha1.RestoreRequestIDAnnotationKey, "req-1")),
+			workspaceAssertions: func(t *testutil.T, ws *v1alpha1.Workspace) {
+				assert.Nil(t, ws.Status.BackupSerial)
+				assert.Equal(t, "req-1", ws.Status.LastRestoreRequest.ID)
+				assert.Equal(t, "backup not found: there is no state #7 to restore", ws.Status.LastRestoreRequest.Error)
+				assert.NotContains(t, ws.Annotations, v1alpha1.RestoreSerialAnnotationKey)
+			},
+		},
 		{
 			// Demonstrate that an ephemeral workspace's state does *not* get
 			// backed up.
@@ -268,7 +412,7 @@ func TestReconcileWorkspace(t *testing.T) {
 			objs: []runtime.Object{
 				testobj.Secret("default", "tfstate-default-workspace-1", testobj.WithCompressedDataFromFile("tfstate", "testdata/tfstate.json")),
 			},
-			backupAssertions: func(t *testutil.T, stateFiles []*corev1.Secret) {
+			backupAssertions: func(t *testutil.T, stateFiles []*backup.FakeObj) {
 				assert.Equal(t, 0, len(stateFiles))
 			},
 			workspaceAssertions: func(t *testutil.T, ws *v1alpha1.Workspace) {
@@ -310,7 +454,7 @@ func TestReconcileWorkspace(t *testing.T) {
 			backupProvider := backup.FakeProvider{BucketObjs: tt.bucketObjs}
 
 			// Reconcile
-			r := NewWorkspaceReconciler(cl, "", WithBackupProvider(&backupProvider), WithEventRecorder(record.NewFakeRecorder(100)))
+			r := NewWorkspaceReconciler(cl, "", WithBackupProvider(&backupProvider), WithBackupRetention(tt.retention), WithEventRecorder(record.NewFakeRecorder(100)))
 			req := requestFromObject(tt.workspace)
 			_, err := r.Reconcile(context.Background(), req)
 			if tt.wantErr {
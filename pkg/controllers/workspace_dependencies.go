@@ -0,0 +1,300 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/pkg/builders"
+	"github.com/leg100/etok/pkg/labels"
+	"github.com/leg100/etok/pkg/util"
+	"github.com/leg100/etok/pkg/util/slice"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	errUpstreamNotFound       = errors.New("upstream workspace not found")
+	errUpstreamOutputNotFound = errors.New("upstream output not found")
+)
+
+// manageDependencies tracks the workspaces upon whose outputs the workspace's
+// variables depend, recording whether their outputs can be resolved as the
+// DependenciesReady condition. Should a referenced output change, a run is
+// created if the workspace requests one.
+func (r *WorkspaceReconciler) manageDependencies(ctx context.Context, ws *v1alpha1.Workspace) (bool, error) {
+	ws.Status.Dependencies = ws.Upstreams()
+	if len(ws.Status.Dependencies) == 0 {
+		meta.RemoveStatusCondition(&ws.Status.Conditions, v1alpha1.WorkspaceDependenciesReadyCondition)
+		ws.Status.UpstreamOutputsDigest = ""
+		return false, nil
+	}
+
+	cycle, err := findCycle(ctx, r.Client, ws)
+	if err != nil {
+		return false, err
+	}
+	if cycle != nil {
+		r.dependenciesNotReady(ws, v1alpha1.DependencyCycleReason, fmt.Sprintf("Dependency cycle: %s", strings.Join(cycle, " -> ")))
+		return false, nil
+	}
+
+	resolved, _, err := resolveVariables(ctx, r.Client, ws)
+	switch {
+	case errors.Is(err, errUpstreamNotFound):
+		r.dependenciesNotReady(ws, v1alpha1.UpstreamNotFoundReason, err.Error())
+		return false, nil
+	case errors.Is(err, errUpstreamOutputNotFound):
+		r.dependenciesNotReady(ws, v1alpha1.UpstreamOutputNotFoundReason, err.Error())
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+
+	meta.SetStatusCondition(&ws.Status.Conditions, metav1.Condition{
+		Type:    v1alpha1.WorkspaceDependenciesReadyCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  v1alpha1.DependenciesResolvedReason,
+		Message: fmt.Sprintf("Resolved outputs of %s", strings.Join(ws.Status.Dependencies, ", ")),
+	})
+
+	digest := upstreamOutputsDigest(ws, resolved)
+	if digest == ws.Status.UpstreamOutputsDigest {
+		return false, nil
+	}
+
+	// Only trigger a run upon a change, and not when the outputs are first
+	// resolved
+	if ws.Status.UpstreamOutputsDigest != "" && ws.Spec.OnUpstreamChange != "" {
+		if ws.Spec.VCS.Repository == "" {
+			r.recorder.Eventf(ws, "Warning", "UpstreamTriggerFailed", "Unable to trigger %s: workspace has no VCS repository", ws.Spec.OnUpstreamChange)
+		} else if reason := upstreamApplyForbidden(ws); reason != "" {
+			r.recorder.Eventf(ws, "Warning", "UpstreamTriggerFailed", "Refusing to trigger apply: %s", reason)
+		} else {
+			run, err := r.createUpstreamTriggeredRun(ctx, ws)
+			if err != nil {
+				r.recorder.Eventf(ws, "Warning", "UpstreamTriggerFailed", "Unable to trigger %s: %s", ws.Spec.OnUpstreamChange, err.Error())
+				return false, err
+			}
+			r.recorder.Eventf(ws, "Normal", "UpstreamTriggered", "Upstream outputs changed, created %s run %s", ws.Spec.OnUpstreamChange, run.Name)
+		}
+	}
+	ws.Status.UpstreamOutputsDigest = digest
+
+	return false, nil
+}
+
+// dependenciesNotReady records that the workspace's dependencies cannot be
+// resolved. An event is only emitted for a new failure.
+func (r *WorkspaceReconciler) dependenciesNotReady(ws *v1alpha1.Workspace, reason, msg string) {
+	if cond := meta.FindStatusCondition(ws.Status.Conditions, v1alpha1.WorkspaceDependenciesReadyCondition); cond != nil {
+		if cond.Reason == reason && cond.Message == msg {
+			return
+		}
+	}
+
+	meta.SetStatusCondition(&ws.Status.Conditions, metav1.Condition{
+		Type:    v1alpha1.WorkspaceDependenciesReadyCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: msg,
+	})
+	r.recorder.Eventf(ws, "Warning", reason, "%s", msg)
+}
+
+// createUpstreamTriggeredRun creates a run of the workspace's VCS repository
+// that either plans or applies, depending on the workspace's OnUpstreamChange
+// setting
+func (r *WorkspaceReconciler) createUpstreamTriggeredRun(ctx context.Context, ws *v1alpha1.Workspace) (*v1alpha1.Run, error) {
+	name := fmt.Sprintf("upstream-%s", util.GenerateRandomString(5))
+
+	script := "terraform init -no-color -input=false && "
+	switch ws.Spec.OnUpstreamChange {
+	case "plan":
+		script += "terraform plan -no-color -input=false"
+	case "apply":
+		script += "terraform apply -no-color -input=false -auto-approve"
+	default:
+		return nil, fmt.Errorf("unsupported command: %s", ws.Spec.OnUpstreamChange)
+	}
+
+	bldr := builders.Run(ws.Namespace, name, ws.Name, "sh", script).
+		SetLabel(labels.UpstreamTrigger.Name, labels.UpstreamTrigger.Value)
+	if ws.Spec.OnUpstreamChange == "apply" {
+		bldr = bldr.SetLabel(labels.ApplyScript.Name, labels.ApplyScript.Value)
+	}
+	run := bldr.Build()
+
+	return run, r.createVCSRun(ctx, ws, run)
+}
+
+// upstreamApplyForbidden returns the reason the workspace forbids an apply
+// triggered by a change to upstream outputs, or an empty string if it is
+// permitted. Such an apply auto-approves its changes without a saved plan, so
+// it could neither be evaluated against the workspace's policy nor approved.
+func upstreamApplyForbidden(ws *v1alpha1.Workspace) string {
+	if ws.Spec.OnUpstreamChange != "apply" {
+		return ""
+	}
+	if len(ws.Spec.PolicyRuleSets) > 0 {
+		return "the workspace has policy rule sets, against which an apply without a saved plan cannot be evaluated"
+	}
+	if slice.ContainsString(ws.Spec.PrivilegedCommands, "apply") {
+		return "apply is a privileged command, requiring approval"
+	}
+	return ""
+}
+
+// upstreamOutputsDigest returns a digest of the values of the upstream outputs
+// that the workspace's variables reference
+func upstreamOutputsDigest(ws, resolved *v1alpha1.Workspace) string {
+	var values []string
+	for i, v := range ws.Spec.Variables {
+		if ref := workspaceOutputRef(v); ref != nil {
+			values = append(values, fmt.Sprintf("%s/%s=%s", ref.Workspace, ref.Output, resolved.Spec.Variables[i].Value))
+		}
+	}
+	sort.Strings(values)
+
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(values, "\n"))))
+}
+
+// resolveVariables returns a copy of the workspace with variables that
+// reference the outputs of other workspaces set to the values of those outputs,
+// along with the indices of those variables set to the values of sensitive
+// outputs
+func resolveVariables(ctx context.Context, c client.Client, ws *v1alpha1.Workspace) (*v1alpha1.Workspace, []int, error) {
+	resolved := ws.DeepCopy()
+	var sensitive []int
+	for i, v := range resolved.Spec.Variables {
+		ref := workspaceOutputRef(v)
+		if ref == nil {
+			continue
+		}
+
+		value, isSensitive, err := resolveOutput(ctx, c, ws.Namespace, ref)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		resolved.Spec.Variables[i].Value = value
+		resolved.Spec.Variables[i].ValueFrom = nil
+		if isSensitive {
+			sensitive = append(sensitive, i)
+		}
+	}
+	return resolved, sensitive, nil
+}
+
+// resolveOutput returns the value of an output of a workspace and whether it is
+// sensitive. The output is retrieved from the workspace's status, or, failing
+// that, directly from its state.
+func resolveOutput(ctx context.Context, c client.Client, namespace string, ref *v1alpha1.WorkspaceOutputSelector) (string, bool, error) {
+	var upstream v1alpha1.Workspace
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Workspace}, &upstream); err != nil {
+		if kerrors.IsNotFound(err) {
+			return "", false, fmt.Errorf("%w: %s", errUpstreamNotFound, ref.Workspace)
+		}
+		return "", false, err
+	}
+
+	for _, o := range upstream.Status.Outputs {
+		// The value of a sensitive output is redacted, so it is retrieved
+		// from the state instead
+		if o.Key == ref.Output && !o.Sensitive {
+			return o.Value, false, nil
+		}
+	}
+
+	var secret corev1.Secret
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: upstream.StateSecretName()}, &secret); err != nil {
+		if kerrors.IsNotFound(err) {
+			return "", false, fmt.Errorf("%w: %s has no state", errUpstreamOutputNotFound, ref.Workspace)
+		}
+		return "", false, err
+	}
+
+	state, err := readState(ctx, &secret)
+	if err != nil {
+		return "", false, err
+	}
+	if o, ok := state.Outputs[ref.Output]; ok {
+		value, err := o.valueString()
+		return value, o.Sensitive, err
+	}
+
+	return "", false, fmt.Errorf("%w: %s/%s", errUpstreamOutputNotFound, ref.Workspace, ref.Output)
+}
+
+// findCycle returns a dependency cycle that includes the workspace, starting
+// and ending with the workspace, or nil if there is no such cycle
+func findCycle(ctx context.Context, c client.Client, ws *v1alpha1.Workspace) ([]string, error) {
+	var workspaces v1alpha1.WorkspaceList
+	if err := c.List(ctx, &workspaces, client.InNamespace(ws.Namespace)); err != nil {
+		return nil, err
+	}
+
+	graph := make(map[string][]string)
+	for _, w := range workspaces.Items {
+		graph[w.Name] = w.Upstreams()
+	}
+	// Use the workspace's current spec rather than the listed copy
+	graph[ws.Name] = ws.Upstreams()
+
+	visited := make(map[string]bool)
+	var visit func(path []string) []string
+	visit = func(path []string) []string {
+		for _, upstream := range graph[path[len(path)-1]] {
+			if upstream == ws.Name {
+				return append(path, upstream)
+			}
+			if visited[upstream] {
+				continue
+			}
+			visited[upstream] = true
+			if cycle := visit(append(path, upstream)); cycle != nil {
+				return cycle
+			}
+		}
+		return nil
+	}
+	return visit([]string{ws.Name}), nil
+}
+
+// dependents returns requests for the workspaces whose variables reference the
+// outputs of the given workspace
+func (r *WorkspaceReconciler) dependents(o client.Object) []ctrl.Request {
+	var workspaces v1alpha1.WorkspaceList
+	if err := r.List(context.Background(), &workspaces, client.InNamespace(o.GetNamespace())); err != nil {
+		return []ctrl.Request{}
+	}
+
+	requests := []ctrl.Request{}
+	for i, ws := range workspaces.Items {
+		for _, upstream := range ws.Upstreams() {
+			if upstream == o.GetName() {
+				requests = append(requests, requestFromObject(&workspaces.Items[i]))
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// workspaceOutputRef returns the reference to another workspace's output from
+// which the variable sources its value, or nil if there is no such reference
+func workspaceOutputRef(v *v1alpha1.Variable) *v1alpha1.WorkspaceOutputSelector {
+	if v.ValueFrom == nil {
+		return nil
+	}
+	return v.ValueFrom.WorkspaceOutputRef
+}
@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	v1alpha1 "github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/pkg/scheme"
+	"github.com/leg100/etok/pkg/testobj"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileWorkspaceDependencies(t *testing.T) {
+	// Workspace that has already observed the current value of its upstream
+	// output
+	unchanged := testobj.Workspace("", "workspace-1", testobj.WithWorkspaceOutputVariable("vpc_id", "network", "vpc_id"), testobj.WithOnUpstreamChange("apply"))
+	unchanged.Status.UpstreamOutputsDigest = upstreamOutputsDigest(unchanged, resolvedWith(unchanged, "vpc-123"))
+
+	tests := []struct {
+		name       string
+		workspace  *v1alpha1.Workspace
+		objs       []runtime.Object
+		assertions func(*testutil.T, *v1alpha1.Workspace, client.Client)
+		wantEvent  string
+	}{
+		{
+			name:      "No dependencies",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithVariables("foo", "bar")),
+			assertions: func(t *testutil.T, ws *v1alpha1.Workspace, cl client.Client) {
+				assert.Nil(t, ws.Status.Dependencies)
+				assert.Nil(t, meta.FindStatusCondition(ws.Status.Conditions, v1alpha1.WorkspaceDependenciesReadyCondition))
+			},
+		},
+		{
+			name:      "Output from status",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithWorkspaceOutputVariable("vpc_id", "network", "vpc_id")),
+			objs: []runtime.Object{
+				testobj.Workspace("", "network", testobj.WithOutputs("vpc_id", "vpc-123")),
+			},
+			assertions: func(t *testutil.T, ws *v1alpha1.Workspace, cl client.Client) {
+				assert.Equal(t, []string{"network"}, ws.Status.Dependencies)
+				assert.NotEmpty(t, ws.Status.UpstreamOutputsDigest)
+				assert.True(t, meta.IsStatusConditionTrue(ws.Status.Conditions, v1alpha1.WorkspaceDependenciesReadyCondition))
+			},
+		},
+		{
+			name:      "Output from state",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithWorkspaceOutputVariable("suffix", "network", "random_string")),
+			objs: []runtime.Object{
+				testobj.Workspace("", "network"),
+				testobj.Secret("", "tfstate-default-network", testobj.WithCompressedDataFromFile("tfstate", "testdata/tfstate.json")),
+			},
+			assertions: func(t *testutil.T, ws *v1alpha1.Workspace, cl client.Client) {
+				assert.True(t, meta.IsStatusConditionTrue(ws.Status.Conditions, v1alpha1.WorkspaceDependenciesReadyCondition))
+			},
+		},
+		{
+			name:      "Sensitive output from state",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithWorkspaceOutputVariable("db_password", "database", "db_password")),
+			objs: []runtime.Object{
+				testobj.Workspace("", "database", func(ws *v1alpha1.Workspace) {
+					ws.Status.Outputs = []*v1alpha1.Output{{Key: "db_password", Type: "string", Sensitive: true}}
+				}),
+				testobj.Secret("", "tfstate-default-database", testobj.WithCompressedDataFromFile("tfstate", "testdata/tfstate_typed.json")),
+			},
+			assertions: func(t *testutil.T, ws *v1alpha1.Workspace, cl client.Client) {
+				assert.True(t, meta.IsStatusConditionTrue(ws.Status.Conditions, v1alpha1.WorkspaceDependenciesReadyCondition))
+
+				resolved, sensitive, err := resolveVariables(context.Background(), cl, ws)
+				require.NoError(t, err)
+				assert.Equal(t, "hunter2", resolved.Spec.Variables[0].Value)
+				assert.Equal(t, []int{0}, sensitive)
+			},
+		},
+		{
+			name:      "Upstream not found",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithWorkspaceOutputVariable("vpc_id", "network", "vpc_id")),
+			assertions: func(t *testutil.T, ws *v1alpha1.Workspace, cl client.Client) {
+				ready := meta.FindStatusCondition(ws.Status.Conditions, v1alpha1.WorkspaceDependenciesReadyCondition)
+				if assert.NotNil(t, ready) {
+					assert.Equal(t, metav1.ConditionFalse, ready.Status)
+					assert.Equal(t, v1alpha1.UpstreamNotFoundReason, ready.Reason)
+				}
+			},
+			wantEvent: "Warning UpstreamNotFound upstream workspace not found: network",
+		},
+		{
+			name:      "Upstream output not found",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithWorkspaceOutputVariable("vpc_id", "network", "vpc_id")),
+			objs: []runtime.Object{
+				testobj.Workspace("", "network", testobj.WithOutputs("subnet_id", "subnet-123")),
+				testobj.Secret("", "tfstate-default-network", testobj.WithCompressedDataFromFile("tfstate", "testdata/tfstate.json")),
+			},
+			assertions: func(t *testutil.T, ws *v1alpha1.Workspace, cl client.Client) {
+				assert.True(t, meta.IsStatusConditionFalse(ws.Status.Conditions, v1alpha1.WorkspaceDependenciesReadyCondition))
+			},
+			wantEvent: "Warning UpstreamOutputNotFound upstream output not found: network/vpc_id",
+		},
+		{
+			name:      "Dependency cycle",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithWorkspaceOutputVariable("vpc_id", "network", "vpc_id")),
+			objs: []runtime.Object{
+				testobj.Workspace("", "network", testobj.WithOutputs("vpc_id", "vpc-123"), testobj.WithWorkspaceOutputVariable("cluster", "cluster", "name")),
+				testobj.Workspace("", "cluster", testobj.WithWorkspaceOutputVariable("app", "workspace-1", "name")),
+			},
+			assertions: func(t *testutil.T, ws *v1alpha1.Workspace, cl client.Client) {
+				ready := meta.FindStatusCondition(ws.Status.Conditions, v1alpha1.WorkspaceDependenciesReadyCondition)
+				if assert.NotNil(t, ready) {
+					assert.Equal(t, metav1.ConditionFalse, ready.Status)
+					assert.Equal(t, v1alpha1.DependencyCycleReason, ready.Reason)
+				}
+			},
+			wantEvent: "Warning DependencyCycle Dependency cycle: workspace-1 -> network -> cluster -> workspace-1",
+		},
+		{
+			name:      "Outputs first resolved",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithWorkspaceOutputVariable("vpc_id", "network", "vpc_id"), testobj.WithOnUpstreamChange("plan")),
+			objs: []runtime.Object{
+				testobj.Workspace("", "network", testobj.WithOutputs("vpc_id", "vpc-123")),
+			},
+			assertions: func(t *testutil.T, ws *v1alpha1.Workspace, cl client.Client) {
+				var runs v1alpha1.RunList
+				require.NoError(t, cl.List(context.Background(), &runs))
+				assert.Equal(t, 0, len(runs.Items))
+			},
+		},
+		{
+			name:      "Outputs changed",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithWorkspaceOutputVariable("vpc_id", "network", "vpc_id"), testobj.WithOnUpstreamChange("plan"), testobj.WithUpstreamOutputsDigest("stale")),
+			objs: []runtime.Object{
+				testobj.Workspace("", "network", testobj.WithOutputs("vpc_id", "vpc-123")),
+			},
+			assertions: func(t *testutil.T, ws *v1alpha1.Workspace, cl client.Client) {
+				assert.NotEqual(t, "stale", ws.Status.UpstreamOutputsDigest)
+
+				var runs v1alpha1.RunList
+				require.NoError(t, cl.List(context.Background(), &runs))
+				if assert.Equal(t, 1, len(runs.Items)) {
+					assert.True(t, strings.HasPrefix(runs.Items[0].Name, "upstream-"))
+					assert.Equal(t, "sh", runs.Items[0].Command)
+					assert.Equal(t, "true", runs.Items[0].Labels["upstream-trigger"])
+					assert.Contains(t, strings.Join(runs.Items[0].Args, " "), "terraform plan")
+				}
+			},
+		},
+		{
+			name:      "Outputs changed on workspace with policy",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithWorkspaceOutputVariable("vpc_id", "network", "vpc_id"), testobj.WithOnUpstreamChange("apply"), testobj.WithUpstreamOutputsDigest("stale"), testobj.WithPolicyRuleSets("policy")),
+			objs: []runtime.Object{
+				testobj.Workspace("", "network", testobj.WithOutputs("vpc_id", "vpc-123")),
+			},
+			wantEvent: "Warning UpstreamTriggerFailed Refusing to trigger apply: the workspace has policy rule sets, against which an apply without a saved plan cannot be evaluated",
+			assertions: func(t *testutil.T, ws *v1alpha1.Workspace, cl client.Client) {
+				assert.NotEqual(t, "stale", ws.Status.UpstreamOutputsDigest)
+
+				var runs v1alpha1.RunList
+				require.NoError(t, cl.List(context.Background(), &runs))
+				assert.Equal(t, 0, len(runs.Items))
+			},
+		},
+		{
+			name:      "Outputs changed on workspace with privileged apply",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithWorkspaceOutputVariable("vpc_id", "network", "vpc_id"), testobj.WithOnUpstreamChange("apply"), testobj.WithUpstreamOutputsDigest("stale"), testobj.WithPrivilegedCommands("apply")),
+			objs: []runtime.Object{
+				testobj.Workspace("", "network", testobj.WithOutputs("vpc_id", "vpc-123")),
+			},
+			wantEvent: "Warning UpstreamTriggerFailed Refusing to trigger apply: apply is a privileged command, requiring approval",
+			assertions: func(t *testutil.T, ws *v1alpha1.Workspace, cl client.Client) {
+				var runs v1alpha1.RunList
+				require.NoError(t, cl.List(context.Background(), &runs))
+				assert.Equal(t, 0, len(runs.Items))
+			},
+		},
+		{
+			name:      "Outputs unchanged",
+			workspace: unchanged,
+			objs: []runtime.Object{
+				testobj.Workspace("", "network", testobj.WithOutputs("vpc_id", "vpc-123")),
+			},
+			assertions: func(t *testutil.T, ws *v1alpha1.Workspace, cl client.Client) {
+				var runs v1alpha1.RunList
+				require.NoError(t, cl.List(context.Background(), &runs))
+				assert.Equal(t, 0, len(runs.Items))
+			},
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			tt.workspace.Spec.VCS.Repository = driftRepo
+
+			objs := append(tt.objs, runtime.Object(tt.workspace), testobj.WorkspacePod("", "workspace-1"))
+			cl := fake.NewFakeClientWithScheme(scheme.Scheme, objs...)
+			recorder := record.NewFakeRecorder(100)
+
+			r := NewWorkspaceReconciler(cl, "", WithEventRecorder(recorder))
+			req := requestFromObject(tt.workspace)
+			_, err := r.Reconcile(context.Background(), req)
+			require.NoError(t, err)
+
+			if tt.wantEvent != "" {
+				if assert.Len(t, recorder.Events, 1) {
+					assert.Equal(t, tt.wantEvent, <-recorder.Events)
+				}
+			}
+
+			ws := &v1alpha1.Workspace{}
+			require.NoError(t, r.Get(context.Background(), req.NamespacedName, ws))
+			tt.assertions(t, ws, cl)
+		})
+	}
+}
+
+// resolvedWith returns a copy of the workspace with its variables set to the
+// given value
+func resolvedWith(ws *v1alpha1.Workspace, value string) *v1alpha1.Workspace {
+	resolved := ws.DeepCopy()
+	for _, v := range resolved.Spec.Variables {
+		v.Value = value
+		v.ValueFrom = nil
+	}
+	return resolved
+}
@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/pkg/builders"
+	"github.com/leg100/etok/pkg/labels"
+	"github.com/leg100/etok/pkg/util"
+	"github.com/robfig/cron/v3"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// manageDrift checks the workspace for drift on its drift detection schedule.
+// Each check is a plan run against the workspace's VCS repository, the result
+// of which is recorded as the Drifted condition once the run completes.
+func (r *WorkspaceReconciler) manageDrift(ctx context.Context, ws *v1alpha1.Workspace) (bool, error) {
+	if ws.Spec.DriftDetection == "" {
+		return false, nil
+	}
+
+	if ws.Status.DriftRun != "" {
+		if err := r.checkDriftRun(ctx, ws); err != nil {
+			return false, err
+		}
+		if ws.Status.DriftRun != "" {
+			// Check is still in progress
+			return false, nil
+		}
+	}
+
+	schedule, err := cron.ParseStandard(ws.Spec.DriftDetection)
+	if err != nil {
+		r.driftCheckFailed(ws, err.Error())
+		return false, nil
+	}
+
+	if r.clock.Now().Before(nextDriftCheck(ws, schedule)) {
+		return false, nil
+	}
+
+	// Record the attempt regardless of its outcome, so that a failed attempt
+	// is not retried until the next scheduled check
+	ws.Status.LastDriftCheck = &metav1.Time{Time: r.clock.Now()}
+
+	if ws.Spec.VCS.Repository == "" {
+		r.driftCheckFailed(ws, "workspace has no VCS repository")
+		return false, nil
+	}
+
+	run, err := r.createDriftRun(ctx, ws)
+	if err != nil {
+		r.driftCheckFailed(ws, fmt.Sprintf("unable to create drift check run: %s", err.Error()))
+		return false, nil
+	}
+	ws.Status.DriftRun = run.Name
+
+	return false, nil
+}
+
+// nextDriftCheck returns the time at which the workspace is next due a drift
+// check
+func nextDriftCheck(ws *v1alpha1.Workspace, schedule cron.Schedule) time.Time {
+	last := ws.CreationTimestamp.Time
+	if ws.Status.LastDriftCheck != nil {
+		last = ws.Status.LastDriftCheck.Time
+	}
+	return schedule.Next(last.UTC())
+}
+
+// untilNextDriftCheck returns the duration until the workspace is next due a
+// drift check. Zero is returned if there is no check to schedule.
+func (r *WorkspaceReconciler) untilNextDriftCheck(ws *v1alpha1.Workspace) time.Duration {
+	if ws.Spec.DriftDetection == "" || ws.Status.DriftRun != "" {
+		return 0
+	}
+
+	schedule, err := cron.ParseStandard(ws.Spec.DriftDetection)
+	if err != nil {
+		return 0
+	}
+
+	next := nextDriftCheck(ws, schedule)
+	if next.IsZero() {
+		return 0
+	}
+	if until := next.Sub(r.clock.Now()); until > 0 {
+		return until
+	}
+	return time.Second
+}
+
+// createDriftRun creates a run that saves a plan of the workspace's VCS
+// repository
+func (r *WorkspaceReconciler) createDriftRun(ctx context.Context, ws *v1alpha1.Workspace) (*v1alpha1.Run, error) {
+	name := fmt.Sprintf("drift-%s", util.GenerateRandomString(5))
+	script := fmt.Sprintf("terraform init -no-color -input=false && terraform plan -no-color -input=false -out=%s", filepath.Join(PlansMountPath, name))
+
+	run := builders.Run(ws.Namespace, name, ws.Name, "sh", script).
+		SetLabel(labels.DriftCheck.Name, labels.DriftCheck.Value).
+		SavePlan().
+		Build()
+
+	return run, r.createVCSRun(ctx, ws, run)
+}
+
+// createVCSRun creates a run against the workspace's VCS repository. Rather
+// than the operator cloning the repository, which would hold up reconciliation
+// and lacks the credentials to clone private repositories, the run's pod
+// clones the repository.
+func (r *WorkspaceReconciler) createVCSRun(ctx context.Context, ws *v1alpha1.Workspace, run *v1alpha1.Run) error {
+	run.Repository = &v1alpha1.RunRepository{URL: ws.Spec.VCS.Repository, Branch: ws.Spec.VCS.Branch}
+
+	// Make workspace owner of run, so if workspace is deleted so is its run
+	if err := controllerutil.SetOwnerReference(ws, run, r.Scheme); err != nil {
+		return err
+	}
+	return r.Create(ctx, run)
+}
+
+// checkDriftRun records the result of the workspace's drift check run once it
+// has completed
+func (r *WorkspaceReconciler) checkDriftRun(ctx context.Context, ws *v1alpha1.Workspace) error {
+	var run v1alpha1.Run
+	err := r.Get(ctx, types.NamespacedName{Namespace: ws.Namespace, Name: ws.Status.DriftRun}, &run)
+	if kerrors.IsNotFound(err) {
+		r.driftCheckFailed(ws, fmt.Sprintf("drift check run %s not found", ws.Status.DriftRun))
+		ws.Status.DriftRun = ""
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if !run.IsDone() {
+		return nil
+	}
+	ws.Status.DriftRun = ""
+
+	complete := meta.FindStatusCondition(run.Conditions, v1alpha1.RunCompleteCondition)
+	if complete == nil || complete.Status != metav1.ConditionTrue || complete.Reason != v1alpha1.PodSucceededReason {
+		r.driftCheckFailed(ws, fmt.Sprintf("drift check run %s failed", run.Name))
+		return nil
+	}
+
+	p, err := getRecordedPlan(ctx, r.Client, &run)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		r.driftCheckFailed(ws, fmt.Sprintf("drift check run %s did not save a plan", run.Name))
+		return nil
+	}
+
+	if p.HasNoChanges() {
+		meta.SetStatusCondition(&ws.Status.Conditions, metav1.Condition{
+			Type:    v1alpha1.WorkspaceDriftedCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  v1alpha1.NoDriftReason,
+			Message: p.Summary(),
+		})
+		r.recorder.Eventf(ws, "Normal", v1alpha1.NoDriftReason, "No drift detected by run %s", run.Name)
+	} else {
+		meta.SetStatusCondition(&ws.Status.Conditions, metav1.Condition{
+			Type:    v1alpha1.WorkspaceDriftedCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  v1alpha1.DriftDetectedReason,
+			Message: p.Summary(),
+		})
+		r.recorder.Eventf(ws, "Warning", v1alpha1.DriftDetectedReason, "Drift detected by run %s: %s", run.Name, p.Summary())
+	}
+
+	return nil
+}
+
+// driftCheckFailed records the failure of a drift check. An event is only
+// emitted for a new failure.
+func (r *WorkspaceReconciler) driftCheckFailed(ws *v1alpha1.Workspace, msg string) {
+	if cond := meta.FindStatusCondition(ws.Status.Conditions, v1alpha1.WorkspaceDriftedCondition); cond != nil {
+		if cond.Reason == v1alpha1.DriftCheckFailedReason && cond.Message == msg {
+			return
+		}
+	}
+
+	meta.SetStatusCondition(&ws.Status.Conditions, metav1.Condition{
+		Type:    v1alpha1.WorkspaceDriftedCondition,
+		Status:  metav1.ConditionUnknown,
+		Reason:  v1alpha1.DriftCheckFailedReason,
+		Message: msg,
+	})
+	r.recorder.Eventf(ws, "Warning", v1alpha1.DriftCheckFailedReason, "%s", msg)
+}
@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	v1alpha1 "github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/pkg/scheme"
+	"github.com/leg100/etok/pkg/testobj"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileWorkspaceDrift(t *testing.T) {
+	now := time.Date(2021, time.March, 3, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		workspace   *v1alpha1.Workspace
+		objs        []runtime.Object
+		assertions  func(*testutil.T, *v1alpha1.Workspace, client.Client)
+		requeue     time.Duration
+		wantEvent   string
+		withoutRepo bool
+	}{
+		{
+			name:      "Check not yet due",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithDriftDetection("@hourly"), testobj.WithLastDriftCheck(now.Add(-10*time.Minute))),
+			assertions: func(t *testutil.T, ws *v1alpha1.Workspace, cl client.Client) {
+				assert.Equal(t, "", ws.Status.DriftRun)
+				assert.Nil(t, meta.FindStatusCondition(ws.Status.Conditions, v1alpha1.WorkspaceDriftedCondition))
+			},
+			requeue: 30 * time.Minute,
+		},
+		{
+			name:      "Start check",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithDriftDetection("@hourly"), testobj.WithLastDriftCheck(now.Add(-90*time.Minute))),
+			assertions: func(t *testutil.T, ws *v1alpha1.Workspace, cl client.Client) {
+				assert.True(t, strings.HasPrefix(ws.Status.DriftRun, "drift-"))
+				assert.Equal(t, now, ws.Status.LastDriftCheck.Time.UTC())
+
+				var run v1alpha1.Run
+				require.NoError(t, cl.Get(context.Background(), types.NamespacedName{Name: ws.Status.DriftRun}, &run))
+				assert.Equal(t, "sh", run.Command)
+				assert.Equal(t, "workspace-1", run.Workspace)
+				assert.True(t, run.SavePlan)
+				assert.Equal(t, "true", run.Labels["drift-check"])
+
+				// Run's pod clones the repository
+				assert.Equal(t, &v1alpha1.RunRepository{URL: driftRepo, Branch: "main"}, run.Repository)
+			},
+		},
+		{
+			name:        "Start check without repository",
+			workspace:   testobj.Workspace("", "workspace-1", testobj.WithDriftDetection("@hourly"), testobj.WithLastDriftCheck(now.Add(-90*time.Minute))),
+			withoutRepo: true,
+			assertions: func(t *testutil.T, ws *v1alpha1.Workspace, cl client.Client) {
+				assert.Equal(t, "", ws.Status.DriftRun)
+				assert.True(t, meta.IsStatusConditionPresentAndEqual(ws.Status.Conditions, v1alpha1.WorkspaceDriftedCondition, metav1.ConditionUnknown))
+			},
+			requeue:   30 * time.Minute,
+			wantEvent: "Warning DriftCheckFailed workspace has no VCS repository",
+		},
+		{
+			name:      "Invalid schedule",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithDriftDetection("every hour")),
+			assertions: func(t *testutil.T, ws *v1alpha1.Workspace, cl client.Client) {
+				drifted := meta.FindStatusCondition(ws.Status.Conditions, v1alpha1.WorkspaceDriftedCondition)
+				if assert.NotNil(t, drifted) {
+					assert.Equal(t, v1alpha1.DriftCheckFailedReason, drifted.Reason)
+				}
+			},
+		},
+		{
+			name:      "Check in progress",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithDriftDetection("@hourly"), testobj.WithDriftRun("drift-1")),
+			objs: []runtime.Object{
+				testobj.Run("", "drift-1", "sh", testobj.WithWorkspace("workspace-1")),
+			},
+			assertions: func(t *testutil.T, ws *v1alpha1.Workspace, cl client.Client) {
+				assert.Equal(t, "drift-1", ws.Status.DriftRun)
+			},
+		},
+		{
+			name:      "Drift detected",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithDriftDetection("@hourly"), testobj.WithLastDriftCheck(now.Add(-10*time.Minute)), testobj.WithDriftRun("drift-1")),
+			objs: []runtime.Object{
+				testobj.Run("", "drift-1", "sh", testobj.WithWorkspace("workspace-1"), testobj.WithCondition(v1alpha1.RunCompleteCondition, v1alpha1.PodSucceededReason)),
+				testobj.ConfigMap("", "drift-1-plan", testobj.WithPlanChanges(`{"resourceChanges":[{"address":"aws_instance.web","action":"update"}]}`)),
+			},
+			assertions: func(t *testutil.T, ws *v1alpha1.Workspace, cl client.Client) {
+				assert.Equal(t, "", ws.Status.DriftRun)
+				drifted := meta.FindStatusCondition(ws.Status.Conditions, v1alpha1.WorkspaceDriftedCondition)
+				if assert.NotNil(t, drifted) {
+					assert.Equal(t, metav1.ConditionTrue, drifted.Status)
+					assert.Equal(t, v1alpha1.DriftDetectedReason, drifted.Reason)
+					assert.Equal(t, "Plan: 0 to add, 1 to change, 0 to destroy.", drifted.Message)
+				}
+			},
+			requeue:   30 * time.Minute,
+			wantEvent: "Warning DriftDetected Drift detected by run drift-1: Plan: 0 to add, 1 to change, 0 to destroy.",
+		},
+		{
+			name:      "No drift",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithDriftDetection("@hourly"), testobj.WithLastDriftCheck(now.Add(-10*time.Minute)), testobj.WithDriftRun("drift-1")),
+			objs: []runtime.Object{
+				testobj.Run("", "drift-1", "sh", testobj.WithWorkspace("workspace-1"), testobj.WithCondition(v1alpha1.RunCompleteCondition, v1alpha1.PodSucceededReason)),
+				testobj.ConfigMap("", "drift-1-plan", testobj.WithPlanChanges(`{}`)),
+			},
+			assertions: func(t *testutil.T, ws *v1alpha1.Workspace, cl client.Client) {
+				assert.True(t, meta.IsStatusConditionFalse(ws.Status.Conditions, v1alpha1.WorkspaceDriftedCondition))
+			},
+			requeue:   30 * time.Minute,
+			wantEvent: "Normal NoDrift No drift detected by run drift-1",
+		},
+		{
+			name:      "Check failed",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithDriftDetection("@hourly"), testobj.WithLastDriftCheck(now.Add(-10*time.Minute)), testobj.WithDriftRun("drift-1")),
+			objs: []runtime.Object{
+				testobj.Run("", "drift-1", "sh", testobj.WithWorkspace("workspace-1"), testobj.WithCondition(v1alpha1.RunCompleteCondition, v1alpha1.PodFailedReason)),
+			},
+			assertions: func(t *testutil.T, ws *v1alpha1.Workspace, cl client.Client) {
+				assert.Equal(t, "", ws.Status.DriftRun)
+				assert.True(t, meta.IsStatusConditionPresentAndEqual(ws.Status.Conditions, v1alpha1.WorkspaceDriftedCondition, metav1.ConditionUnknown))
+			},
+			requeue:   30 * time.Minute,
+			wantEvent: "Warning DriftCheckFailed drift check run drift-1 failed",
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			if !tt.withoutRepo {
+				tt.workspace.Spec.VCS.Repository = driftRepo
+				tt.workspace.Spec.VCS.Branch = "main"
+			}
+
+			objs := append(tt.objs, runtime.Object(tt.workspace), testobj.WorkspacePod("", "workspace-1"))
+			cl := fake.NewFakeClientWithScheme(scheme.Scheme, objs...)
+			recorder := record.NewFakeRecorder(100)
+
+			r := NewWorkspaceReconciler(cl, "", WithEventRecorder(recorder), WithClock(clock.NewFakeClock(now)))
+			req := requestFromObject(tt.workspace)
+			result, err := r.Reconcile(context.Background(), req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.requeue, result.RequeueAfter)
+
+			if tt.wantEvent != "" {
+				if assert.Len(t, recorder.Events, 1) {
+					assert.Equal(t, tt.wantEvent, <-recorder.Events)
+				}
+			}
+
+			ws := &v1alpha1.Workspace{}
+			require.NoError(t, r.Get(context.Background(), req.NamespacedName, ws))
+			tt.assertions(t, ws, cl)
+		})
+	}
+}
+
+// driftRepo is the URL of the VCS repository of workspaces in drift tests
+const driftRepo = "https://github.com/leg100/etok-e2e.git"
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/pkg/labels"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// errNotProjection is returned when a resource into which outputs are to be
+// projected already exists but is not a projection of the workspace's outputs.
+var errNotProjection = errors.New("resource already exists and is not a projection of the workspace's outputs")
+
+// projectOutputs projects the state's outputs into the secrets and config maps
+// specified by the workspace. The workspace owns the projected resources, so
+// they are deleted along with the workspace. An existing resource is only
+// updated if it is already a projection of the workspace's outputs, lest the
+// workspace take over, and overwrite, a resource it did not create.
+func (r *WorkspaceReconciler) projectOutputs(ctx context.Context, ws *v1alpha1.Workspace, s *state) error {
+	for _, p := range ws.Spec.OutputProjections {
+		data, skipped, err := projectedData(&p, s)
+		if err != nil {
+			return err
+		}
+
+		var obj client.Object
+		var setData func()
+		switch p.Kind {
+		case "Secret":
+			secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: ws.Namespace, Name: p.Name}}
+			setData = func() {
+				secret.Data = make(map[string][]byte)
+				for k, v := range data {
+					secret.Data[k] = []byte(v)
+				}
+			}
+			obj = secret
+		case "ConfigMap":
+			configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: ws.Namespace, Name: p.Name}}
+			setData = func() {
+				configMap.Data = data
+			}
+			obj = configMap
+		default:
+			return fmt.Errorf("unsupported output projection kind: %s", p.Kind)
+		}
+
+		// Refuse to touch an existing resource that is not already a
+		// projection
+		if err := r.Get(ctx, client.ObjectKeyFromObject(obj), obj); err == nil {
+			if !isProjection(ws, obj) {
+				r.recorder.Eventf(ws, "Warning", "OutputProjectionFailed", "Unable to project outputs into %s %s: %s", p.Kind, p.Name, errNotProjection.Error())
+				continue
+			}
+		} else if !kerrors.IsNotFound(err) {
+			return err
+		}
+
+		result, err := controllerutil.CreateOrUpdate(ctx, r.Client, obj, func() error {
+			setData()
+
+			// Set etok's common labels
+			labels.SetCommonLabels(obj)
+			// Permit filtering resources by workspace
+			labels.SetLabel(obj, labels.Workspace(ws.Name))
+			// Permit filtering etok resources by component
+			labels.SetLabel(obj, labels.OutputsComponent)
+
+			return controllerutil.SetControllerReference(ws, obj, r.Scheme)
+		})
+		if err != nil {
+			var owned *controllerutil.AlreadyOwnedError
+			if errors.As(err, &owned) {
+				r.recorder.Eventf(ws, "Warning", "OutputProjectionFailed", "Unable to project outputs into %s %s: %s", p.Kind, p.Name, err.Error())
+				continue
+			}
+			return err
+		}
+
+		// Only report changes to the projection
+		if result == controllerutil.OperationResultNone {
+			continue
+		}
+		r.recorder.Eventf(ws, "Normal", "OutputsProjected", "Projected outputs into %s %s", p.Kind, p.Name)
+		if len(skipped) > 0 {
+			r.recorder.Eventf(ws, "Warning", "SensitiveOutputsSkipped", "Sensitive outputs are only projected into a secret, skipped: %s", strings.Join(skipped, ", "))
+		}
+	}
+	return nil
+}
+
+// isProjection determines whether obj is a projection of the workspace's
+// outputs, i.e. it carries the workspace's outputs labels and the workspace is
+// its controller.
+func isProjection(ws *v1alpha1.Workspace, obj client.Object) bool {
+	lbls := obj.GetLabels()
+	for _, lbl := range []labels.Label{labels.OutputsComponent, labels.Workspace(ws.Name)} {
+		if lbls[lbl.Name] != lbl.Value {
+			return false
+		}
+	}
+	return metav1.IsControlledBy(obj, ws)
+}
+
+// projectedData returns the values of the outputs to be projected, keyed by
+// output name, along with the names of sensitive outputs skipped because the
+// projection is not into a secret. Outputs not found in the state are ignored.
+func projectedData(p *v1alpha1.OutputProjection, s *state) (map[string]string, []string, error) {
+	keys := p.Outputs
+	if len(keys) == 0 {
+		keys = s.outputKeys()
+	}
+
+	data := make(map[string]string)
+	var skipped []string
+	for _, k := range keys {
+		o, ok := s.Outputs[k]
+		if !ok {
+			continue
+		}
+		if o.Sensitive && p.Kind != "Secret" {
+			skipped = append(skipped, k)
+			continue
+		}
+
+		value, err := o.valueString()
+		if err != nil {
+			return nil, nil, err
+		}
+		data[k] = value
+	}
+	return data, skipped, nil
+}
@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	v1alpha1 "github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/pkg/labels"
+	"github.com/leg100/etok/pkg/scheme"
+	"github.com/leg100/etok/pkg/testobj"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileWorkspaceOutputProjections(t *testing.T) {
+	tests := []struct {
+		name       string
+		workspace  *v1alpha1.Workspace
+		objs       []runtime.Object
+		assertions func(*testutil.T, client.Client)
+		wantEvents []string
+	}{
+		{
+			name: "Project all outputs into secret",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithOutputProjection(v1alpha1.OutputProjection{
+				Kind: "Secret",
+				Name: "db",
+			})),
+			assertions: func(t *testutil.T, cl client.Client) {
+				var secret corev1.Secret
+				require.NoError(t, cl.Get(context.Background(), types.NamespacedName{Name: "db"}, &secret))
+				assert.Equal(t, map[string][]byte{
+					"db_password": []byte("hunter2"),
+					"endpoint":    []byte("db.example.com"),
+					"port":        []byte("5432"),
+					"replicas":    []byte(`["replica-1","replica-2"]`),
+				}, secret.Data)
+				assert.Equal(t, "workspace-1", metav1.GetControllerOf(&secret).Name)
+			},
+			wantEvents: []string{
+				"Normal OutputsProjected Projected outputs into Secret db",
+			},
+		},
+		{
+			name: "Project selected outputs into config map",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithOutputProjection(v1alpha1.OutputProjection{
+				Kind:    "ConfigMap",
+				Name:    "db",
+				Outputs: []string{"endpoint", "port", "db_password", "non_existent"},
+			})),
+			assertions: func(t *testutil.T, cl client.Client) {
+				var configMap corev1.ConfigMap
+				require.NoError(t, cl.Get(context.Background(), types.NamespacedName{Name: "db"}, &configMap))
+				assert.Equal(t, map[string]string{
+					"endpoint": "db.example.com",
+					"port":     "5432",
+				}, configMap.Data)
+			},
+			wantEvents: []string{
+				"Normal OutputsProjected Projected outputs into ConfigMap db",
+				"Warning SensitiveOutputsSkipped Sensitive outputs are only projected into a secret, skipped: db_password",
+			},
+		},
+		{
+			name: "Resource owned by another controller",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithOutputProjection(v1alpha1.OutputProjection{
+				Kind: "ConfigMap",
+				Name: "db",
+			})),
+			objs: []runtime.Object{
+				testobj.ConfigMap("", "db", func(configMap *corev1.ConfigMap) {
+					isController := true
+					configMap.OwnerReferences = []metav1.OwnerReference{
+						{APIVersion: "apps/v1", Kind: "Deployment", Name: "app", Controller: &isController},
+					}
+				}),
+			},
+			assertions: func(t *testutil.T, cl client.Client) {
+				var configMap corev1.ConfigMap
+				require.NoError(t, cl.Get(context.Background(), types.NamespacedName{Name: "db"}, &configMap))
+				assert.Nil(t, configMap.Data)
+			},
+			wantEvents: []string{
+				`Warning OutputProjectionFailed Unable to project outputs into ConfigMap db: resource already exists and is not a projection of the workspace's outputs`,
+			},
+		},
+		{
+			name: "Resource without an owner",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithOutputProjection(v1alpha1.OutputProjection{
+				Kind: "Secret",
+				Name: "tfstate-default-workspace-2",
+			})),
+			objs: []runtime.Object{
+				testobj.Secret("", "tfstate-default-workspace-2", testobj.WithStringData("tfstate", "other state")),
+			},
+			assertions: func(t *testutil.T, cl client.Client) {
+				var secret corev1.Secret
+				require.NoError(t, cl.Get(context.Background(), types.NamespacedName{Name: "tfstate-default-workspace-2"}, &secret))
+				assert.Nil(t, metav1.GetControllerOf(&secret))
+				assert.Equal(t, map[string]string{"tfstate": "other state"}, secret.StringData)
+			},
+			wantEvents: []string{
+				`Warning OutputProjectionFailed Unable to project outputs into Secret tfstate-default-workspace-2: resource already exists and is not a projection of the workspace's outputs`,
+			},
+		},
+		{
+			name: "Update existing projection",
+			workspace: testobj.Workspace("", "workspace-1", testobj.WithOutputProjection(v1alpha1.OutputProjection{
+				Kind:    "ConfigMap",
+				Name:    "db",
+				Outputs: []string{"port"},
+			})),
+			objs: []runtime.Object{
+				testobj.ConfigMap("", "db", func(configMap *corev1.ConfigMap) {
+					isController := true
+					configMap.Labels = labels.MakeLabels(labels.OutputsComponent, labels.Workspace("workspace-1"))
+					configMap.OwnerReferences = []metav1.OwnerReference{
+						{APIVersion: "etok.dev/v1alpha1", Kind: "Workspace", Name: "workspace-1", Controller: &isController},
+					}
+					configMap.Data = map[string]string{"port": "5431"}
+				}),
+			},
+			assertions: func(t *testutil.T, cl client.Client) {
+				var configMap corev1.ConfigMap
+				require.NoError(t, cl.Get(context.Background(), types.NamespacedName{Name: "db"}, &configMap))
+				assert.Equal(t, map[string]string{"port": "5432"}, configMap.Data)
+			},
+			wantEvents: []string{
+				"Normal OutputsProjected Projected outputs into ConfigMap db",
+			},
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			objs := append(tt.objs,
+				runtime.Object(tt.workspace),
+				testobj.WorkspacePod("", "workspace-1", testobj.WithPhase(corev1.PodRunning)),
+				testobj.Secret("", "tfstate-default-workspace-1", testobj.WithCompressedDataFromFile("tfstate", "testdata/tfstate_typed.json")),
+			)
+			cl := fake.NewFakeClientWithScheme(scheme.Scheme, objs...)
+			recorder := record.NewFakeRecorder(100)
+
+			r := NewWorkspaceReconciler(cl, "", WithEventRecorder(recorder))
+			_, err := r.Reconcile(context.Background(), requestFromObject(tt.workspace))
+			require.NoError(t, err)
+
+			if assert.Len(t, recorder.Events, len(tt.wantEvents)) {
+				for _, want := range tt.wantEvents {
+					assert.Equal(t, want, <-recorder.Events)
+				}
+			}
+
+			tt.assertions(t, cl)
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package controllers
 
 import (
+	"sort"
+
 	v1alpha1 "github.com/leg100/etok/api/etok.dev/v1alpha1"
 	"github.com/leg100/etok/pkg/commands"
 	"github.com/leg100/etok/pkg/util/slice"
@@ -9,9 +11,11 @@ import (
 // updateCombinedQueue updates a workspace's combined queue (the active run +
 // the queue) with the given list of runs.  Runs in the existing queue are
 // expunged if they meet certain criteria.  If they are not expunged they
-// mantain their position.
+// mantain their position, unless overtaken by runs with a higher priority. The
+// active run is never displaced.
 func updateCombinedQueue(ws *v1alpha1.Workspace, runs []v1alpha1.Run) {
 	newQ := []string{}
+	priorities := make(map[string]int)
 	currQ := append([]string{ws.Status.Active}, ws.Status.Queue...)
 
 	// Filter run resources
@@ -26,6 +30,12 @@ func updateCombinedQueue(ws *v1alpha1.Workspace, runs []v1alpha1.Run) {
 			continue
 		}
 
+		// Filter out cancelled runs, unless active, in which case the run
+		// remains active until its command has been interrupted
+		if run.Cancel && run.Name != ws.Status.Active {
+			continue
+		}
+
 		// Filter out non-queueable runs
 		if !commands.IsQueueable(run.Command) {
 			continue
@@ -39,6 +49,7 @@ func updateCombinedQueue(ws *v1alpha1.Workspace, runs []v1alpha1.Run) {
 		}
 
 		newQ = append(newQ, run.Name)
+		priorities[run.Name] = ws.RunPriority(&run)
 	}
 
 	// Re-order new queue to ensure runs maintain their position from the
@@ -58,6 +69,17 @@ func updateCombinedQueue(ws *v1alpha1.Workspace, runs []v1alpha1.Run) {
 		newQ = append([]string{currQ[i]}, newQ...)
 	}
 
+	// Order runs by priority, retaining their relative position within the
+	// same priority. The active run is excluded, because it remains active
+	// regardless of the priority of the runs behind it.
+	backlog := newQ
+	if len(newQ) > 0 && newQ[0] == ws.Status.Active {
+		backlog = newQ[1:]
+	}
+	sort.SliceStable(backlog, func(i, j int) bool {
+		return priorities[backlog[i]] > priorities[backlog[j]]
+	})
+
 	// Update workspace with new (combined) queue
 	if len(newQ) > 0 {
 		ws.Status.Active, ws.Status.Queue = newQ[0], newQ[1:]
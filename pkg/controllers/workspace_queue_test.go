@@ -49,6 +49,26 @@ func TestUpdateCombinedQueue(t *testing.T) {
 			wantActive: "apply-1",
 			wantQueue:  []string{"apply-2"},
 		},
+		{
+			name:      "Cancelled queued run",
+			workspace: testobj.Workspace("default", "workspace-1", testobj.WithCombinedQueue("apply-1", "apply-2")),
+			runs: []v1alpha1.Run{
+				*testobj.Run("default", "apply-1", "apply", testobj.WithWorkspace("workspace-1")),
+				*testobj.Run("default", "apply-2", "apply", testobj.WithWorkspace("workspace-1"), testobj.WithCancel()),
+			},
+			wantActive: "apply-1",
+			wantQueue:  []string{},
+		},
+		{
+			name:      "Cancelled active run",
+			workspace: testobj.Workspace("default", "workspace-1", testobj.WithCombinedQueue("apply-1", "apply-2")),
+			runs: []v1alpha1.Run{
+				*testobj.Run("default", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), testobj.WithCancel()),
+				*testobj.Run("default", "apply-2", "apply", testobj.WithWorkspace("workspace-1")),
+			},
+			wantActive: "apply-1",
+			wantQueue:  []string{"apply-2"},
+		},
 		{
 			name:      "One completed run",
 			workspace: testobj.Workspace("default", "workspace-1", testobj.WithCombinedQueue("apply-1")),
@@ -97,6 +117,62 @@ func TestUpdateCombinedQueue(t *testing.T) {
 			wantActive: "apply-1",
 			wantQueue:  []string{},
 		},
+		{
+			name:      "Granted priority",
+			workspace: testobj.Workspace("default", "workspace-1", testobj.WithCombinedQueue("apply-1", "apply-2", "apply-3"), testobj.WithPriorityGrant("apply-3", 1)),
+			runs: []v1alpha1.Run{
+				*testobj.Run("default", "apply-1", "apply", testobj.WithWorkspace("workspace-1")),
+				*testobj.Run("default", "apply-2", "apply", testobj.WithWorkspace("workspace-1")),
+				*testobj.Run("default", "apply-3", "apply", testobj.WithWorkspace("workspace-1"), testobj.WithPriority(1)),
+			},
+			wantActive: "apply-1",
+			wantQueue:  []string{"apply-3", "apply-2"},
+		},
+		{
+			name:      "Ungranted priority",
+			workspace: testobj.Workspace("default", "workspace-1", testobj.WithCombinedQueue("apply-1", "apply-2", "apply-3")),
+			runs: []v1alpha1.Run{
+				*testobj.Run("default", "apply-1", "apply", testobj.WithWorkspace("workspace-1")),
+				*testobj.Run("default", "apply-2", "apply", testobj.WithWorkspace("workspace-1")),
+				*testobj.Run("default", "apply-3", "apply", testobj.WithWorkspace("workspace-1"), testobj.WithPriority(1)),
+			},
+			wantActive: "apply-1",
+			wantQueue:  []string{"apply-2", "apply-3"},
+		},
+		{
+			name:      "Priority granted differs from priority",
+			workspace: testobj.Workspace("default", "workspace-1", testobj.WithCombinedQueue("apply-1", "apply-2", "apply-3"), testobj.WithPriorityGrant("apply-3", 1)),
+			runs: []v1alpha1.Run{
+				*testobj.Run("default", "apply-1", "apply", testobj.WithWorkspace("workspace-1")),
+				*testobj.Run("default", "apply-2", "apply", testobj.WithWorkspace("workspace-1")),
+				*testobj.Run("default", "apply-3", "apply", testobj.WithWorkspace("workspace-1"), testobj.WithPriority(100)),
+			},
+			wantActive: "apply-1",
+			wantQueue:  []string{"apply-2", "apply-3"},
+		},
+		{
+			name:      "FIFO within same priority",
+			workspace: testobj.Workspace("default", "workspace-1", testobj.WithCombinedQueue("apply-1", "apply-4", "apply-2", "apply-3"), testobj.WithPriorityGrant("apply-2", 1), testobj.WithPriorityGrant("apply-3", 1)),
+			runs: []v1alpha1.Run{
+				*testobj.Run("default", "apply-1", "apply", testobj.WithWorkspace("workspace-1")),
+				*testobj.Run("default", "apply-2", "apply", testobj.WithWorkspace("workspace-1"), testobj.WithPriority(1)),
+				*testobj.Run("default", "apply-3", "apply", testobj.WithWorkspace("workspace-1"), testobj.WithPriority(1)),
+				*testobj.Run("default", "apply-4", "apply", testobj.WithWorkspace("workspace-1")),
+			},
+			wantActive: "apply-1",
+			wantQueue:  []string{"apply-2", "apply-3", "apply-4"},
+		},
+		{
+			name:      "Priority run becomes active when active run completes",
+			workspace: testobj.Workspace("default", "workspace-1", testobj.WithCombinedQueue("apply-1", "apply-2", "apply-3"), testobj.WithPriorityGrant("apply-3", 1)),
+			runs: []v1alpha1.Run{
+				*testobj.Run("default", "apply-1", "apply", testobj.WithWorkspace("workspace-1"), testobj.WithCondition(v1alpha1.RunCompleteCondition)),
+				*testobj.Run("default", "apply-2", "apply", testobj.WithWorkspace("workspace-1")),
+				*testobj.Run("default", "apply-3", "apply", testobj.WithWorkspace("workspace-1"), testobj.WithPriority(1)),
+			},
+			wantActive: "apply-3",
+			wantQueue:  []string{"apply-2"},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -83,10 +83,11 @@ func newRoleForNamespace(ws *v1alpha1.Workspace) *rbacv1.Role {
 				APIGroups: []string{""},
 			},
 			// ...and the runner specifies the run resource as owner of said
-			// config map, so it needs to retrieve run resource metadata as well
+			// config map, so it needs to retrieve run resource metadata as
+			// well, and it watches its run for cancellation
 			{
 				Resources: []string{"runs"},
-				Verbs:     []string{"get"},
+				Verbs:     []string{"get", "list", "watch"},
 				APIGroups: []string{"etok.dev"},
 			},
 			// Terraform state backend mgmt
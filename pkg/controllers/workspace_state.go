@@ -6,7 +6,9 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"sort"
 
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 )
 
@@ -16,8 +18,73 @@ type state struct {
 }
 
 type output struct {
-	Type  string
-	Value string
+	// Type and value are decoded later, because they may be of any type
+	Type      json.RawMessage
+	Value     json.RawMessage
+	Sensitive bool
+}
+
+// valueString returns the output's value as a string. A string is returned
+// as-is, whereas other types are returned encoded as JSON.
+func (o output) valueString() (string, error) {
+	return rawString(o.Value)
+}
+
+// typeString returns the output's type as a string, e.g. string, or
+// ["list","string"]
+func (o output) typeString() (string, error) {
+	return rawString(o.Type)
+}
+
+// rawString returns a JSON string unquoted, and any other JSON compacted
+func rawString(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 {
+		return "", nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+
+	buf := new(bytes.Buffer)
+	if err := json.Compact(buf, raw); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// statusOutputs converts the state's outputs into outputs for the workspace
+// status, sorted by key, with the values of sensitive outputs redacted
+func (s *state) statusOutputs() ([]*v1alpha1.Output, error) {
+	var outputs []*v1alpha1.Output
+	for _, k := range s.outputKeys() {
+		o := s.Outputs[k]
+
+		typ, err := o.typeString()
+		if err != nil {
+			return nil, err
+		}
+
+		so := &v1alpha1.Output{Key: k, Type: typ, Sensitive: o.Sensitive}
+		if !o.Sensitive {
+			so.Value, err = o.valueString()
+			if err != nil {
+				return nil, err
+			}
+		}
+		outputs = append(outputs, so)
+	}
+	return outputs, nil
+}
+
+// outputKeys returns the keys of the state's outputs, sorted
+func (s *state) outputKeys() (keys []string) {
+	for k := range s.Outputs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return
 }
 
 // Unmarshal state from secret
@@ -0,0 +1,61 @@
+package execer
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/kubectl/pkg/scheme"
+)
+
+// ExecFunc runs a command in a container of a pod. Substitutable for testing.
+type ExecFunc func(cfg rest.Config, namespace, name, containerName string, command ...string) error
+
+// Exec appropriates the behaviour of 'kubectl exec', running a command in a
+// container of a pod without stdin or a TTY. The command's stderr is included
+// in the error returned should the command fail.
+func Exec(cfg rest.Config, namespace, name, containerName string, command ...string) error {
+	cfg.ContentConfig = rest.ContentConfig{
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		GroupVersion:         &schema.GroupVersion{Version: "v1"},
+	}
+	cfg.APIPath = "/api"
+
+	client, err := rest.RESTClientFor(&cfg)
+	if err != nil {
+		return err
+	}
+
+	exec, err := remotecommand.NewSPDYExecutor(&cfg, "POST", makeExecRequest(client, namespace, name, containerName, command).URL())
+	if err != nil {
+		return err
+	}
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	if err := exec.Stream(remotecommand.StreamOptions{Stdout: stdout, Stderr: stderr}); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+func makeExecRequest(client rest.Interface, namespace, name, container string, command []string) *rest.Request {
+	req := client.Post().
+		Resource("pods").
+		Name(name).
+		Namespace(namespace).
+		SubResource("exec")
+
+	return req.VersionedParams(&corev1.PodExecOptions{
+		Container: container,
+		Command:   command,
+		Stdout:    true,
+		Stderr:    true,
+	}, scheme.ParameterCodec)
+}
@@ -3,12 +3,21 @@ package executor
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"syscall"
+	"time"
 
 	cmdutil "github.com/leg100/etok/cmd/util"
+	"golang.org/x/crypto/ssh/terminal"
 	"k8s.io/klog/v2"
 )
 
+// DefaultInterruptTimeout is the default time to wait for an interrupted
+// command to exit before it is killed
+const DefaultInterruptTimeout = 5 * time.Minute
+
 type Executor interface {
 	Execute(context.Context, []string, ...ExecOption) error
 }
@@ -17,26 +26,102 @@ type ExecOption func(*exec.Cmd)
 
 type Exec struct {
 	cmdutil.IOStreams
+
+	// InterruptTimeout is the time to wait for a command to exit after it is
+	// interrupted before it is killed. Defaults to DefaultInterruptTimeout.
+	InterruptTimeout time.Duration
 }
 
+// Execute runs a command. If the context is cancelled the command is sent an
+// interrupt, permitting it to exit gracefully, i.e. for terraform to release
+// its state lock. If the command has yet to exit after the interrupt timeout
+// then it is killed.
 func (tc *Exec) Execute(ctx context.Context, args []string, opts ...ExecOption) error {
 	klog.V(1).Infof("running command %v\n", args)
 
-	exe := exec.CommandContext(ctx, args[0], args[1:]...)
+	exe := exec.Command(args[0], args[1:]...)
 	exe.Stdin = tc.In
 	exe.Stdout = tc.Out
 	exe.Stderr = tc.ErrOut
 
+	// Unless attached to a terminal, run the command in its own process
+	// group, so that an interrupt is received by any processes it spawns too,
+	// e.g. the commands of a shell script. Whereas a command attached to a
+	// terminal must remain in the foreground process group in order to read
+	// from the terminal.
+	interactive := isTerminal(tc.In)
+	if !interactive {
+		exe.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	}
+
 	for _, o := range opts {
 		o(exe)
 	}
 
-	if err := exe.Run(); err != nil {
+	if err := exe.Start(); err != nil {
+		return fmt.Errorf("unable to run command %v: %w", args, err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- exe.Wait()
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		klog.V(1).Infof("interrupting command %v\n", args)
+		signal(exe.Process.Pid, syscall.SIGINT, !interactive)
+
+		timeout := tc.InterruptTimeout
+		if timeout == 0 {
+			timeout = DefaultInterruptTimeout
+		}
+
+		select {
+		case err = <-done:
+		case <-time.After(timeout):
+			klog.V(1).Infof("killing command %v\n", args)
+			signal(exe.Process.Pid, syscall.SIGKILL, !interactive)
+			err = <-done
+		}
+
+		if err == nil {
+			// Command exited gracefully yet it was interrupted and so
+			// likely did not do all that was asked of it
+			err = ctx.Err()
+		}
+	}
+
+	if err != nil {
 		return fmt.Errorf("unable to run command %v: %w", args, err)
 	}
 	return nil
 }
 
+// signal sends a signal to a process, or to its process group
+func signal(pid int, sig syscall.Signal, group bool) {
+	if group {
+		pid = -pid
+	}
+	if err := syscall.Kill(pid, sig); err != nil {
+		klog.V(1).Infof("unable to send %s to process %d: %s\n", sig, pid, err.Error())
+	}
+}
+
+func isTerminal(in io.Reader) bool {
+	f, ok := in.(*os.File)
+	return ok && terminal.IsTerminal(int(f.Fd()))
+}
+
+// WithStdout redirects the command's stdout to w
+func WithStdout(w io.Writer) ExecOption {
+	return func(cmd *exec.Cmd) {
+		cmd.Stdout = w
+	}
+}
+
 func withPath(path string) ExecOption {
 	return func(cmd *exec.Cmd) {
 		cmd.Dir = path
@@ -7,6 +7,7 @@ import (
 	osexec "os/exec"
 	"path/filepath"
 	"testing"
+	"time"
 
 	cmdutil "github.com/leg100/etok/cmd/util"
 	"github.com/leg100/etok/pkg/testutil"
@@ -49,4 +50,27 @@ func TestExecutor(t *testing.T) {
 			assert.Equal(t, 101, exiterr.ExitCode())
 		}
 	})
+	testutil.Run(t, "interrupt", func(t *testutil.T) {
+		out := new(bytes.Buffer)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		err := (&Exec{IOStreams: cmdutil.IOStreams{Out: out}}).Execute(ctx, []string{"sh", "-c", "trap 'echo -n interrupted; exit 0' INT; while true; do sleep 0.1; done"})
+
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		assert.Equal(t, "interrupted", out.String())
+	})
+
+	testutil.Run(t, "kill after interrupt timeout", func(t *testutil.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		err := (&Exec{InterruptTimeout: 100 * time.Millisecond}).Execute(ctx, []string{"sh", "-c", "trap '' INT; sleep 10"})
+
+		var exiterr *osexec.ExitError
+		if assert.True(t, errors.As(err, &exiterr)) {
+			assert.Equal(t, -1, exiterr.ExitCode())
+		}
+	})
 }
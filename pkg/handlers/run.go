@@ -70,3 +70,24 @@ func RunConnectable(name string, isTTY bool) watchtools.ConditionFunc {
 		return false, nil
 	}
 }
+
+// RunCancelled returns true when the run has been cancelled.
+func RunCancelled(name string) watchtools.ConditionFunc {
+	return func(event watch.Event) (bool, error) {
+		run := event.Object.(*v1alpha1.Run)
+
+		// ListWatcher field selector filters out other runs but the fake client
+		// doesn't implement the field selector, so the following is necessary
+		// purely for testing purposes
+		if run.Name != name {
+			return false, nil
+		}
+
+		switch event.Type {
+		case watch.Deleted:
+			return false, ErrResourceUnexpectedlyDeleted
+		}
+
+		return run.Cancel, nil
+	}
+}
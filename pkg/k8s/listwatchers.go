@@ -59,6 +59,11 @@ func (lw *RunListWatcher) Watch(options metav1.ListOptions) (watch.Interface, er
 	return lw.Client.EtokV1alpha1().Runs(lw.Namespace).Watch(context.TODO(), options)
 }
 
+// setNameSelector restricts the list or watch to the named object. An empty
+// name leaves the list or watch unrestricted.
 func setNameSelector(options *metav1.ListOptions, name string) {
+	if name == "" {
+		return
+	}
 	options.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
 }
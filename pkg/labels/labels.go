@@ -18,7 +18,17 @@ var (
 	OperatorComponent  = Component("operator")
 	WorkspaceComponent = Component("workspace")
 	RunComponent       = Component("run")
+	PlanComponent      = Component("plan")
 	WebhookComponent   = Component("webhook")
+	OutputsComponent   = Component("outputs")
+	// DriftCheck marks runs that check a workspace for drift
+	DriftCheck = Label{"drift-check", "true"}
+	// UpstreamTrigger marks runs triggered by a change to the outputs of
+	// upstream workspaces
+	UpstreamTrigger = Label{"upstream-trigger", "true"}
+	// ApplyScript marks runs whose script applies changes. Such runs run the
+	// sh command rather than the apply command.
+	ApplyScript = Label{"apply-script", "true"}
 )
 
 // A valid label must be an empty string or consist of alphanumeric characters ,
@@ -0,0 +1,351 @@
+package plan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Action is the change to be made to a resource
+type Action string
+
+const (
+	Create  Action = "create"
+	Update  Action = "update"
+	Replace Action = "replace"
+	Delete  Action = "delete"
+
+	// Unknown is a combination of actions not recognised, e.g. one introduced
+	// by a newer version of terraform. It is treated as a change.
+	Unknown Action = "unknown"
+)
+
+var (
+	ErrNotPlan = errors.New("not a terraform plan JSON representation")
+)
+
+// ResourceChange is a change to be made to a resource
+type ResourceChange struct {
+	// Address of the resource, e.g. module.network.aws_vpc.main
+	Address string `json:"address"`
+	Action  Action `json:"action"`
+
+	// Provider managing the resource, e.g. registry.terraform.io/hashicorp/aws
+	Provider string `json:"provider,omitempty"`
+	// Module containing the resource, e.g. module.network. Empty if the
+	// resource is in the root module.
+	Module string `json:"module,omitempty"`
+
+	// Sensitive is true if any of the changed attributes are sensitive
+	Sensitive bool `json:"sensitive,omitempty"`
+
+	// Changes to the resource's top-level attributes, ordered by name
+	Attributes []AttributeChange `json:"attributes,omitempty"`
+
+	// AttributesOmitted is true if the changes to the resource's attributes
+	// have been omitted to limit the size of the plan
+	AttributesOmitted bool `json:"attributesOmitted,omitempty"`
+}
+
+// AttributeChange is a change to a resource attribute. Values are rendered in
+// JSON, with sensitive values masked and unknown values marked as such.
+type AttributeChange struct {
+	Name string `json:"name"`
+
+	// Before is empty if the attribute is being added
+	Before string `json:"before,omitempty"`
+	// After is empty if the attribute is being removed
+	After string `json:"after,omitempty"`
+
+	Sensitive bool `json:"sensitive,omitempty"`
+}
+
+// Plan is the set of changes a terraform plan proposes to make to resources.
+// Changes that make no change to a resource, and reads of data sources, are
+// omitted.
+type Plan struct {
+	ResourceChanges []ResourceChange `json:"resourceChanges,omitempty"`
+
+	// Number of resource changes omitted to limit the size of the plan
+	Omitted int `json:"omitted,omitempty"`
+
+	// Number of resource changes omitted, keyed by action, so that counts of
+	// changes include them
+	OmittedActions map[Action]int `json:"omittedActions,omitempty"`
+}
+
+// tfPlan is the subset of the JSON representation of a plan, as output by
+// `terraform show -json <planfile>`, that is of interest.
+type tfPlan struct {
+	FormatVersion   string `json:"format_version"`
+	ResourceChanges []struct {
+		Address       string `json:"address"`
+		ModuleAddress string `json:"module_address"`
+		ProviderName  string `json:"provider_name"`
+		Change        struct {
+			Actions         []string               `json:"actions"`
+			Before          map[string]interface{} `json:"before"`
+			After           map[string]interface{} `json:"after"`
+			AfterUnknown    map[string]interface{} `json:"after_unknown"`
+			BeforeSensitive interface{}            `json:"before_sensitive"`
+			AfterSensitive  interface{}            `json:"after_sensitive"`
+		} `json:"change"`
+	} `json:"resource_changes"`
+}
+
+// Parse parses the JSON representation of a plan, as output by `terraform
+// show -json <planfile>`.
+func Parse(data []byte) (*Plan, error) {
+	var tfp tfPlan
+	if err := json.Unmarshal(data, &tfp); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotPlan, err.Error())
+	}
+	if tfp.FormatVersion == "" {
+		return nil, fmt.Errorf("%w: missing format version", ErrNotPlan)
+	}
+
+	p := &Plan{}
+	for _, rc := range tfp.ResourceChanges {
+		action := toAction(rc.Change.Actions)
+		if action == "" {
+			continue
+		}
+
+		change := ResourceChange{
+			Address:  rc.Address,
+			Action:   action,
+			Provider: rc.ProviderName,
+			Module:   rc.ModuleAddress,
+		}
+
+		for _, name := range attributeNames(rc.Change.Before, rc.Change.After, rc.Change.AfterUnknown) {
+			before, after := rc.Change.Before[name], rc.Change.After[name]
+			unknown := marked(rc.Change.AfterUnknown[name])
+
+			if !unknown && reflect.DeepEqual(before, after) {
+				continue
+			}
+
+			attr := AttributeChange{
+				Name:      name,
+				Sensitive: marked(lookup(rc.Change.BeforeSensitive, name)) || marked(lookup(rc.Change.AfterSensitive, name)),
+			}
+			attr.Before = renderValue(before, attr.Sensitive)
+			if unknown {
+				attr.After = "(known after apply)"
+			} else {
+				attr.After = renderValue(after, attr.Sensitive)
+			}
+
+			change.Sensitive = change.Sensitive || attr.Sensitive
+			change.Attributes = append(change.Attributes, attr)
+		}
+
+		p.ResourceChanges = append(p.ResourceChanges, change)
+	}
+	return p, nil
+}
+
+// attributeNames returns the sorted union of the names of the attributes found
+// in the given maps
+func attributeNames(maps ...map[string]interface{}) (names []string) {
+	seen := make(map[string]bool)
+	for _, attrs := range maps {
+		for name := range attrs {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return
+}
+
+// lookup returns the value of the named attribute in v, which is either a map
+// of attributes or, if the entire resource is marked, a bool
+func lookup(v interface{}, name string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return v[name]
+	default:
+		return v
+	}
+}
+
+// marked determines whether a value in terraform's sensitive or unknown
+// structures is marked, either wholly or partially. These structures mirror
+// the values they describe, with true marking a value.
+func marked(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case map[string]interface{}:
+		for _, vv := range v {
+			if marked(vv) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, vv := range v {
+			if marked(vv) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// renderValue renders an attribute value in JSON. Null values are rendered as
+// an empty string.
+func renderValue(v interface{}, sensitive bool) string {
+	if v == nil {
+		return ""
+	}
+	if sensitive {
+		return "(sensitive)"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
+
+// toAction converts terraform's list of actions for a resource into a single
+// action. An empty action is returned for no-ops and reads, and Unknown for
+// any combination of actions not recognised.
+func toAction(actions []string) Action {
+	switch len(actions) {
+	case 1:
+		switch actions[0] {
+		case "no-op", "read":
+			return ""
+		case "create":
+			return Create
+		case "update":
+			return Update
+		case "delete":
+			return Delete
+		}
+	case 2:
+		// Replacement is either delete-then-create, or create-then-delete
+		if (actions[0] == "delete" && actions[1] == "create") || (actions[0] == "create" && actions[1] == "delete") {
+			return Replace
+		}
+	}
+	return Unknown
+}
+
+// Addresses returns the addresses of resources to which the given action is to
+// be made
+func (p *Plan) Addresses(action Action) (addresses []string) {
+	for _, rc := range p.ResourceChanges {
+		if rc.Action == action {
+			addresses = append(addresses, rc.Address)
+		}
+	}
+	return
+}
+
+// count returns the number of resources to which the given action is to be
+// made, including those whose changes have been omitted
+func (p *Plan) count(action Action) int {
+	return len(p.Addresses(action)) + p.OmittedActions[action]
+}
+
+// Adds returns the number of resources to be created, including those to be
+// replaced
+func (p *Plan) Adds() int {
+	return p.count(Create) + p.count(Replace)
+}
+
+// Changes returns the number of resources to be updated in-place, including
+// those to which an unknown action is to be made
+func (p *Plan) Changes() int {
+	return p.count(Update) + p.count(Unknown)
+}
+
+// Deletions returns the number of resources to be destroyed, including those to
+// be replaced
+func (p *Plan) Deletions() int {
+	return p.count(Delete) + p.count(Replace)
+}
+
+// OmittedDeletions returns the number of resources that might be destroyed,
+// i.e. those to be deleted, replaced, or to which an unknown action is to be
+// made, whose changes have been omitted. Their addresses are unknown.
+func (p *Plan) OmittedDeletions() int {
+	return p.OmittedActions[Delete] + p.OmittedActions[Replace] + p.OmittedActions[Unknown]
+}
+
+func (p *Plan) HasNoChanges() bool {
+	return len(p.ResourceChanges) == 0 && p.Omitted == 0
+}
+
+// Truncate limits the size of the plan's JSON encoding to max bytes. The
+// changes to attributes are omitted first, starting with the resources with
+// the largest changes. Only if that is insufficient are resource changes
+// omitted, starting with the last. The actions of omitted resource changes are
+// counted, so that counts of changes, e.g. Adds(), still reflect every change,
+// but their addresses are lost.
+func (p *Plan) Truncate(max int) error {
+	size, err := encodedSize(p)
+	if err != nil {
+		return err
+	}
+	if size <= max {
+		return nil
+	}
+
+	// Bytes taken by each resource's attribute changes
+	sizes := make([]int, len(p.ResourceChanges))
+	order := make([]int, len(p.ResourceChanges))
+	for i, rc := range p.ResourceChanges {
+		if sizes[i], err = encodedSize(rc.Attributes); err != nil {
+			return err
+		}
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return sizes[order[i]] > sizes[order[j]] })
+
+	for _, i := range order {
+		if size <= max || len(p.ResourceChanges[i].Attributes) == 0 {
+			break
+		}
+		p.ResourceChanges[i].Attributes = nil
+		p.ResourceChanges[i].AttributesOmitted = true
+		if size, err = encodedSize(p); err != nil {
+			return err
+		}
+	}
+
+	for size > max && len(p.ResourceChanges) > 0 {
+		last := p.ResourceChanges[len(p.ResourceChanges)-1]
+		p.ResourceChanges = p.ResourceChanges[:len(p.ResourceChanges)-1]
+		if p.OmittedActions == nil {
+			p.OmittedActions = make(map[Action]int)
+		}
+		p.OmittedActions[last.Action]++
+		p.Omitted++
+		if size, err = encodedSize(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodedSize(v interface{}) (int, error) {
+	data, err := json.Marshal(v)
+	return len(data), err
+}
+
+// Summary summarizes the plan in the same format as terraform's own summary
+func (p *Plan) Summary() string {
+	if p.HasNoChanges() {
+		return "No changes."
+	}
+	return fmt.Sprintf("Plan: %d to add, %d to change, %d to destroy.", p.Adds(), p.Changes(), p.Deletions())
+}
@@ -0,0 +1,188 @@
+package plan
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		err        error
+		assertions func(*testutil.T, *Plan)
+	}{
+		{
+			name: "changes",
+			path: "testdata/plan.json",
+			assertions: func(t *testutil.T, p *Plan) {
+				assert.Equal(t, []ResourceChange{
+					{
+						Address:  "null_resource.example",
+						Action:   Create,
+						Provider: "registry.terraform.io/hashicorp/null",
+						Attributes: []AttributeChange{
+							{Name: "id", After: "(known after apply)"},
+						},
+					},
+					{
+						Address:  "module.random.random_id.test",
+						Action:   Replace,
+						Provider: "registry.terraform.io/hashicorp/random",
+						Module:   "module.random",
+						Attributes: []AttributeChange{
+							{Name: "byte_length", Before: "2", After: "4"},
+							{Name: "id", After: "(known after apply)"},
+						},
+					},
+					{
+						Address:   "local_file.config",
+						Action:    Update,
+						Provider:  "registry.terraform.io/hashicorp/local",
+						Sensitive: true,
+						Attributes: []AttributeChange{
+							{Name: "content", Before: `"foo"`, After: `"bar"`},
+							{Name: "sensitive_content", Before: "(sensitive)", After: "(sensitive)", Sensitive: true},
+						},
+					},
+					{
+						Address:  "local_file.old",
+						Action:   Delete,
+						Provider: "registry.terraform.io/hashicorp/local",
+						Attributes: []AttributeChange{
+							{Name: "content", Before: `"old"`},
+						},
+					},
+				}, p.ResourceChanges)
+				assert.Equal(t, []string{"module.random.random_id.test"}, p.Addresses(Replace))
+				assert.Equal(t, 2, p.Adds())
+				assert.Equal(t, 1, p.Changes())
+				assert.Equal(t, 2, p.Deletions())
+				assert.False(t, p.HasNoChanges())
+				assert.Equal(t, "Plan: 2 to add, 1 to change, 2 to destroy.", p.Summary())
+			},
+		},
+		{
+			name: "no changes",
+			path: "testdata/plan_no_changes.json",
+			assertions: func(t *testutil.T, p *Plan) {
+				assert.True(t, p.HasNoChanges())
+				assert.Equal(t, "No changes.", p.Summary())
+			},
+		},
+		{
+			name: "unknown action",
+			path: "testdata/plan_unknown_action.json",
+			assertions: func(t *testutil.T, p *Plan) {
+				assert.Equal(t, []string{"null_resource.example"}, p.Addresses(Unknown))
+				assert.Equal(t, 1, p.Changes())
+				assert.False(t, p.HasNoChanges())
+				assert.Equal(t, "Plan: 0 to add, 1 to change, 0 to destroy.", p.Summary())
+			},
+		},
+		{
+			name: "human readable plan output",
+			path: "testdata/plan.txt",
+			err:  ErrNotPlan,
+		},
+		{
+			name: "not a plan",
+			path: "testdata/not_plan.json",
+			err:  ErrNotPlan,
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			data, err := os.ReadFile(tt.path)
+			require.NoError(t, err)
+
+			p, err := Parse(data)
+			if !assert.True(t, errors.Is(err, tt.err)) {
+				t.Errorf("no error in %v's chain matches %v", err, tt.err)
+			}
+
+			if tt.assertions != nil {
+				tt.assertions(t, p)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	newPlan := func() *Plan {
+		return &Plan{
+			ResourceChanges: []ResourceChange{
+				{
+					Address:    "local_file.small",
+					Action:     Create,
+					Attributes: []AttributeChange{{Name: "content", After: `"foo"`}},
+				},
+				{
+					Address:    "local_file.large",
+					Action:     Create,
+					Attributes: []AttributeChange{{Name: "content", After: `"` + strings.Repeat("x", 1000) + `"`}},
+				},
+			},
+		}
+	}
+	size := func(p *Plan) int {
+		data, err := json.Marshal(p)
+		require.NoError(t, err)
+		return len(data)
+	}
+
+	tests := []struct {
+		name       string
+		max        func(*Plan) int
+		assertions func(*testutil.T, *Plan)
+	}{
+		{
+			name: "within limit",
+			max:  size,
+			assertions: func(t *testutil.T, p *Plan) {
+				assert.Equal(t, newPlan(), p)
+			},
+		},
+		{
+			name: "omit largest attributes",
+			max:  func(p *Plan) int { return size(p) - 500 },
+			assertions: func(t *testutil.T, p *Plan) {
+				assert.Equal(t, 1, len(p.ResourceChanges[0].Attributes))
+				assert.False(t, p.ResourceChanges[0].AttributesOmitted)
+				assert.Nil(t, p.ResourceChanges[1].Attributes)
+				assert.True(t, p.ResourceChanges[1].AttributesOmitted)
+				assert.Equal(t, 0, p.Omitted)
+			},
+		},
+		{
+			name: "omit resources",
+			max:  func(p *Plan) int { return 140 },
+			assertions: func(t *testutil.T, p *Plan) {
+				if assert.Equal(t, 1, len(p.ResourceChanges)) {
+					assert.Equal(t, "local_file.small", p.ResourceChanges[0].Address)
+					assert.True(t, p.ResourceChanges[0].AttributesOmitted)
+				}
+				assert.Equal(t, 1, p.Omitted)
+				assert.Equal(t, map[Action]int{Create: 1}, p.OmittedActions)
+				// Omitted changes are still counted
+				assert.Equal(t, "Plan: 2 to add, 0 to change, 0 to destroy.", p.Summary())
+			},
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			p := newPlan()
+			max := tt.max(p)
+			require.NoError(t, p.Truncate(max))
+			assert.LessOrEqual(t, size(p), max)
+			tt.assertions(t, p)
+		})
+	}
+}
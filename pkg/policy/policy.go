@@ -0,0 +1,129 @@
+package policy
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/leg100/etok/pkg/plan"
+	"sigs.k8s.io/yaml"
+)
+
+// RuleSet is a set of rules against which a plan is evaluated. Rule sets are
+// written in YAML, e.g.:
+//
+//	rules:
+//	- name: protect-databases
+//	  denyDelete: aws_db_instance.*
+//	- name: limit-changes
+//	  maxChanges: 20
+type RuleSet struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Rule restricts the changes a plan may make. A rule with several
+// restrictions is violated if any one of them is violated.
+type Rule struct {
+	// Name identifies the rule in violations
+	Name string `json:"name"`
+
+	// DenyDelete forbids deleting, or replacing, resources whose address
+	// matches the glob pattern
+	DenyDelete string `json:"denyDelete,omitempty"`
+
+	// MaxChanges caps the number of resources a plan may change
+	MaxChanges *int `json:"maxChanges,omitempty"`
+}
+
+// Violation is the violation of a rule by a plan
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Rule, v.Message)
+}
+
+// Parse parses a rule set, validating its rules
+func Parse(data []byte) (*RuleSet, error) {
+	var rs RuleSet
+	if err := yaml.UnmarshalStrict(data, &rs); err != nil {
+		return nil, err
+	}
+
+	for i, rule := range rs.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule #%d has no name", i)
+		}
+		if rule.DenyDelete == "" && rule.MaxChanges == nil {
+			return nil, fmt.Errorf("rule %s has no restrictions", rule.Name)
+		}
+		if rule.DenyDelete != "" {
+			// Check pattern is well-formed
+			if _, err := path.Match(rule.DenyDelete, ""); err != nil {
+				return nil, fmt.Errorf("rule %s has invalid pattern: %w", rule.Name, err)
+			}
+		}
+		if rule.MaxChanges != nil && *rule.MaxChanges < 0 {
+			return nil, fmt.Errorf("rule %s has negative max changes", rule.Name)
+		}
+	}
+
+	return &rs, nil
+}
+
+// Evaluate evaluates the plan against the rule set, returning any violations
+func (rs *RuleSet) Evaluate(p *plan.Plan) (violations []Violation) {
+	for _, rule := range rs.Rules {
+		if rule.DenyDelete != "" {
+			var matches, unknown []string
+			for _, rc := range p.ResourceChanges {
+				if rc.Action != plan.Delete && rc.Action != plan.Replace && rc.Action != plan.Unknown {
+					continue
+				}
+				// Pattern has already been validated
+				if match, _ := path.Match(rule.DenyDelete, rc.Address); !match {
+					continue
+				}
+				if rc.Action == plan.Unknown {
+					unknown = append(unknown, rc.Address)
+				} else {
+					matches = append(matches, rc.Address)
+				}
+			}
+			if len(matches) > 0 {
+				sort.Strings(matches)
+				violations = append(violations, Violation{
+					Rule:    rule.Name,
+					Message: fmt.Sprintf("deletion of %s is forbidden", strings.Join(matches, ", ")),
+				})
+			}
+			// Fail closed: an unknown action might delete the resource
+			if len(unknown) > 0 {
+				sort.Strings(unknown)
+				violations = append(violations, Violation{
+					Rule:    rule.Name,
+					Message: fmt.Sprintf("unknown changes to %s cannot be checked", strings.Join(unknown, ", ")),
+				})
+			}
+			// Fail closed: deletions omitted from the plan cannot be checked
+			// against the pattern
+			if n := p.OmittedDeletions(); n > 0 {
+				violations = append(violations, Violation{
+					Rule:    rule.Name,
+					Message: fmt.Sprintf("deletion of %d resources omitted from the recorded plan cannot be checked", n),
+				})
+			}
+		}
+
+		if changed := len(p.ResourceChanges) + p.Omitted; rule.MaxChanges != nil && changed > *rule.MaxChanges {
+			violations = append(violations, Violation{
+				Rule:    rule.Name,
+				Message: fmt.Sprintf("%d resources changed, exceeding the maximum of %d", changed, *rule.MaxChanges),
+			})
+		}
+	}
+	return
+}
@@ -0,0 +1,183 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/leg100/etok/pkg/plan"
+	"github.com/leg100/etok/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		ruleSet string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			ruleSet: `
+rules:
+- name: protect-databases
+  denyDelete: aws_db_instance.*
+- name: limit-changes
+  maxChanges: 20
+`,
+		},
+		{
+			name: "unknown field",
+			ruleSet: `
+rules:
+- name: protect-databases
+  forbid: aws_db_instance.*
+`,
+			wantErr: true,
+		},
+		{
+			name: "no name",
+			ruleSet: `
+rules:
+- maxChanges: 20
+`,
+			wantErr: true,
+		},
+		{
+			name: "no restrictions",
+			ruleSet: `
+rules:
+- name: nothing
+`,
+			wantErr: true,
+		},
+		{
+			name: "invalid pattern",
+			ruleSet: `
+rules:
+- name: protect-databases
+  denyDelete: aws_db_instance[
+`,
+			wantErr: true,
+		},
+		{
+			name: "negative max changes",
+			ruleSet: `
+rules:
+- name: limit-changes
+  maxChanges: -1
+`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			_, err := Parse([]byte(tt.ruleSet))
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	rs, err := Parse([]byte(`
+rules:
+- name: protect-databases
+  denyDelete: module.*.aws_db_instance.*
+- name: limit-changes
+  maxChanges: 2
+`))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		changes    []plan.ResourceChange
+		omitted    map[plan.Action]int
+		violations []Violation
+	}{
+		{
+			name: "no violations",
+			changes: []plan.ResourceChange{
+				{Address: "module.db.aws_db_instance.main", Action: plan.Update},
+				{Address: "module.db.aws_security_group.main", Action: plan.Delete},
+			},
+		},
+		{
+			name: "delete protected resource",
+			changes: []plan.ResourceChange{
+				{Address: "module.db.aws_db_instance.main", Action: plan.Delete},
+			},
+			violations: []Violation{
+				{Rule: "protect-databases", Message: "deletion of module.db.aws_db_instance.main is forbidden"},
+			},
+		},
+		{
+			name: "replace protected resource",
+			changes: []plan.ResourceChange{
+				{Address: "module.db.aws_db_instance.main", Action: plan.Replace},
+			},
+			violations: []Violation{
+				{Rule: "protect-databases", Message: "deletion of module.db.aws_db_instance.main is forbidden"},
+			},
+		},
+		{
+			name: "unknown change to protected resource",
+			changes: []plan.ResourceChange{
+				{Address: "module.db.aws_db_instance.main", Action: plan.Unknown},
+				{Address: "module.db.aws_security_group.main", Action: plan.Unknown},
+			},
+			violations: []Violation{
+				{Rule: "protect-databases", Message: "unknown changes to module.db.aws_db_instance.main cannot be checked"},
+			},
+		},
+		{
+			name: "unknown changes count towards max changes",
+			changes: []plan.ResourceChange{
+				{Address: "random_id.a", Action: plan.Create},
+				{Address: "random_id.b", Action: plan.Unknown},
+				{Address: "random_id.c", Action: plan.Unknown},
+			},
+			violations: []Violation{
+				{Rule: "limit-changes", Message: "3 resources changed, exceeding the maximum of 2"},
+			},
+		},
+		{
+			name: "too many changes",
+			changes: []plan.ResourceChange{
+				{Address: "random_id.a", Action: plan.Create},
+				{Address: "random_id.b", Action: plan.Create},
+				{Address: "random_id.c", Action: plan.Create},
+			},
+			violations: []Violation{
+				{Rule: "limit-changes", Message: "3 resources changed, exceeding the maximum of 2"},
+			},
+		},
+		{
+			name: "omitted deletion",
+			changes: []plan.ResourceChange{
+				{Address: "module.db.aws_db_instance.main", Action: plan.Update},
+			},
+			omitted: map[plan.Action]int{plan.Replace: 1},
+			violations: []Violation{
+				{Rule: "protect-databases", Message: "deletion of 1 resources omitted from the recorded plan cannot be checked"},
+			},
+		},
+		{
+			name: "too many changes including omitted changes",
+			changes: []plan.ResourceChange{
+				{Address: "random_id.a", Action: plan.Create},
+			},
+			omitted: map[plan.Action]int{plan.Create: 2},
+			violations: []Violation{
+				{Rule: "limit-changes", Message: "3 resources changed, exceeding the maximum of 2"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		testutil.Run(t, tt.name, func(t *testutil.T) {
+			p := &plan.Plan{ResourceChanges: tt.changes, OmittedActions: tt.omitted}
+			for _, n := range tt.omitted {
+				p.Omitted += n
+			}
+			assert.Equal(t, tt.violations, rs.Evaluate(p))
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"regexp"
+	"strings"
 
 	"github.com/go-git/go-git/v5"
 )
@@ -113,3 +114,26 @@ var githubNonHttpUrl = regexp.MustCompile(`(?:ssh://)?git@github.com[:/]([\w/\-]
 func normalizeUrl(url string) string {
 	return githubNonHttpUrl.ReplaceAllString(url, "https://github.com/$1")
 }
+
+var nonHttpUrl = regexp.MustCompile(`^(?:ssh://)?git@([^:/]+)[:/](.+)$`)
+
+// CanonicalUrl returns a repository URL in a form suitable for comparison with
+// other forms of the same URL: ssh URLs are converted to https URLs, the host is
+// lowercased, and a trailing slash or .git suffix is removed.
+func CanonicalUrl(url string) string {
+	if m := nonHttpUrl.FindStringSubmatch(url); m != nil {
+		url = "https://" + m[1] + "/" + m[2]
+	}
+
+	url = strings.TrimSuffix(url, "/")
+	url = strings.TrimSuffix(url, ".git")
+
+	if i := strings.Index(url, "://"); i != -1 {
+		scheme, rest := url[:i+3], url[i+3:]
+		if j := strings.Index(rest, "/"); j != -1 {
+			url = scheme + strings.ToLower(rest[:j]) + rest[j:]
+		}
+	}
+
+	return url
+}
@@ -64,3 +64,22 @@ func TestNormalizeUrl(t *testing.T) {
 		assert.Equal(t, "https://github.com/leg100/etok.git", normalizeUrl(tt))
 	}
 }
+
+func TestCanonicalUrl(t *testing.T) {
+	tests := []string{
+		"https://github.com/leg100/etok.git",
+		"https://github.com/leg100/etok",
+		"https://github.com/leg100/etok/",
+		"https://GitHub.com/leg100/etok",
+		"git@github.com:leg100/etok.git",
+		"ssh://git@github.com/leg100/etok.git",
+		"git@github.com:leg100/etok",
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, "https://github.com/leg100/etok", CanonicalUrl(tt))
+	}
+
+	assert.Equal(t, "https://github.example.com/leg100/etok", CanonicalUrl("git@github.example.com:leg100/etok.git"))
+	assert.Equal(t, "file:///tmp/bob/myrepo", CanonicalUrl("file:///tmp/bob/myrepo.git"))
+}
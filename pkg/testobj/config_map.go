@@ -1,6 +1,10 @@
 package testobj
 
 import (
+	"strconv"
+
+	"github.com/leg100/etok/api/etok.dev/v1alpha1"
+	"github.com/leg100/etok/pkg/labels"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -18,3 +22,38 @@ func ConfigMap(namespace, name string, opts ...func(*corev1.ConfigMap)) *corev1.
 
 	return configMap
 }
+
+// WithSavedPlan populates the config map with the details of a plan saved for
+// the given workspace
+func WithSavedPlan(workspace string, serial int, summary string) func(*corev1.ConfigMap) {
+	return func(configMap *corev1.ConfigMap) {
+		labels.SetLabel(configMap, labels.Workspace(workspace))
+		labels.SetLabel(configMap, labels.PlanComponent)
+
+		configMap.Data = map[string]string{
+			v1alpha1.PlanSerialKey:  strconv.Itoa(serial),
+			v1alpha1.PlanSummaryKey: summary,
+		}
+	}
+}
+
+// WithPlanChanges populates the config map with the JSON encoded changes of a
+// saved plan
+func WithPlanChanges(changes string) func(*corev1.ConfigMap) {
+	return func(configMap *corev1.ConfigMap) {
+		if configMap.Data == nil {
+			configMap.Data = make(map[string]string)
+		}
+		configMap.Data[v1alpha1.PlanChangesKey] = changes
+	}
+}
+
+// WithRuleSet adds a policy rule set to the config map
+func WithRuleSet(key, ruleSet string) func(*corev1.ConfigMap) {
+	return func(configMap *corev1.ConfigMap) {
+		if configMap.Data == nil {
+			configMap.Data = make(map[string]string)
+		}
+		configMap.Data[key] = ruleSet
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"compress/gzip"
 	"io"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/leg100/etok/api/etok.dev/v1alpha1"
@@ -60,12 +61,48 @@ func WithBranch(branch string) func(*v1alpha1.Workspace) {
 	}
 }
 
+func WithApplyRequirements(reqs v1alpha1.ApplyRequirements) func(*v1alpha1.Workspace) {
+	return func(ws *v1alpha1.Workspace) {
+		ws.Spec.VCS.ApplyRequirements = &reqs
+	}
+}
+
 func WithPrivilegedCommands(cmds ...string) func(*v1alpha1.Workspace) {
 	return func(ws *v1alpha1.Workspace) {
 		ws.Spec.PrivilegedCommands = cmds
 	}
 }
 
+func WithPolicyRuleSets(names ...string) func(*v1alpha1.Workspace) {
+	return func(ws *v1alpha1.Workspace) {
+		ws.Spec.PolicyRuleSets = names
+	}
+}
+
+func WithCriticalResources(patterns ...string) func(*v1alpha1.Workspace) {
+	return func(ws *v1alpha1.Workspace) {
+		ws.Spec.CriticalResources = patterns
+	}
+}
+
+func WithDriftDetection(schedule string) func(*v1alpha1.Workspace) {
+	return func(ws *v1alpha1.Workspace) {
+		ws.Spec.DriftDetection = schedule
+	}
+}
+
+func WithLastDriftCheck(t time.Time) func(*v1alpha1.Workspace) {
+	return func(ws *v1alpha1.Workspace) {
+		ws.Status.LastDriftCheck = &metav1.Time{Time: t}
+	}
+}
+
+func WithDriftRun(run string) func(*v1alpha1.Workspace) {
+	return func(ws *v1alpha1.Workspace) {
+		ws.Status.DriftRun = run
+	}
+}
+
 func WithVariables(keyValues ...string) func(*v1alpha1.Workspace) {
 	return func(ws *v1alpha1.Workspace) {
 		for i := 0; i < len(keyValues); i += 2 {
@@ -86,6 +123,18 @@ func WithEphemeral() func(*v1alpha1.Workspace) {
 	}
 }
 
+func WithBackupSerial(serial int) func(*v1alpha1.Workspace) {
+	return func(ws *v1alpha1.Workspace) {
+		ws.Status.BackupSerial = &serial
+	}
+}
+
+func WithSerial(serial int) func(*v1alpha1.Workspace) {
+	return func(ws *v1alpha1.Workspace) {
+		ws.Status.Serial = &serial
+	}
+}
+
 func WithEnvironmentVariables(keyValues ...string) func(*v1alpha1.Workspace) {
 	return func(ws *v1alpha1.Workspace) {
 		for i := 0; i < len(keyValues); i += 2 {
@@ -126,6 +175,22 @@ func WithApprovals(run ...string) func(*v1alpha1.Workspace) {
 	}
 }
 
+func WithPodTemplate(tmpl *v1alpha1.PodTemplate) func(*v1alpha1.Workspace) {
+	return func(ws *v1alpha1.Workspace) {
+		ws.Spec.PodTemplate = tmpl
+	}
+}
+
+// WithPriorityGrant grants a run a priority on the workspace
+func WithPriorityGrant(run string, priority int) func(*v1alpha1.Workspace) {
+	return func(ws *v1alpha1.Workspace) {
+		if ws.Annotations == nil {
+			ws.Annotations = make(map[string]string)
+		}
+		ws.Annotations[v1alpha1.PriorityAnnotationKey(run)] = strconv.Itoa(priority)
+	}
+}
+
 func WithAnnotations(keyValues ...string) func(*v1alpha1.Workspace) {
 	return func(ws *v1alpha1.Workspace) {
 		if ws.Annotations == nil {
@@ -137,6 +202,50 @@ func WithAnnotations(keyValues ...string) func(*v1alpha1.Workspace) {
 	}
 }
 
+// WithWorkspaceOutputVariable sets a variable to an output of another workspace
+func WithWorkspaceOutputVariable(key, workspace, output string) func(*v1alpha1.Workspace) {
+	return func(ws *v1alpha1.Workspace) {
+		ws.Spec.Variables = append(ws.Spec.Variables, &v1alpha1.Variable{
+			Key: key,
+			ValueFrom: &v1alpha1.VariableSource{
+				WorkspaceOutputRef: &v1alpha1.WorkspaceOutputSelector{Workspace: workspace, Output: output},
+			},
+		})
+	}
+}
+
+func WithOutputs(keyValues ...string) func(*v1alpha1.Workspace) {
+	return func(ws *v1alpha1.Workspace) {
+		for i := 0; i < len(keyValues); i += 2 {
+			ws.Status.Outputs = append(ws.Status.Outputs, &v1alpha1.Output{Key: keyValues[i], Value: keyValues[i+1]})
+		}
+	}
+}
+
+func WithOutputProjection(p v1alpha1.OutputProjection) func(*v1alpha1.Workspace) {
+	return func(ws *v1alpha1.Workspace) {
+		ws.Spec.OutputProjections = append(ws.Spec.OutputProjections, p)
+	}
+}
+
+func WithOnUpstreamChange(cmd string) func(*v1alpha1.Workspace) {
+	return func(ws *v1alpha1.Workspace) {
+		ws.Spec.OnUpstreamChange = cmd
+	}
+}
+
+func WithUpstreamOutputsDigest(digest string) func(*v1alpha1.Workspace) {
+	return func(ws *v1alpha1.Workspace) {
+		ws.Status.UpstreamOutputsDigest = digest
+	}
+}
+
+func WithRunRetention(policy v1alpha1.RunRetentionPolicy) func(*v1alpha1.Workspace) {
+	return func(ws *v1alpha1.Workspace) {
+		ws.Spec.RunRetention = policy
+	}
+}
+
 func RunPod(namespace, name string, opts ...func(*corev1.Pod)) *corev1.Pod {
 	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
@@ -223,6 +332,18 @@ func WithRunnerExitCode(code int32) func(*corev1.Pod) {
 	}
 }
 
+func WithRunnerFinishedAt(t time.Time) func(*corev1.Pod) {
+	return func(pod *corev1.Pod) {
+		k8s.ContainerStatusByName(pod, globals.RunnerContainerName).State.Terminated.FinishedAt = metav1.NewTime(t)
+	}
+}
+
+func WithRunnerTerminationMessage(msg string) func(*corev1.Pod) {
+	return func(pod *corev1.Pod) {
+		k8s.ContainerStatusByName(pod, globals.RunnerContainerName).State.Terminated.Message = msg
+	}
+}
+
 func WithInstallerExitCode(code int32) func(*corev1.Pod) {
 	return func(pod *corev1.Pod) {
 		k8s.ContainerStatusByName(pod, "installer").State.Terminated.ExitCode = code
@@ -315,6 +436,65 @@ func WithNotCompleteConditionForTimeout(reason string, ago time.Duration) func(*
 	}
 }
 
+// Produces a complete condition that is true, with the given reason, and a
+// last transition time set to now minus time. Intended for use with faking the
+// age of completed runs.
+func WithCompleteConditionForAge(reason string, ago time.Duration) func(*v1alpha1.Run) {
+	return func(run *v1alpha1.Run) {
+		meta.SetStatusCondition(&run.Conditions, metav1.Condition{
+			Type:               v1alpha1.RunCompleteCondition,
+			Status:             metav1.ConditionTrue,
+			Reason:             reason,
+			LastTransitionTime: metav1.NewTime(time.Now().Add(-ago)),
+		})
+	}
+}
+
+func WithSavePlan() func(*v1alpha1.Run) {
+	return func(run *v1alpha1.Run) {
+		run.SavePlan = true
+	}
+}
+
+func WithPlan(plan string) func(*v1alpha1.Run) {
+	return func(run *v1alpha1.Run) {
+		run.Plan = plan
+	}
+}
+
+func WithBlastRadius(br *v1alpha1.BlastRadius) func(*v1alpha1.Run) {
+	return func(run *v1alpha1.Run) {
+		run.BlastRadius = br
+	}
+}
+
+func WithCancel() func(*v1alpha1.Run) {
+	return func(run *v1alpha1.Run) {
+		run.Cancel = true
+	}
+}
+
+func WithPriority(priority int) func(*v1alpha1.Run) {
+	return func(run *v1alpha1.Run) {
+		run.Priority = priority
+	}
+}
+
+func WithLaunchedBy(launcher string) func(*v1alpha1.Run) {
+	return func(run *v1alpha1.Run) {
+		if run.Annotations == nil {
+			run.Annotations = make(map[string]string)
+		}
+		run.Annotations[v1alpha1.LaunchedByAnnotationKey] = launcher
+	}
+}
+
+func WithMaxDuration(d time.Duration) func(*v1alpha1.Run) {
+	return func(run *v1alpha1.Run) {
+		run.MaxDuration = &metav1.Duration{Duration: d}
+	}
+}
+
 func WithArgs(args ...string) func(*v1alpha1.Run) {
 	return func(run *v1alpha1.Run) {
 		run.Args = args
@@ -0,0 +1,160 @@
+package vcs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// CommentPrefix is the word with which a line of a comment must begin for the
+// line to be treated as an etok command
+const CommentPrefix = "etok"
+
+// Commands that can be requested in a comment
+const (
+	PlanCommand  = "plan"
+	ApplyCommand = "apply"
+)
+
+var (
+	ErrUnknownCommand    = errors.New("unknown command")
+	ErrUnexpectedArgs    = errors.New("unexpected arguments: terraform arguments must follow --")
+	ErrUnterminatedQuote = errors.New("unterminated quote")
+	ErrApplyArgs         = errors.New("arguments cannot be passed to apply: it applies the saved plan as is")
+)
+
+// Command is a command requested in a comment on a pull or merge request, e.g.
+// `etok plan -w networking -- -target=module.vpc`
+type Command struct {
+	// Name of the command, either plan or apply
+	Name string
+
+	// Namespace and name of the workspace to which the command is targeted.
+	// The namespace is optional. If neither is specified then the command is
+	// targeted at all workspaces.
+	Namespace, Workspace string
+
+	// Additional arguments to pass to terraform. Only a plan accepts
+	// arguments: an apply applies a saved plan, which terraform applies as is.
+	Args []string
+}
+
+// ParseComment parses the first line of a comment that begins with "etok". Nil
+// is returned if there is no such line.
+func ParseComment(body string) (*Command, error) {
+	for _, line := range strings.Split(body, "\n") {
+		if fields := strings.Fields(line); len(fields) == 0 || fields[0] != CommentPrefix {
+			continue
+		}
+		words, err := splitWords(strings.TrimSpace(line))
+		if err != nil {
+			return nil, err
+		}
+		return parseCommand(words[1:])
+	}
+	return nil, nil
+}
+
+func parseCommand(words []string) (*Command, error) {
+	if len(words) == 0 {
+		return nil, fmt.Errorf("%w: no command specified", ErrUnknownCommand)
+	}
+
+	cmd := &Command{Name: words[0]}
+	if cmd.Name != PlanCommand && cmd.Name != ApplyCommand {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCommand, words[0])
+	}
+
+	var workspace string
+	fs := pflag.NewFlagSet(cmd.Name, pflag.ContinueOnError)
+	fs.SetOutput(&strings.Builder{})
+	fs.StringVarP(&workspace, "workspace", "w", "", "Workspace to which the command is targeted")
+	if err := fs.Parse(words[1:]); err != nil {
+		return nil, err
+	}
+
+	// Arguments are only permitted following --
+	dash := fs.ArgsLenAtDash()
+	if dash == -1 && fs.NArg() > 0 || dash > 0 {
+		return nil, ErrUnexpectedArgs
+	}
+	if dash == 0 {
+		cmd.Args = fs.Args()
+	}
+	if cmd.Name == ApplyCommand && len(cmd.Args) > 0 {
+		return nil, ErrApplyArgs
+	}
+
+	if parts := strings.SplitN(workspace, "/", 2); len(parts) == 2 {
+		cmd.Namespace, cmd.Workspace = parts[0], parts[1]
+	} else {
+		cmd.Workspace = workspace
+	}
+
+	return cmd, nil
+}
+
+// Targets determines whether the command is targeted at a workspace
+func (c *Command) Targets(namespace, workspace string) bool {
+	if c.Namespace != "" && c.Namespace != namespace {
+		return false
+	}
+	return c.Workspace == "" || c.Workspace == workspace
+}
+
+// String returns the command as it would be written in a comment
+func (c *Command) String() string {
+	s := CommentPrefix + " " + c.Name
+	if c.Workspace != "" {
+		s += " -w "
+		if c.Namespace != "" {
+			s += c.Namespace + "/"
+		}
+		s += c.Workspace
+	}
+	if len(c.Args) > 0 {
+		s += " -- " + strings.Join(c.Args, " ")
+	}
+	return s
+}
+
+// splitWords splits a line into words separated by whitespace. Single and
+// double quotes group words together, as they would in a shell.
+func splitWords(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	var inWord bool
+	var quote rune
+
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, ErrUnterminatedQuote
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,105 @@
+package vcs
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseComment(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want *Command
+		err  error
+	}{
+		{
+			name: "not a command",
+			body: "looks good to me",
+		},
+		{
+			name: "plan",
+			body: "etok plan",
+			want: &Command{Name: PlanCommand},
+		},
+		{
+			name: "plan workspace",
+			body: "etok plan -w networking",
+			want: &Command{Name: PlanCommand, Workspace: "networking"},
+		},
+		{
+			name: "plan namespaced workspace",
+			body: "etok plan --workspace dev/networking",
+			want: &Command{Name: PlanCommand, Namespace: "dev", Workspace: "networking"},
+		},
+		{
+			name: "plan with args",
+			body: "etok plan -w networking -- -target=module.vpc -var='tags=a b'",
+			want: &Command{Name: PlanCommand, Workspace: "networking", Args: []string{"-target=module.vpc", "-var=tags=a b"}},
+		},
+		{
+			name: "apply on later line",
+			body: "Plan looks good, don't you think?\r\n  etok apply -w networking\r\n",
+			want: &Command{Name: ApplyCommand, Workspace: "networking"},
+		},
+		{
+			name: "apply with args",
+			body: "etok apply -w networking -- -target=module.vpc",
+			err:  ErrApplyArgs,
+		},
+		{
+			name: "unknown command",
+			body: "etok destroy",
+			err:  ErrUnknownCommand,
+		},
+		{
+			name: "no command",
+			body: "etok",
+			err:  ErrUnknownCommand,
+		},
+		{
+			name: "args without dash",
+			body: "etok plan -w networking -target=module.vpc",
+			err:  errors.New("unknown shorthand flag: 't' in -target=module.vpc"),
+		},
+		{
+			name: "positional args",
+			body: "etok plan networking",
+			err:  ErrUnexpectedArgs,
+		},
+		{
+			name: "unterminated quote",
+			body: "etok plan -- -var='tags=a b",
+			err:  ErrUnterminatedQuote,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseComment(tt.body)
+			if tt.err != nil {
+				require.Error(t, err)
+				if !errors.Is(err, tt.err) {
+					assert.Equal(t, tt.err.Error(), err.Error())
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCommandString(t *testing.T) {
+	cmd := &Command{Name: PlanCommand, Namespace: "dev", Workspace: "networking", Args: []string{"-target=module.vpc"}}
+	assert.Equal(t, "etok plan -w dev/networking -- -target=module.vpc", cmd.String())
+}
+
+func TestCommandTargets(t *testing.T) {
+	assert.True(t, (&Command{Name: PlanCommand}).Targets("dev", "networking"))
+	assert.True(t, (&Command{Name: PlanCommand, Workspace: "networking"}).Targets("dev", "networking"))
+	assert.True(t, (&Command{Name: PlanCommand, Namespace: "dev", Workspace: "networking"}).Targets("dev", "networking"))
+	assert.False(t, (&Command{Name: PlanCommand, Namespace: "prod", Workspace: "networking"}).Targets("dev", "networking"))
+	assert.False(t, (&Command{Name: PlanCommand, Workspace: "db"}).Targets("dev", "networking"))
+}
@@ -0,0 +1,9 @@
+package vcs
+
+import "strings"
+
+// ShellQuote wraps a string in single quotes, escaping any single quotes
+// within, to prevent its interpretation by the shell
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,12 @@
+package vcs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, `'-target=module.vpc'`, ShellQuote("-target=module.vpc"))
+	assert.Equal(t, `'-var=owner='\''bob'\'''`, ShellQuote("-var=owner='bob'"))
+}